@@ -449,6 +449,70 @@ func TestSysVClassifyStruct(t *testing.T) {
 	expect.Nil(t, request)
 }
 
+func TestSysVClassifyCStruct(t *testing.T) {
+	sysV := sysVLite{}
+
+	// struct { float x, y, z; } (12 bytes)
+	structType := ir.NewCStructType([]ir.Field{
+		{Name: "x", Type: ir.Float32},
+		{Name: "y", Type: ir.Float32},
+		{Name: "z", Type: ir.Float32},
+	})
+	request, onStack := sysV.classify(structType)
+	expect.False(t, onStack)
+	expect.Equal(t, []bool{true, true}, request)
+
+	// struct { float x, y; int8_t z; } (12 bytes)
+	structType = ir.NewCStructType([]ir.Field{
+		{Name: "x", Type: ir.Float32},
+		{Name: "y", Type: ir.Float32},
+		{Name: "z", Type: ir.Int8},
+	})
+	request, onStack = sysV.classify(structType)
+	expect.False(t, onStack)
+	expect.Equal(t, []bool{true, false}, request)
+
+	// struct { int8_t a; struct { float b; } c; } (8 bytes)
+	structType = ir.NewCStructType([]ir.Field{
+		{Name: "a", Type: ir.Int8},
+		{
+			Name: "c",
+			Type: ir.NewCStructType([]ir.Field{{Name: "b", Type: ir.Float32}}),
+		},
+	})
+	request, onStack = sysV.classify(structType)
+	expect.False(t, onStack)
+	expect.Equal(t, []bool{false}, request)
+
+	// struct { int8_t a; struct { int8_t b[9]; } c; } (10 bytes)
+	structType = ir.NewCStructType([]ir.Field{
+		{Name: "a", Type: ir.Int8},
+		{
+			Name: "c",
+			Type: ir.NewCStructType([]ir.Field{
+				{Name: "b", Type: ir.NewCArrayType(ir.Int8, 9)},
+			}),
+		},
+	})
+	request, onStack = sysV.classify(structType)
+	expect.False(t, onStack)
+	expect.Equal(t, []bool{false, false}, request)
+
+	// float [3] (12 bytes)
+	request, onStack = sysV.classify(ir.NewCArrayType(ir.Float32, 3))
+	expect.False(t, onStack)
+	expect.Equal(t, []bool{true, true}, request)
+
+	// struct { double a; int8_t b[9]; } (24 bytes)
+	structType = ir.NewCStructType([]ir.Field{
+		{Name: "a", Type: ir.Float64},
+		{Name: "b", Type: ir.NewCArrayType(ir.Int8, 9)},
+	})
+	request, onStack = sysV.classify(structType)
+	expect.True(t, onStack)
+	expect.Nil(t, request)
+}
+
 func TestSysVBasicRegistersCall(t *testing.T) {
 	structType := ir.NewStructType([]ir.Field{
 		{
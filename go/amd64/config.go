@@ -23,6 +23,7 @@ var (
 		ExecutableFormat: executable.Config{
 			VirtualAddressStart:    0x400000,
 			ElfMachineArchitecture: executable.EM_X86_64,
+			DynamicLinker:          "/lib64/ld-linux-x86-64.so.2",
 		},
 	}
 )
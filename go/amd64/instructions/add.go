@@ -45,6 +45,37 @@ func add(
 	newRM(isFloat, operandSize, opCode, dest, src).encode(builder)
 }
 
+// <int/uint dest> += <int/uint src>
+//
+// Unlike add, this always uses the exact operand size such that the carry /
+// overflow status flags reflect the operand type.
+//
+// https://www.felixcloutier.com/x86/add
+//
+// 8-bit (RM Op/En):        02 /r
+// 16/32/64-bit (RM Op/En): 03 /r
+func addWithStatusFlags(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	src *architecture.Register,
+) {
+	switch simpleType.(type) {
+	case *ir.SignedIntType:
+	case *ir.UnsignedIntType:
+	default:
+		panic("should never happen")
+	}
+
+	operandSize := simpleType.Size()
+	opCode := []byte{0x03}
+	if operandSize == 1 {
+		opCode = []byte{0x02}
+	}
+
+	newRM(false, operandSize, opCode, dest, src).encode(builder)
+}
+
 // <int/uint dest> += <int/uint immediate>
 //
 // https://www.felixcloutier.com/x86/add
@@ -356,6 +356,57 @@ func TestSelectAddIntImmediate(t *testing.T) {
 	}
 }
 
+func TestSelectAddEnumImmediate(t *testing.T) {
+	enumType := ir.NewEnumType(
+		"ordering",
+		ir.Int16,
+		[]ir.EnumValue{
+			{Name: "less", Value: -1},
+			{Name: "equal", Value: 0},
+			{Name: "greater", Value: 1},
+		})
+
+	src := ir.NewLocalReference("src")
+	src.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "src",
+		Type: enumType,
+	}
+
+	dest := &ir.Definition{
+		Type: enumType,
+		Operation: &ir.BinaryOperation{
+			Kind: ir.Add,
+			Src1: src,
+			Src2: ir.NewEnumImmediate(enumType, "greater"),
+		},
+	}
+
+	// Enum values operate as their underlying int values.
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(binaryMIOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			constraints.RegisterSources[0].RegisterConstraint: registers.Rcx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0x66, 0x81, 0xc1, 0x01, 0x00}, // add cx, 1
+		segment.Content.Flatten())
+}
+
 func TestSelectAddUIntImmediate(t *testing.T) {
 	src := ir.NewLocalReference("src")
 	srcDef := &ir.Definition{
@@ -0,0 +1,141 @@
+package instructions
+
+import (
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// amd64 uses the total store order memory model: loads are not reordered
+// with other loads, stores are not reordered with other stores, and loads are
+// not reordered with older stores.  The only visible reordering is a store
+// followed by a load to a different location.  Hence:
+//
+//   - all aligned loads have acquire semantics,
+//   - all aligned stores have release semantics, and
+//   - only sequentially consistent stores and fences require serialization.
+//     We'll use xchg for sequentially consistent stores such that sequentially
+//     consistent loads can use plain mov.
+//
+// Locked read-modify-write instructions are full barriers.
+//
+// NOTE: the address must be aligned to the operand size.
+
+// <int/uint dest> = [<address>]
+//
+// https://www.felixcloutier.com/x86/mov
+//
+// 8-bit (RM Op/En):        8A /r
+// 16/32/64-bit (RM Op/En): 8B /r
+func atomicLoad(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	address *architecture.Register,
+) {
+	copyMemoryToGeneral(builder, simpleType.Size(), dest, address)
+}
+
+// [<address>] = <int/uint src>
+//
+// https://www.felixcloutier.com/x86/mov
+//
+// NOTE: this is only used by relaxed / release stores.  Sequentially consistent
+// stores use xchg.
+//
+// 8-bit (MR Op/En):        88 /r
+// 16/32/64-bit (MR Op/En): 89 /r
+func atomicStore(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	address *architecture.Register,
+	src *architecture.Register,
+) {
+	copyGeneralToMemory(builder, simpleType.Size(), address, src)
+}
+
+// <int/uint src>, [<address>] = [<address>], <int/uint src>
+//
+// NOTE: xchg with a memory operand is implicitly locked.
+//
+// https://www.felixcloutier.com/x86/xchg
+//
+// 8-bit (MR Op/En):        86 /r
+// 16/32/64-bit (MR Op/En): 87 /r
+func exchange(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	address *architecture.Register,
+	src *architecture.Register,
+) {
+	opCode := []byte{0x87}
+	if simpleType.Size() == 1 {
+		opCode = []byte{0x86}
+	}
+
+	newIndirectRM(false, simpleType.Size(), opCode, src, address).encode(builder)
+}
+
+// if <rax> == [<address>] { [<address>] = <int/uint src> }
+// else { <rax> = [<address>] }
+//
+// i.e., <rax> always holds [<address>]'s original value afterward.
+//
+// https://www.felixcloutier.com/x86/cmpxchg
+//
+// 8-bit (MR Op/En):        F0 0F B0 /r
+// 16/32/64-bit (MR Op/En): F0 0F B1 /r
+func compareExchange(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	address *architecture.Register,
+	src *architecture.Register,
+) {
+	opCode := []byte{0x0F, 0xB1}
+	if simpleType.Size() == 1 {
+		opCode = []byte{0x0F, 0xB0}
+	}
+
+	spec := newIndirectRM(false, simpleType.Size(), opCode, src, address)
+	spec.requireLockPrefix = true
+	spec.encode(builder)
+}
+
+// <int/uint src>, [<address>] = [<address>], [<address>] + <int/uint src>
+//
+// https://www.felixcloutier.com/x86/xadd
+//
+// 8-bit (MR Op/En):        F0 0F C0 /r
+// 16/32/64-bit (MR Op/En): F0 0F C1 /r
+func fetchAdd(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	address *architecture.Register,
+	src *architecture.Register,
+) {
+	opCode := []byte{0x0F, 0xC1}
+	if simpleType.Size() == 1 {
+		opCode = []byte{0x0F, 0xC0}
+	}
+
+	spec := newIndirectRM(false, simpleType.Size(), opCode, src, address)
+	spec.requireLockPrefix = true
+	spec.encode(builder)
+}
+
+// NOTE: acquire / release / acquire-release fences are no-ops (see memory
+// model comment above); only sequentially consistent fences emit mfence.
+//
+// https://www.felixcloutier.com/x86/mfence
+//
+// (ZO Op/En): 0F AE F0
+func fence(
+	builder *layout.SegmentBuilder,
+	order ir.MemoryOrder,
+) {
+	if order != ir.SequentiallyConsistent {
+		return
+	}
+
+	builder.AppendBasicData([]byte{0x0F, 0xAE, 0xF0})
+}
@@ -0,0 +1,292 @@
+package instructions
+
+import (
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// The operand type of the atomic operation (the definition's type is empty
+// struct for stores / fences).
+func atomicValueType(atomicOp *ir.AtomicOperation) ir.Type {
+	return ir.UnderlyingType(atomicOp.Address.Type().(*ir.AddressType).ValueType)
+}
+
+type atomicLoadOperation struct {
+	*ir.Definition
+
+	architecture.InstructionConstraints
+}
+
+func (op atomicLoadOperation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op atomicLoadOperation) Constraints() architecture.InstructionConstraints {
+	return op.InstructionConstraints
+}
+
+func (op atomicLoadOperation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	address := op.RegisterSources[0].RegisterConstraint
+	dest := op.RegisterDestinations[0].RegisterConstraint
+	atomicLoad(
+		builder,
+		ir.UnderlyingType(op.Type),
+		selectedRegisters[dest],
+		selectedRegisters[address])
+}
+
+type atomicLoadSelector struct{}
+
+func (atomicLoadSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	atomicOp *ir.AtomicOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	return atomicLoadOperation{
+		Definition: def,
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						AnyGeneral: true,
+					},
+					DefinitionChunk: atomicOp.Address.Def().Chunks()[0],
+				},
+			},
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						Clobbered:  true,
+						AnyGeneral: true,
+					},
+					DefinitionChunk: def.Chunks()[0],
+				},
+			},
+		},
+	}
+}
+
+type encodeAtomicFunc func(
+	*layout.SegmentBuilder,
+	ir.Type,
+	*architecture.Register, // address
+	*architecture.Register) // src
+
+// Store / exchange / compare-exchange / fetch-add operation of the form
+// <encode> [<address>], <src>
+//
+// The first source register is the address, and the second source register
+// is the src.  The compare-exchange operation's third source register (if
+// specified) is rax.
+type atomicOperation struct {
+	*ir.Definition
+
+	valueType ir.Type
+
+	architecture.InstructionConstraints
+
+	encode encodeAtomicFunc
+}
+
+func (op atomicOperation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op atomicOperation) Constraints() architecture.InstructionConstraints {
+	return op.InstructionConstraints
+}
+
+func (op atomicOperation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	address := op.RegisterSources[0].RegisterConstraint
+	src := op.RegisterSources[1].RegisterConstraint
+	op.encode(
+		builder,
+		op.valueType,
+		selectedRegisters[address],
+		selectedRegisters[src])
+}
+
+type atomicStoreSelector struct{}
+
+func (atomicStoreSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	atomicOp *ir.AtomicOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	encode := atomicStore
+	if atomicOp.Order == ir.SequentiallyConsistent {
+		encode = exchange
+	}
+
+	return atomicOperation{
+		Definition: def,
+		valueType:  atomicValueType(atomicOp),
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						AnyGeneral: true,
+					},
+					DefinitionChunk: atomicOp.Address.Def().Chunks()[0],
+				},
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						// xchg swaps the src with the original value
+						Clobbered:  atomicOp.Order == ir.SequentiallyConsistent,
+						AnyGeneral: true,
+					},
+					DefinitionChunk: atomicOp.Src.Def().Chunks()[0],
+				},
+			},
+		},
+		encode: encode,
+	}
+}
+
+// Exchange / fetch-add selector.  The src register holds the original value
+// afterward.
+type atomicReadModifyWriteSelector struct {
+	encode encodeAtomicFunc
+}
+
+func (selector atomicReadModifyWriteSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	atomicOp *ir.AtomicOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	src := &architecture.RegisterConstraint{
+		Clobbered:  true,
+		AnyGeneral: true,
+	}
+
+	return atomicOperation{
+		Definition: def,
+		valueType:  atomicValueType(atomicOp),
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						AnyGeneral: true,
+					},
+					DefinitionChunk: atomicOp.Address.Def().Chunks()[0],
+				},
+				{
+					RegisterConstraint: src,
+					DefinitionChunk:    atomicOp.Src.Def().Chunks()[0],
+				},
+			},
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: src,
+					DefinitionChunk:    def.Chunks()[0],
+				},
+			},
+		},
+		encode: selector.encode,
+	}
+}
+
+type atomicCompareExchangeSelector struct{}
+
+func (atomicCompareExchangeSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	atomicOp *ir.AtomicOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	rax := &architecture.RegisterConstraint{
+		Clobbered: true,
+		Require:   registers.Rax,
+	}
+
+	address := architecture.RegisterMapping{
+		RegisterConstraint: &architecture.RegisterConstraint{
+			AnyGeneral: true,
+		},
+		DefinitionChunk: atomicOp.Address.Def().Chunks()[0],
+	}
+
+	expected := architecture.RegisterMapping{
+		RegisterConstraint: rax,
+		DefinitionChunk:    atomicOp.Expected.Def().Chunks()[0],
+	}
+
+	srcChunk := atomicOp.Src.Def().Chunks()[0]
+
+	var sources []architecture.RegisterMapping
+	if srcChunk == expected.DefinitionChunk {
+		sources = []architecture.RegisterMapping{address, expected}
+	} else {
+		sources = []architecture.RegisterMapping{
+			address,
+			{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					AnyGeneral: true,
+				},
+				DefinitionChunk: srcChunk,
+			},
+			expected,
+		}
+	}
+
+	return atomicOperation{
+		Definition: def,
+		valueType:  atomicValueType(atomicOp),
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: sources,
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: rax,
+					DefinitionChunk:    def.Chunks()[0],
+				},
+			},
+		},
+		encode: compareExchange,
+	}
+}
+
+type fenceOperation struct {
+	*ir.Definition
+
+	order ir.MemoryOrder
+}
+
+func (op fenceOperation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op fenceOperation) Constraints() architecture.InstructionConstraints {
+	return architecture.InstructionConstraints{}
+}
+
+func (op fenceOperation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	fence(builder, op.order)
+}
+
+type fenceSelector struct{}
+
+func (fenceSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	atomicOp *ir.AtomicOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	return fenceOperation{
+		Definition: def,
+		order:      atomicOp.Order,
+	}
+}
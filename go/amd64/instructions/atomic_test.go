@@ -0,0 +1,566 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+func TestAtomicLoad(t *testing.T) {
+	type testCase struct {
+		name     string
+		opType   ir.Type
+		dest     *architecture.Register
+		address  *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:     "load u8", // mov sil, byte [rdi]
+			opType:   ir.Uint8,
+			dest:     registers.Rsi,
+			address:  registers.Rdi,
+			expected: []byte{0x40, 0x8a, 0x37},
+		},
+		{
+			name:     "load i16", // mov cx, word [r12]
+			opType:   ir.Int16,
+			dest:     registers.Rcx,
+			address:  registers.R12,
+			expected: []byte{0x66, 0x41, 0x8b, 0x0c, 0x24},
+		},
+		{
+			name:     "load u32", // mov r9d, dword [rbp+0]
+			opType:   ir.Uint32,
+			dest:     registers.R9,
+			address:  registers.Rbp,
+			expected: []byte{0x44, 0x8b, 0x4d, 0x00},
+		},
+		{
+			name:     "load i64", // mov r15, qword [r13+0]
+			opType:   ir.Int64,
+			dest:     registers.R15,
+			address:  registers.R13,
+			expected: []byte{0x4d, 0x8b, 0x7d, 0x00},
+		},
+		{
+			name:     "load i64", // mov rbx, qword [r8]
+			opType:   ir.Int64,
+			dest:     registers.Rbx,
+			address:  registers.R8,
+			expected: []byte{0x49, 0x8b, 0x18},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			atomicLoad(builder, test.opType, test.dest, test.address)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func TestAtomicMemoryOperations(t *testing.T) {
+	type testCase struct {
+		name     string
+		encode   encodeAtomicFunc
+		opType   ir.Type
+		address  *architecture.Register
+		src      *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:     "store u8", // mov byte [rdi], sil
+			encode:   atomicStore,
+			opType:   ir.Uint8,
+			address:  registers.Rdi,
+			src:      registers.Rsi,
+			expected: []byte{0x40, 0x88, 0x37},
+		},
+		{
+			name:     "store i16", // mov word [r12], cx
+			encode:   atomicStore,
+			opType:   ir.Int16,
+			address:  registers.R12,
+			src:      registers.Rcx,
+			expected: []byte{0x66, 0x41, 0x89, 0x0c, 0x24},
+		},
+		{
+			name:     "store u32", // mov dword [rbp+0], r9d
+			encode:   atomicStore,
+			opType:   ir.Uint32,
+			address:  registers.Rbp,
+			src:      registers.R9,
+			expected: []byte{0x44, 0x89, 0x4d, 0x00},
+		},
+		{
+			name:     "store i64", // mov qword [r13+0], r15
+			encode:   atomicStore,
+			opType:   ir.Int64,
+			address:  registers.R13,
+			src:      registers.R15,
+			expected: []byte{0x4d, 0x89, 0x7d, 0x00},
+		},
+		{
+			name:     "store i64", // mov qword [r8], rbx
+			encode:   atomicStore,
+			opType:   ir.Int64,
+			address:  registers.R8,
+			src:      registers.Rbx,
+			expected: []byte{0x49, 0x89, 0x18},
+		},
+		{
+			name:     "xchg u8", // xchg byte [rdi], sil
+			encode:   exchange,
+			opType:   ir.Uint8,
+			address:  registers.Rdi,
+			src:      registers.Rsi,
+			expected: []byte{0x40, 0x86, 0x37},
+		},
+		{
+			name:     "xchg i16", // xchg word [r12], cx
+			encode:   exchange,
+			opType:   ir.Int16,
+			address:  registers.R12,
+			src:      registers.Rcx,
+			expected: []byte{0x66, 0x41, 0x87, 0x0c, 0x24},
+		},
+		{
+			name:     "xchg u32", // xchg dword [rbp+0], r9d
+			encode:   exchange,
+			opType:   ir.Uint32,
+			address:  registers.Rbp,
+			src:      registers.R9,
+			expected: []byte{0x44, 0x87, 0x4d, 0x00},
+		},
+		{
+			name:     "xchg i64", // xchg qword [r13+0], r15
+			encode:   exchange,
+			opType:   ir.Int64,
+			address:  registers.R13,
+			src:      registers.R15,
+			expected: []byte{0x4d, 0x87, 0x7d, 0x00},
+		},
+		{
+			name:     "xchg i64", // xchg qword [r8], rbx
+			encode:   exchange,
+			opType:   ir.Int64,
+			address:  registers.R8,
+			src:      registers.Rbx,
+			expected: []byte{0x49, 0x87, 0x18},
+		},
+		{
+			name:     "cmpxchg u8", // lock cmpxchg byte [rdi], sil
+			encode:   compareExchange,
+			opType:   ir.Uint8,
+			address:  registers.Rdi,
+			src:      registers.Rsi,
+			expected: []byte{0xf0, 0x40, 0x0f, 0xb0, 0x37},
+		},
+		{
+			name:     "cmpxchg i16", // lock cmpxchg word [r12], cx
+			encode:   compareExchange,
+			opType:   ir.Int16,
+			address:  registers.R12,
+			src:      registers.Rcx,
+			expected: []byte{0xf0, 0x66, 0x41, 0x0f, 0xb1, 0x0c, 0x24},
+		},
+		{
+			name:     "cmpxchg u32", // lock cmpxchg dword [rbp+0], r9d
+			encode:   compareExchange,
+			opType:   ir.Uint32,
+			address:  registers.Rbp,
+			src:      registers.R9,
+			expected: []byte{0xf0, 0x44, 0x0f, 0xb1, 0x4d, 0x00},
+		},
+		{
+			name:     "cmpxchg i64", // lock cmpxchg qword [r13+0], r15
+			encode:   compareExchange,
+			opType:   ir.Int64,
+			address:  registers.R13,
+			src:      registers.R15,
+			expected: []byte{0xf0, 0x4d, 0x0f, 0xb1, 0x7d, 0x00},
+		},
+		{
+			name:     "cmpxchg i64", // lock cmpxchg qword [r8], rbx
+			encode:   compareExchange,
+			opType:   ir.Int64,
+			address:  registers.R8,
+			src:      registers.Rbx,
+			expected: []byte{0xf0, 0x49, 0x0f, 0xb1, 0x18},
+		},
+		{
+			name:     "xadd u8", // lock xadd byte [rdi], sil
+			encode:   fetchAdd,
+			opType:   ir.Uint8,
+			address:  registers.Rdi,
+			src:      registers.Rsi,
+			expected: []byte{0xf0, 0x40, 0x0f, 0xc0, 0x37},
+		},
+		{
+			name:     "xadd i16", // lock xadd word [r12], cx
+			encode:   fetchAdd,
+			opType:   ir.Int16,
+			address:  registers.R12,
+			src:      registers.Rcx,
+			expected: []byte{0xf0, 0x66, 0x41, 0x0f, 0xc1, 0x0c, 0x24},
+		},
+		{
+			name:     "xadd u32", // lock xadd dword [rbp+0], r9d
+			encode:   fetchAdd,
+			opType:   ir.Uint32,
+			address:  registers.Rbp,
+			src:      registers.R9,
+			expected: []byte{0xf0, 0x44, 0x0f, 0xc1, 0x4d, 0x00},
+		},
+		{
+			name:     "xadd i64", // lock xadd qword [r13+0], r15
+			encode:   fetchAdd,
+			opType:   ir.Int64,
+			address:  registers.R13,
+			src:      registers.R15,
+			expected: []byte{0xf0, 0x4d, 0x0f, 0xc1, 0x7d, 0x00},
+		},
+		{
+			name:     "xadd i64", // lock xadd qword [r8], rbx
+			encode:   fetchAdd,
+			opType:   ir.Int64,
+			address:  registers.R8,
+			src:      registers.Rbx,
+			expected: []byte{0xf0, 0x49, 0x0f, 0xc1, 0x18},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			test.encode(builder, test.opType, test.address, test.src)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func TestFence(t *testing.T) {
+	for _, order := range []ir.MemoryOrder{
+		ir.Relaxed,
+		ir.Acquire,
+		ir.Release,
+		ir.AcquireRelease,
+	} {
+		builder := layout.NewSegmentBuilder()
+		fence(builder, order)
+		segment, err := builder.Finalize(amd64.ArchitectureLayout)
+		expect.Nil(t, err)
+		expect.Equal(t, []byte{}, segment.Content.Flatten())
+	}
+
+	builder := layout.NewSegmentBuilder()
+	fence(builder, ir.SequentiallyConsistent)
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0x0f, 0xae, 0xf0}, segment.Content.Flatten()) // mfence
+}
+
+func newTestAtomicOperation(
+	kind ir.AtomicOperationKind,
+	order ir.MemoryOrder,
+	valueType ir.Type,
+) (
+	*ir.AtomicOperation,
+	*ir.Definition,
+) {
+	address := ir.NewLocalReference("address")
+	address.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "address",
+		Type: ir.NewAddressType(valueType),
+	}
+
+	src := ir.NewLocalReference("src")
+	src.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "src",
+		Type: valueType,
+	}
+
+	expected := ir.NewLocalReference("expected")
+	expected.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "expected",
+		Type: valueType,
+	}
+
+	atomicOp := &ir.AtomicOperation{
+		Kind:     kind,
+		Order:    order,
+		Address:  address,
+		Src:      src,
+		Expected: expected,
+	}
+
+	destType := valueType
+	if kind == ir.AtomicStore || kind == ir.Fence {
+		destType = ir.NewStructType(nil)
+	}
+
+	dest := &ir.Definition{
+		Type:      destType,
+		Operation: atomicOp,
+	}
+
+	return atomicOp, dest
+}
+
+func TestSelectAtomicLoad(t *testing.T) {
+	atomicOp, dest := newTestAtomicOperation(
+		ir.AtomicLoad,
+		ir.SequentiallyConsistent,
+		ir.Int32)
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+	expect.Equal(
+		t,
+		atomicOp.Address.Def().Chunks()[0],
+		constraints.RegisterSources[0].DefinitionChunk)
+	expect.Equal(t, 1, len(constraints.RegisterDestinations))
+
+	address := constraints.RegisterSources[0].RegisterConstraint
+	destRegister := constraints.RegisterDestinations[0].RegisterConstraint
+	expect.False(t, address.Clobbered)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			address:      registers.Rsi,
+			destRegister: registers.Rdx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0x8b, 0x16}, // mov edx, dword [rsi]
+		segment.Content.Flatten())
+}
+
+func TestSelectAtomicStore(t *testing.T) {
+	for _, order := range []ir.MemoryOrder{
+		ir.Release,
+		ir.SequentiallyConsistent,
+	} {
+		atomicOp, dest := newTestAtomicOperation(ir.AtomicStore, order, ir.Uint64)
+
+		instruction := architecture.SelectInstruction(
+			testConfig,
+			dest,
+			architecture.SelectorHint{})
+
+		constraints := instruction.Constraints()
+		expect.Equal(t, 2, len(constraints.RegisterSources))
+		expect.Equal(t, 0, len(constraints.RegisterDestinations))
+		expect.Equal(
+			t,
+			atomicOp.Src.Def().Chunks()[0],
+			constraints.RegisterSources[1].DefinitionChunk)
+
+		address := constraints.RegisterSources[0].RegisterConstraint
+		src := constraints.RegisterSources[1].RegisterConstraint
+
+		builder := layout.NewSegmentBuilder()
+		instruction.EmitTo(
+			builder,
+			map[*architecture.RegisterConstraint]*architecture.Register{
+				address: registers.Rdi,
+				src:     registers.Rax,
+			})
+		segment, err := builder.Finalize(amd64.ArchitectureLayout)
+		expect.Nil(t, err)
+
+		if order == ir.SequentiallyConsistent {
+			expect.True(t, src.Clobbered)
+			expect.Equal(
+				t,
+				[]byte{0x48, 0x87, 0x07}, // xchg qword [rdi], rax
+				segment.Content.Flatten())
+		} else {
+			expect.False(t, src.Clobbered)
+			expect.Equal(
+				t,
+				[]byte{0x48, 0x89, 0x07}, // mov qword [rdi], rax
+				segment.Content.Flatten())
+		}
+	}
+}
+
+func TestSelectAtomicFetchAdd(t *testing.T) {
+	atomicOp, dest := newTestAtomicOperation(
+		ir.AtomicFetchAdd,
+		ir.Relaxed,
+		ir.Uint16)
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 2, len(constraints.RegisterSources))
+	expect.Equal(
+		t,
+		atomicOp.Src.Def().Chunks()[0],
+		constraints.RegisterSources[1].DefinitionChunk)
+	expect.Equal(t, 1, len(constraints.RegisterDestinations))
+	expect.Equal(
+		t,
+		dest.Chunks()[0],
+		constraints.RegisterDestinations[0].DefinitionChunk)
+
+	address := constraints.RegisterSources[0].RegisterConstraint
+	src := constraints.RegisterSources[1].RegisterConstraint
+	expect.True(t, src.Clobbered)
+	expect.True(t, src == constraints.RegisterDestinations[0].RegisterConstraint)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			address: registers.R8,
+			src:     registers.R10,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0xf0, 0x66, 0x45, 0x0f, 0xc1, 0x10}, // lock xadd word [r8], r10w
+		segment.Content.Flatten())
+}
+
+func TestSelectAtomicCompareExchange(t *testing.T) {
+	atomicOp, dest := newTestAtomicOperation(
+		ir.AtomicCompareExchange,
+		ir.SequentiallyConsistent,
+		ir.Int64)
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 3, len(constraints.RegisterSources))
+	expect.Equal(
+		t,
+		atomicOp.Src.Def().Chunks()[0],
+		constraints.RegisterSources[1].DefinitionChunk)
+	expect.Equal(
+		t,
+		atomicOp.Expected.Def().Chunks()[0],
+		constraints.RegisterSources[2].DefinitionChunk)
+
+	address := constraints.RegisterSources[0].RegisterConstraint
+	src := constraints.RegisterSources[1].RegisterConstraint
+	rax := constraints.RegisterSources[2].RegisterConstraint
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered: true,
+			Require:   registers.Rax,
+		},
+		rax)
+	expect.True(t, rax == constraints.RegisterDestinations[0].RegisterConstraint)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			address: registers.Rbx,
+			src:     registers.Rcx,
+			rax:     registers.Rax,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0xf0, 0x48, 0x0f, 0xb1, 0x0b}, // lock cmpxchg qword [rbx], rcx
+		segment.Content.Flatten())
+}
+
+func TestSelectAtomicCompareExchangeSameSource(t *testing.T) {
+	atomicOp, dest := newTestAtomicOperation(
+		ir.AtomicCompareExchange,
+		ir.SequentiallyConsistent,
+		ir.Uint8)
+	atomicOp.Src = atomicOp.Expected
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	// The expected value's rax register is also used as the src register.
+	constraints := instruction.Constraints()
+	expect.Equal(t, 2, len(constraints.RegisterSources))
+	address := constraints.RegisterSources[0].RegisterConstraint
+	rax := constraints.RegisterSources[1].RegisterConstraint
+	expect.Equal(t, registers.Rax, rax.Require)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			address: registers.Rsi,
+			rax:     registers.Rax,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0xf0, 0x0f, 0xb0, 0x06}, // lock cmpxchg byte [rsi], al
+		segment.Content.Flatten())
+}
+
+func TestSelectFence(t *testing.T) {
+	_, dest := newTestAtomicOperation(
+		ir.Fence,
+		ir.SequentiallyConsistent,
+		ir.Uint64)
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	expect.Equal(
+		t,
+		architecture.InstructionConstraints{},
+		instruction.Constraints())
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0x0f, 0xae, 0xf0}, segment.Content.Flatten())
+}
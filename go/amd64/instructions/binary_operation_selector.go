@@ -16,7 +16,11 @@ func isMISupportedImmediate(src ir.Value) bool {
 		return false
 	}
 
-	switch value := immediate.Value.(type) {
+	return isMISupportedImmediateValue(immediate.Value)
+}
+
+func isMISupportedImmediateValue(immediate interface{}) bool {
+	switch value := immediate.(type) {
 	case int8:
 	case int16:
 	case int32:
@@ -32,7 +36,7 @@ func isMISupportedImmediate(src ir.Value) bool {
 	case float64:
 		return false
 	default:
-		panic(fmt.Sprintf("unsupported immediate value type: %#V", immediate.Value))
+		panic(fmt.Sprintf("unsupported immediate value type: %#V", immediate))
 	}
 
 	return true
@@ -62,7 +66,7 @@ func (op imulRMIOperation) EmitTo(
 	src := op.RegisterSources[0].RegisterConstraint
 	mulIntImmediate(
 		builder,
-		op.Type,
+		ir.UnderlyingType(op.Type),
 		selectedRegisters[dest],
 		selectedRegisters[src],
 		op.immediate)
@@ -99,7 +103,7 @@ func (op binaryMIOperation) EmitTo(
 ) {
 	constraint := op.RegisterSources[0].RegisterConstraint
 	register := selectedRegisters[constraint]
-	op.encodeMI(builder, op.Type, register, op.immediate)
+	op.encodeMI(builder, ir.UnderlyingType(op.Type), register, op.immediate)
 }
 
 type encodeRMFunc func(
@@ -131,13 +135,13 @@ func (op binaryRMOperation) EmitTo(
 	if len(op.RegisterSources) == 1 {
 		constraint := op.RegisterSources[0].RegisterConstraint
 		register := selectedRegisters[constraint]
-		op.encodeRM(builder, op.Type, register, register)
+		op.encodeRM(builder, ir.UnderlyingType(op.Type), register, register)
 	} else {
 		registers := make([]*architecture.Register, 2)
 		for idx, source := range op.RegisterSources {
 			registers[idx] = selectedRegisters[source.RegisterConstraint]
 		}
-		op.encodeRM(builder, op.Type, registers[0], registers[1])
+		op.encodeRM(builder, ir.UnderlyingType(op.Type), registers[0], registers[1])
 	}
 }
 
@@ -168,13 +172,16 @@ func (op mOperation) EmitTo(
 	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
 ) {
 	constraint := op.RegisterSources[0].RegisterConstraint
-	op.encodeM(builder, op.Type, selectedRegisters[constraint])
+	op.encodeM(builder, ir.UnderlyingType(op.Type), selectedRegisters[constraint])
 }
 
 type divRemOperation struct {
 	*ir.Definition
 
 	architecture.InstructionConstraints
+
+	// Only set when the architecture config requires defined semantics.
+	divideByZeroHandler string
 }
 
 func (op divRemOperation) Instruction() ir.Instruction {
@@ -190,7 +197,16 @@ func (op divRemOperation) EmitTo(
 	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
 ) {
 	constraint := op.RegisterSources[len(op.RegisterSources)-1].RegisterConstraint
-	divRemInt(builder, op.Type, selectedRegisters[constraint])
+	if op.divideByZeroHandler != "" {
+		divRemIntDefined(
+			builder,
+			ir.UnderlyingType(op.Type),
+			selectedRegisters[constraint],
+			op.divideByZeroHandler)
+		return
+	}
+
+	divRemInt(builder, ir.UnderlyingType(op.Type), selectedRegisters[constraint])
 }
 
 // Common binary operation of the form (<dest> = <op> <dest> <src>) with
@@ -426,6 +442,11 @@ func (selector commonBinaryOperationSelector) newBinaryRMOperation(
 type shiftSelector struct {
 	encodeMI8 encodeMIFunc
 	encodeMC  encodeMFunc
+
+	// Used instead of encodeMI8 / encodeMC when the architecture config
+	// requires defined semantics.
+	encodeDefinedMI8 encodeMIFunc
+	encodeDefinedMC  encodeMFunc
 }
 
 func (selector shiftSelector) Select(
@@ -434,18 +455,30 @@ func (selector shiftSelector) Select(
 	binaryOp *ir.BinaryOperation,
 	hint architecture.SelectorHint,
 ) architecture.MachineInstruction {
-	instruction := selector.maybeNewBinaryMI8Operation(binaryOp, def, hint)
+	encodeMI8 := selector.encodeMI8
+	encodeMC := selector.encodeMC
+	if config.DefinedSemantics {
+		encodeMI8 = selector.encodeDefinedMI8
+		encodeMC = selector.encodeDefinedMC
+	}
+
+	instruction := selector.maybeNewBinaryMI8Operation(
+		binaryOp,
+		def,
+		hint,
+		encodeMI8)
 	if instruction != nil {
 		return instruction
 	}
 
-	return selector.newBinaryMCOperation(binaryOp, def, hint)
+	return selector.newBinaryMCOperation(binaryOp, def, hint, encodeMC)
 }
 
 func (selector shiftSelector) maybeNewBinaryMI8Operation(
 	binaryOp *ir.BinaryOperation,
 	def *ir.Definition,
 	hint architecture.SelectorHint,
+	encodeMI8 encodeMIFunc,
 ) architecture.MachineInstruction {
 	immediate, ok := binaryOp.Src2.(*ir.Immediate)
 	if !ok {
@@ -476,7 +509,7 @@ func (selector shiftSelector) maybeNewBinaryMI8Operation(
 				},
 			},
 		},
-		encodeMI: selector.encodeMI8,
+		encodeMI: encodeMI8,
 	}
 }
 
@@ -484,6 +517,7 @@ func (selector shiftSelector) newBinaryMCOperation(
 	binaryOp *ir.BinaryOperation,
 	def *ir.Definition,
 	hint architecture.SelectorHint,
+	encodeMC encodeMFunc,
 ) architecture.MachineInstruction {
 	constraints := architecture.InstructionConstraints{}
 	src1Chunk := binaryOp.Src1.Def().Chunks()[0]
@@ -538,7 +572,7 @@ func (selector shiftSelector) newBinaryMCOperation(
 	return mOperation{
 		Definition:             def,
 		InstructionConstraints: constraints,
-		encodeM:                selector.encodeMC,
+		encodeM:                encodeMC,
 	}
 }
 
@@ -598,8 +632,17 @@ func (selector divRemSelector) Select(
 			})
 	}
 
+	divideByZeroHandler := ""
+	if config.DefinedSemantics {
+		if config.DivideByZeroHandler == "" {
+			panic("divide by zero handler not specified")
+		}
+		divideByZeroHandler = config.DivideByZeroHandler
+	}
+
 	return divRemOperation{
 		Definition:             def,
 		InstructionConstraints: constraints,
+		divideByZeroHandler:    divideByZeroHandler,
 	}
 }
@@ -0,0 +1,339 @@
+package instructions
+
+import (
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// popcnt / lzcnt / tzcnt general register RM Op/En instructions, which use the
+// 0xf3 prefix as part of the op code.  Only 32/64-bit operand variants are
+// supported.
+func newF3RM(
+	operandSize int,
+	opCode []byte,
+	reg *architecture.Register,
+	rm *architecture.Register,
+) modRMSpec {
+	if !reg.AllowGeneralOperations || !rm.AllowGeneralOperations {
+		panic("invalid register")
+	}
+
+	// NOTE: The float32 encoding convention emits the 0xf3 prefix before the
+	// rex prefix.
+	spec := _newRMI(true, 4, opCode, reg, rm, nil)
+	switch operandSize {
+	case 4:
+	case 8:
+		spec.requireRexWBit = true
+	default:
+		panic("should never happen")
+	}
+
+	return spec
+}
+
+// Zero extend 8/16-bit dest to 32-bit dest.  Returns the extended operand size.
+func zeroExtendSmallInt(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) int {
+	switch simpleType.Size() {
+	case 1:
+		extendInt(builder, 4, dest, ir.Uint8, dest)
+		return 4
+	case 2:
+		extendInt(builder, 4, dest, ir.Uint16, dest)
+		return 4
+	default:
+		return simpleType.Size()
+	}
+}
+
+// <int/uint dest> = popcnt(<int/uint dest>)
+//
+// NOTE: requires the popcnt cpu feature.  8/16-bit dest is zero extended and
+// uses the 32-bit variant.
+//
+// https://www.felixcloutier.com/x86/popcnt
+//
+// 32/64-bit (RM Op/En): F3 0F B8 /r
+func popcnt(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	operandSize := zeroExtendSmallInt(builder, simpleType, dest)
+	newF3RM(operandSize, []byte{0x0F, 0xB8}, dest, dest).encode(builder)
+}
+
+// <int/uint dest> = popcnt(<int/uint dest>)
+//
+// Fallback for cpus without the popcnt feature.  This uses the standard
+// parallel bit count sequence, where each step sums adjacent bit fields:
+//
+//	mov <scratch>, <dest>
+//	shr <scratch>, 1
+//	and <scratch>, 0x55..55
+//	sub <dest>, <scratch>              // 2-bit field counts
+//	mov <scratch>, <dest>
+//	shr <scratch>, 2
+//	and <scratch>, 0x33..33
+//	and <dest>, 0x33..33
+//	add <dest>, <scratch>              // 4-bit field counts
+//	mov <scratch>, <dest>
+//	shr <scratch>, 4
+//	add <dest>, <scratch>
+//	and <dest>, 0x0f..0f               // 8-bit field counts
+//	imul <dest>, 0x01..01              // sum of all fields in the top byte
+//	shr <dest>, <bit width - 8>
+//
+// 8/16-bit dest is zero extended and uses the 32-bit sequence.  The 32-bit
+// sequence uses immediate masks.  The 64-bit sequence loads the masks into
+// the mask register (the mask register is unused and may be nil otherwise).
+//
+// https://en.wikipedia.org/wiki/Hamming_weight
+func popcntFallback(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	scratch *architecture.Register,
+	mask *architecture.Register,
+) {
+	operandSize := zeroExtendSmallInt(builder, simpleType, dest)
+
+	var opType ir.Type = ir.Uint32
+	if operandSize == 8 {
+		opType = ir.Uint64
+	}
+
+	// NOTE: 64-bit masks are not representable as sign extended 32-bit
+	// immediates.
+	loadedMask := uint64(0)
+	andMask := func(register *architecture.Register, value uint64) {
+		if operandSize == 8 {
+			if loadedMask != value {
+				setImmediate(builder, mask, value)
+				loadedMask = value
+			}
+			and(builder, opType, register, mask)
+		} else {
+			andIntImmediate(builder, opType, register, uint32(value))
+		}
+	}
+
+	// 2-bit field counts
+	copyGeneral(builder, operandSize, scratch, dest)
+	shrIntImmediate(builder, opType, scratch, uint8(1))
+	andMask(scratch, 0x5555555555555555)
+	sub(builder, opType, dest, scratch)
+
+	// 4-bit field counts
+	copyGeneral(builder, operandSize, scratch, dest)
+	shrIntImmediate(builder, opType, scratch, uint8(2))
+	andMask(scratch, 0x3333333333333333)
+	andMask(dest, 0x3333333333333333)
+	add(builder, opType, dest, scratch)
+
+	// 8-bit field counts
+	copyGeneral(builder, operandSize, scratch, dest)
+	shrIntImmediate(builder, opType, scratch, uint8(4))
+	add(builder, opType, dest, scratch)
+	andMask(dest, 0x0f0f0f0f0f0f0f0f)
+
+	// sum of all 8-bit field counts
+	if operandSize == 8 {
+		setImmediate(builder, mask, uint64(0x0101010101010101))
+		mul(builder, opType, dest, mask)
+	} else {
+		mulIntImmediate(builder, opType, dest, dest, uint32(0x01010101))
+	}
+	shrIntImmediate(builder, opType, dest, uint8(8*operandSize-8))
+}
+
+// <int/uint dest> = count leading zeros(<int/uint dest>)
+//
+// NOTE: requires the lzcnt cpu feature (lzcnt decodes as bsr on cpus without
+// the feature).  8/16-bit dest is zero extended and uses the 32-bit variant,
+// adjusted by the number of extended bits.
+//
+// https://www.felixcloutier.com/x86/lzcnt
+//
+// 32/64-bit (RM Op/En): F3 0F BD /r
+func lzcnt(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	operandSize := zeroExtendSmallInt(builder, simpleType, dest)
+	newF3RM(operandSize, []byte{0x0F, 0xBD}, dest, dest).encode(builder)
+
+	extendedBits := 8 * (operandSize - simpleType.Size())
+	if extendedBits > 0 {
+		subIntImmediate(builder, ir.Uint32, dest, uint32(extendedBits))
+	}
+}
+
+// <int/uint dest> = count leading zeros(<int/uint dest>)
+//
+// Fallback for cpus without the lzcnt feature.  bsr returns the highest set
+// bit's index, and leaves the index undefined when the src is zero:
+//
+//	bsr <dest>, <dest>
+//	jne nonZero
+//	mov <dest>d, -1
+//	nonZero:
+//	neg <dest>d
+//	add <dest>d, <bit width - 1>       // (bit width - 1) - index
+//
+// 8/16-bit dest is zero extended and uses the 32-bit variant.
+//
+// https://www.felixcloutier.com/x86/bsr
+//
+// 32/64-bit (RM Op/En): 0F BD /r
+func clzFallback(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	nonZero := "non zero"
+
+	instructions := layout.NewSegmentBuilder()
+
+	operandSize := zeroExtendSmallInt(instructions, simpleType, dest)
+	newRM(false, operandSize, []byte{0x0F, 0xBD}, dest, dest).encode(
+		instructions)
+
+	d32Instruction(
+		instructions,
+		[]byte{0x0F, 0x85}, // jne
+		layout.BasicBlockKind,
+		nonZero)
+	setImmediate(instructions, dest, int32(-1))
+
+	defineInlinedLabel(instructions, nonZero)
+	negSignedInt(instructions, ir.Int32, dest)
+	addIntImmediate(
+		instructions,
+		ir.Int32,
+		dest,
+		int32(8*simpleType.Size()-1))
+
+	appendInlinedInstructions(builder, instructions)
+}
+
+// <int/uint dest> = count trailing zeros(<int/uint dest>)
+//
+// NOTE: requires the bmi1 cpu feature (tzcnt decodes as bsf on cpus without
+// the feature).  8/16-bit dest uses the 32-bit variant, with the bit right
+// above the operand's bit width set such that the zero src case is correctly
+// handled.
+//
+// https://www.felixcloutier.com/x86/tzcnt
+//
+// 32/64-bit (RM Op/En): F3 0F BC /r
+func tzcnt(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	operandSize := simpleType.Size()
+	if operandSize < 4 {
+		orIntImmediate(builder, ir.Uint32, dest, uint32(1)<<(8*operandSize))
+		operandSize = 4
+	}
+
+	newF3RM(operandSize, []byte{0x0F, 0xBC}, dest, dest).encode(builder)
+}
+
+// <int/uint dest> = count trailing zeros(<int/uint dest>)
+//
+// Fallback for cpus without the bmi1 feature.  bsf returns the lowest set
+// bit's index, and leaves the index undefined when the src is zero:
+//
+//	bsf <dest>, <dest>
+//	jne end
+//	mov <dest>d, <bit width>
+//	end:
+//
+// 8/16-bit dest uses the 32-bit variant, with the bit right above the
+// operand's bit width set (the src is never zero):
+//
+//	or <dest>d, 1 << <bit width>
+//	bsf <dest>d, <dest>d
+//
+// https://www.felixcloutier.com/x86/bsf
+//
+// 32/64-bit (RM Op/En): 0F BC /r
+func ctzFallback(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	operandSize := simpleType.Size()
+	if operandSize < 4 {
+		orIntImmediate(builder, ir.Uint32, dest, uint32(1)<<(8*operandSize))
+		newRM(false, 4, []byte{0x0F, 0xBC}, dest, dest).encode(builder)
+		return
+	}
+
+	end := "end"
+
+	instructions := layout.NewSegmentBuilder()
+
+	newRM(false, operandSize, []byte{0x0F, 0xBC}, dest, dest).encode(
+		instructions)
+	d32Instruction(
+		instructions,
+		[]byte{0x0F, 0x85}, // jne
+		layout.BasicBlockKind,
+		end)
+	setImmediate(instructions, dest, int32(8*operandSize))
+
+	defineInlinedLabel(instructions, end)
+
+	appendInlinedInstructions(builder, instructions)
+}
+
+// <int/uint dest> = byte swap(<int/uint dest>)
+//
+// https://www.felixcloutier.com/x86/bswap
+// https://www.felixcloutier.com/x86/rcl:rcr:rol:ror
+//
+// 8-bit:                 no-op
+// 16-bit (rol MI8 Op/En): 66 C1 /0 08
+// 32-bit (O Op/En):      0F C8+rd
+// 64-bit (O Op/En):      REX.W 0F C8+rd
+func bswap(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	if !dest.AllowGeneralOperations {
+		panic("invalid register")
+	}
+
+	switch simpleType.Size() {
+	case 1:
+		return
+	case 2:
+		rolIntImmediate(builder, simpleType, dest, uint8(8))
+		return
+	}
+
+	instruction := make([]byte, 0, 3)
+
+	rex := rexPrefix
+	if simpleType.Size() == 8 {
+		rex |= rexWBit
+	}
+	rex |= byte((dest.Encoding & 0x08) >> 3) // REX.B bit
+
+	if rex != rexPrefix {
+		instruction = append(instruction, rex)
+	}
+
+	instruction = append(instruction, 0x0F, 0xC8|byte(dest.Encoding&0x07))
+	builder.AppendBasicData(instruction)
+}
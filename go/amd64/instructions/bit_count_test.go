@@ -0,0 +1,486 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// NOTE: the fallback sequences' inlined labels are fully resolved; the
+// segments do not contain any definitions / relocations.
+func TestBitCount(t *testing.T) {
+	type testCase struct {
+		name     string
+		encode   encodeMFunc
+		opType   ir.Type
+		dest     *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:   "popcnt u8 rsi",
+			encode: popcnt,
+			opType: ir.Uint8,
+			dest:   registers.Rsi,
+			expected: []byte{
+				0x40, 0x0f, 0xb6, 0xf6, // movzx esi, sil
+				0xf3, 0x0f, 0xb8, 0xf6, // popcnt esi, esi
+			},
+		},
+		{
+			name:   "popcnt i16 r9",
+			encode: popcnt,
+			opType: ir.Int16,
+			dest:   registers.R9,
+			expected: []byte{
+				0x45, 0x0f, 0xb7, 0xc9, // movzx r9d, r9w
+				0xf3, 0x45, 0x0f, 0xb8, 0xc9, // popcnt r9d, r9d
+			},
+		},
+		{
+			name:   "popcnt u32 rbx",
+			encode: popcnt,
+			opType: ir.Uint32,
+			dest:   registers.Rbx,
+			expected: []byte{
+				0xf3, 0x0f, 0xb8, 0xdb, // popcnt ebx, ebx
+			},
+		},
+		{
+			name:   "popcnt i64 r12",
+			encode: popcnt,
+			opType: ir.Int64,
+			dest:   registers.R12,
+			expected: []byte{
+				0xf3, 0x4d, 0x0f, 0xb8, 0xe4, // popcnt r12, r12
+			},
+		},
+		{
+			name:   "lzcnt u8 rcx",
+			encode: lzcnt,
+			opType: ir.Uint8,
+			dest:   registers.Rcx,
+			expected: []byte{
+				0x0f, 0xb6, 0xc9, // movzx ecx, cl
+				0xf3, 0x0f, 0xbd, 0xc9, // lzcnt ecx, ecx
+				0x81, 0xe9, 0x18, 0x00, 0x00, 0x00, // sub ecx, 24
+			},
+		},
+		{
+			name:   "lzcnt u16 r10",
+			encode: lzcnt,
+			opType: ir.Uint16,
+			dest:   registers.R10,
+			expected: []byte{
+				0x45, 0x0f, 0xb7, 0xd2, // movzx r10d, r10w
+				0xf3, 0x45, 0x0f, 0xbd, 0xd2, // lzcnt r10d, r10d
+				0x41, 0x81, 0xea, 0x10, 0x00, 0x00, 0x00, // sub r10d, 16
+			},
+		},
+		{
+			name:   "lzcnt i32 rdx",
+			encode: lzcnt,
+			opType: ir.Int32,
+			dest:   registers.Rdx,
+			expected: []byte{
+				0xf3, 0x0f, 0xbd, 0xd2, // lzcnt edx, edx
+			},
+		},
+		{
+			name:   "lzcnt u64 rbp",
+			encode: lzcnt,
+			opType: ir.Uint64,
+			dest:   registers.Rbp,
+			expected: []byte{
+				0xf3, 0x48, 0x0f, 0xbd, 0xed, // lzcnt rbp, rbp
+			},
+		},
+		{
+			name:   "clzf u16 rbx",
+			encode: clzFallback,
+			opType: ir.Uint16,
+			dest:   registers.Rbx,
+			expected: []byte{
+				0x0f, 0xb7, 0xdb, // movzx ebx, bx
+				0x0f, 0xbd, 0xdb, // bsr ebx, ebx
+				0x0f, 0x85, 0x05, 0x00, 0x00, 0x00, // jne
+				0xbb, 0xff, 0xff, 0xff, 0xff, // mov ebx, 0xffffffff
+				0xf7, 0xdb, // neg ebx
+				0x81, 0xc3, 0x0f, 0x00, 0x00, 0x00, // add ebx, 15
+			},
+		},
+		{
+			name:   "clzf u64 r13",
+			encode: clzFallback,
+			opType: ir.Uint64,
+			dest:   registers.R13,
+			expected: []byte{
+				0x4d, 0x0f, 0xbd, 0xed, // bsr r13, r13
+				0x0f, 0x85, 0x06, 0x00, 0x00, 0x00, // jne
+				0x41, 0xbd, 0xff, 0xff, 0xff, 0xff, // mov r13d, 0xffffffff
+				0x41, 0xf7, 0xdd, // neg r13d
+				0x41, 0x81, 0xc5, 0x3f, 0x00, 0x00, 0x00, // add r13d, 63
+			},
+		},
+		{
+			name:   "tzcnt u8 rax",
+			encode: tzcnt,
+			opType: ir.Uint8,
+			dest:   registers.Rax,
+			expected: []byte{
+				0x81, 0xc8, 0x00, 0x01, 0x00, 0x00, // or eax, 256
+				0xf3, 0x0f, 0xbc, 0xc0, // tzcnt eax, eax
+			},
+		},
+		{
+			name:   "tzcnt i32 r11",
+			encode: tzcnt,
+			opType: ir.Int32,
+			dest:   registers.R11,
+			expected: []byte{
+				0xf3, 0x45, 0x0f, 0xbc, 0xdb, // tzcnt r11d, r11d
+			},
+		},
+		{
+			name:   "tzcnt u64 rsi",
+			encode: tzcnt,
+			opType: ir.Uint64,
+			dest:   registers.Rsi,
+			expected: []byte{
+				0xf3, 0x48, 0x0f, 0xbc, 0xf6, // tzcnt rsi, rsi
+			},
+		},
+		{
+			name:   "ctzf i16 rcx",
+			encode: ctzFallback,
+			opType: ir.Int16,
+			dest:   registers.Rcx,
+			expected: []byte{
+				0x81, 0xc9, 0x00, 0x00, 0x01, 0x00, // or ecx, 0x10000
+				0x0f, 0xbc, 0xc9, // bsf ecx, ecx
+			},
+		},
+		{
+			name:   "ctzf u32 rdi",
+			encode: ctzFallback,
+			opType: ir.Uint32,
+			dest:   registers.Rdi,
+			expected: []byte{
+				0x0f, 0xbc, 0xff, // bsf edi, edi
+				0x0f, 0x85, 0x05, 0x00, 0x00, 0x00, // jne
+				0xbf, 0x20, 0x00, 0x00, 0x00, // mov edi, 32
+			},
+		},
+		{
+			name:   "ctzf i64 r14",
+			encode: ctzFallback,
+			opType: ir.Int64,
+			dest:   registers.R14,
+			expected: []byte{
+				0x4d, 0x0f, 0xbc, 0xf6, // bsf r14, r14
+				0x0f, 0x85, 0x06, 0x00, 0x00, 0x00, // jne
+				0x41, 0xbe, 0x40, 0x00, 0x00, 0x00, // mov r14d, 64
+			},
+		},
+		{
+			name:     "bswap u8 rax",
+			encode:   bswap,
+			opType:   ir.Uint8,
+			dest:     registers.Rax,
+			expected: []byte{},
+		},
+		{
+			name:   "bswap i16 rdx",
+			encode: bswap,
+			opType: ir.Int16,
+			dest:   registers.Rdx,
+			expected: []byte{
+				0x66, 0xc1, 0xc2, 0x08, // rol dx, 8
+			},
+		},
+		{
+			name:   "bswap u32 r8",
+			encode: bswap,
+			opType: ir.Uint32,
+			dest:   registers.R8,
+			expected: []byte{
+				0x41, 0x0f, 0xc8, // bswap r8d
+			},
+		},
+		{
+			name:   "bswap i64 rbx",
+			encode: bswap,
+			opType: ir.Int64,
+			dest:   registers.Rbx,
+			expected: []byte{
+				0x48, 0x0f, 0xcb, // bswap rbx
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			test.encode(builder, test.opType, test.dest)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func TestPopcntFallback(t *testing.T) {
+	type testCase struct {
+		name     string
+		opType   ir.Type
+		dest     *architecture.Register
+		scratch  *architecture.Register
+		mask     *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:    "popf u8 rdi rsi",
+			opType:  ir.Uint8,
+			dest:    registers.Rdi,
+			scratch: registers.Rsi,
+			mask:    nil,
+			expected: []byte{
+				0x40, 0x0f, 0xb6, 0xff, // movzx edi, dil
+				0x8b, 0xf7, // mov esi, edi
+				0xc1, 0xee, 0x01, // shr esi, 1
+				0x81, 0xe6, 0x55, 0x55, 0x55, 0x55, // and esi, 0x55555555
+				0x2b, 0xfe, // sub edi, esi
+				0x8b, 0xf7, // mov esi, edi
+				0xc1, 0xee, 0x02, // shr esi, 2
+				0x81, 0xe6, 0x33, 0x33, 0x33, 0x33, // and esi, 0x33333333
+				0x81, 0xe7, 0x33, 0x33, 0x33, 0x33, // and edi, 0x33333333
+				0x03, 0xfe, // add edi, esi
+				0x8b, 0xf7, // mov esi, edi
+				0xc1, 0xee, 0x04, // shr esi, 4
+				0x03, 0xfe, // add edi, esi
+				0x81, 0xe7, 0x0f, 0x0f, 0x0f, 0x0f, // and edi, 0xf0f0f0f
+				0x69, 0xff, 0x01, 0x01, 0x01, 0x01, // imul edi, edi, 0x1010101
+				0xc1, 0xef, 0x18, // shr edi, 24
+			},
+		},
+		{
+			name:    "popf u32 rdx rcx",
+			opType:  ir.Uint32,
+			dest:    registers.Rdx,
+			scratch: registers.Rcx,
+			mask:    nil,
+			expected: []byte{
+				0x8b, 0xca, // mov ecx, edx
+				0xc1, 0xe9, 0x01, // shr ecx, 1
+				0x81, 0xe1, 0x55, 0x55, 0x55, 0x55, // and ecx, 0x55555555
+				0x2b, 0xd1, // sub edx, ecx
+				0x8b, 0xca, // mov ecx, edx
+				0xc1, 0xe9, 0x02, // shr ecx, 2
+				0x81, 0xe1, 0x33, 0x33, 0x33, 0x33, // and ecx, 0x33333333
+				0x81, 0xe2, 0x33, 0x33, 0x33, 0x33, // and edx, 0x33333333
+				0x03, 0xd1, // add edx, ecx
+				0x8b, 0xca, // mov ecx, edx
+				0xc1, 0xe9, 0x04, // shr ecx, 4
+				0x03, 0xd1, // add edx, ecx
+				0x81, 0xe2, 0x0f, 0x0f, 0x0f, 0x0f, // and edx, 0xf0f0f0f
+				0x69, 0xd2, 0x01, 0x01, 0x01, 0x01, // imul edx, edx, 0x1010101
+				0xc1, 0xea, 0x18, // shr edx, 24
+			},
+		},
+		{
+			name:    "popf u64 rax r8 r9",
+			opType:  ir.Uint64,
+			dest:    registers.Rax,
+			scratch: registers.R8,
+			mask:    registers.R9,
+			expected: []byte{
+				0x4c, 0x8b, 0xc0, // mov r8, rax
+				0x49, 0xc1, 0xe8, 0x01, // shr r8, 1
+				// movabs r9, 0x5555555555555555
+				0x49, 0xb9, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55,
+				0x4d, 0x23, 0xc1, // and r8, r9
+				0x49, 0x2b, 0xc0, // sub rax, r8
+				0x4c, 0x8b, 0xc0, // mov r8, rax
+				0x49, 0xc1, 0xe8, 0x02, // shr r8, 2
+				// movabs r9, 0x3333333333333333
+				0x49, 0xb9, 0x33, 0x33, 0x33, 0x33, 0x33, 0x33, 0x33, 0x33,
+				0x4d, 0x23, 0xc1, // and r8, r9
+				0x49, 0x23, 0xc1, // and rax, r9
+				0x49, 0x03, 0xc0, // add rax, r8
+				0x4c, 0x8b, 0xc0, // mov r8, rax
+				0x49, 0xc1, 0xe8, 0x04, // shr r8, 4
+				0x49, 0x03, 0xc0, // add rax, r8
+				// movabs r9, 0xf0f0f0f0f0f0f0f
+				0x49, 0xb9, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
+				0x49, 0x23, 0xc1, // and rax, r9
+				// movabs r9, 0x101010101010101
+				0x49, 0xb9, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+				0x49, 0x0f, 0xaf, 0xc1, // imul rax, r9
+				0x48, 0xc1, 0xe8, 0x38, // shr rax, 56
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			popcntFallback(builder, test.opType, test.dest, test.scratch, test.mask)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func newTestUnaryOperation(
+	kind ir.UnaryOperationKind,
+	valueType ir.Type,
+) (
+	*ir.Definition,
+	*ir.Definition,
+) {
+	src := ir.NewLocalReference("src")
+	srcDef := &ir.Definition{
+		Name: "src",
+		Type: valueType,
+	}
+	src.(*ir.LocalReference).UseDef = srcDef
+
+	dest := &ir.Definition{
+		Type: valueType,
+		Operation: &ir.UnaryOperation{
+			Kind: kind,
+			Src:  src,
+		},
+	}
+
+	return srcDef, dest
+}
+
+func TestSelectPopcntSupported(t *testing.T) {
+	srcDef, dest := newTestUnaryOperation(ir.Popcnt, ir.Uint64)
+
+	config := testConfig
+	config.Features = map[string]bool{PopcntFeature: true}
+
+	instruction := architecture.SelectInstruction(
+		config,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(mOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+	expect.Equal(
+		t,
+		srcDef.Chunks()[0],
+		constraints.RegisterSources[0].DefinitionChunk)
+
+	register := constraints.RegisterSources[0].RegisterConstraint
+	expect.True(t, register.Clobbered)
+	expect.True(t, register.AnyGeneral)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			register: registers.Rdx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0xf3, 0x48, 0x0f, 0xb8, 0xd2}, // popcnt rdx, rdx
+		segment.Content.Flatten())
+}
+
+func TestSelectPopcntFallback(t *testing.T) {
+	for _, valueType := range []ir.Type{ir.Uint64, ir.Int32, ir.Int8} {
+		srcDef, dest := newTestUnaryOperation(ir.Popcnt, valueType)
+
+		instruction := architecture.SelectInstruction(
+			testConfig,
+			dest,
+			architecture.SelectorHint{})
+
+		_, ok := instruction.(popcntFallbackOperation)
+		expect.True(t, ok)
+
+		constraints := instruction.Constraints()
+
+		// 64-bit masks must be loaded into a register.
+		if valueType.Size() == 8 {
+			expect.Equal(t, 3, len(constraints.RegisterSources))
+		} else {
+			expect.Equal(t, 2, len(constraints.RegisterSources))
+		}
+
+		expect.Equal(
+			t,
+			srcDef.Chunks()[0],
+			constraints.RegisterSources[0].DefinitionChunk)
+		for _, scratch := range constraints.RegisterSources[1:] {
+			expect.Nil(t, scratch.DefinitionChunk)
+			expect.True(t, scratch.RegisterConstraint.Clobbered)
+			expect.True(t, scratch.RegisterConstraint.AnyGeneral)
+		}
+
+		expect.Equal(t, 1, len(constraints.RegisterDestinations))
+		expect.True(
+			t,
+			constraints.RegisterSources[0].RegisterConstraint ==
+				constraints.RegisterDestinations[0].RegisterConstraint)
+	}
+}
+
+func TestSelectClz(t *testing.T) {
+	for _, supported := range []bool{false, true} {
+		_, dest := newTestUnaryOperation(ir.Clz, ir.Int32)
+
+		config := testConfig
+		config.Features = map[string]bool{LzcntFeature: supported}
+
+		instruction := architecture.SelectInstruction(
+			config,
+			dest,
+			architecture.SelectorHint{})
+
+		_, ok := instruction.(mOperation)
+		expect.True(t, ok)
+
+		register := instruction.Constraints().RegisterSources[0].RegisterConstraint
+
+		builder := layout.NewSegmentBuilder()
+		instruction.EmitTo(
+			builder,
+			map[*architecture.RegisterConstraint]*architecture.Register{
+				register: registers.Rax,
+			})
+		segment, err := builder.Finalize(amd64.ArchitectureLayout)
+		expect.Nil(t, err)
+
+		if supported {
+			expect.Equal(
+				t,
+				[]byte{0xf3, 0x0f, 0xbd, 0xc0}, // lzcnt eax, eax
+				segment.Content.Flatten())
+		} else {
+			expect.Equal(t, 0x0f, segment.Content.Flatten()[0]) // bsr
+		}
+	}
+}
@@ -101,6 +101,82 @@ func divRemInt(
 	}
 }
 
+// divRemInt with defined semantics.  Division by zero calls the (non-returning)
+// divide by zero handler, and signed MIN / -1 yields (quotient MIN, remainder
+// 0) instead of trapping.
+//
+//	test <divisor>, <divisor>
+//	jne nonZero
+//	and rsp, -16
+//	call <divide by zero handler>
+//	nonZero:
+//	cmp <divisor>, -1           (signed int only)
+//	jne divide                  (signed int only)
+//	neg <rax>                   (signed int only)
+//	xor edx, edx                (signed int only)
+//	jmp end                     (signed int only)
+//	divide:
+//	<divRemInt>
+//	end:
+//
+// NOTE: -x is x for x = MIN, and -x is x / -1 for all other x.
+//
+// NOTE: the handler is called from an arbitrary stack position.  The stack
+// pointer is aligned prior to the call, which is safe since the handler never
+// returns.
+func divRemIntDefined(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	divisor *architecture.Register,
+	divideByZeroHandler string,
+) {
+	nonZero := "non zero"
+	divide := "divide"
+	end := "end"
+
+	instructions := layout.NewSegmentBuilder()
+
+	testInt(instructions, simpleType, divisor, divisor)
+	d32Instruction(
+		instructions,
+		[]byte{0x0F, 0x85}, // jne
+		layout.BasicBlockKind,
+		nonZero)
+	alignStackPointer(instructions)
+	callSymbol(instructions, divideByZeroHandler)
+
+	defineInlinedLabel(instructions, nonZero)
+
+	_, isSigned := simpleType.(*ir.SignedIntType)
+	if isSigned {
+		var minusOne interface{}
+		switch simpleType.Size() {
+		case 1:
+			minusOne = int8(-1)
+		case 2:
+			minusOne = int16(-1)
+		case 4:
+			minusOne = int32(-1)
+		case 8:
+			minusOne = int64(-1)
+		default:
+			panic("should never happen")
+		}
+
+		jneIntImmediate(instructions, divide, simpleType, divisor, minusOne)
+		negSignedInt(instructions, simpleType, registers.Rax)
+		setImmediate(instructions, registers.Rdx, int32(0))
+		jump(instructions, end)
+	}
+
+	defineInlinedLabel(instructions, divide)
+	divRemInt(instructions, simpleType, divisor)
+
+	defineInlinedLabel(instructions, end)
+
+	appendInlinedInstructions(builder, instructions)
+}
+
 // <float dest> /= <float src>
 //
 // https://www.felixcloutier.com/x86/divss
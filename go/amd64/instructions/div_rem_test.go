@@ -700,3 +700,225 @@ func TestDivFloat(t *testing.T) {
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
+
+func TestDivRemUint64Defined(t *testing.T) {
+	// test rbx, rbx
+	// jne nonZero
+	// and rsp, -16
+	// call <handler>
+	// nonZero:
+	// xor edx, edx
+	// div rbx
+	builder := layout.NewSegmentBuilder()
+	divRemIntDefined(builder, ir.Uint64, registers.Rbx, "divide by zero")
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x48, 0x85, 0xdb, // test
+			0x0f, 0x85, 12, 0, 0, 0, // jne
+			0x48, 0x81, 0xe4, 0xf0, 0xff, 0xff, 0xff, // and
+			0xe8, 0, 0, 0, 0, // call
+			0x33, 0xd2, // xor
+			0x48, 0xf7, 0xf3, // div
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, 0, len(segment.Relocations.Labels))
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{
+				Name:   "divide by zero",
+				Offset: 17,
+			},
+		},
+		segment.Relocations.Symbols)
+}
+
+func TestDivRemInt32Defined(t *testing.T) {
+	// test esi, esi
+	// jne nonZero
+	// and rsp, -16
+	// call <handler>
+	// nonZero:
+	// cmp esi, -1
+	// jne divide
+	// neg eax
+	// xor edx, edx
+	// jmp end
+	// divide:
+	// cdq
+	// idiv esi
+	// end:
+	builder := layout.NewSegmentBuilder()
+	divRemIntDefined(builder, ir.Int32, registers.Rsi, "divide by zero")
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x85, 0xf6, // test
+			0x0f, 0x85, 12, 0, 0, 0, // jne
+			0x48, 0x81, 0xe4, 0xf0, 0xff, 0xff, 0xff, // and
+			0xe8, 0, 0, 0, 0, // call
+			0x81, 0xfe, 0xff, 0xff, 0xff, 0xff, // cmp
+			0x0f, 0x85, 9, 0, 0, 0, // jne
+			0xf7, 0xd8, // neg
+			0x33, 0xd2, // xor
+			0xe9, 3, 0, 0, 0, // jmp
+			0x99,       // cdq
+			0xf7, 0xfe, // idiv
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, 0, len(segment.Relocations.Labels))
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{
+				Name:   "divide by zero",
+				Offset: 16,
+			},
+		},
+		segment.Relocations.Symbols)
+}
+
+func TestDivRemInt8Defined(t *testing.T) {
+	// test bpl, bpl
+	// jne nonZero
+	// and rsp, -16
+	// call <handler>
+	// nonZero:
+	// cmp bpl, -1
+	// jne divide
+	// neg al
+	// xor edx, edx
+	// jmp end
+	// divide:
+	// movsx eax, al
+	// movsx ebp, bpl
+	// cdq
+	// idiv ebp
+	// end:
+	builder := layout.NewSegmentBuilder()
+	divRemIntDefined(builder, ir.Int8, registers.Rbp, "divide by zero")
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x40, 0x84, 0xed, // test
+			0x0f, 0x85, 12, 0, 0, 0, // jne
+			0x48, 0x81, 0xe4, 0xf0, 0xff, 0xff, 0xff, // and
+			0xe8, 0, 0, 0, 0, // call
+			0x40, 0x80, 0xfd, 0xff, // cmp
+			0x0f, 0x85, 9, 0, 0, 0, // jne
+			0xf6, 0xd8, // neg
+			0x33, 0xd2, // xor
+			0xe9, 10, 0, 0, 0, // jmp
+			0x0f, 0xbe, 0xc0, // movsx
+			0x40, 0x0f, 0xbe, 0xed, // movsx
+			0x99,       // cdq
+			0xf7, 0xfd, // idiv
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, 0, len(segment.Relocations.Labels))
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{
+				Name:   "divide by zero",
+				Offset: 17,
+			},
+		},
+		segment.Relocations.Symbols)
+}
+
+func TestRemIntDefined(t *testing.T) {
+	src1 := ir.NewLocalReference("src1")
+	src1Def := &ir.Definition{
+		Name: "src1",
+		Type: ir.Int64,
+	}
+	src1.(*ir.LocalReference).UseDef = src1Def
+
+	src2 := ir.NewLocalReference("src2")
+	src2Def := &ir.Definition{
+		Name: "src2",
+		Type: ir.Int64,
+	}
+	src2.(*ir.LocalReference).UseDef = src2Def
+
+	dest := &ir.Definition{
+		Type: ir.Int64,
+		Operation: &ir.BinaryOperation{
+			Kind: ir.Rem,
+			Src1: src1,
+			Src2: src2,
+		},
+	}
+
+	config := testConfig
+	config.DefinedSemantics = true
+	config.DivideByZeroHandler = "divide by zero"
+
+	instruction := architecture.SelectInstruction(
+		config,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(divRemOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 3, len(constraints.RegisterSources))
+	expect.Nil(t, constraints.RegisterSources[0].DefinitionChunk)
+
+	rdx := constraints.RegisterSources[0].RegisterConstraint
+	rax := constraints.RegisterSources[1].RegisterConstraint
+	divisor := constraints.RegisterSources[2].RegisterConstraint
+
+	expect.Equal(t, 1, len(constraints.RegisterDestinations))
+	destRegister := constraints.RegisterDestinations[0].RegisterConstraint
+	expect.True(t, rdx == destRegister)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			rdx:     registers.Rdx,
+			rax:     registers.Rax,
+			divisor: registers.R8,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x4d, 0x85, 0xc0, // test r8, r8
+			0x0f, 0x85, 12, 0, 0, 0, // jne nonZero
+			0x48, 0x81, 0xe4, 0xf0, 0xff, 0xff, 0xff, // and rsp, -16
+			0xe8, 0, 0, 0, 0, // call <handler>
+			0x49, 0x81, 0xf8, 0xff, 0xff, 0xff, 0xff, // nonZero: cmp r8, -1
+			0x0f, 0x85, 10, 0, 0, 0, // jne divide
+			0x48, 0xf7, 0xd8, // neg rax
+			0x33, 0xd2, // xor edx, edx
+			0xe9, 5, 0, 0, 0, // jmp end
+			0x48, 0x99, // divide: cqo
+			0x49, 0xf7, 0xf8, // idiv r8
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{
+				Name:   "divide by zero",
+				Offset: 17,
+			},
+		},
+		segment.Relocations.Symbols)
+}
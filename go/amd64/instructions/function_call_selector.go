@@ -0,0 +1,73 @@
+package instructions
+
+import (
+	"fmt"
+
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// The call is of the form:
+//
+//	call <rel32 function symbol>  (direct call via global function reference)
+//	call <function address>       (indirect call via function value)
+//
+// The argument / return value placement, the base pointer, and the caller-
+// saved register evictions are all expressed as the call convention's
+// constraints (see architecture.CallConvention's CallConstraints).
+type functionCallOperation struct {
+	*ir.Definition
+
+	convention *architecture.CallConvention
+
+	architecture.InstructionConstraints
+}
+
+func (op functionCallOperation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op functionCallOperation) Constraints() architecture.InstructionConstraints {
+	return op.InstructionConstraints
+}
+
+func (op functionCallOperation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	switch function := op.Operation.(*ir.FunctionCall).Function.(type) {
+	case *ir.GlobalReference:
+		callSymbol(builder, function.Name)
+	case *ir.LocalReference:
+		callAddress(builder, selectedRegisters[op.convention.FunctionAddress])
+	default:
+		panic("should never happen")
+	}
+}
+
+type functionCallSelector struct{}
+
+func (functionCallSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	call *ir.FunctionCall,
+) architecture.MachineInstruction {
+	switch call.Function.(type) {
+	case *ir.GlobalReference, *ir.LocalReference:
+	default:
+		panic(fmt.Sprintf("invalid function call target: %#v", call.Function))
+	}
+
+	functionType, ok := call.Function.Type().(*ir.FunctionType)
+	if !ok {
+		panic(fmt.Sprintf("invalid function type: %v", call.Function.Type()))
+	}
+
+	convention := config.CallConventions.Compute(functionType)
+	return functionCallOperation{
+		Definition:             def,
+		convention:             convention,
+		InstructionConstraints: convention.CallConstraints(config, def, call),
+	}
+}
@@ -0,0 +1,345 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	"github.com/pattyshack/chickadee/amd64/call"
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+var (
+	callTestConfig = architecture.Config{
+		Registers:       registers.Registers,
+		InstructionSet:  InstructionSet,
+		CallConventions: call.Conventions,
+	}
+)
+
+func newTestLocalValue(
+	name string,
+	valueType ir.Type,
+) (
+	ir.Value,
+	*ir.Definition,
+) {
+	def := &ir.Definition{
+		Name: name,
+		Type: valueType,
+	}
+
+	ref := ir.NewLocalReference(name)
+	ref.(*ir.LocalReference).UseDef = def
+	return ref, def
+}
+
+// Returns the call instruction (within a function body) and the caller's
+// current frame pointer definition.
+func newTestFunctionCall(
+	function ir.Value,
+	arguments []ir.Value,
+	returnType ir.Type,
+) (
+	*ir.Definition,
+	*ir.Definition,
+) {
+	instruction := &ir.Definition{
+		Name: "ret",
+		Type: returnType,
+		Operation: &ir.FunctionCall{
+			Kind:      ir.Call,
+			Function:  function,
+			Arguments: arguments,
+		},
+	}
+
+	block := &ir.Block{
+		Operations: []*ir.Definition{instruction},
+	}
+
+	framePtrDef := &ir.Definition{
+		Name:               ir.CurrentFramePointer,
+		Type:               ir.NewVariableLengthArrayAddressType(ir.Int8),
+		IsPseudoDefinition: true,
+	}
+
+	funcDef := &ir.FunctionDefinition{
+		Name: "caller",
+		Type: ir.NewFunctionType(
+			ir.SysVLiteCallConvention,
+			nil,
+			ir.NewStructType(nil)),
+		Blocks:              []*ir.Block{block},
+		CurrentFramePointer: framePtrDef,
+	}
+
+	instruction.Block = block
+	block.Function = funcDef
+
+	return instruction, framePtrDef
+}
+
+func TestSelectDirectFunctionCall(t *testing.T) {
+	funcType := ir.NewFunctionType(
+		ir.SysVLiteCallConvention,
+		[]ir.Type{ir.Int64},
+		ir.Int64)
+
+	function := ir.NewGlobalReference("callee")
+	function.(*ir.GlobalReference).PseudoDefinition = &ir.Definition{
+		Name: "callee",
+		Type: funcType,
+	}
+
+	arg, argDef := newTestLocalValue("arg", ir.Int64)
+
+	dest, framePtrDef := newTestFunctionCall(
+		function,
+		[]ir.Value{arg},
+		ir.Int64)
+
+	instruction := architecture.SelectInstruction(
+		callTestConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(functionCallOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(
+		t,
+		architecture.RegisterMapping{
+			RegisterConstraint: &architecture.RegisterConstraint{
+				Require: registers.Rbp,
+			},
+			DefinitionChunk: framePtrDef.Chunks()[0],
+		},
+		constraints.RegisterSources[0])
+	expect.Equal(
+		t,
+		architecture.RegisterMapping{
+			RegisterConstraint: &architecture.RegisterConstraint{
+				Clobbered: true,
+				Require:   registers.Rdi,
+			},
+			DefinitionChunk: argDef.Chunks()[0],
+		},
+		constraints.RegisterSources[1])
+	for _, source := range constraints.RegisterSources[2:] {
+		// evicted caller-saved registers
+		expect.True(t, source.Clobbered)
+		expect.Nil(t, source.DefinitionChunk)
+	}
+	expect.Equal(
+		t,
+		[]architecture.RegisterMapping{
+			{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered: true,
+					Require:   registers.Rax,
+				},
+				DefinitionChunk: dest.Chunks()[0],
+			},
+		},
+		constraints.RegisterDestinations)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0xe8, 0, 0, 0, 0}, // call callee
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "callee",
+					Offset: 1,
+				},
+			},
+		},
+		segment.Relocations)
+}
+
+func TestSelectIndirectFunctionCall(t *testing.T) {
+	funcType := ir.NewFunctionType(
+		ir.SysVLiteCallConvention,
+		nil,
+		ir.Float64)
+
+	// funcPtr = callee
+	ref := ir.NewGlobalReference("callee")
+	ref.(*ir.GlobalReference).PseudoDefinition = &ir.Definition{
+		Name: "callee",
+		Type: funcType,
+	}
+
+	funcPtrDef := &ir.Definition{
+		Name:      "funcPtr",
+		Type:      funcType,
+		Operation: ref,
+	}
+
+	function := ir.NewLocalReference("funcPtr")
+	function.(*ir.LocalReference).UseDef = funcPtrDef
+
+	// ret = funcPtr()
+	dest, _ := newTestFunctionCall(function, nil, ir.Float64)
+
+	funcPtr := architecture.SelectInstruction(
+		callTestConfig,
+		funcPtrDef,
+		architecture.SelectorHint{})
+
+	instruction := architecture.SelectInstruction(
+		callTestConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(functionCallOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(
+		t,
+		architecture.RegisterMapping{
+			RegisterConstraint: &architecture.RegisterConstraint{
+				Clobbered: true,
+				Require:   registers.R11,
+			},
+			DefinitionChunk: funcPtrDef.Chunks()[0],
+		},
+		constraints.RegisterSources[0])
+	expect.Equal(
+		t,
+		[]architecture.RegisterMapping{
+			{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered: true,
+					Require:   registers.Xmm0,
+				},
+				DefinitionChunk: dest.Chunks()[0],
+			},
+		},
+		constraints.RegisterDestinations)
+
+	funcPtrDest := funcPtr.Constraints().RegisterDestinations[0]
+
+	builder := layout.NewSegmentBuilder()
+	funcPtr.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			funcPtrDest.RegisterConstraint: registers.R11,
+		})
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			constraints.RegisterSources[0].RegisterConstraint: registers.R11,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x4c, 0x8d, 0x1d, 0, 0, 0, 0, // lea r11, [rip + callee]
+			0x41, 0xff, 0xd3, // call r11
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "callee",
+					Offset: 3,
+				},
+			},
+		},
+		segment.Relocations)
+}
+
+func TestSelectFunctionCallWithFunctionValueArguments(t *testing.T) {
+	callbackType := ir.NewFunctionType(
+		ir.SysVLiteCallConvention,
+		[]ir.Type{ir.Int32},
+		ir.Int32)
+
+	// A struct holding a function value, e.g., a closure.
+	closureType := ir.NewStructType([]ir.Field{
+		{
+			Name: "callback",
+			Type: callbackType,
+		},
+		{
+			Name: "context",
+			Type: ir.NewAddressType(ir.Int32),
+		},
+	})
+
+	funcType := ir.NewFunctionType(
+		ir.SysVLiteCallConvention,
+		[]ir.Type{callbackType, closureType},
+		callbackType)
+
+	function := ir.NewGlobalReference("register")
+	function.(*ir.GlobalReference).PseudoDefinition = &ir.Definition{
+		Name: "register",
+		Type: funcType,
+	}
+
+	callback, callbackDef := newTestLocalValue("callback", callbackType)
+	closure, closureDef := newTestLocalValue("closure", closureType)
+
+	dest, _ := newTestFunctionCall(
+		function,
+		[]ir.Value{callback, closure},
+		callbackType)
+
+	instruction := architecture.SelectInstruction(
+		callTestConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+
+	expected := []struct {
+		*ir.DefinitionChunk
+		*architecture.Register
+	}{
+		{callbackDef.Chunks()[0], registers.Rdi},
+		{closureDef.Chunks()[0], registers.Rsi},
+		{closureDef.Chunks()[1], registers.Rdx},
+	}
+
+	for idx, entry := range expected {
+		source := constraints.RegisterSources[idx+1] // skip base pointer
+		expect.Equal(t, entry.DefinitionChunk, source.DefinitionChunk)
+		expect.Equal(t, entry.Register, source.Require)
+	}
+
+	expect.Equal(
+		t,
+		[]architecture.RegisterMapping{
+			{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered: true,
+					Require:   registers.Rax,
+				},
+				DefinitionChunk: dest.Chunks()[0],
+			},
+		},
+		constraints.RegisterDestinations)
+}
@@ -0,0 +1,57 @@
+package instructions
+
+import (
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// lea <dest>, [rip + <function symbol>]
+type functionAddressOperation struct {
+	*ir.Definition
+
+	architecture.InstructionConstraints
+}
+
+func (op functionAddressOperation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op functionAddressOperation) Constraints() architecture.InstructionConstraints {
+	return op.InstructionConstraints
+}
+
+func (op functionAddressOperation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	computeSymbolAddress(
+		builder,
+		selectedRegisters[op.RegisterDestinations[0].RegisterConstraint],
+		op.Operation.(*ir.GlobalReference).Name,
+		0)
+}
+
+type functionAddressSelector struct{}
+
+func (functionAddressSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	ref *ir.GlobalReference,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	return functionAddressOperation{
+		Definition: def,
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						Clobbered:  true,
+						AnyGeneral: true,
+					},
+					DefinitionChunk: def.Chunks()[0],
+				},
+			},
+		},
+	}
+}
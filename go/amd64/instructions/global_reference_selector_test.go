@@ -0,0 +1,81 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+func TestSelectFunctionAddress(t *testing.T) {
+	funcType := ir.NewFunctionType(
+		ir.SysVLiteCallConvention,
+		[]ir.Type{ir.Int64},
+		ir.Int64)
+
+	ref := ir.NewGlobalReference("callee")
+	ref.(*ir.GlobalReference).PseudoDefinition = &ir.Definition{
+		Name: "callee",
+		Type: funcType,
+	}
+
+	dest := &ir.Definition{
+		Name:      "funcPtr",
+		Type:      funcType,
+		Operation: ref,
+	}
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(functionAddressOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(
+		t,
+		architecture.InstructionConstraints{
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						Clobbered:  true,
+						AnyGeneral: true,
+					},
+					DefinitionChunk: dest.Chunks()[0],
+				},
+			},
+		},
+		constraints)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			constraints.RegisterDestinations[0].RegisterConstraint: registers.R11,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{0x4c, 0x8d, 0x1d, 0, 0, 0, 0}, // lea r11, [rip + callee]
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "callee",
+					Offset: 3,
+				},
+			},
+		},
+		segment.Relocations)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
 	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
 )
@@ -20,6 +21,7 @@ import (
 // all uses intel syntax.
 
 const (
+	lockPrefix           = 0xf0
 	operandSizePrefix    = 0x66
 	addressSizePrefix    = 0x67
 	float32OperandPrefix = 0xf3
@@ -39,6 +41,7 @@ const (
 )
 
 type modRMSpec struct {
+	requireLockPrefix        bool // (0xf0) atomic read-modify-write operation
 	requireOperandSizePrefix bool // (0x66) 16-bit int (and some float) operation
 	requireAddressSizePrefix bool // (0x67)
 	requireFloat32Prefix     bool // (0xf3) SSE2 float32 operations
@@ -68,8 +71,12 @@ func (spec *modRMSpec) maybeSetRexPrefix(xReg int) {
 }
 
 func (spec modRMSpec) encode(builder *layout.SegmentBuilder) {
-	// 6 for prefixes and modRM suffix
-	instruction := make([]byte, 0, 6+len(spec.opCode)+len(spec.sibAndOrImmediate))
+	// 7 for prefixes and modRM suffix
+	instruction := make([]byte, 0, 7+len(spec.opCode)+len(spec.sibAndOrImmediate))
+
+	if spec.requireLockPrefix {
+		instruction = append(instruction, lockPrefix)
+	}
 
 	if spec.requireOperandSizePrefix {
 		instruction = append(instruction, operandSizePrefix)
@@ -493,3 +500,54 @@ func d32Instruction(
 
 	builder.AppendData(bytes, layout.Definitions{}, relocations)
 }
+
+// Define a basic block label at the current end of the instructions.
+//
+// NOTE: the label must be unique within the function.  Use defineInlinedLabel
+// for labels that are only referenced locally.
+func defineLabel(builder *layout.SegmentBuilder, label string) {
+	builder.AppendData(
+		nil,
+		layout.Definitions{
+			Labels: []*layout.Symbol{
+				{
+					Kind:   layout.BasicBlockKind,
+					Name:   label,
+					Offset: 0,
+				},
+			},
+		},
+		layout.Relocations{})
+}
+
+// Define a basic block label at the current end of the inlined instructions.
+// The label is only visible within the inlined instructions (see
+// appendInlinedInstructions).
+func defineInlinedLabel(instructions *layout.SegmentBuilder, label string) {
+	defineLabel(instructions, label)
+}
+
+// Resolve the inlined instructions' label relocations and append the
+// instructions to the builder.  All label relocations must refer to inlined
+// labels.  The inlined labels are dropped, but symbol relocations (e.g., call
+// to a function) are preserved.
+func appendInlinedInstructions(
+	builder *layout.SegmentBuilder,
+	instructions *layout.SegmentBuilder,
+) {
+	segment, err := instructions.Finalize(amd64.ArchitectureLayout)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(segment.Relocations.Labels) > 0 ||
+		len(segment.Definitions.Symbols) > 0 {
+
+		panic("should never happen")
+	}
+
+	// Drop inlined labels
+	segment.Definitions = layout.Definitions{}
+
+	builder.Append(segment)
+}
@@ -4,6 +4,13 @@ import (
 	"github.com/pattyshack/chickadee/platform/architecture"
 )
 
+// Optional cpu features (see architecture.Config's Features)
+const (
+	PopcntFeature = "popcnt"
+	LzcntFeature  = "lzcnt"
+	Bmi1Feature   = "bmi1" // tzcnt
+)
+
 var InstructionSet = architecture.InstructionSet{
 	Jump: jumpSelector{},
 
@@ -11,6 +18,7 @@ var InstructionSet = architecture.InstructionSet{
 		isFloat:              false,
 		encodeRightImmediate: jeIntImmediate,
 		encodeLeftImmediate:  jeIntImmediate,
+		encodeZeroAddress:    jeZero,
 		encode:               je,
 	},
 	JeqInt: conditionalJumpSelector{
@@ -28,6 +36,7 @@ var InstructionSet = architecture.InstructionSet{
 		isFloat:              false,
 		encodeRightImmediate: jneIntImmediate,
 		encodeLeftImmediate:  jneIntImmediate,
+		encodeZeroAddress:    jneZero,
 		encode:               jne,
 	},
 	JneInt: conditionalJumpSelector{
@@ -109,6 +118,39 @@ var InstructionSet = architecture.InstructionSet{
 		encode:  jge,
 	},
 
+	JaddOverflowUint: overflowJumpSelector{
+		encode: jaddOverflow,
+	},
+	JaddOverflowInt: overflowJumpSelector{
+		encode: jaddOverflow,
+	},
+
+	JsubOverflowUint: overflowJumpSelector{
+		encode: jsubOverflow,
+	},
+	JsubOverflowInt: overflowJumpSelector{
+		encode: jsubOverflow,
+	},
+
+	JmulOverflowUint: overflowJumpSelector{
+		isMul:  true,
+		encode: jmulOverflow,
+	},
+	JmulOverflowInt: overflowJumpSelector{
+		isMul:  true,
+		encode: jmulOverflow,
+	},
+
+	SwitchUint: switchSelector{},
+	SwitchInt:  switchSelector{},
+
+	Trap: terminalSelector{
+		encode: trap,
+	},
+	Unreachable: terminalSelector{
+		encode: unreachable,
+	},
+
 	NotUint: unaryMSelector{
 		encodeM: not,
 	},
@@ -125,6 +167,66 @@ var InstructionSet = architecture.InstructionSet{
 		},
 	},
 
+	PopcntUint: featureSelector{
+		feature: PopcntFeature,
+		supported: unaryMSelector{
+			encodeM: popcnt,
+		},
+		fallback: popcntFallbackSelector{},
+	},
+	PopcntInt: featureSelector{
+		feature: PopcntFeature,
+		supported: unaryMSelector{
+			encodeM: popcnt,
+		},
+		fallback: popcntFallbackSelector{},
+	},
+
+	ClzUint: featureSelector{
+		feature: LzcntFeature,
+		supported: unaryMSelector{
+			encodeM: lzcnt,
+		},
+		fallback: unaryMSelector{
+			encodeM: clzFallback,
+		},
+	},
+	ClzInt: featureSelector{
+		feature: LzcntFeature,
+		supported: unaryMSelector{
+			encodeM: lzcnt,
+		},
+		fallback: unaryMSelector{
+			encodeM: clzFallback,
+		},
+	},
+
+	CtzUint: featureSelector{
+		feature: Bmi1Feature,
+		supported: unaryMSelector{
+			encodeM: tzcnt,
+		},
+		fallback: unaryMSelector{
+			encodeM: ctzFallback,
+		},
+	},
+	CtzInt: featureSelector{
+		feature: Bmi1Feature,
+		supported: unaryMSelector{
+			encodeM: tzcnt,
+		},
+		fallback: unaryMSelector{
+			encodeM: ctzFallback,
+		},
+	},
+
+	BswapUint: unaryMSelector{
+		encodeM: bswap,
+	},
+	BswapInt: unaryMSelector{
+		encodeM: bswap,
+	},
+
 	UintToUint: conversionSelector{
 		srcIsFloat:       false,
 		destIsFloat:      false,
@@ -135,10 +237,12 @@ var InstructionSet = architecture.InstructionSet{
 		destIsFloat:      false,
 		encodeConversion: convertIntToInt,
 	},
-	FloatToUint: conversionSelector{
-		srcIsFloat:       true,
-		destIsFloat:      false,
-		encodeConversion: convertFloatToInt,
+	FloatToUint: floatToUintSelector{
+		smallUint: conversionSelector{
+			srcIsFloat:       true,
+			destIsFloat:      false,
+			encodeConversion: convertFloatToSmallUint,
+		},
 	},
 
 	UintToInt: conversionSelector{
@@ -249,21 +353,56 @@ var InstructionSet = architecture.InstructionSet{
 	},
 
 	ShlUint: shiftSelector{
-		encodeMI8: shlIntImmediate,
-		encodeMC:  shl,
+		encodeMI8:        shlIntImmediate,
+		encodeMC:         shl,
+		encodeDefinedMI8: shlIntImmediateDefined,
+		encodeDefinedMC:  shlDefined,
 	},
 	ShlInt: shiftSelector{
-		encodeMI8: shlIntImmediate,
-		encodeMC:  shl,
+		encodeMI8:        shlIntImmediate,
+		encodeMC:         shl,
+		encodeDefinedMI8: shlIntImmediateDefined,
+		encodeDefinedMC:  shlDefined,
 	},
 
 	ShrUint: shiftSelector{
-		encodeMI8: shrIntImmediate,
-		encodeMC:  shr,
+		encodeMI8:        shrIntImmediate,
+		encodeMC:         shr,
+		encodeDefinedMI8: shrIntImmediateDefined,
+		encodeDefinedMC:  shrDefined,
 	},
 	ShrInt: shiftSelector{
-		encodeMI8: shrIntImmediate,
-		encodeMC:  shr,
+		encodeMI8:        shrIntImmediate,
+		encodeMC:         shr,
+		encodeDefinedMI8: shrIntImmediateDefined,
+		encodeDefinedMC:  shrDefined,
+	},
+
+	// NOTE: rotation is always well defined.
+	RotlUint: shiftSelector{
+		encodeMI8:        rolIntImmediate,
+		encodeMC:         rol,
+		encodeDefinedMI8: rolIntImmediate,
+		encodeDefinedMC:  rol,
+	},
+	RotlInt: shiftSelector{
+		encodeMI8:        rolIntImmediate,
+		encodeMC:         rol,
+		encodeDefinedMI8: rolIntImmediate,
+		encodeDefinedMC:  rol,
+	},
+
+	RotrUint: shiftSelector{
+		encodeMI8:        rorIntImmediate,
+		encodeMC:         ror,
+		encodeDefinedMI8: rorIntImmediate,
+		encodeDefinedMC:  ror,
+	},
+	RotrInt: shiftSelector{
+		encodeMI8:        rorIntImmediate,
+		encodeMC:         ror,
+		encodeDefinedMI8: rorIntImmediate,
+		encodeDefinedMC:  ror,
 	},
 
 	AndUint: commonBinaryOperationSelector{
@@ -304,4 +443,19 @@ var InstructionSet = architecture.InstructionSet{
 		encodeMI:    xorIntImmediate,
 		encodeRM:    xor,
 	},
+
+	AtomicLoad:  atomicLoadSelector{},
+	AtomicStore: atomicStoreSelector{},
+	AtomicExchange: atomicReadModifyWriteSelector{
+		encode: exchange,
+	},
+	AtomicCompareExchange: atomicCompareExchangeSelector{},
+	AtomicFetchAdd: atomicReadModifyWriteSelector{
+		encode: fetchAdd,
+	},
+	Fence: fenceSelector{},
+
+	FunctionAddress: functionAddressSelector{},
+
+	Call: functionCallSelector{},
 }
@@ -48,20 +48,48 @@ func computeSymbolAddress(
 	spec.mode = indirectDisp0ModRMMode
 	spec.encode(builder)
 
-	displacement := make([]byte, 4)
-	_, err := binary.Encode(displacement, binary.LittleEndian, offset)
-	if err != nil {
-		panic(err)
-	}
-
 	// The displacement bytes, to be relocated.
 	builder.AppendData(
-		displacement,
+		make([]byte, 4),
 		layout.Definitions{},
 		layout.Relocations{
 			Symbols: []*layout.Relocation{
 				{
-					Name: symbolName,
+					Name:   symbolName,
+					Addend: int64(offset),
+				},
+			},
+		})
+}
+
+// <general dest> = <label's address> = <RIP> + <disp32 relocation>
+//
+// https://www.felixcloutier.com/x86/lea
+//
+// 64 dest (RM Op/En): REX.W + 8D /r
+func computeLabelAddress(
+	builder *layout.SegmentBuilder,
+	dest *architecture.Register,
+	label string,
+) {
+	// NOTE: see computeSymbolAddress for [RIP + disp32] encoding.
+	spec := newRM(
+		false,
+		8, // address size
+		[]byte{0x8D},
+		dest,
+		registers.Rbp)
+	spec.mode = indirectDisp0ModRMMode
+	spec.encode(builder)
+
+	// The displacement bytes, to be relocated.
+	builder.AppendData(
+		make([]byte, 4),
+		layout.Definitions{},
+		layout.Relocations{
+			Labels: []*layout.Relocation{
+				{
+					Name: label,
 				},
 			},
 		})
@@ -166,3 +194,26 @@ func deallocateStackFrame(
 
 	_updateStack(builder, size)
 }
+
+// The stack pointer must be aligned to 16 bytes prior to function calls.
+const stackAlignment = 16
+
+// <RSP> &= -<stack alignment>
+//
+// NOTE: used for aligning the stack prior to calling non-returning functions
+// (e.g., the divide by zero handler) from arbitrary stack positions.
+//
+// https://www.felixcloutier.com/x86/and
+//
+// 64-bit (MI Op/En): REX.W 81 /4 id
+func alignStackPointer(builder *layout.SegmentBuilder) {
+	spec := newMI(
+		false, // isUnsigned
+		8,     // address size
+		[]byte{0x81},
+		4,             // op code extension
+		registers.Rax, // placeholder for rsp
+		int64(-stackAlignment))
+	spec.rm = registers.RspEncoding
+	spec.encode(builder)
+}
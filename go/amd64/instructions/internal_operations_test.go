@@ -11,14 +11,14 @@ import (
 )
 
 func TestComputeSymbolAddress(t *testing.T) {
-	// lea rdx, [rip + 0x12345678]
+	// lea rdx, [rip + 0x12345678] (displacement relocated with addend)
 	builder := layout.NewSegmentBuilder()
 	computeSymbolAddress(builder, registers.Rdx, "symbol", int32(0x12345678))
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0x48, 0x8d, 0x15, 0x78, 0x56, 0x34, 0x12},
+		[]byte{0x48, 0x8d, 0x15, 0, 0, 0, 0},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(
@@ -28,19 +28,20 @@ func TestComputeSymbolAddress(t *testing.T) {
 				{
 					Name:   "symbol",
 					Offset: 3,
+					Addend: 0x12345678,
 				},
 			},
 		},
 		segment.Relocations)
 
-	// lea rbp, [rip + 0x01020304]
+	// lea rbp, [rip + 0x01020304] (displacement relocated with addend)
 	builder = layout.NewSegmentBuilder()
 	computeSymbolAddress(builder, registers.Rbp, "symbol2", int32(0x01020304))
 	segment, err = builder.Finalize(amd64.ArchitectureLayout)
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0x48, 0x8d, 0x2d, 0x04, 0x03, 0x02, 0x01},
+		[]byte{0x48, 0x8d, 0x2d, 0, 0, 0, 0},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(
@@ -50,6 +51,7 @@ func TestComputeSymbolAddress(t *testing.T) {
 				{
 					Name:   "symbol2",
 					Offset: 3,
+					Addend: 0x01020304,
 				},
 			},
 		},
@@ -661,6 +661,7 @@ func TestJeqFloat(t *testing.T) {
 		t,
 		[]byte{
 			0x66, 0x0f, 0x2f, 0xca,
+			0x7a, 0x06,
 			0x0f, 0x84, 0, 0, 0, 0,
 		},
 		segment.Content.Flatten())
@@ -671,7 +672,7 @@ func TestJeqFloat(t *testing.T) {
 			Labels: []*layout.Relocation{
 				{
 					Name:   "jeq-label",
-					Offset: 6,
+					Offset: 8,
 				},
 			},
 		},
@@ -586,8 +586,8 @@ func TestJltFloat(t *testing.T) {
 	expect.Equal(
 		t,
 		[]byte{
-			0x66, 0x0f, 0x2f, 0xca,
-			0x0f, 0x82, 0, 0, 0, 0,
+			0x66, 0x0f, 0x2f, 0xd1,
+			0x0f, 0x87, 0, 0, 0, 0,
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -588,6 +588,7 @@ func TestJneFloat(t *testing.T) {
 		[]byte{
 			0x66, 0x0f, 0x2f, 0xca,
 			0x0f, 0x85, 0, 0, 0, 0,
+			0x0f, 0x8a, 0, 0, 0, 0,
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -599,6 +600,10 @@ func TestJneFloat(t *testing.T) {
 					Name:   "jne-label",
 					Offset: 6,
 				},
+				{
+					Name:   "jne-label",
+					Offset: 12,
+				},
 			},
 		},
 		segment.Relocations)
@@ -6,6 +6,7 @@ import (
 	"github.com/pattyshack/gt/testing/expect"
 
 	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
 	"github.com/pattyshack/chickadee/ir"
 	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
@@ -47,3 +48,221 @@ func TestSelectJump(t *testing.T) {
 		},
 		segment.Relocations)
 }
+
+func TestSelectTerminal(t *testing.T) {
+	type testCase struct {
+		kind     ir.TerminalKind
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			kind:     ir.Trap,
+			expected: []byte{0x0f, 0x0b}, // ud2
+		},
+		{
+			kind:     ir.Unreachable,
+			expected: []byte{0xcc}, // int3
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(string(test.kind), func(t *testing.T) {
+			terminal := &ir.Terminal{
+				Kind: test.kind,
+			}
+
+			instruction := architecture.SelectInstruction(
+				testConfig,
+				terminal,
+				architecture.SelectorHint{})
+
+			_, ok := instruction.(terminalInstruction)
+			expect.True(t, ok)
+
+			expect.Equal(
+				t,
+				architecture.InstructionConstraints{},
+				instruction.Constraints())
+
+			builder := layout.NewSegmentBuilder()
+			instruction.EmitTo(builder, nil)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func TestSelectAddressConditionalJump(t *testing.T) {
+	addressType := ir.NewAddressType(ir.Int32)
+	functionType := ir.NewFunctionType(
+		ir.SysVLiteCallConvention,
+		nil,
+		ir.NewStructType(nil))
+
+	newRef := func(name string, refType ir.Type) ir.Value {
+		ref := ir.NewLocalReference(name)
+		ref.(*ir.LocalReference).UseDef = &ir.Definition{
+			Name: name,
+			Type: refType,
+		}
+		return ref
+	}
+
+	type testCase struct {
+		name     string
+		kind     ir.ConditionalJumpKind
+		src1     ir.Value
+		src2     ir.Value
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name: "address lt",
+			kind: ir.Jlt,
+			src1: newRef("a", addressType),
+			src2: newRef("b", addressType),
+			expected: []byte{
+				0x48, 0x3b, 0xfe, // cmp rdi, rsi
+				0x0f, 0x82, 0, 0, 0, 0, // jb
+			},
+		},
+		{
+			name: "function eq",
+			kind: ir.Jeq,
+			src1: newRef("a", functionType),
+			src2: newRef("b", functionType),
+			expected: []byte{
+				0x48, 0x3b, 0xfe, // cmp rdi, rsi
+				0x0f, 0x84, 0, 0, 0, 0, // je
+			},
+		},
+		{
+			name: "address eq null",
+			kind: ir.Jeq,
+			src1: newRef("a", addressType),
+			src2: ir.NewComplexImmediate(addressType, make([]byte, 8)),
+			expected: []byte{
+				0x48, 0x85, 0xff, // test rdi, rdi
+				0x0f, 0x84, 0, 0, 0, 0, // je
+			},
+		},
+		{
+			name: "null ne address",
+			kind: ir.Jne,
+			src1: ir.NewComplexImmediate(addressType, make([]byte, 8)),
+			src2: newRef("a", addressType),
+			expected: []byte{
+				0x48, 0x85, 0xff, // test rdi, rdi
+				0x0f, 0x85, 0, 0, 0, 0, // jne
+			},
+		},
+		{
+			name: "address ge immediate",
+			kind: ir.Jge,
+			src1: newRef("a", addressType),
+			src2: ir.NewComplexImmediate(
+				addressType,
+				[]byte{0x10, 0, 0, 0, 0, 0, 0, 0}),
+			expected: []byte{
+				0x48, 0x81, 0xff, 0x10, 0, 0, 0, // cmp rdi, 0x10
+				0x0f, 0x83, 0, 0, 0, 0, // jae
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			jump := &ir.ConditionalJump{
+				Kind:  test.kind,
+				Label: "label",
+				Src1:  test.src1,
+				Src2:  test.src2,
+			}
+
+			instruction := architecture.SelectInstruction(
+				testConfig,
+				jump,
+				architecture.SelectorHint{})
+
+			constraints := instruction.Constraints()
+			selected := map[*architecture.RegisterConstraint]*architecture.Register{}
+			for idx, source := range constraints.RegisterSources {
+				if idx == 0 {
+					selected[source.RegisterConstraint] = registers.Rdi
+				} else {
+					selected[source.RegisterConstraint] = registers.Rsi
+				}
+			}
+
+			builder := layout.NewSegmentBuilder()
+			instruction.EmitTo(builder, selected)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(
+				t,
+				layout.Relocations{
+					Labels: []*layout.Relocation{
+						{
+							Name:   "label",
+							Offset: int64(len(test.expected) - 4),
+						},
+					},
+				},
+				segment.Relocations)
+		})
+	}
+}
+
+func TestSelectEnumConditionalJump(t *testing.T) {
+	enumType := ir.NewEnumType(
+		"ordering",
+		ir.Int16,
+		[]ir.EnumValue{
+			{Name: "less", Value: -1},
+			{Name: "equal", Value: 0},
+			{Name: "greater", Value: 1},
+		})
+
+	src := ir.NewLocalReference("a")
+	src.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "a",
+		Type: enumType,
+	}
+
+	jump := &ir.ConditionalJump{
+		Kind:  ir.Jlt,
+		Label: "label",
+		Src1:  src,
+		Src2:  ir.NewEnumImmediate(enumType, "less"),
+	}
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		jump,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			constraints.RegisterSources[0].RegisterConstraint: registers.Rdi,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x66, 0x81, 0xff, 0xff, 0xff, // cmp di, -1
+			0x0f, 0x8c, 0, 0, 0, 0, // jl
+		},
+		segment.Content.Flatten())
+}
@@ -1,6 +1,7 @@
 package instructions
 
 import (
+	"github.com/pattyshack/chickadee/amd64/registers"
 	"github.com/pattyshack/chickadee/ir"
 	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
@@ -15,6 +16,24 @@ func jump(builder *layout.SegmentBuilder, label string) {
 	d32Instruction(builder, []byte{0xE9}, layout.BasicBlockKind, label)
 }
 
+// https://www.felixcloutier.com/x86/ud
+//
+// (ZO Op/En): 0F 0B
+func trap(builder *layout.SegmentBuilder) {
+	builder.AppendBasicData([]byte{0x0F, 0x0B}) // ud2
+}
+
+// Unreachable code is never executed.  We'll emit int3 (the same instruction
+// used for padding) anyway to guard against miscompilation, and to ensure the
+// block does not fall through to the next block.
+//
+// https://www.felixcloutier.com/x86/intn:into:int3:int1
+//
+// (ZO Op/En): CC
+func unreachable(builder *layout.SegmentBuilder) {
+	builder.AppendBasicData([]byte{0xCC}) // int3
+}
+
 // Compare two registers of the same class and set eflags.  The srcs are left
 // unmodified.  This pairs with the jcc instruction to implement a conditional
 // jump.
@@ -113,11 +132,51 @@ func compareIntImmediate(
 	newMI(isUnsigned, operandSize, opCode, 7, src, immediate).encode(builder)
 }
 
+// Bitwise and two int registers and set eflags (the result is discarded).  The
+// srcs are left unmodified.  This pairs with the jcc instruction to implement
+// zero / sign checks (test <src>, <src>).
+//
+// https://www.felixcloutier.com/x86/test
+//
+// 8-bit (MR Op/En):        84 /r
+// 16/32/64-bit (MR Op/En): 85 /r
+func testInt(
+	builder *layout.SegmentBuilder,
+	compareType ir.Type,
+	src1 *architecture.Register,
+	src2 *architecture.Register,
+) {
+	switch compareType.(type) {
+	case *ir.SignedIntType:
+	case *ir.UnsignedIntType:
+	default:
+		panic("should never happen")
+	}
+
+	operandSize := compareType.Size()
+	opCode := []byte{0x85}
+	if operandSize == 1 {
+		opCode = []byte{0x84}
+	}
+
+	// NOTE: test is symmetric.  RM and MR encodings are interchangeable.
+	newRM(false, operandSize, opCode, src1, src2).encode(builder)
+}
+
 // je <label> <int/uint/float src1> <int/uint/float src2>
 //
+// NOTE: comiss/comisd sets ZF, PF and CF when either float operand is NaN
+// (unordered).  Float equality must also check that PF is unset:
+//
+//	comis* <src1>, <src2>
+//	jp <skip>      // unordered.  NaN is not equal to anything
+//	je <label>
+//	skip:
+//
 // https://www.felixcloutier.com/x86/jcc
 //
 // int/uint/float (JE D Op/En): 0F 84 cd
+// float (JP D Op/En):          7A cb
 func je(
 	builder *layout.SegmentBuilder,
 	label string,
@@ -126,6 +185,13 @@ func je(
 	src2 *architecture.Register,
 ) {
 	compare(builder, compareType, src1, src2)
+
+	_, isFloat := compareType.(*ir.FloatType)
+	if isFloat {
+		// jp rel8, skipping over the 6 bytes je rel32 instruction
+		builder.AppendBasicData([]byte{0x7A, 0x06})
+	}
+
 	d32Instruction(builder, []byte{0x0F, 0x84}, layout.BasicBlockKind, label)
 }
 
@@ -145,11 +211,37 @@ func jeIntImmediate(
 	d32Instruction(builder, []byte{0x0F, 0x84}, layout.BasicBlockKind, label)
 }
 
+// je <label> <int/uint src> 0
+//
+//	test <src>, <src>
+//	je <label>
+//
+// https://www.felixcloutier.com/x86/jcc
+//
+// int/uint (JE D Op/En): 0F 84 cd
+func jeZero(
+	builder *layout.SegmentBuilder,
+	label string,
+	compareType ir.Type,
+	src *architecture.Register,
+) {
+	testInt(builder, compareType, src, src)
+	d32Instruction(builder, []byte{0x0F, 0x84}, layout.BasicBlockKind, label)
+}
+
 // jne <label> <int/uint/float src1> <int/uint/float src2>
 //
+// NOTE: comiss/comisd sets ZF, PF and CF when either float operand is NaN
+// (unordered).  Float inequality must also jump when PF is set:
+//
+//	comis* <src1>, <src2>
+//	jne <label>
+//	jp <label>     // unordered.  NaN is not equal to anything
+//
 // https://www.felixcloutier.com/x86/jcc
 //
 // int/uint/float (JNE D Op/En): 0F 85 cd
+// float (JP D Op/En):           0F 8A cd
 func jne(
 	builder *layout.SegmentBuilder,
 	label string,
@@ -159,6 +251,11 @@ func jne(
 ) {
 	compare(builder, compareType, src1, src2)
 	d32Instruction(builder, []byte{0x0F, 0x85}, layout.BasicBlockKind, label)
+
+	_, isFloat := compareType.(*ir.FloatType)
+	if isFloat {
+		d32Instruction(builder, []byte{0x0F, 0x8A}, layout.BasicBlockKind, label)
+	}
 }
 
 // jne <label <int/uint src> <int/uint immediate>
@@ -177,12 +274,39 @@ func jneIntImmediate(
 	d32Instruction(builder, []byte{0x0F, 0x85}, layout.BasicBlockKind, label)
 }
 
+// jne <label> <int/uint src> 0
+//
+//	test <src>, <src>
+//	jne <label>
+//
+// https://www.felixcloutier.com/x86/jcc
+//
+// int/uint (JNE D Op/En): 0F 85 cd
+func jneZero(
+	builder *layout.SegmentBuilder,
+	label string,
+	compareType ir.Type,
+	src *architecture.Register,
+) {
+	testInt(builder, compareType, src, src)
+	d32Instruction(builder, []byte{0x0F, 0x85}, layout.BasicBlockKind, label)
+}
+
 // jlt <label> <int/uint/float src1> <int/uint/float src2>
 //
+// NOTE: comiss/comisd sets CF when either float operand is NaN (unordered), so
+// jb would incorrectly jump on NaN.  Float less than is instead implemented by
+// swapping the operands and checking (src2 > src1) via ja, which requires
+// both CF and ZF to be unset and is therefore false when unordered:
+//
+//	comis* <src2>, <src1>
+//	ja <label>
+//
 // https://www.felixcloutier.com/x86/jcc
 //
-// uint/float (JB D Op/En): 0F 82 cd
-// int (JL D Op/En):        0F 8C cd
+// uint (JB D Op/En):  0F 82 cd
+// int (JL D Op/En):   0F 8C cd
+// float (JA D Op/En): 0F 87 cd
 func jlt(
 	builder *layout.SegmentBuilder,
 	label string,
@@ -194,6 +318,8 @@ func jlt(
 	switch compareType.(type) {
 	case *ir.UnsignedIntType:
 	case *ir.FloatType:
+		opCode = []byte{0x0F, 0x87}
+		src1, src2 = src2, src1
 	case *ir.SignedIntType:
 		opCode = []byte{0x0F, 0x8C}
 	default:
@@ -233,10 +359,20 @@ func jltIntImmediate(
 
 // jle <label> <int/uint/float src1> <int/uint/float src2>
 //
+// NOTE: comiss/comisd sets ZF and CF when either float operand is NaN
+// (unordered), so jbe would incorrectly jump on NaN.  Float less than or equal
+// is instead implemented by swapping the operands and checking
+// (src2 >= src1) via jae, which requires CF to be unset and is therefore false
+// when unordered:
+//
+//	comis* <src2>, <src1>
+//	jae <label>
+//
 // https://www.felixcloutier.com/x86/jcc
 //
-// uint/float (JBE D Op/En): 0F 86 cd
-// int (JLE D Op/En):        0F 8E cd
+// uint (JBE D Op/En):  0F 86 cd
+// int (JLE D Op/En):   0F 8E cd
+// float (JAE D Op/En): 0F 83 cd
 func jle(
 	builder *layout.SegmentBuilder,
 	label string,
@@ -248,6 +384,8 @@ func jle(
 	switch compareType.(type) {
 	case *ir.UnsignedIntType:
 	case *ir.FloatType:
+		opCode = []byte{0x0F, 0x83}
+		src1, src2 = src2, src1
 	case *ir.SignedIntType:
 		opCode = []byte{0x0F, 0x8E}
 	default:
@@ -287,6 +425,9 @@ func jleIntImmediate(
 
 // jgt <label> <int/uint/float src1> <int/uint/float src2>
 //
+// NOTE: ja requires both CF and ZF to be unset, and is therefore false when
+// either float operand is NaN (unordered).
+//
 // https://www.felixcloutier.com/x86/jcc
 //
 // uint/float (JA D Op/En): 0F 87 cd
@@ -341,6 +482,9 @@ func jgtIntImmediate(
 
 // jge <label> <int/uint/float src1> <int/uint/float src2>
 //
+// NOTE: jae requires CF to be unset, and is therefore false when either float
+// operand is NaN (unordered).
+//
 // https://www.felixcloutier.com/x86/jcc
 //
 // uint/float (JAE D Op/En): 0F 83 cd
@@ -392,3 +536,83 @@ func jgeIntImmediate(
 	compareIntImmediate(builder, compareType, src, immediate)
 	d32Instruction(builder, opCode, layout.BasicBlockKind, label)
 }
+
+// jo/jc <label> (<int/uint src1> += <int/uint src2>)
+//
+// NOTE: src1 is clobbered.
+//
+// https://www.felixcloutier.com/x86/jcc
+//
+// int (JO D Op/En):  0F 80 cd
+// uint (JB D Op/En): 0F 82 cd
+func jaddOverflow(
+	builder *layout.SegmentBuilder,
+	label string,
+	operandType ir.Type,
+	src1 *architecture.Register,
+	src2 *architecture.Register,
+) {
+	addWithStatusFlags(builder, operandType, src1, src2)
+	jumpOnStatusFlagsOverflow(builder, label, operandType)
+}
+
+// jo/jc <label> (<int/uint src1> -= <int/uint src2>)
+//
+// NOTE: src1 is clobbered.
+//
+// https://www.felixcloutier.com/x86/jcc
+//
+// int (JO D Op/En):  0F 80 cd
+// uint (JB D Op/En): 0F 82 cd
+func jsubOverflow(
+	builder *layout.SegmentBuilder,
+	label string,
+	operandType ir.Type,
+	src1 *architecture.Register,
+	src2 *architecture.Register,
+) {
+	sub(builder, operandType, src1, src2)
+	jumpOnStatusFlagsOverflow(builder, label, operandType)
+}
+
+// jo <label> (<int/uint RAX src1> * <int/uint src2>)
+//
+// NOTE: RAX and RDX (except for 8-bit operand) are clobbered.  mul / imul sets
+// both the carry and overflow status flags on overflow.
+//
+// https://www.felixcloutier.com/x86/jcc
+//
+// int/uint (JO D Op/En): 0F 80 cd
+func jmulOverflow(
+	builder *layout.SegmentBuilder,
+	label string,
+	operandType ir.Type,
+	src1 *architecture.Register,
+	src2 *architecture.Register,
+) {
+	if src1 != registers.Rax {
+		panic("should never happen")
+	}
+
+	mulWithStatusFlags(builder, operandType, src2)
+	d32Instruction(builder, []byte{0x0F, 0x80}, layout.BasicBlockKind, label)
+}
+
+// Signed int overflow is indicated by the overflow flag, whereas unsigned int
+// overflow is indicated by the carry flag.
+func jumpOnStatusFlagsOverflow(
+	builder *layout.SegmentBuilder,
+	label string,
+	operandType ir.Type,
+) {
+	opCode := []byte{0x0F, 0x80}
+	switch operandType.(type) {
+	case *ir.SignedIntType:
+	case *ir.UnsignedIntType:
+		opCode = []byte{0x0F, 0x82}
+	default:
+		panic("should never happen")
+	}
+
+	d32Instruction(builder, opCode, layout.BasicBlockKind, label)
+}
@@ -1,6 +1,7 @@
 package instructions
 
 import (
+	"math"
 	"testing"
 
 	"github.com/pattyshack/gt/testing/expect"
@@ -8,11 +9,13 @@ import (
 	amd64 "github.com/pattyshack/chickadee/amd64/layout"
 	"github.com/pattyshack/chickadee/amd64/registers"
 	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
 )
 
 func TestJeFloat32(t *testing.T) {
 	// comiss xmm0, xmm7
+	// jp +6
 	// je (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	je(builder, "jump-label", ir.Float32, registers.Xmm0, registers.Xmm7)
@@ -22,6 +25,7 @@ func TestJeFloat32(t *testing.T) {
 		t,
 		[]byte{
 			0x0f, 0x2f, 0xc7, // comiss
+			0x7a, 0x06, // jp
 			0x0f, 0x84, 0, 0, 0, 0, // je
 		},
 		segment.Content.Flatten())
@@ -32,7 +36,7 @@ func TestJeFloat32(t *testing.T) {
 			Labels: []*layout.Relocation{
 				{
 					Name:   "jump-label",
-					Offset: 5,
+					Offset: 7,
 				},
 			},
 		},
@@ -42,6 +46,7 @@ func TestJeFloat32(t *testing.T) {
 func TestJneFloat32(t *testing.T) {
 	// comiss xmm8, xmm6
 	// jne (4 byte placeholder)
+	// jp (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	jne(builder, "jump-label", ir.Float32, registers.Xmm8, registers.Xmm6)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
@@ -51,6 +56,7 @@ func TestJneFloat32(t *testing.T) {
 		[]byte{
 			0x44, 0x0f, 0x2f, 0xc6, // comiss
 			0x0f, 0x85, 0, 0, 0, 0, // jne
+			0x0f, 0x8a, 0, 0, 0, 0, // jp
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -62,14 +68,18 @@ func TestJneFloat32(t *testing.T) {
 					Name:   "jump-label",
 					Offset: 6,
 				},
+				{
+					Name:   "jump-label",
+					Offset: 12,
+				},
 			},
 		},
 		segment.Relocations)
 }
 
 func TestJltFloat32(t *testing.T) {
-	// comiss xmm5, xmm9
-	// jb (4 byte placeholder)
+	// comiss xmm9, xmm5
+	// ja (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	jlt(builder, "jump-label", ir.Float32, registers.Xmm5, registers.Xmm9)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
@@ -77,8 +87,8 @@ func TestJltFloat32(t *testing.T) {
 	expect.Equal(
 		t,
 		[]byte{
-			0x41, 0x0f, 0x2f, 0xe9, // comiss
-			0x0f, 0x82, 0, 0, 0, 0, // jb
+			0x44, 0x0f, 0x2f, 0xcd, // comiss
+			0x0f, 0x87, 0, 0, 0, 0, // ja
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -96,8 +106,8 @@ func TestJltFloat32(t *testing.T) {
 }
 
 func TestJleFloat32(t *testing.T) {
-	// comiss xmm10, xmm12
-	// jbe (4 byte placeholder)
+	// comiss xmm12, xmm10
+	// jae (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	jle(builder, "jump-label", ir.Float32, registers.Xmm10, registers.Xmm12)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
@@ -105,8 +115,8 @@ func TestJleFloat32(t *testing.T) {
 	expect.Equal(
 		t,
 		[]byte{
-			0x45, 0x0f, 0x2f, 0xd4, // comiss
-			0x0f, 0x86, 0, 0, 0, 0, // jbe
+			0x45, 0x0f, 0x2f, 0xe2, // comiss
+			0x0f, 0x83, 0, 0, 0, 0, // jae
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -181,6 +191,7 @@ func TestJgeFloat32(t *testing.T) {
 
 func TestJeFloat64(t *testing.T) {
 	// comisd xmm0, xmm7
+	// jp +6
 	// je (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	je(builder, "jump-label", ir.Float64, registers.Xmm0, registers.Xmm7)
@@ -190,6 +201,7 @@ func TestJeFloat64(t *testing.T) {
 		t,
 		[]byte{
 			0x66, 0x0f, 0x2f, 0xc7, // comisd
+			0x7a, 0x06, // jp
 			0x0f, 0x84, 0, 0, 0, 0, // je
 		},
 		segment.Content.Flatten())
@@ -200,7 +212,7 @@ func TestJeFloat64(t *testing.T) {
 			Labels: []*layout.Relocation{
 				{
 					Name:   "jump-label",
-					Offset: 6,
+					Offset: 8,
 				},
 			},
 		},
@@ -210,6 +222,7 @@ func TestJeFloat64(t *testing.T) {
 func TestJneFloat64(t *testing.T) {
 	// comisd xmm8, xmm6
 	// jne (4 byte placeholder)
+	// jp (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	jne(builder, "jump-label", ir.Float64, registers.Xmm8, registers.Xmm6)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
@@ -219,6 +232,7 @@ func TestJneFloat64(t *testing.T) {
 		[]byte{
 			0x66, 0x44, 0x0f, 0x2f, 0xc6, // comisd
 			0x0f, 0x85, 0, 0, 0, 0, // jne
+			0x0f, 0x8a, 0, 0, 0, 0, // jp
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -230,14 +244,18 @@ func TestJneFloat64(t *testing.T) {
 					Name:   "jump-label",
 					Offset: 7,
 				},
+				{
+					Name:   "jump-label",
+					Offset: 13,
+				},
 			},
 		},
 		segment.Relocations)
 }
 
 func TestJltFloat64(t *testing.T) {
-	// comisd xmm5, xmm9
-	// jb (4 byte placeholder)
+	// comisd xmm9, xmm5
+	// ja (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	jlt(builder, "jump-label", ir.Float64, registers.Xmm5, registers.Xmm9)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
@@ -245,8 +263,8 @@ func TestJltFloat64(t *testing.T) {
 	expect.Equal(
 		t,
 		[]byte{
-			0x66, 0x41, 0x0f, 0x2f, 0xe9, // comisd
-			0x0f, 0x82, 0, 0, 0, 0, // jb
+			0x66, 0x44, 0x0f, 0x2f, 0xcd, // comisd
+			0x0f, 0x87, 0, 0, 0, 0, // ja
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -264,8 +282,8 @@ func TestJltFloat64(t *testing.T) {
 }
 
 func TestJleFloat64(t *testing.T) {
-	// comisd xmm10, xmm12
-	// jbe (4 byte placeholder)
+	// comisd xmm12, xmm10
+	// jae (4 byte placeholder)
 	builder := layout.NewSegmentBuilder()
 	jle(builder, "jump-label", ir.Float64, registers.Xmm10, registers.Xmm12)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
@@ -273,8 +291,8 @@ func TestJleFloat64(t *testing.T) {
 	expect.Equal(
 		t,
 		[]byte{
-			0x66, 0x45, 0x0f, 0x2f, 0xd4, // comisd
-			0x0f, 0x86, 0, 0, 0, 0, // jbe
+			0x66, 0x45, 0x0f, 0x2f, 0xe2, // comisd
+			0x0f, 0x83, 0, 0, 0, 0, // jae
 		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
@@ -346,3 +364,166 @@ func TestJgeFloat64(t *testing.T) {
 		},
 		segment.Relocations)
 }
+
+// Evaluate the emitted float conditional jump by simulating the comiss/comisd
+// eflags result and the subsequent jcc instructions.  Returns true if the
+// jump to the relocated label is taken.
+func simulateFloatJump(
+	t *testing.T,
+	segment layout.Segment,
+	values map[int]float64,
+) bool {
+	relocated := map[int64]struct{}{}
+	for _, reloc := range segment.Relocations.Labels {
+		relocated[reloc.Offset] = struct{}{}
+	}
+
+	zf := false
+	pf := false
+	cf := false
+	isTaken := func(condition byte) bool {
+		switch condition {
+		case 0x2: // jb
+			return cf
+		case 0x3: // jae
+			return !cf
+		case 0x4: // je
+			return zf
+		case 0x5: // jne
+			return !zf
+		case 0x6: // jbe
+			return cf || zf
+		case 0x7: // ja
+			return !cf && !zf
+		case 0xA: // jp
+			return pf
+		case 0xB: // jnp
+			return !pf
+		}
+
+		t.Fatalf("unexpected condition: %x", condition)
+		return false
+	}
+
+	content := segment.Content.Flatten()
+	pc := 0
+	for pc < len(content) {
+		rex := byte(0)
+		if content[pc] == 0x66 {
+			pc++
+		}
+		if content[pc]&0xf0 == 0x40 {
+			rex = content[pc]
+			pc++
+		}
+
+		if content[pc] == 0x0f && content[pc+1] == 0x2f { // comiss / comisd
+			modRM := content[pc+2]
+			reg := int((modRM>>3)&0x7) | int(rex&0x4)<<1
+			rm := int(modRM&0x7) | int(rex&0x1)<<3
+
+			src1 := values[reg]
+			src2 := values[rm]
+			switch {
+			case math.IsNaN(src1) || math.IsNaN(src2):
+				zf, pf, cf = true, true, true
+			case src1 > src2:
+				zf, pf, cf = false, false, false
+			case src1 < src2:
+				zf, pf, cf = false, false, true
+			default:
+				zf, pf, cf = true, false, false
+			}
+
+			pc += 3
+		} else if content[pc]&0xf0 == 0x70 { // jcc rel8
+			pc += 2
+			if isTaken(content[pc-2] & 0x0f) {
+				pc += int(int8(content[pc-1]))
+			}
+		} else if content[pc] == 0x0f && content[pc+1]&0xf0 == 0x80 { // jcc rel32
+			_, ok := relocated[int64(pc+2)]
+			expect.True(t, ok)
+
+			if isTaken(content[pc+1] & 0x0f) {
+				return true
+			}
+			pc += 6
+		} else {
+			t.Fatalf("unexpected instruction at %d: %v", pc, content[pc:])
+		}
+	}
+
+	return false
+}
+
+func TestFloatJumpSemantics(t *testing.T) {
+	type encodeFunc func(
+		*layout.SegmentBuilder,
+		string,
+		ir.Type,
+		*architecture.Register,
+		*architecture.Register)
+
+	jumps := []struct {
+		name     string
+		encode   encodeFunc
+		evaluate func(float64, float64) bool
+	}{
+		{"je", je, func(a float64, b float64) bool { return a == b }},
+		{"jne", jne, func(a float64, b float64) bool { return a != b }},
+		{"jlt", jlt, func(a float64, b float64) bool { return a < b }},
+		{"jle", jle, func(a float64, b float64) bool { return a <= b }},
+		{"jgt", jgt, func(a float64, b float64) bool { return a > b }},
+		{"jge", jge, func(a float64, b float64) bool { return a >= b }},
+	}
+
+	values := []float64{
+		math.NaN(),
+		math.Inf(1),
+		math.Inf(-1),
+		0,
+		math.Copysign(0, -1),
+		1,
+		-1,
+		1.5,
+		math.SmallestNonzeroFloat32,
+		math.MaxFloat32,
+	}
+
+	for _, floatType := range []*ir.FloatType{ir.Float32, ir.Float64} {
+		for _, jump := range jumps {
+			builder := layout.NewSegmentBuilder()
+			jump.encode(
+				builder,
+				"jump-label",
+				floatType,
+				registers.Xmm3,
+				registers.Xmm12)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+
+			for _, src1 := range values {
+				for _, src2 := range values {
+					taken := simulateFloatJump(
+						t,
+						segment,
+						map[int]float64{
+							registers.Xmm3.Encoding:  src1,
+							registers.Xmm12.Encoding: src2,
+						})
+
+					if taken != jump.evaluate(src1, src2) {
+						t.Errorf(
+							"%s float%d (%v, %v): unexpected jump result (%v)",
+							jump.name,
+							floatType.ByteSize*8,
+							src1,
+							src2,
+							taken)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+func TestOverflowJumps(t *testing.T) {
+	type testCase struct {
+		name     string
+		encode   encodeConditionalJumpFunc
+		opType   ir.Type
+		src1     *architecture.Register
+		src2     *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:   "add int8", // add dil, bl ; jo
+			encode: jaddOverflow,
+			opType: ir.Int8,
+			src1:   registers.Rdi,
+			src2:   registers.Rbx,
+			expected: []byte{
+				0x40, 0x02, 0xfb,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "add uint16", // add dx, cx ; jc
+			encode: jaddOverflow,
+			opType: ir.Uint16,
+			src1:   registers.Rdx,
+			src2:   registers.Rcx,
+			expected: []byte{
+				0x66, 0x03, 0xd1,
+				0x0f, 0x82, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "add int32", // add r8d, eax ; jo
+			encode: jaddOverflow,
+			opType: ir.Int32,
+			src1:   registers.R8,
+			src2:   registers.Rax,
+			expected: []byte{
+				0x44, 0x03, 0xc0,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "add uint64", // add rax, r15 ; jc
+			encode: jaddOverflow,
+			opType: ir.Uint64,
+			src1:   registers.Rax,
+			src2:   registers.R15,
+			expected: []byte{
+				0x49, 0x03, 0xc7,
+				0x0f, 0x82, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "sub uint8", // sub sil, al ; jc
+			encode: jsubOverflow,
+			opType: ir.Uint8,
+			src1:   registers.Rsi,
+			src2:   registers.Rax,
+			expected: []byte{
+				0x40, 0x2a, 0xf0,
+				0x0f, 0x82, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "sub int16", // sub ax, bx ; jo
+			encode: jsubOverflow,
+			opType: ir.Int16,
+			src1:   registers.Rax,
+			src2:   registers.Rbx,
+			expected: []byte{
+				0x66, 0x2b, 0xc3,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "sub uint32", // sub ecx, edx ; jc
+			encode: jsubOverflow,
+			opType: ir.Uint32,
+			src1:   registers.Rcx,
+			src2:   registers.Rdx,
+			expected: []byte{
+				0x2b, 0xca,
+				0x0f, 0x82, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "sub int64", // sub r9, r10 ; jo
+			encode: jsubOverflow,
+			opType: ir.Int64,
+			src1:   registers.R9,
+			src2:   registers.R10,
+			expected: []byte{
+				0x4d, 0x2b, 0xca,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "mul uint8", // mul bl ; jo
+			encode: jmulOverflow,
+			opType: ir.Uint8,
+			src1:   registers.Rax,
+			src2:   registers.Rbx,
+			expected: []byte{
+				0xf6, 0xe3,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "mul int8", // imul sil ; jo
+			encode: jmulOverflow,
+			opType: ir.Int8,
+			src1:   registers.Rax,
+			src2:   registers.Rsi,
+			expected: []byte{
+				0x40, 0xf6, 0xee,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "mul uint16", // mul cx ; jo
+			encode: jmulOverflow,
+			opType: ir.Uint16,
+			src1:   registers.Rax,
+			src2:   registers.Rcx,
+			expected: []byte{
+				0x66, 0xf7, 0xe1,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "mul int32", // imul r11d ; jo
+			encode: jmulOverflow,
+			opType: ir.Int32,
+			src1:   registers.Rax,
+			src2:   registers.R11,
+			expected: []byte{
+				0x41, 0xf7, 0xeb,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "mul uint64", // mul rdi ; jo
+			encode: jmulOverflow,
+			opType: ir.Uint64,
+			src1:   registers.Rax,
+			src2:   registers.Rdi,
+			expected: []byte{
+				0x48, 0xf7, 0xe7,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "mul int64", // imul rax ; jo
+			encode: jmulOverflow,
+			opType: ir.Int64,
+			src1:   registers.Rax,
+			src2:   registers.Rax,
+			expected: []byte{
+				0x48, 0xf7, 0xe8,
+				0x0f, 0x80, 0, 0, 0, 0,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			test.encode(builder, "overflow", test.opType, test.src1, test.src2)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(
+				t,
+				layout.Relocations{
+					Labels: []*layout.Relocation{
+						{
+							Name:   "overflow",
+							Offset: int64(len(test.expected) - 4),
+						},
+					},
+				},
+				segment.Relocations)
+		})
+	}
+}
+
+func TestJaddOverflowInt(t *testing.T) {
+	src1 := ir.NewLocalReference("src1")
+	src1Def := &ir.Definition{
+		Name: "src1",
+		Type: ir.Int16,
+	}
+	src1.(*ir.LocalReference).UseDef = src1Def
+	src1Chunk := src1Def.Chunks()[0]
+
+	src2 := ir.NewLocalReference("src2")
+	src2Def := &ir.Definition{
+		Name: "src2",
+		Type: ir.Int16,
+	}
+	src2.(*ir.LocalReference).UseDef = src2Def
+	src2Chunk := src2Def.Chunks()[0]
+
+	jump := &ir.ConditionalJump{
+		Kind:  ir.JaddOverflow,
+		Label: "overflow",
+		Src1:  src1,
+		Src2:  src2,
+	}
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		jump,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(conditionalJumpInstruction)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Nil(t, constraints.StackSources)
+	expect.Nil(t, constraints.StackDestination)
+	expect.Nil(t, constraints.RegisterDestinations)
+
+	expect.Equal(t, 2, len(constraints.RegisterSources))
+	expect.Equal(t, src1Chunk, constraints.RegisterSources[0].DefinitionChunk)
+	expect.Equal(t, src2Chunk, constraints.RegisterSources[1].DefinitionChunk)
+
+	src1Register := constraints.RegisterSources[0].RegisterConstraint
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered:  true,
+			AnyGeneral: true,
+		},
+		src1Register)
+
+	src2Register := constraints.RegisterSources[1].RegisterConstraint
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered:  false,
+			AnyGeneral: true,
+		},
+		src2Register)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			src1Register: registers.Rbx,
+			src2Register: registers.Rsi,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x66, 0x03, 0xde, // add bx, si
+			0x0f, 0x80, 0, 0, 0, 0, // jo
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Labels: []*layout.Relocation{
+				{
+					Name:   "overflow",
+					Offset: 5,
+				},
+			},
+		},
+		segment.Relocations)
+}
+
+func TestJsubOverflowUintSameSource(t *testing.T) {
+	src := ir.NewLocalReference("src")
+	srcDef := &ir.Definition{
+		Name: "src",
+		Type: ir.Uint32,
+	}
+	src.(*ir.LocalReference).UseDef = srcDef
+
+	jump := &ir.ConditionalJump{
+		Kind:  ir.JsubOverflow,
+		Label: "overflow",
+		Src1:  src,
+		Src2:  src,
+	}
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		jump,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+	srcRegister := constraints.RegisterSources[0].RegisterConstraint
+	expect.True(t, srcRegister.Clobbered)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			srcRegister: registers.Rcx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x2b, 0xc9, // sub ecx, ecx
+			0x0f, 0x82, 0, 0, 0, 0, // jc
+		},
+		segment.Content.Flatten())
+}
+
+func TestJmulOverflowUint(t *testing.T) {
+	src1 := ir.NewLocalReference("src1")
+	src1Def := &ir.Definition{
+		Name: "src1",
+		Type: ir.Uint64,
+	}
+	src1.(*ir.LocalReference).UseDef = src1Def
+	src1Chunk := src1Def.Chunks()[0]
+
+	src2 := ir.NewLocalReference("src2")
+	src2Def := &ir.Definition{
+		Name: "src2",
+		Type: ir.Uint64,
+	}
+	src2.(*ir.LocalReference).UseDef = src2Def
+	src2Chunk := src2Def.Chunks()[0]
+
+	jump := &ir.ConditionalJump{
+		Kind:  ir.JmulOverflow,
+		Label: "overflow",
+		Src1:  src1,
+		Src2:  src2,
+	}
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		jump,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(conditionalJumpInstruction)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Nil(t, constraints.RegisterDestinations)
+	expect.Equal(t, 3, len(constraints.RegisterSources))
+	expect.Equal(t, src1Chunk, constraints.RegisterSources[0].DefinitionChunk)
+	expect.Equal(t, src2Chunk, constraints.RegisterSources[1].DefinitionChunk)
+	expect.Nil(t, constraints.RegisterSources[2].DefinitionChunk)
+
+	rax := constraints.RegisterSources[0].RegisterConstraint
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered: true,
+			Require:   registers.Rax,
+		},
+		rax)
+
+	src2Register := constraints.RegisterSources[1].RegisterConstraint
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered:  false,
+			AnyGeneral: true,
+		},
+		src2Register)
+
+	rdx := constraints.RegisterSources[2].RegisterConstraint
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered: true,
+			Require:   registers.Rdx,
+		},
+		rdx)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			rax:          registers.Rax,
+			src2Register: registers.R12,
+			rdx:          registers.Rdx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x49, 0xf7, 0xe4, // mul r12
+			0x0f, 0x80, 0, 0, 0, 0, // jo
+		},
+		segment.Content.Flatten())
+}
+
+func TestJmulOverflowInt8SameSource(t *testing.T) {
+	src := ir.NewLocalReference("src")
+	srcDef := &ir.Definition{
+		Name: "src",
+		Type: ir.Int8,
+	}
+	src.(*ir.LocalReference).UseDef = srcDef
+
+	jump := &ir.ConditionalJump{
+		Kind:  ir.JmulOverflow,
+		Label: "overflow",
+		Src1:  src,
+		Src2:  src,
+	}
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		jump,
+		architecture.SelectorHint{})
+
+	// 8-bit mul stores the upper bits in AH; RDX is not needed.
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+	rax := constraints.RegisterSources[0].RegisterConstraint
+	expect.Equal(t, registers.Rax, rax.Require)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			rax: registers.Rax,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0xf6, 0xe8, // imul al
+			0x0f, 0x80, 0, 0, 0, 0, // jo
+		},
+		segment.Content.Flatten())
+}
@@ -1,6 +1,9 @@
 package instructions
 
 import (
+	"encoding/binary"
+
+	"github.com/pattyshack/chickadee/amd64/registers"
 	"github.com/pattyshack/chickadee/ir"
 	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
@@ -25,6 +28,35 @@ func (inst jumpInstruction) EmitTo(
 	jump(builder, inst.Label)
 }
 
+// Address and function values are compared as uint64, and enum values are
+// compared as their underlying int types.
+func conditionalJumpCompareType(valueType ir.Type) ir.Type {
+	switch t := valueType.(type) {
+	case *ir.AddressType, *ir.FunctionType:
+		return ir.Uint64
+	case *ir.EnumType:
+		return t.UnderlyingType
+	default:
+		return valueType
+	}
+}
+
+// Returns the immediate's int*/uint* value (address immediates are converted
+// to uint64), or nil if the value is not an immediate.
+func conditionalJumpImmediateValue(value ir.Value) interface{} {
+	immediate, ok := value.(*ir.Immediate)
+	if !ok {
+		return nil
+	}
+
+	switch immediate.Type().(type) {
+	case *ir.AddressType:
+		return binary.LittleEndian.Uint64(immediate.Value.([]byte))
+	default:
+		return immediate.Value
+	}
+}
+
 type encodeConditionalJumpFunc func(
 	*layout.SegmentBuilder,
 	string,
@@ -52,16 +84,19 @@ func (inst conditionalJumpInstruction) EmitTo(
 	builder *layout.SegmentBuilder,
 	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
 ) {
-	src1 := inst.InstructionConstraints.RegisterSources[0].RegisterConstraint
+	// NOTE: scratch registers (nil definition chunk) are always listed after
+	// the srcs.
+	sources := inst.InstructionConstraints.RegisterSources
+	src1 := sources[0].RegisterConstraint
 	src2 := src1
-	if len(inst.InstructionConstraints.RegisterSources) == 2 {
-		src2 = inst.InstructionConstraints.RegisterSources[1].RegisterConstraint
+	if len(sources) >= 2 && sources[1].DefinitionChunk != nil {
+		src2 = sources[1].RegisterConstraint
 	}
 
 	inst.encode(
 		builder,
 		inst.Label,
-		inst.Src1.Type(),
+		conditionalJumpCompareType(inst.Src1.Type()),
 		selectedRegisters[src1],
 		selectedRegisters[src2])
 }
@@ -100,7 +135,7 @@ func (inst conditionalJumpImmediateInstruction) EmitTo(
 	inst.encode(
 		builder,
 		inst.Label,
-		inst.Src1.Type(),
+		conditionalJumpCompareType(inst.Src1.Type()),
 		selectedRegisters[src1],
 		inst.immediate)
 }
@@ -117,12 +152,58 @@ func (jumpSelector) Select(
 	}
 }
 
+type terminalInstruction struct {
+	*ir.Terminal
+
+	encode func(*layout.SegmentBuilder)
+}
+
+func (inst terminalInstruction) Instruction() ir.Instruction {
+	return inst.Terminal
+}
+
+func (terminalInstruction) Constraints() architecture.InstructionConstraints {
+	return architecture.InstructionConstraints{}
+}
+
+func (inst terminalInstruction) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	inst.encode(builder)
+}
+
+// Trap / unreachable selector.
+type terminalSelector struct {
+	encode func(*layout.SegmentBuilder)
+}
+
+func (selector terminalSelector) Select(
+	config architecture.Config,
+	terminal *ir.Terminal,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	return terminalInstruction{
+		Terminal: terminal,
+		encode:   selector.encode,
+	}
+}
+
 type conditionalJumpSelector struct {
 	isFloat bool
 
 	encodeRightImmediate encodeConditionalJumpImmediateFunc
 	encodeLeftImmediate  encodeConditionalJumpImmediateFunc
-	encode               encodeConditionalJumpFunc
+
+	// Optional.  When specified, address / function values are compared against
+	// zero (null) immediates using test instead of cmp.
+	encodeZeroAddress func(
+		*layout.SegmentBuilder,
+		string,
+		ir.Type,
+		*architecture.Register)
+
+	encode encodeConditionalJumpFunc
 }
 
 func (selector conditionalJumpSelector) Select(
@@ -148,20 +229,38 @@ func (selector conditionalJumpSelector) maybeNewImmediateJump(
 
 	encode := selector.encodeRightImmediate
 	src := jump.Src1
-	immediate := jump.Src2
-	if isMISupportedImmediate(jump.Src2) {
+	immediate := conditionalJumpImmediateValue(jump.Src2)
+	if immediate != nil && isMISupportedImmediateValue(immediate) {
 		// do nothing
-	} else if isMISupportedImmediate(jump.Src1) {
+	} else {
+		immediate = conditionalJumpImmediateValue(jump.Src1)
+		if immediate == nil || !isMISupportedImmediateValue(immediate) {
+			return nil
+		}
+
 		encode = selector.encodeLeftImmediate
 		src = jump.Src2
-		immediate = jump.Src1
-	} else {
-		return nil
+	}
+
+	if selector.encodeZeroAddress != nil && immediate == uint64(0) {
+		switch src.Type().(type) {
+		case *ir.AddressType, *ir.FunctionType:
+			encodeZero := selector.encodeZeroAddress
+			encode = func(
+				builder *layout.SegmentBuilder,
+				label string,
+				compareType ir.Type,
+				src *architecture.Register,
+				immediate interface{},
+			) {
+				encodeZero(builder, label, compareType, src)
+			}
+		}
 	}
 
 	return conditionalJumpImmediateInstruction{
 		ConditionalJump: jump,
-		immediate:       immediate.(*ir.Immediate).Value,
+		immediate:       immediate,
 		InstructionConstraints: architecture.InstructionConstraints{
 			RegisterSources: []architecture.RegisterMapping{
 				{
@@ -214,3 +313,177 @@ func (selector conditionalJumpSelector) newJump(
 		encode: selector.encode,
 	}
 }
+
+// Overflow check of the form (<clobbered src1> = <src1> <op> <src2>),
+// followed by a conditional jump on the overflow / carry status flag.
+type overflowJumpSelector struct {
+	// mul requires src1 in RAX and clobbers RDX (except for 8-bit operand).
+	isMul bool
+
+	encode encodeConditionalJumpFunc
+}
+
+func (selector overflowJumpSelector) Select(
+	config architecture.Config,
+	jump *ir.ConditionalJump,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	src1 := &architecture.RegisterConstraint{
+		Clobbered:  true,
+		AnyGeneral: true,
+	}
+	if selector.isMul {
+		src1 = &architecture.RegisterConstraint{
+			Clobbered: true,
+			Require:   registers.Rax,
+		}
+	}
+
+	sources := []architecture.RegisterMapping{
+		{
+			RegisterConstraint: src1,
+			DefinitionChunk:    jump.Src1.Def().Chunks()[0],
+		},
+	}
+
+	if jump.Src1.Def() != jump.Src2.Def() {
+		sources = append(
+			sources,
+			architecture.RegisterMapping{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered:  false,
+					AnyGeneral: true,
+				},
+				DefinitionChunk: jump.Src2.Def().Chunks()[0],
+			})
+	}
+
+	if selector.isMul && jump.Src1.Type().Size() > 1 {
+		sources = append(
+			sources,
+			architecture.RegisterMapping{ // scratch space for product upper bytes
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered: true,
+					Require:   registers.Rdx,
+				},
+				DefinitionChunk: nil,
+			})
+	}
+
+	return conditionalJumpInstruction{
+		ConditionalJump: jump,
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: sources,
+		},
+		encode: selector.encode,
+	}
+}
+
+type switchInstruction struct {
+	*ir.Switch
+
+	cases []switchCase // sorted
+
+	useJumpTable bool
+
+	architecture.InstructionConstraints
+}
+
+func (inst switchInstruction) Instruction() ir.Instruction {
+	return inst.Switch
+}
+
+func (inst switchInstruction) Constraints() architecture.InstructionConstraints {
+	return inst.InstructionConstraints
+}
+
+func (inst switchInstruction) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	// NOTE: scratch registers (nil definition chunk) are always listed after
+	// the value.
+	sources := inst.InstructionConstraints.RegisterSources
+	value := selectedRegisters[sources[0].RegisterConstraint]
+
+	labelPrefix := "%switch." + inst.Block.Label
+
+	if inst.useJumpTable {
+		switchJumpTable(
+			builder,
+			labelPrefix,
+			ir.UnderlyingType(inst.Value.Type()),
+			value,
+			selectedRegisters[sources[1].RegisterConstraint],
+			selectedRegisters[sources[2].RegisterConstraint],
+			inst.cases,
+			inst.DefaultLabel)
+		return
+	}
+
+	var scratch *architecture.Register
+	if len(sources) > 1 {
+		scratch = selectedRegisters[sources[1].RegisterConstraint]
+	}
+
+	switchCompareTree(
+		builder,
+		labelPrefix,
+		ir.UnderlyingType(inst.Value.Type()),
+		value,
+		scratch,
+		inst.cases,
+		inst.DefaultLabel)
+}
+
+// Use jump table for dense switches, and compare tree otherwise.
+type switchSelector struct{}
+
+func (switchSelector) Select(
+	config architecture.Config,
+	switchInst *ir.Switch,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	cases := newSwitchCases(
+		ir.UnderlyingType(switchInst.Value.Type()),
+		switchInst.Cases)
+	useJumpTable := numJumpTableEntries(cases) > 0
+
+	sources := []architecture.RegisterMapping{
+		{
+			RegisterConstraint: &architecture.RegisterConstraint{
+				Clobbered:  false,
+				AnyGeneral: true,
+			},
+			DefinitionChunk: switchInst.Value.Def().Chunks()[0],
+		},
+	}
+
+	numScratch := 0
+	if useJumpTable {
+		numScratch = 2 // index and table address
+	} else if compareTreeRequiresScratch(cases) {
+		numScratch = 1
+	}
+
+	for i := 0; i < numScratch; i++ {
+		sources = append(
+			sources,
+			architecture.RegisterMapping{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered:  true,
+					AnyGeneral: true,
+				},
+				DefinitionChunk: nil,
+			})
+	}
+
+	return switchInstruction{
+		Switch:       switchInst,
+		cases:        cases,
+		useJumpTable: useJumpTable,
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: sources,
+		},
+	}
+}
@@ -45,6 +45,45 @@ func mul(
 	newRM(isFloat, operandSize, opCode, dest, src).encode(builder)
 }
 
+// <int/uint upper RDX>:<int/uint lower RAX> = <int/uint RAX> * <int/uint src>
+//
+// NOTE: For 8-bit operand, the upper bits are stored in AH instead of RDX.
+// The carry and overflow status flags are set when the upper bits are
+// significant (i.e., the product does not fit in the operand type).
+//
+// https://www.felixcloutier.com/x86/mul
+// https://www.felixcloutier.com/x86/imul
+//
+// Unsigned int (mul):
+// 8-bit (M Op/En):        F6 /4
+// 16/32/64-bit (M Op/En): F7 /4
+//
+// Signed int (imul):
+// 8-bit (M Op/En):        F6 /5
+// 16/32/64-bit (M Op/En): F7 /5
+func mulWithStatusFlags(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	src *architecture.Register,
+) {
+	opCodeExt := byte(5)
+	switch simpleType.(type) {
+	case *ir.SignedIntType:
+	case *ir.UnsignedIntType:
+		opCodeExt = 4
+	default:
+		panic("should never happen")
+	}
+
+	operandSize := simpleType.Size()
+	opCode := []byte{0xF7}
+	if operandSize == 1 {
+		opCode = []byte{0xF6}
+	}
+
+	newM(operandSize, opCode, opCodeExt, src).encode(builder)
+}
+
 // <int/uint dest> = <int/uint src> * <immediate>
 //
 // https://www.felixcloutier.com/x86/imul
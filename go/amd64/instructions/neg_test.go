@@ -162,6 +162,49 @@ func TestSelectNegInt(t *testing.T) {
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
+func TestSelectNegEnum(t *testing.T) {
+	enumType := ir.NewEnumType(
+		"sign",
+		ir.Int32,
+		[]ir.EnumValue{
+			{Name: "negative", Value: -1},
+			{Name: "positive", Value: 1},
+		})
+
+	src := ir.NewLocalReference("src")
+	src.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "src",
+		Type: enumType,
+	}
+
+	dest := &ir.Definition{
+		Type: enumType,
+		Operation: &ir.UnaryOperation{
+			Kind: ir.Neg,
+			Src:  src,
+		},
+	}
+
+	// Enum values operate as their underlying int values.
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			constraints.RegisterSources[0].RegisterConstraint: registers.Rdx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0xf7, 0xda}, segment.Content.Flatten())
+}
+
 func TestSelectNegFloat32(t *testing.T) {
 	src := ir.NewLocalReference("src")
 	srcDef := &ir.Definition{
@@ -0,0 +1,118 @@
+package instructions
+
+import (
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// <int/uint dest> = rotate left(<int/uint dest>, <uint8 RCX>)
+//
+// NOTE: Unlike shl, the exact operand size variant must be used since the
+// rotated bits depend on the operand size.  The rotation count is masked to
+// 5 bits (6 bits for 64-bit operand), which is always a multiple of the
+// operand's bit width away from the unmasked count.
+//
+// https://www.felixcloutier.com/x86/rcl:rcr:rol:ror
+//
+// 8-bit (MC Op/En):        D2 /0
+// 16/32/64-bit (MC Op/En): D3 /0
+func rol(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	rotate(builder, simpleType, 0, dest)
+}
+
+// <int/uint dest> = rotate left(<int/uint dest>, <imm8>)
+//
+// https://www.felixcloutier.com/x86/rcl:rcr:rol:ror
+//
+// 8-bit (MI8 Op/En):        C0 /0 ib
+// 16/32/64-bit (MI8 Op/En): C1 /0 ib
+func rolIntImmediate(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	immediate interface{}, // uint8
+) {
+	rotateIntImmediate(builder, simpleType, 0, dest, immediate)
+}
+
+// <int/uint dest> = rotate right(<int/uint dest>, <uint8 RCX>)
+//
+// See rol for operand size notes.
+//
+// https://www.felixcloutier.com/x86/rcl:rcr:rol:ror
+//
+// 8-bit (MC Op/En):        D2 /1
+// 16/32/64-bit (MC Op/En): D3 /1
+func ror(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	rotate(builder, simpleType, 1, dest)
+}
+
+// <int/uint dest> = rotate right(<int/uint dest>, <imm8>)
+//
+// https://www.felixcloutier.com/x86/rcl:rcr:rol:ror
+//
+// 8-bit (MI8 Op/En):        C0 /1 ib
+// 16/32/64-bit (MI8 Op/En): C1 /1 ib
+func rorIntImmediate(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	immediate interface{}, // uint8
+) {
+	rotateIntImmediate(builder, simpleType, 1, dest, immediate)
+}
+
+func rotate(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	opCodeExt byte,
+	dest *architecture.Register,
+) {
+	switch simpleType.(type) {
+	case *ir.SignedIntType:
+	case *ir.UnsignedIntType:
+	default:
+		panic("should never happen")
+	}
+
+	operandSize := simpleType.Size()
+	opCode := byte(0xD3)
+	if operandSize == 1 {
+		opCode = 0xD2
+	}
+
+	newM(operandSize, []byte{opCode}, opCodeExt, dest).encode(builder)
+}
+
+func rotateIntImmediate(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	opCodeExt byte,
+	dest *architecture.Register,
+	immediate interface{}, // uint8
+) {
+	switch simpleType.(type) {
+	case *ir.SignedIntType:
+	case *ir.UnsignedIntType:
+	default:
+		panic("should never happen")
+	}
+
+	operandSize := simpleType.Size()
+	opCode := byte(0xC1)
+	if operandSize == 1 {
+		opCode = 0xC0
+	}
+
+	newMI8(operandSize, []byte{opCode}, opCodeExt, dest, immediate).encode(
+		builder)
+}
@@ -0,0 +1,216 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+func TestRotate(t *testing.T) {
+	type testCase struct {
+		name     string
+		encode   encodeMFunc
+		opType   ir.Type
+		dest     *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:   "rol i8 rsi",
+			encode: rol,
+			opType: ir.Int8,
+			dest:   registers.Rsi,
+			expected: []byte{
+				0x40, 0xd2, 0xc6, // rol sil, cl
+			},
+		},
+		{
+			name:   "rol u16 rax",
+			encode: rol,
+			opType: ir.Uint16,
+			dest:   registers.Rax,
+			expected: []byte{
+				0x66, 0xd3, 0xc0, // rol ax, cl
+			},
+		},
+		{
+			name:   "rol i32 r15",
+			encode: rol,
+			opType: ir.Int32,
+			dest:   registers.R15,
+			expected: []byte{
+				0x41, 0xd3, 0xc7, // rol r15d, cl
+			},
+		},
+		{
+			name:   "rol u64 rdx",
+			encode: rol,
+			opType: ir.Uint64,
+			dest:   registers.Rdx,
+			expected: []byte{
+				0x48, 0xd3, 0xc2, // rol rdx, cl
+			},
+		},
+		{
+			name:   "ror u8 rbx",
+			encode: ror,
+			opType: ir.Uint8,
+			dest:   registers.Rbx,
+			expected: []byte{
+				0xd2, 0xcb, // ror bl, cl
+			},
+		},
+		{
+			name:   "ror i16 r8",
+			encode: ror,
+			opType: ir.Int16,
+			dest:   registers.R8,
+			expected: []byte{
+				0x66, 0x41, 0xd3, 0xc8, // ror r8w, cl
+			},
+		},
+		{
+			name:   "ror u32 rdi",
+			encode: ror,
+			opType: ir.Uint32,
+			dest:   registers.Rdi,
+			expected: []byte{
+				0xd3, 0xcf, // ror edi, cl
+			},
+		},
+		{
+			name:   "ror i64 rcx",
+			encode: ror,
+			opType: ir.Int64,
+			dest:   registers.Rcx,
+			expected: []byte{
+				0x48, 0xd3, 0xc9, // ror rcx, cl
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			test.encode(builder, test.opType, test.dest)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func TestRotateImmediate(t *testing.T) {
+	type testCase struct {
+		name     string
+		encode   encodeMIFunc
+		opType   ir.Type
+		dest     *architecture.Register
+		count    uint8
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:   "roli u8 rdi 3",
+			encode: rolIntImmediate,
+			opType: ir.Uint8,
+			dest:   registers.Rdi,
+			count:  uint8(3),
+			expected: []byte{
+				0x40, 0xc0, 0xc7, 0x03, // rol dil, 3
+			},
+		},
+		{
+			name:   "roli i16 rcx 5",
+			encode: rolIntImmediate,
+			opType: ir.Int16,
+			dest:   registers.Rcx,
+			count:  uint8(5),
+			expected: []byte{
+				0x66, 0xc1, 0xc1, 0x05, // rol cx, 5
+			},
+		},
+		{
+			name:   "roli u32 r9 7",
+			encode: rolIntImmediate,
+			opType: ir.Uint32,
+			dest:   registers.R9,
+			count:  uint8(7),
+			expected: []byte{
+				0x41, 0xc1, 0xc1, 0x07, // rol r9d, 7
+			},
+		},
+		{
+			name:   "roli i64 rax 9",
+			encode: rolIntImmediate,
+			opType: ir.Int64,
+			dest:   registers.Rax,
+			count:  uint8(9),
+			expected: []byte{
+				0x48, 0xc1, 0xc0, 0x09, // rol rax, 9
+			},
+		},
+		{
+			name:   "rori i8 rax 1",
+			encode: rorIntImmediate,
+			opType: ir.Int8,
+			dest:   registers.Rax,
+			count:  uint8(1),
+			expected: []byte{
+				0xc0, 0xc8, 0x01, // ror al, 1
+			},
+		},
+		{
+			name:   "rori u16 rsi 15",
+			encode: rorIntImmediate,
+			opType: ir.Uint16,
+			dest:   registers.Rsi,
+			count:  uint8(15),
+			expected: []byte{
+				0x66, 0xc1, 0xce, 0x0f, // ror si, 15
+			},
+		},
+		{
+			name:   "rori i32 rbx 31",
+			encode: rorIntImmediate,
+			opType: ir.Int32,
+			dest:   registers.Rbx,
+			count:  uint8(31),
+			expected: []byte{
+				0xc1, 0xcb, 0x1f, // ror ebx, 31
+			},
+		},
+		{
+			name:   "rori u64 r11 63",
+			encode: rorIntImmediate,
+			opType: ir.Uint64,
+			dest:   registers.R11,
+			count:  uint8(63),
+			expected: []byte{
+				0x49, 0xc1, 0xcb, 0x3f, // ror r11, 63
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			test.encode(builder, test.opType, test.dest, test.count)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
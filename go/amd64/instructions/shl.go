@@ -1,6 +1,7 @@
 package instructions
 
 import (
+	"github.com/pattyshack/chickadee/amd64/registers"
 	"github.com/pattyshack/chickadee/ir"
 	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
@@ -62,3 +63,78 @@ func shlIntImmediate(
 
 	newMI8(operandSize, []byte{0xC1}, 4, dest, immediate).encode(builder)
 }
+
+// <int/uint dest> <<= <uint8 RCX>, with defined semantics (dest is zeroed
+// when the shift count is greater than or equal to dest's bit width):
+//
+//	cmp cl, <bit width>
+//	jb shift
+//	xor <dest>d, <dest>d
+//	jmp end
+//	shift:
+//	shl <dest>, cl
+//	end:
+func shlDefined(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	shiftDefined(
+		builder,
+		simpleType,
+		dest,
+		func(instructions *layout.SegmentBuilder) {
+			setImmediate(instructions, dest, int32(0))
+		},
+		shl)
+}
+
+// <int/uint dest> <<= <imm8>, with defined semantics (dest is zeroed when the
+// shift count is greater than or equal to dest's bit width).
+func shlIntImmediateDefined(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	immediate interface{}, // uint8
+) {
+	if int(immediate.(uint8)) >= 8*simpleType.Size() {
+		setImmediate(builder, dest, int32(0))
+		return
+	}
+
+	shlIntImmediate(builder, simpleType, dest, immediate)
+}
+
+// Shared implementation for shlDefined and shrDefined.  overflow is emitted
+// for shift counts (in RCX) greater than or equal to dest's bit width,
+// otherwise shift is emitted.
+func shiftDefined(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	overflow func(*layout.SegmentBuilder),
+	shift encodeMFunc,
+) {
+	shiftLabel := "shift"
+	end := "end"
+
+	instructions := layout.NewSegmentBuilder()
+
+	// if uint8(<count>) < <bit width>
+	jltIntImmediate(
+		instructions,
+		shiftLabel,
+		ir.Uint8,
+		registers.Rcx,
+		uint8(8*simpleType.Size()))
+
+	overflow(instructions)
+	jump(instructions, end)
+
+	defineInlinedLabel(instructions, shiftLabel)
+	shift(instructions, simpleType, dest)
+
+	defineInlinedLabel(instructions, end)
+
+	appendInlinedInstructions(builder, instructions)
+}
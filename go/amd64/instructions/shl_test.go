@@ -621,3 +621,136 @@ func TestSelectShlUint(t *testing.T) {
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
+
+func TestShlUint32Defined(t *testing.T) {
+	// cmp cl, 32
+	// jb shift
+	// xor ebx, ebx
+	// jmp end
+	// shift:
+	// shl ebx, cl
+	// end:
+	builder := layout.NewSegmentBuilder()
+	shlDefined(builder, ir.Uint32, registers.Rbx)
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x80, 0xf9, 32, // cmp
+			0x0f, 0x82, 7, 0, 0, 0, // jb
+			0x33, 0xdb, // xor
+			0xe9, 2, 0, 0, 0, // jmp
+			0xd3, 0xe3, // shl
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestShlInt64Defined(t *testing.T) {
+	// cmp cl, 64
+	// jb shift
+	// xor r9d, r9d
+	// jmp end
+	// shift:
+	// shl r9, cl
+	// end:
+	builder := layout.NewSegmentBuilder()
+	shlDefined(builder, ir.Int64, registers.R9)
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x80, 0xf9, 64, // cmp
+			0x0f, 0x82, 8, 0, 0, 0, // jb
+			0x45, 0x33, 0xc9, // xor
+			0xe9, 3, 0, 0, 0, // jmp
+			0x49, 0xd3, 0xe1, // shl
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestShlUint16ImmediateDefined(t *testing.T) {
+	// shl edx, 15 (32-bit variant)
+	builder := layout.NewSegmentBuilder()
+	shlIntImmediateDefined(builder, ir.Uint16, registers.Rdx, uint8(15))
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0xc1, 0xe2, 15}, segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+
+	// xor edx, edx
+	builder = layout.NewSegmentBuilder()
+	shlIntImmediateDefined(builder, ir.Uint16, registers.Rdx, uint8(16))
+	segment, err = builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0x33, 0xd2}, segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestSelectShlIntDefined(t *testing.T) {
+	src1 := ir.NewLocalReference("src1")
+	src1Def := &ir.Definition{
+		Name: "src1",
+		Type: ir.Int8,
+	}
+	src1.(*ir.LocalReference).UseDef = src1Def
+
+	src2 := ir.NewLocalReference("src2")
+	src2Def := &ir.Definition{
+		Name: "src2",
+		Type: ir.Uint8,
+	}
+	src2.(*ir.LocalReference).UseDef = src2Def
+
+	dest := &ir.Definition{
+		Type: ir.Int8,
+		Operation: &ir.BinaryOperation{
+			Kind: ir.Shl,
+			Src1: src1,
+			Src2: src2,
+		},
+	}
+
+	config := testConfig
+	config.DefinedSemantics = true
+
+	instruction := architecture.SelectInstruction(
+		config,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(mOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 2, len(constraints.RegisterSources))
+	clobberedRegister := constraints.RegisterSources[0].RegisterConstraint
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			clobberedRegister: registers.Rsi,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x80, 0xf9, 8, // cmp cl, 8
+			0x0f, 0x82, 7, 0, 0, 0, // jb shift
+			0x33, 0xf6, // xor esi, esi
+			0xe9, 2, 0, 0, 0, // jmp end
+			0xd3, 0xe6, // shift: shl esi, cl
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
@@ -70,3 +70,63 @@ func shrIntImmediate(
 
 	newMI8(operandSize, []byte{opCode}, ext, dest, immediate).encode(builder)
 }
+
+// <int/uint dest> >>= <uint8 RCX>, with defined semantics (when the shift
+// count is greater than or equal to dest's bit width, unsigned dest is zeroed
+// and signed dest is filled with its sign bit):
+//
+//	cmp cl, <bit width>
+//	jb shift
+//	xor <dest>d, <dest>d     | sar <dest>, <bit width - 1>
+//	jmp end
+//	shift:
+//	shr <dest>, cl           | sar <dest>, cl
+//	end:
+func shrDefined(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	shiftDefined(
+		builder,
+		simpleType,
+		dest,
+		func(instructions *layout.SegmentBuilder) {
+			shrOverflow(instructions, simpleType, dest)
+		},
+		shr)
+}
+
+// <int/uint dest> >>= <imm8>, with defined semantics (see shrDefined).
+func shrIntImmediateDefined(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+	immediate interface{}, // uint8
+) {
+	if int(immediate.(uint8)) >= 8*simpleType.Size() {
+		shrOverflow(builder, simpleType, dest)
+		return
+	}
+
+	shrIntImmediate(builder, simpleType, dest, immediate)
+}
+
+func shrOverflow(
+	builder *layout.SegmentBuilder,
+	simpleType ir.Type,
+	dest *architecture.Register,
+) {
+	switch simpleType.(type) {
+	case *ir.SignedIntType:
+		shrIntImmediate(
+			builder,
+			simpleType,
+			dest,
+			uint8(8*simpleType.Size()-1))
+	case *ir.UnsignedIntType:
+		setImmediate(builder, dest, int32(0))
+	default:
+		panic("should never happen")
+	}
+}
@@ -387,3 +387,139 @@ func TestSelectShrUint(t *testing.T) {
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
+
+func TestShrInt64Defined(t *testing.T) {
+	// cmp cl, 64
+	// jb shift
+	// sar rsi, 63
+	// jmp end
+	// shift:
+	// sar rsi, cl
+	// end:
+	builder := layout.NewSegmentBuilder()
+	shrDefined(builder, ir.Int64, registers.Rsi)
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x80, 0xf9, 64, // cmp
+			0x0f, 0x82, 9, 0, 0, 0, // jb
+			0x48, 0xc1, 0xfe, 63, // sar
+			0xe9, 3, 0, 0, 0, // jmp
+			0x48, 0xd3, 0xfe, // sar
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestShrUint16Defined(t *testing.T) {
+	// cmp cl, 16
+	// jb shift
+	// xor eax, eax
+	// jmp end
+	// shift:
+	// shr ax, cl
+	// end:
+	builder := layout.NewSegmentBuilder()
+	shrDefined(builder, ir.Uint16, registers.Rax)
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x80, 0xf9, 16, // cmp
+			0x0f, 0x82, 7, 0, 0, 0, // jb
+			0x33, 0xc0, // xor
+			0xe9, 3, 0, 0, 0, // jmp
+			0x66, 0xd3, 0xe8, // shr
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestShrInt8ImmediateDefined(t *testing.T) {
+	// sar al, 3
+	builder := layout.NewSegmentBuilder()
+	shrIntImmediateDefined(builder, ir.Int8, registers.Rax, uint8(3))
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0xc0, 0xf8, 3}, segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+
+	// sar al, 7
+	builder = layout.NewSegmentBuilder()
+	shrIntImmediateDefined(builder, ir.Int8, registers.Rax, uint8(200))
+	segment, err = builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0xc0, 0xf8, 7}, segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestShrUint32ImmediateDefined(t *testing.T) {
+	// xor ecx, ecx
+	builder := layout.NewSegmentBuilder()
+	shrIntImmediateDefined(builder, ir.Uint32, registers.Rcx, uint8(32))
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, []byte{0x33, 0xc9}, segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestSelectShrIntImmediateDefined(t *testing.T) {
+	src := ir.NewLocalReference("src")
+	srcDef := &ir.Definition{
+		Name: "src",
+		Type: ir.Int32,
+	}
+	src.(*ir.LocalReference).UseDef = srcDef
+
+	imm := ir.NewBasicImmediate(uint8(40))
+	immDef := &ir.Definition{
+		Name: "imm",
+		Type: ir.Uint8,
+	}
+	imm.(*ir.Immediate).PseudoDefinition = immDef
+
+	dest := &ir.Definition{
+		Type: ir.Int32,
+		Operation: &ir.BinaryOperation{
+			Kind: ir.Shr,
+			Src1: src,
+			Src2: imm,
+		},
+	}
+
+	config := testConfig
+	config.DefinedSemantics = true
+
+	instruction := architecture.SelectInstruction(
+		config,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(binaryMIOperation)
+	expect.True(t, ok)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 1, len(constraints.RegisterSources))
+	srcRegister := constraints.RegisterSources[0].RegisterConstraint
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			srcRegister: registers.Rdx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	// sar edx, 31
+	expect.Equal(t, []byte{0xc1, 0xfa, 31}, segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
@@ -0,0 +1,355 @@
+package instructions
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+const (
+	// Switches with fewer cases always use compare trees.
+	minJumpTableCases = 4
+
+	// Jump table is used only when at least 1/maxJumpTableSparsity of the
+	// table's entries are non-default cases.
+	maxJumpTableSparsity = 4
+
+	// Compare trees linearly compare sub-trees with at most this many cases.
+	maxLinearCompareCases = 3
+)
+
+type switchCase struct {
+	// The case value's 64-bit representation (sign extended for int, zero
+	// extended for uint).  Int keys are compared as int64.
+	key uint64
+
+	immediate interface{} // int* or uint*
+
+	label string
+}
+
+// Returns the switch's cases sorted by key.
+func newSwitchCases(valueType ir.Type, cases []ir.SwitchCase) []switchCase {
+	isSigned := false
+	switch valueType.(type) {
+	case *ir.SignedIntType:
+		isSigned = true
+	case *ir.UnsignedIntType:
+	default:
+		panic("should never happen")
+	}
+
+	result := make([]switchCase, 0, len(cases))
+	for _, c := range cases {
+		immediate := c.Value.(*ir.Immediate).Value
+
+		var key uint64
+		switch value := immediate.(type) {
+		case int8:
+			key = uint64(int64(value))
+		case int16:
+			key = uint64(int64(value))
+		case int32:
+			key = uint64(int64(value))
+		case int64:
+			key = uint64(value)
+		case uint8:
+			key = uint64(value)
+		case uint16:
+			key = uint64(value)
+		case uint32:
+			key = uint64(value)
+		case uint64:
+			key = value
+		default:
+			panic(fmt.Sprintf("invalid switch case value: %#v", immediate))
+		}
+
+		result = append(
+			result,
+			switchCase{
+				key:       key,
+				immediate: immediate,
+				label:     c.Label,
+			})
+	}
+
+	sort.Slice(
+		result,
+		func(i int, j int) bool {
+			if isSigned {
+				return int64(result[i].key) < int64(result[j].key)
+			}
+			return result[i].key < result[j].key
+		})
+
+	return result
+}
+
+// The number of entries in the jump table spanning [min case, max case].
+// Returns 0 if jump table should not be used.
+func numJumpTableEntries(cases []switchCase) int {
+	if len(cases) < minJumpTableCases {
+		return 0
+	}
+
+	// NOTE: the difference is correct for both signed and unsigned keys since
+	// the cases are sorted.
+	span := cases[len(cases)-1].key - cases[0].key
+	if span >= uint64(maxJumpTableSparsity*len(cases)) {
+		return 0
+	}
+
+	return int(span) + 1
+}
+
+// Whether the compare tree needs a scratch register for loading 64-bit case
+// values that are not representable as sign extended 32-bit immediates.
+func compareTreeRequiresScratch(cases []switchCase) bool {
+	for _, c := range cases {
+		if !isMISupportedImmediateValue(c.immediate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare tree (binary search) of the form:
+//
+//	cmp <value>, <mid case>
+//	je <mid case label>
+//	jl/jb <less>
+//	<compare tree for cases greater than mid case>
+//	less:
+//	<compare tree for cases less than mid case>
+//
+// Sub-trees with few cases are linearly compared, followed by a jump to the
+// default label.
+//
+// NOTE: labelPrefix must be unique within the function.  The scratch register
+// is only used (and may be nil otherwise) for loading 64-bit case values that
+// are not representable as sign extended 32-bit immediates.
+func switchCompareTree(
+	builder *layout.SegmentBuilder,
+	labelPrefix string,
+	valueType ir.Type,
+	value *architecture.Register,
+	scratch *architecture.Register,
+	cases []switchCase,
+	defaultLabel string,
+) {
+	lessOpCode := []byte{0x0F, 0x82} // jb
+	if _, ok := valueType.(*ir.SignedIntType); ok {
+		lessOpCode = []byte{0x0F, 0x8C} // jl
+	}
+
+	compareCase := func(c switchCase) {
+		if isMISupportedImmediateValue(c.immediate) {
+			compareIntImmediate(builder, valueType, value, c.immediate)
+		} else {
+			setImmediate(builder, scratch, c.immediate)
+			compare(builder, valueType, value, scratch)
+		}
+	}
+
+	numSubTrees := 0
+
+	var emit func([]switchCase)
+	emit = func(cases []switchCase) {
+		if len(cases) <= maxLinearCompareCases {
+			for _, c := range cases {
+				compareCase(c)
+				d32Instruction(
+					builder,
+					[]byte{0x0F, 0x84}, // je
+					layout.BasicBlockKind,
+					c.label)
+			}
+			jump(builder, defaultLabel)
+			return
+		}
+
+		mid := len(cases) / 2
+		less := fmt.Sprintf("%s.less.%d", labelPrefix, numSubTrees)
+		numSubTrees++
+
+		compareCase(cases[mid])
+		d32Instruction(
+			builder,
+			[]byte{0x0F, 0x84}, // je
+			layout.BasicBlockKind,
+			cases[mid].label)
+		d32Instruction(builder, lessOpCode, layout.BasicBlockKind, less)
+
+		emit(cases[mid+1:])
+
+		defineLabel(builder, less)
+		emit(cases[:mid])
+	}
+
+	emit(cases)
+}
+
+// Position independent jump table of the form:
+//
+//	movzx/movsx/mov <index>, <value>   // extend to 64-bit
+//	sub <index>, <min case>
+//	cmp <index>, <number of entries - 1>
+//	ja <default label>                 // unsigned compare also covers < min
+//	lea <table>, [rip + table]
+//	movsxd <index>, dword [<table> + <index> * 4]
+//	add <index>, <table>
+//	jmp <index>
+//
+// where the table is placed in .rodata (as the function's read-only data):
+//
+//	table:
+//	dd <entry label> - table           // one entry per value in [min, max]
+//	...
+//
+// NOTE: the entries are pc relative relocations with addends that shift the
+// entries' bases back to the table's start.  labelPrefix must be unique
+// within the function.
+func switchJumpTable(
+	builder *layout.SegmentBuilder,
+	labelPrefix string,
+	valueType ir.Type,
+	value *architecture.Register,
+	index *architecture.Register,
+	table *architecture.Register,
+	cases []switchCase,
+	defaultLabel string,
+) {
+	numEntries := numJumpTableEntries(cases)
+	if numEntries == 0 {
+		panic("should never happen")
+	}
+
+	if valueType.Size() == 8 {
+		copyGeneral(builder, 8, index, value)
+	} else {
+		extendInt(builder, 8, index, valueType, value)
+	}
+
+	minKey := cases[0].key
+	if minKey != 0 {
+		if isMISupportedImmediateValue(int64(minKey)) {
+			subIntImmediate(builder, ir.Int64, index, int64(minKey))
+		} else {
+			setImmediate(builder, table, minKey)
+			sub(builder, ir.Int64, index, table)
+		}
+	}
+
+	compareIntImmediate(builder, ir.Uint64, index, uint64(numEntries-1))
+	d32Instruction(
+		builder,
+		[]byte{0x0F, 0x87}, // ja
+		layout.BasicBlockKind,
+		defaultLabel)
+
+	tableLabel := labelPrefix + ".table"
+	computeLabelAddress(builder, table, tableLabel)
+	loadJumpTableEntry(builder, index, table, index)
+	add(builder, ir.Int64, index, table)
+	jumpIndirect(builder, index)
+
+	entries := make([]string, numEntries)
+	for idx := range entries {
+		entries[idx] = defaultLabel
+	}
+	for _, c := range cases {
+		entries[c.key-minKey] = c.label
+	}
+
+	relocations := make([]*layout.Relocation, 0, numEntries)
+	for idx, label := range entries {
+		relocations = append(
+			relocations,
+			&layout.Relocation{
+				Name:   label,
+				Offset: int64(4 * idx),
+				// rel32 is relative to the end of the entry.
+				Addend: int64(4 * (idx + 1)),
+			})
+	}
+
+	builder.AppendReadOnlyData(
+		make([]byte, 4*numEntries),
+		layout.Definitions{
+			Labels: []*layout.Symbol{
+				{
+					Kind: layout.BasicBlockKind,
+					Name: tableLabel,
+				},
+			},
+		},
+		layout.Relocations{
+			Labels: relocations,
+		})
+}
+
+// <int64 dest> = sign extended dword [<table> + <index> * 4]
+//
+// https://www.felixcloutier.com/x86/movsx:movsxd
+//
+// (RM Op/En): REX.W 63 /r
+func loadJumpTableEntry(
+	builder *layout.SegmentBuilder,
+	dest *architecture.Register,
+	table *architecture.Register,
+	index *architecture.Register,
+) {
+	if !dest.AllowGeneralOperations ||
+		!table.AllowGeneralOperations ||
+		!index.AllowGeneralOperations {
+
+		panic("invalid register")
+	}
+
+	// SIB byte = (SIB.scale, SIB.index, SIB.base) where
+	//
+	// SIB.scale = 10 (factor s = 4)
+	// SIB.index = <REX.X>.<index>
+	// SIB.base = <REX.B>.<table>
+	sib := byte(0b10_000_000) |
+		byte(index.Encoding&0x07)<<3 |
+		byte(table.Encoding&0x07)
+
+	spec := modRMSpec{
+		requireRexWBit:    true,
+		requireRexRBit:    (dest.Encoding & 0x08) != 0,
+		requireRexXBit:    (index.Encoding & 0x08) != 0,
+		requireRexBBit:    (table.Encoding & 0x08) != 0,
+		opCode:            []byte{0x63},
+		mode:              indirectDisp0ModRMMode,
+		reg:               byte(dest.Encoding & 0x07),
+		rm:                0b100, // [SIB]
+		sibAndOrImmediate: []byte{sib},
+	}
+
+	if table.Encoding&0x07 == 5 { // either rbp or r13
+		// NOTE: SIB.base = 101 refers to [<index> * s + disp32] rather than
+		// [<base> + <index> * s] in indirectDisp0ModRMMode.
+		spec.mode = indirectDisp8ModRMMode
+		spec.sibAndOrImmediate = append(spec.sibAndOrImmediate, 0)
+	}
+
+	spec.encode(builder)
+}
+
+// jmp <general target>
+//
+// https://www.felixcloutier.com/x86/jmp
+//
+// (M Op/En): FF /4
+func jumpIndirect(
+	builder *layout.SegmentBuilder,
+	target *architecture.Register,
+) {
+	// NOTE: the operand size is always 64-bit (REX.W is not needed).
+	newM(4, []byte{0xFF}, 4, target).encode(builder)
+}
@@ -0,0 +1,629 @@
+package instructions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/amd64/registers"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/architecture"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// Case i's label is "case<i>".
+func newTestSwitchCases(values ...interface{}) []ir.SwitchCase {
+	cases := []ir.SwitchCase{}
+	for idx, value := range values {
+		cases = append(
+			cases,
+			ir.SwitchCase{
+				Value: ir.NewBasicImmediate(value),
+				Label: fmt.Sprintf("case%d", idx),
+			})
+	}
+	return cases
+}
+
+func TestNewSwitchCases(t *testing.T) {
+	cases := newSwitchCases(
+		ir.Int16,
+		newTestSwitchCases(int16(3), int16(-1), int16(-20), int16(0)))
+
+	expect.Equal(
+		t,
+		[]switchCase{
+			{key: uint64(0xffffffffffffffec), immediate: int16(-20), label: "case2"},
+			{key: uint64(0xffffffffffffffff), immediate: int16(-1), label: "case1"},
+			{key: 0, immediate: int16(0), label: "case3"},
+			{key: 3, immediate: int16(3), label: "case0"},
+		},
+		cases)
+
+	cases = newSwitchCases(
+		ir.Uint64,
+		newTestSwitchCases(uint64(1<<63), uint64(7), uint64(2)))
+
+	expect.Equal(
+		t,
+		[]switchCase{
+			{key: 2, immediate: uint64(2), label: "case2"},
+			{key: 7, immediate: uint64(7), label: "case1"},
+			{key: 1 << 63, immediate: uint64(1 << 63), label: "case0"},
+		},
+		cases)
+}
+
+func TestNumJumpTableEntries(t *testing.T) {
+	type testCase struct {
+		name      string
+		valueType ir.Type
+		values    []interface{}
+		expected  int
+	}
+
+	testCases := []testCase{
+		{
+			name:      "too few cases",
+			valueType: ir.Uint32,
+			values:    []interface{}{uint32(1), uint32(2), uint32(3)},
+			expected:  0,
+		},
+		{
+			name:      "dense",
+			valueType: ir.Uint32,
+			values:    []interface{}{uint32(1), uint32(2), uint32(3), uint32(5)},
+			expected:  5,
+		},
+		{
+			name:      "max sparsity",
+			valueType: ir.Int8,
+			values:    []interface{}{int8(-8), int8(0), int8(1), int8(7)},
+			expected:  16,
+		},
+		{
+			name:      "too sparse",
+			valueType: ir.Int8,
+			values:    []interface{}{int8(-8), int8(0), int8(1), int8(8)},
+			expected:  0,
+		},
+		{
+			name:      "span overflow",
+			valueType: ir.Int64,
+			values: []interface{}{
+				int64(-1 << 63),
+				int64(0),
+				int64(1),
+				int64(1<<63 - 1),
+			},
+			expected: 0,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			cases := newSwitchCases(
+				test.valueType,
+				newTestSwitchCases(test.values...))
+			expect.Equal(t, test.expected, numJumpTableEntries(cases))
+		})
+	}
+}
+
+func TestSwitchLinearCompare(t *testing.T) {
+	builder := layout.NewSegmentBuilder()
+	switchCompareTree(
+		builder,
+		"%switch.b0",
+		ir.Int32,
+		registers.Rdi,
+		nil,
+		newSwitchCases(ir.Int32, newTestSwitchCases(int32(2), int32(1))),
+		"default")
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x81, 0xff, 0x01, 0x00, 0x00, 0x00, // cmp edi, 1
+			0x0f, 0x84, 0, 0, 0, 0, // je case1
+			0x81, 0xff, 0x02, 0x00, 0x00, 0x00, // cmp edi, 2
+			0x0f, 0x84, 0, 0, 0, 0, // je case0
+			0xe9, 0, 0, 0, 0, // jmp default
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Labels: []*layout.Relocation{
+				{
+					Name:   "case1",
+					Offset: 8,
+				},
+				{
+					Name:   "case0",
+					Offset: 20,
+				},
+				{
+					Name:   "default",
+					Offset: 25,
+				},
+			},
+		},
+		segment.Relocations)
+}
+
+func TestSwitchCompareTree(t *testing.T) {
+	builder := layout.NewSegmentBuilder()
+	switchCompareTree(
+		builder,
+		"%switch.b0",
+		ir.Uint64,
+		registers.Rsi,
+		registers.R10,
+		newSwitchCases(
+			ir.Uint64,
+			newTestSwitchCases(uint64(1<<40), uint64(3), uint64(1), uint64(2))),
+		"default")
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x48, 0x81, 0xfe, 0x03, 0x00, 0x00, 0x00, // cmp rsi, 3
+			0x0f, 0x84, 0, 0, 0, 0, // je case1
+			0x0f, 0x82, 0x18, 0x00, 0x00, 0x00, // jb %switch.b0.less.0
+			// mov r10, 1 << 40
+			0x49, 0xba, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+			0x49, 0x3b, 0xf2, // cmp rsi, r10
+			0x0f, 0x84, 0, 0, 0, 0, // je case0
+			0xe9, 0, 0, 0, 0, // jmp default
+			// %switch.b0.less.0:
+			0x48, 0x81, 0xfe, 0x01, 0x00, 0x00, 0x00, // cmp rsi, 1
+			0x0f, 0x84, 0, 0, 0, 0, // je case2
+			0x48, 0x81, 0xfe, 0x02, 0x00, 0x00, 0x00, // cmp rsi, 2
+			0x0f, 0x84, 0, 0, 0, 0, // je case3
+			0xe9, 0, 0, 0, 0, // jmp default
+		},
+		segment.Content.Flatten())
+	expect.Equal(
+		t,
+		layout.Definitions{
+			Labels: []*layout.Symbol{
+				{
+					Kind:   layout.BasicBlockKind,
+					Name:   "%switch.b0.less.0",
+					Offset: 43,
+				},
+			},
+		},
+		segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Labels: []*layout.Relocation{
+				{Name: "case1", Offset: 9},
+				{Name: "case0", Offset: 34},
+				{Name: "default", Offset: 39},
+				{Name: "case2", Offset: 52},
+				{Name: "case3", Offset: 65},
+				{Name: "default", Offset: 70},
+			},
+		},
+		segment.Relocations)
+}
+
+func TestSwitchJumpTable(t *testing.T) {
+	builder := layout.NewSegmentBuilder()
+	switchJumpTable(
+		builder,
+		"%switch.b0",
+		ir.Uint8,
+		registers.Rdi,
+		registers.Rcx,
+		registers.R13,
+		newSwitchCases(
+			ir.Uint8,
+			newTestSwitchCases(uint8(5), uint8(2), uint8(3), uint8(1))),
+		"default")
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0x40, 0x0f, 0xb6, 0xcf, // movzx ecx, dil
+			0x48, 0x81, 0xe9, 0x01, 0x00, 0x00, 0x00, // sub rcx, 1
+			0x48, 0x81, 0xf9, 0x04, 0x00, 0x00, 0x00, // cmp rcx, 4
+			0x0f, 0x87, 0, 0, 0, 0, // ja default
+			0x4c, 0x8d, 0x2d, 0, 0, 0, 0, // lea r13, [rip + table]
+			0x49, 0x63, 0x4c, 0x8d, 0x00, // movsxd rcx, dword [r13 + rcx*4 + 0]
+			0x49, 0x03, 0xcd, // add rcx, r13
+			0xff, 0xe1, // jmp rcx
+		},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Labels: []*layout.Relocation{
+				{Name: "default", Offset: 20},
+				{Name: "%switch.b0.table", Offset: 27},
+			},
+		},
+		segment.Relocations)
+
+	// The table is placed in the function's read-only data.
+	table, err := builder.ReadOnlyData.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, make([]byte, 20), table.Content.Flatten())
+	expect.Equal(
+		t,
+		layout.Definitions{
+			Labels: []*layout.Symbol{
+				{
+					Kind: layout.BasicBlockKind,
+					Name: "%switch.b0.table",
+				},
+			},
+		},
+		table.Definitions)
+
+	entry := func(name string, idx int64) *layout.Relocation {
+		return &layout.Relocation{
+			Name:   name,
+			Offset: 4 * idx,
+			Addend: 4 * (idx + 1),
+		}
+	}
+
+	expect.Equal(
+		t,
+		layout.Relocations{
+			Labels: []*layout.Relocation{
+				entry("case3", 0),
+				entry("case1", 1),
+				entry("case2", 2),
+				entry("default", 3),
+				entry("case0", 4),
+			},
+		},
+		table.Relocations)
+}
+
+func TestSwitchJumpTableLargeMin(t *testing.T) {
+	function := &layout.Symbol{
+		Kind:    layout.FunctionKind,
+		Section: layout.TextSection,
+		Name:    "main",
+	}
+
+	builder := layout.NewSegmentBuilder()
+	builder.AppendData(
+		nil,
+		layout.Definitions{
+			Symbols: []*layout.Symbol{function},
+		},
+		layout.Relocations{})
+
+	switchJumpTable(
+		builder,
+		"%switch.b0",
+		ir.Int64,
+		registers.Rbx,
+		registers.R8,
+		registers.Rdx,
+		newSwitchCases(
+			ir.Int64,
+			newTestSwitchCases(
+				int64(1<<33),
+				int64(1<<33+1),
+				int64(1<<33+2),
+				int64(1<<33+3))),
+		"default")
+
+	// Resolve the table's entries.
+	for idx := 0; idx < 4; idx++ {
+		defineLabel(builder, fmt.Sprintf("case%d", idx))
+		builder.AppendBasicData([]byte{0x90}) // nop
+	}
+	defineLabel(builder, "default")
+	function.Size = builder.Size
+
+	objectBuilder := layout.NewObjectFileBuilder()
+	err := objectBuilder.AppendFunction(amd64.LinuxLayout, builder)
+	expect.Nil(t, err)
+
+	file, err := objectBuilder.Finalize(amd64.LinuxLayout)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(amd64.LinuxLayout, "main")
+	expect.Nil(t, err)
+
+	// The table is in .rodata, and its entries are relative to the table's
+	// start.
+	textStart := image.ExecutableSegmentStart
+	tableStart := image.ReadOnlySegmentStart
+	tableDisp := tableStart - (textStart + 36)
+
+	expect.Equal(
+		t,
+		[]byte{
+			0x4c, 0x8b, 0xc3, // mov r8, rbx
+			// mov rdx, 1 << 33
+			0x48, 0xba, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+			0x4c, 0x2b, 0xc2, // sub r8, rdx
+			0x49, 0x81, 0xf8, 0x03, 0x00, 0x00, 0x00, // cmp r8, 3
+			0x0f, 0x87, 0x15, 0x00, 0x00, 0x00, // ja default
+			// lea rdx, [rip + table]
+			0x48, 0x8d, 0x15,
+			byte(tableDisp), byte(tableDisp >> 8),
+			byte(tableDisp >> 16), byte(tableDisp >> 24),
+			0x4e, 0x63, 0x04, 0x82, // movsxd r8, dword [rdx + r8*4]
+			0x4c, 0x03, 0xc2, // add r8, rdx
+			0x41, 0xff, 0xe0, // jmp r8
+			0x90, 0x90, 0x90, 0x90,
+		},
+		image.Text.Flatten()[:50])
+
+	entries := []byte{}
+	for idx := int64(0); idx < 4; idx++ {
+		delta := textStart + 46 + idx - tableStart
+		entries = append(
+			entries,
+			byte(delta), byte(delta>>8), byte(delta>>16), byte(delta>>24))
+	}
+	expect.Equal(t, entries, image.ReadOnlyData.Flatten()[:16])
+}
+
+func TestLoadJumpTableEntry(t *testing.T) {
+	type testCase struct {
+		name     string
+		dest     *architecture.Register
+		table    *architecture.Register
+		index    *architecture.Register
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{
+			name:     "movsxd rax, dword [rcx + rdx*4]",
+			dest:     registers.Rax,
+			table:    registers.Rcx,
+			index:    registers.Rdx,
+			expected: []byte{0x48, 0x63, 0x04, 0x91},
+		},
+		{
+			name:     "movsxd r8, dword [rbp + r13*4 + 0]",
+			dest:     registers.R8,
+			table:    registers.Rbp,
+			index:    registers.R13,
+			expected: []byte{0x4e, 0x63, 0x44, 0xad, 0x00},
+		},
+		{
+			name:     "movsxd rsi, dword [r13 + rbp*4 + 0]",
+			dest:     registers.Rsi,
+			table:    registers.R13,
+			index:    registers.Rbp,
+			expected: []byte{0x49, 0x63, 0x74, 0xad, 0x00},
+		},
+		{
+			name:     "movsxd r15, dword [r12 + r9*4]",
+			dest:     registers.R15,
+			table:    registers.R12,
+			index:    registers.R9,
+			expected: []byte{0x4f, 0x63, 0x3c, 0x8c},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			builder := layout.NewSegmentBuilder()
+			loadJumpTableEntry(builder, test.dest, test.table, test.index)
+			segment, err := builder.Finalize(amd64.ArchitectureLayout)
+			expect.Nil(t, err)
+			expect.Equal(t, test.expected, segment.Content.Flatten())
+			expect.Equal(t, layout.Definitions{}, segment.Definitions)
+			expect.Equal(t, layout.Relocations{}, segment.Relocations)
+		})
+	}
+}
+
+func TestJumpIndirect(t *testing.T) {
+	builder := layout.NewSegmentBuilder()
+	jumpIndirect(builder, registers.Rax)
+	jumpIndirect(builder, registers.R11)
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		[]byte{
+			0xff, 0xe0, // jmp rax
+			0x41, 0xff, 0xe3, // jmp r11
+		},
+		segment.Content.Flatten())
+}
+
+func newTestSwitch(
+	valueType ir.Type,
+	values ...interface{},
+) *ir.Switch {
+	value := ir.NewLocalReference("value")
+	value.(*ir.LocalReference).UseDef = &ir.Definition{
+		Name: "value",
+		Type: valueType,
+	}
+
+	switchInst := &ir.Switch{
+		Value:        value,
+		Cases:        newTestSwitchCases(values...),
+		DefaultLabel: "default",
+	}
+	switchInst.SetParentBlock(&ir.Block{Label: "b0"})
+
+	return switchInst
+}
+
+func TestSelectSwitchJumpTable(t *testing.T) {
+	switchInst := newTestSwitch(
+		ir.Uint8,
+		uint8(5), uint8(2), uint8(3), uint8(1))
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		switchInst,
+		architecture.SelectorHint{})
+
+	inst, ok := instruction.(switchInstruction)
+	expect.True(t, ok)
+	expect.True(t, inst.useJumpTable)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 3, len(constraints.RegisterSources))
+	expect.Equal(t, 0, len(constraints.RegisterDestinations))
+
+	value := constraints.RegisterSources[0]
+	expect.False(t, value.Clobbered)
+	expect.Equal(t, switchInst.Value.Def().Chunks()[0], value.DefinitionChunk)
+
+	index := constraints.RegisterSources[1]
+	expect.True(t, index.Clobbered)
+	expect.Nil(t, index.DefinitionChunk)
+
+	table := constraints.RegisterSources[2]
+	expect.True(t, table.Clobbered)
+	expect.Nil(t, table.DefinitionChunk)
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			value.RegisterConstraint: registers.Rdi,
+			index.RegisterConstraint: registers.Rcx,
+			table.RegisterConstraint: registers.R13,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, 2, len(segment.Relocations.Labels))
+
+	readOnlyData, err := builder.ReadOnlyData.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+	expect.Equal(
+		t,
+		layout.Definitions{
+			Labels: []*layout.Symbol{
+				{
+					Kind: layout.BasicBlockKind,
+					Name: "%switch.b0.table",
+				},
+			},
+		},
+		readOnlyData.Definitions)
+	expect.Equal(t, 5, len(readOnlyData.Relocations.Labels))
+}
+
+func TestSelectSwitchCompareTree(t *testing.T) {
+	switchInst := newTestSwitch(
+		ir.Int32,
+		int32(100), int32(-100), int32(0), int32(1000))
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		switchInst,
+		architecture.SelectorHint{})
+
+	inst, ok := instruction.(switchInstruction)
+	expect.True(t, ok)
+	expect.False(t, inst.useJumpTable)
+
+	constraints := instruction.Constraints()
+	expect.Equal(
+		t,
+		architecture.InstructionConstraints{
+			RegisterSources: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						AnyGeneral: true,
+					},
+					DefinitionChunk: switchInst.Value.Def().Chunks()[0],
+				},
+			},
+		},
+		constraints)
+}
+
+func TestSelectSwitchCompareTreeWithScratch(t *testing.T) {
+	switchInst := newTestSwitch(ir.Uint64, uint64(1), uint64(1<<40))
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		switchInst,
+		architecture.SelectorHint{})
+
+	inst, ok := instruction.(switchInstruction)
+	expect.True(t, ok)
+	expect.False(t, inst.useJumpTable)
+
+	constraints := instruction.Constraints()
+	expect.Equal(t, 2, len(constraints.RegisterSources))
+
+	scratch := constraints.RegisterSources[1]
+	expect.True(t, scratch.Clobbered)
+	expect.Nil(t, scratch.DefinitionChunk)
+}
+
+func TestSelectSwitchEnum(t *testing.T) {
+	enumType := ir.NewEnumType(
+		"color",
+		ir.Uint8,
+		[]ir.EnumValue{
+			{Name: "red", Value: 5},
+			{Name: "green", Value: 2},
+			{Name: "blue", Value: 3},
+			{Name: "white", Value: 1},
+		})
+
+	enumSwitch := newTestSwitch(enumType)
+	for idx, value := range enumType.Values {
+		enumSwitch.Cases = append(
+			enumSwitch.Cases,
+			ir.SwitchCase{
+				Value: ir.NewEnumImmediate(enumType, value.Name),
+				Label: fmt.Sprintf("case%d", idx),
+			})
+	}
+
+	// The enum switch is equivalent to the underlying type's switch.
+	uint8Switch := newTestSwitch(
+		ir.Uint8,
+		uint8(5), uint8(2), uint8(3), uint8(1))
+
+	emit := func(switchInst *ir.Switch) []byte {
+		instruction := architecture.SelectInstruction(
+			testConfig,
+			switchInst,
+			architecture.SelectorHint{})
+
+		inst, ok := instruction.(switchInstruction)
+		expect.True(t, ok)
+		expect.True(t, inst.useJumpTable)
+
+		sources := instruction.Constraints().RegisterSources
+		builder := layout.NewSegmentBuilder()
+		instruction.EmitTo(
+			builder,
+			map[*architecture.RegisterConstraint]*architecture.Register{
+				sources[0].RegisterConstraint: registers.Rdi,
+				sources[1].RegisterConstraint: registers.Rcx,
+				sources[2].RegisterConstraint: registers.R13,
+			})
+		segment, err := builder.Finalize(amd64.ArchitectureLayout)
+		expect.Nil(t, err)
+		return segment.Content.Flatten()
+	}
+
+	expect.Equal(t, emit(uint8Switch), emit(enumSwitch))
+}
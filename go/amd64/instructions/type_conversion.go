@@ -1,7 +1,6 @@
 package instructions
 
 import (
-	amd64 "github.com/pattyshack/chickadee/amd64/layout"
 	"github.com/pattyshack/chickadee/ir"
 	"github.com/pattyshack/chickadee/platform/architecture"
 	"github.com/pattyshack/chickadee/platform/layout"
@@ -60,14 +59,16 @@ func convertFloatToFloat(
 // int bytes may be larger than the dest size (the dest type will ignore those
 // bytes).
 //
-// NOTE: we'll follow c conversion of truncating the decimals
-// (cvttss2si/cvttsd2si) rather than rounding (cvtss2si/cvtsd2si).
+// NOTE: we'll follow c conversion of truncating the decimals toward zero
+// (cvttss2si/cvttsd2si), e.g., 2.7 converts to 2 and -2.7 converts to -2.
+// This produces a signed integer; uint64 dest is handled by
+// convertFloatToUint64, which splits the src at 2^63.
 //
 // https://www.felixcloutier.com/x86/cvttss2si
 // https://www.felixcloutier.com/x86/cvttsd2si
 //
-// 8/16/32-bit dest cvttss2si/cvttsdsi (A Op/En): 0F 2D /r
-// 64-bit dest cvttss2si/cvttsdsi (A Op/En):      REX.W 0F 2D /r
+// 8/16/32-bit dest cvttss2si/cvttsdsi (A Op/En): 0F 2C /r
+// 64-bit dest cvttss2si/cvttsdsi (A Op/En):      REX.W 0F 2C /r
 func convertFloatToInt(
 	builder *layout.SegmentBuilder,
 	destType ir.Type,
@@ -82,7 +83,7 @@ func convertFloatToInt(
 	spec := _newRMI(
 		true, // isFloat
 		srcType.(*ir.FloatType).ByteSize,
-		[]byte{0x0F, 0x2D},
+		[]byte{0x0F, 0x2C},
 		dest,
 		src,
 		nil)
@@ -94,6 +95,144 @@ func convertFloatToInt(
 	spec.encode(builder)
 }
 
+// <uint8/uint16/uint32 dest> = <float src>
+//
+// NOTE: dest is a general register and src is a float register.  The float is
+// always converted using the 64-bit dest variant since the 32-bit variant
+// cannot represent uint32 values in [2^31, 2^32).  The converted int bytes are
+// larger than the dest size (the dest type will ignore those bytes).
+//
+// Negative and out of range src values are truncated to int64 and then
+// wrapped modulo 2^(8*dest size), i.e., the result is the same as converting
+// the src to int64 and then converting the int64 to the dest type.  NaN and
+// values outside of the int64 range convert to zero.
+//
+// https://www.felixcloutier.com/x86/cvttss2si
+// https://www.felixcloutier.com/x86/cvttsd2si
+//
+// cvttss2si/cvttsdsi (A Op/En): REX.W 0F 2C /r
+func convertFloatToSmallUint(
+	builder *layout.SegmentBuilder,
+	destType ir.Type,
+	dest *architecture.Register,
+	srcType ir.Type,
+	src *architecture.Register,
+) {
+	if destType.Size() == 8 { // uint64 is handled differently
+		panic("should never happen")
+	}
+
+	convertFloatToInt(builder, ir.Int64, dest, srcType, src)
+}
+
+// <uint64 dest> = <float src>
+//
+// NOTE: dest is a general register while src and scratch are float registers.
+// Both src and scratch are clobbered.
+//
+// NOTE: uint64 must be special-cased since cvtts*2si produces a signed
+// integer.  When the src is at least 2^63, we'll subtract 2^63 from the src
+// prior to conversion and then restore the top bit (this matches gcc
+// behavior).
+//
+//	<dest> = <2^63 float bits> // dest is used as a temporary
+//	<scratch> = <dest>
+//	if <src> >= <scratch> {
+//	  <src> = sub <src>, <scratch>
+//	  <dest> = [cvtts*2si] <src>
+//	  <dest> = btc <dest>, 63      // restore the top bit
+//	} else {
+//	  <dest> = [cvtts*2si] <src>
+//	}
+//
+// Defined results for negative and out of range src values:
+//   - (-1, 2^64): the truncated value.
+//   - [-2^63, -1]: the truncated int64 value's two's complement bit pattern
+//     (i.e., the value wraps modulo 2^64, same as converting to int64 first).
+//   - NaN and values less than -2^63: 0x8000000000000000 (the integer
+//     indefinite value).
+//   - Values at least 2^64 (including +Inf): 0 (the integer indefinite value
+//     with the top bit flipped).
+//
+// https://www.felixcloutier.com/x86/cvttss2si
+// https://www.felixcloutier.com/x86/cvttsd2si
+// https://www.felixcloutier.com/x86/btc
+//
+// cvttss2si/cvttsdsi (A Op/En): REX.W 0F 2C /r
+// btc (MI Op/En):               REX.W 0F BA /7 ib
+func convertFloatToUint64(
+	builder *layout.SegmentBuilder,
+	srcType ir.Type,
+	dest *architecture.Register,
+	src *architecture.Register,
+	scratch *architecture.Register,
+) {
+	if !dest.AllowGeneralOperations {
+		panic("invalid register")
+	}
+
+	if !src.AllowFloatOperations {
+		panic("invalid register")
+	}
+
+	if !scratch.AllowFloatOperations {
+		panic("invalid register")
+	}
+
+	operandSize := srcType.(*ir.FloatType).ByteSize
+	var twoPow63 interface{}
+	switch operandSize {
+	case 4:
+		twoPow63 = float32(1 << 63)
+	case 8:
+		twoPow63 = float64(1 << 63)
+	default:
+		panic("should never happen")
+	}
+
+	largeValue := "large value"
+	end := "end"
+
+	instructions := layout.NewSegmentBuilder()
+
+	// <scratch> = 2^63
+	setImmediate(instructions, dest, twoPow63)
+	copyGeneralToFloat(instructions, operandSize, scratch, dest)
+
+	// if <src> >= 2^63
+	jge(instructions, largeValue, srcType, src, scratch)
+
+	//
+	// small value branch (NaN is unordered and is also handled here)
+	//
+
+	convertFloatToInt(instructions, ir.Int64, dest, srcType, src)
+
+	jump(instructions, end)
+
+	//
+	// large value branch
+	//
+
+	defineInlinedLabel(instructions, largeValue)
+
+	// <src> = <src> - 2^63
+	sub(instructions, srcType, src, scratch)
+
+	convertFloatToInt(instructions, ir.Int64, dest, srcType, src)
+
+	// btc <dest>, 63
+	newMI8(8, []byte{0x0F, 0xBA}, 7, dest, uint8(63)).encode(instructions)
+
+	//
+	// end of inlined conversion function
+	//
+
+	defineInlinedLabel(instructions, end)
+
+	appendInlinedInstructions(builder, instructions)
+}
+
 // <dest-sized float dest> = <src-sized signed int src>
 //
 // NOTE: dest is a float register and src is a general register.  8/16-bit src
@@ -284,18 +423,7 @@ func convertUint64ToFloat(
 	// non-negative src branch
 	//
 
-	instructions.AppendData(
-		nil,
-		layout.Definitions{
-			Labels: []*layout.Symbol{
-				{
-					Kind:   layout.BasicBlockKind,
-					Name:   nonNegative,
-					Offset: 0,
-				},
-			},
-		},
-		layout.Relocations{})
+	defineInlinedLabel(instructions, nonNegative)
 
 	// cvtsi2ss or cvtsi2sd
 	cvt.encode(instructions)
@@ -304,33 +432,7 @@ func convertUint64ToFloat(
 	// end of inlined conversion function
 	//
 
-	// Add end label
-	instructions.AppendData(
-		nil,
-		layout.Definitions{
-			Labels: []*layout.Symbol{
-				{
-					Kind:   layout.BasicBlockKind,
-					Name:   end,
-					Offset: 0,
-				},
-			},
-		},
-		layout.Relocations{})
-
-	segment, err := instructions.Finalize(amd64.ArchitectureLayout)
-	if err != nil {
-		panic(err)
-	}
-
-	if len(segment.Relocations.Labels) > 0 ||
-		len(segment.Relocations.Symbols) > 0 {
-
-		panic("should never happen")
-	}
-
-	// Drop nonNegative / end labels
-	segment.Definitions = layout.Definitions{}
+	defineInlinedLabel(instructions, end)
 
-	builder.Append(segment)
+	appendInlinedInstructions(builder, instructions)
 }
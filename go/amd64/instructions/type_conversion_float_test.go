@@ -1,6 +1,7 @@
 package instructions
 
 import (
+	"math"
 	"testing"
 
 	"github.com/pattyshack/gt/testing/expect"
@@ -48,7 +49,7 @@ func TestFloat32ToFloat64(t *testing.T) {
 }
 
 func TestFloat32ToInt8(t *testing.T) {
-	// cvtss2si ebp, xmm7
+	// cvttss2si ebp, xmm7
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -60,14 +61,14 @@ func TestFloat32ToInt8(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x0f, 0x2d, 0xef},
+		[]byte{0xf3, 0x0f, 0x2c, 0xef},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToInt16(t *testing.T) {
-	// cvtss2si edx, xmm14
+	// cvttss2si edx, xmm14
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -79,14 +80,14 @@ func TestFloat32ToInt16(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x41, 0x0f, 0x2d, 0xd6},
+		[]byte{0xf3, 0x41, 0x0f, 0x2c, 0xd6},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToInt32(t *testing.T) {
-	// cvtss2si r9d, xmm4
+	// cvttss2si r9d, xmm4
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -98,14 +99,14 @@ func TestFloat32ToInt32(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x44, 0x0f, 0x2d, 0xcc},
+		[]byte{0xf3, 0x44, 0x0f, 0x2c, 0xcc},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToInt64(t *testing.T) {
-	// cvtss2si rcx, xmm3
+	// cvttss2si rcx, xmm3
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -117,16 +118,16 @@ func TestFloat32ToInt64(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x48, 0x0f, 0x2d, 0xcb},
+		[]byte{0xf3, 0x48, 0x0f, 0x2c, 0xcb},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToUint8(t *testing.T) {
-	// cvtss2si ebp, xmm7
+	// cvttss2si rbp, xmm7
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToSmallUint(
 		builder,
 		ir.Uint8,
 		registers.Rbp,
@@ -136,16 +137,16 @@ func TestFloat32ToUint8(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x0f, 0x2d, 0xef},
+		[]byte{0xf3, 0x48, 0x0f, 0x2c, 0xef},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToUint16(t *testing.T) {
-	// cvtss2si edx, xmm14
+	// cvttss2si rdx, xmm14
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToSmallUint(
 		builder,
 		ir.Uint16,
 		registers.Rdx,
@@ -155,16 +156,16 @@ func TestFloat32ToUint16(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x41, 0x0f, 0x2d, 0xd6},
+		[]byte{0xf3, 0x49, 0x0f, 0x2c, 0xd6},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToUint32(t *testing.T) {
-	// cvtss2si r9d, xmm4
+	// cvttss2si r9, xmm4
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToSmallUint(
 		builder,
 		ir.Uint32,
 		registers.R9,
@@ -174,26 +175,54 @@ func TestFloat32ToUint32(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x44, 0x0f, 0x2d, 0xcc},
+		[]byte{0xf3, 0x4c, 0x0f, 0x2c, 0xcc},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat32ToUint64(t *testing.T) {
-	// cvtss2si rcx, xmm3
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToUint64(
 		builder,
-		ir.Uint64,
-		registers.Rcx,
 		ir.Float32,
-		registers.Xmm3)
+		registers.Rcx,
+		registers.Xmm3,
+		registers.Xmm9)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x48, 0x0f, 0x2d, 0xcb},
+		[]byte{
+			// comparison basic block
+
+			// 0: mov ecx, 0x5f000000 (2^63)
+			0xb9, 0x00, 0x00, 0x00, 0x5f,
+			// 5: movd xmm9, ecx
+			0x66, 0x44, 0x0f, 0x6e, 0xc9,
+			// 10: comiss xmm3, xmm9
+			0x41, 0x0f, 0x2f, 0xd9,
+			// 14: jae <largeValue offset> (= 30 - 20 = 10 = 0x0a)
+			0x0f, 0x83, 0x0a, 0x00, 0x00, 0x00,
+
+			// small value branch basic block
+
+			// 20: cvttss2si rcx, xmm3
+			0xf3, 0x48, 0x0f, 0x2c, 0xcb,
+			// 25: jmp <end offset> (= 45 - 30 = 15 = 0x0f)
+			0xe9, 0x0f, 0x00, 0x00, 0x00,
+
+			// large value branch basic block
+
+			// 30: subss xmm3, xmm9
+			0xf3, 0x41, 0x0f, 0x5c, 0xd9,
+			// 35: cvttss2si rcx, xmm3
+			0xf3, 0x48, 0x0f, 0x2c, 0xcb,
+			// 40: btc rcx, 63
+			0x48, 0x0f, 0xba, 0xf9, 0x3f,
+
+			// 45: end basic block
+		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
@@ -235,7 +264,7 @@ func TestFloat64ToFloat64(t *testing.T) {
 }
 
 func TestFloat64ToInt8(t *testing.T) {
-	// cvtsd2si ebp, xmm7
+	// cvttsd2si ebp, xmm7
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -247,14 +276,14 @@ func TestFloat64ToInt8(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x0f, 0x2d, 0xef},
+		[]byte{0xf2, 0x0f, 0x2c, 0xef},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToInt16(t *testing.T) {
-	// cvtsd2si edx, xmm14
+	// cvttsd2si edx, xmm14
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -266,14 +295,14 @@ func TestFloat64ToInt16(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x41, 0x0f, 0x2d, 0xd6},
+		[]byte{0xf2, 0x41, 0x0f, 0x2c, 0xd6},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToInt32(t *testing.T) {
-	// cvtsd2si r9d, xmm4
+	// cvttsd2si r9d, xmm4
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -285,14 +314,14 @@ func TestFloat64ToInt32(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x44, 0x0f, 0x2d, 0xcc},
+		[]byte{0xf2, 0x44, 0x0f, 0x2c, 0xcc},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToInt64(t *testing.T) {
-	// cvtsd2si rcx, xmm3
+	// cvttsd2si rcx, xmm3
 	builder := layout.NewSegmentBuilder()
 	convertFloatToInt(
 		builder,
@@ -304,16 +333,16 @@ func TestFloat64ToInt64(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x48, 0x0f, 0x2d, 0xcb},
+		[]byte{0xf2, 0x48, 0x0f, 0x2c, 0xcb},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToUint8(t *testing.T) {
-	// cvtsd2si ebp, xmm7
+	// cvttsd2si rbp, xmm7
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToSmallUint(
 		builder,
 		ir.Uint8,
 		registers.Rbp,
@@ -323,16 +352,16 @@ func TestFloat64ToUint8(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x0f, 0x2d, 0xef},
+		[]byte{0xf2, 0x48, 0x0f, 0x2c, 0xef},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToUint16(t *testing.T) {
-	// cvtsd2si edx, xmm14
+	// cvttsd2si rdx, xmm14
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToSmallUint(
 		builder,
 		ir.Uint16,
 		registers.Rdx,
@@ -342,16 +371,16 @@ func TestFloat64ToUint16(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x41, 0x0f, 0x2d, 0xd6},
+		[]byte{0xf2, 0x49, 0x0f, 0x2c, 0xd6},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToUint32(t *testing.T) {
-	// cvtsd2si r9d, xmm4
+	// cvttsd2si r9, xmm4
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToSmallUint(
 		builder,
 		ir.Uint32,
 		registers.R9,
@@ -361,26 +390,54 @@ func TestFloat64ToUint32(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x44, 0x0f, 0x2d, 0xcc},
+		[]byte{0xf2, 0x4c, 0x0f, 0x2c, 0xcc},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
 
 func TestFloat64ToUint64(t *testing.T) {
-	// cvtsd2si rcx, xmm3
 	builder := layout.NewSegmentBuilder()
-	convertFloatToInt(
+	convertFloatToUint64(
 		builder,
-		ir.Uint64,
-		registers.Rcx,
 		ir.Float64,
-		registers.Xmm3)
+		registers.Rcx,
+		registers.Xmm3,
+		registers.Xmm9)
 	segment, err := builder.Finalize(amd64.ArchitectureLayout)
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf2, 0x48, 0x0f, 0x2d, 0xcb},
+		[]byte{
+			// comparison basic block
+
+			// 0: mov rcx, 0x43e0000000000000 (2^63)
+			0x48, 0xb9, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0, 0x43,
+			// 10: movq xmm9, rcx
+			0x66, 0x4c, 0x0f, 0x6e, 0xc9,
+			// 15: comisd xmm3, xmm9
+			0x66, 0x41, 0x0f, 0x2f, 0xd9,
+			// 20: jae <largeValue offset> (= 36 - 26 = 10 = 0x0a)
+			0x0f, 0x83, 0x0a, 0x00, 0x00, 0x00,
+
+			// small value branch basic block
+
+			// 26: cvttsd2si rcx, xmm3
+			0xf2, 0x48, 0x0f, 0x2c, 0xcb,
+			// 31: jmp <end offset> (= 51 - 36 = 15 = 0x0f)
+			0xe9, 0x0f, 0x00, 0x00, 0x00,
+
+			// large value branch basic block
+
+			// 36: subsd xmm3, xmm9
+			0xf2, 0x41, 0x0f, 0x5c, 0xd9,
+			// 41: cvttsd2si rcx, xmm3
+			0xf2, 0x48, 0x0f, 0x2c, 0xcb,
+			// 46: btc rcx, 63
+			0x48, 0x0f, 0xba, 0xf9, 0x3f,
+
+			// 51: end basic block
+		},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
@@ -469,7 +526,7 @@ func TestSelectFloatToInt(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x44, 0x0f, 0x2d, 0xcc},
+		[]byte{0xf3, 0x44, 0x0f, 0x2c, 0xcc},
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
@@ -558,7 +615,123 @@ func TestSelectFloatToUint(t *testing.T) {
 	expect.Nil(t, err)
 	expect.Equal(
 		t,
-		[]byte{0xf3, 0x44, 0x0f, 0x2d, 0xcc},
+		[]byte{0xf3, 0x4c, 0x0f, 0x2c, 0xcc},
+		segment.Content.Flatten())
+	expect.Equal(t, layout.Definitions{}, segment.Definitions)
+	expect.Equal(t, layout.Relocations{}, segment.Relocations)
+}
+
+func TestSelectFloatToUint64(t *testing.T) {
+	src := ir.NewLocalReference("src")
+	srcDef := &ir.Definition{
+		Name: "src",
+		Type: ir.Float64,
+	}
+	src.(*ir.LocalReference).UseDef = srcDef
+	srcChunk := srcDef.Chunks()[0]
+
+	dest := &ir.Definition{
+		Type: ir.Uint64,
+		Operation: &ir.UnaryOperation{
+			Kind: ir.ToUint64,
+			Src:  src,
+		},
+	}
+	destChunk := dest.Chunks()[0]
+
+	instruction := architecture.SelectInstruction(
+		testConfig,
+		dest,
+		architecture.SelectorHint{})
+
+	_, ok := instruction.(floatToUint64Operation)
+	expect.True(t, ok)
+
+	// Validate constraints
+
+	constraints := instruction.Constraints()
+	expect.Nil(t, constraints.StackSources)
+	expect.Nil(t, constraints.StackDestination)
+
+	expect.Equal(t, 2, len(constraints.RegisterSources))
+
+	expect.Equal(
+		t,
+		srcChunk,
+		constraints.RegisterSources[0].DefinitionChunk)
+	expect.Nil(t, constraints.RegisterSources[1].DefinitionChunk)
+
+	expect.Equal(t, 1, len(constraints.RegisterDestinations))
+	expect.Equal(
+		t,
+		destChunk,
+		constraints.RegisterDestinations[0].DefinitionChunk)
+
+	srcRegister := constraints.RegisterSources[0].RegisterConstraint
+	expect.NotNil(t, srcRegister)
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered:  true,
+			AnyGeneral: false,
+			AnyFloat:   true,
+			Require:    nil,
+		},
+		srcRegister)
+
+	scratchRegister := constraints.RegisterSources[1].RegisterConstraint
+	expect.NotNil(t, scratchRegister)
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered:  true,
+			AnyGeneral: false,
+			AnyFloat:   true,
+			Require:    nil,
+		},
+		scratchRegister)
+
+	destRegister := constraints.RegisterDestinations[0].RegisterConstraint
+	expect.NotNil(t, destRegister)
+	expect.Equal(
+		t,
+		&architecture.RegisterConstraint{
+			Clobbered:  true,
+			AnyGeneral: true,
+			AnyFloat:   false,
+			Require:    nil,
+		},
+		destRegister)
+	expect.True(t, srcRegister != scratchRegister)
+	expect.True(t, srcRegister != destRegister)
+	expect.True(t, scratchRegister != destRegister)
+
+	// Validate encoding
+
+	builder := layout.NewSegmentBuilder()
+	instruction.EmitTo(
+		builder,
+		map[*architecture.RegisterConstraint]*architecture.Register{
+			srcRegister:     registers.Xmm3,
+			scratchRegister: registers.Xmm9,
+			destRegister:    registers.Rcx,
+		})
+	segment, err := builder.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+
+	expected := layout.NewSegmentBuilder()
+	convertFloatToUint64(
+		expected,
+		ir.Float64,
+		registers.Rcx,
+		registers.Xmm3,
+		registers.Xmm9)
+	expectedSegment, err := expected.Finalize(amd64.ArchitectureLayout)
+	expect.Nil(t, err)
+
+	expect.Equal(
+		t,
+		expectedSegment.Content.Flatten(),
 		segment.Content.Flatten())
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
@@ -735,3 +908,53 @@ func TestSelectFloatToFloatNoFreeRegister(t *testing.T) {
 	expect.Equal(t, layout.Definitions{}, segment.Definitions)
 	expect.Equal(t, layout.Relocations{}, segment.Relocations)
 }
+
+// Model of the convertFloatToUint64 instruction sequence, used for checking
+// the documented boundary behavior.
+func simulateFloatToUint64(src float64) uint64 {
+	cvtt := func(value float64) uint64 { // cvttsd2si with 64-bit dest
+		if math.IsNaN(value) || value < -(1<<63) || value >= 1<<63 {
+			return 1 << 63 // integer indefinite
+		}
+		return uint64(int64(value))
+	}
+
+	if src >= 1<<63 {
+		return cvtt(src-(1<<63)) ^ (1 << 63) // btc 63
+	}
+	return cvtt(src)
+}
+
+func TestFloatToUint64Boundaries(t *testing.T) {
+	expect.Equal(t, uint32(0x5f000000), math.Float32bits(float32(1<<63)))
+	expect.Equal(
+		t,
+		uint64(0x43e0000000000000),
+		math.Float64bits(float64(1<<63)))
+
+	largestBelow2Pow63 := math.Nextafter(1<<63, 0)
+	largestBelow2Pow64 := math.Nextafter(1<<64, 0)
+
+	for _, test := range []struct {
+		src      float64
+		expected uint64
+	}{
+		{0, 0},
+		{math.Copysign(0, -1), 0},
+		{0.75, 0},
+		{-0.75, 0},
+		{1, 1},
+		{largestBelow2Pow63, uint64(largestBelow2Pow63)},
+		{1 << 63, 1 << 63},
+		{1<<63 + 2048, 1<<63 + 2048},
+		{largestBelow2Pow64, uint64(largestBelow2Pow64)},
+		{-1, math.MaxUint64},
+		{-(1 << 63), 1 << 63},
+		{1 << 64, 0},
+		{math.Inf(1), 0},
+		{math.Inf(-1), 1 << 63},
+		{math.NaN(), 1 << 63},
+	} {
+		expect.Equal(t, test.expected, simulateFloatToUint64(test.src))
+	}
+}
@@ -39,7 +39,7 @@ func (op conversionOperation) EmitTo(
 	src := op.RegisterSources[0].RegisterConstraint
 	op.encodeConversion(
 		builder,
-		op.Type,
+		ir.UnderlyingType(op.Type),
 		selectedRegisters[dest],
 		op.srcType,
 		selectedRegisters[src])
@@ -68,7 +68,38 @@ func (op uint64ToFloatOperation) EmitTo(
 	scratch := op.RegisterSources[1].RegisterConstraint
 	convertUint64ToFloat(
 		builder,
-		op.Type,
+		ir.UnderlyingType(op.Type),
+		selectedRegisters[dest],
+		selectedRegisters[src],
+		selectedRegisters[scratch])
+}
+
+type floatToUint64Operation struct {
+	*ir.Definition
+
+	srcType ir.Type
+
+	architecture.InstructionConstraints
+}
+
+func (op floatToUint64Operation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op floatToUint64Operation) Constraints() architecture.InstructionConstraints {
+	return op.InstructionConstraints
+}
+
+func (op floatToUint64Operation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	dest := op.RegisterDestinations[0].RegisterConstraint
+	src := op.RegisterSources[0].RegisterConstraint
+	scratch := op.RegisterSources[1].RegisterConstraint
+	convertFloatToUint64(
+		builder,
+		op.srcType,
 		selectedRegisters[dest],
 		selectedRegisters[src],
 		selectedRegisters[scratch])
@@ -154,7 +185,7 @@ func (selector conversionSelector) Select(
 
 	return conversionOperation{
 		Definition:             def,
-		srcType:                unaryOp.Src.Def().Type,
+		srcType:                ir.UnderlyingType(unaryOp.Src.Def().Type),
 		InstructionConstraints: constraints,
 		encodeConversion:       selector.encodeConversion,
 	}
@@ -211,6 +242,58 @@ func (selector uintToFloatSelector) Select(
 	}
 }
 
+type floatToUintSelector struct {
+	smallUint conversionSelector
+}
+
+func (selector floatToUintSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	unaryOp *ir.UnaryOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	if def.Size() != 8 {
+		return selector.smallUint.Select(config, def, unaryOp, hint)
+	}
+
+	destChunk := def.Chunks()[0]
+	srcChunk := unaryOp.Src.Def().Chunks()[0]
+	return floatToUint64Operation{
+		Definition: def,
+		srcType:    ir.UnderlyingType(unaryOp.Src.Def().Type),
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						Clobbered:  true,
+						AnyGeneral: false,
+						AnyFloat:   true,
+					},
+					DefinitionChunk: srcChunk,
+				},
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						Clobbered:  true,
+						AnyGeneral: false,
+						AnyFloat:   true,
+					},
+					DefinitionChunk: nil, // scratch register
+				},
+			},
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: &architecture.RegisterConstraint{
+						Clobbered:  true,
+						AnyGeneral: true,
+						AnyFloat:   false,
+					},
+					DefinitionChunk: destChunk,
+				},
+			},
+		},
+	}
+}
+
 type unaryMSelector struct {
 	encodeM encodeMFunc
 }
@@ -298,3 +381,115 @@ func (selector negFloatSelector) Select(
 		encodeRM: negFloat64,
 	}
 }
+
+type popcntFallbackOperation struct {
+	*ir.Definition
+
+	architecture.InstructionConstraints
+}
+
+func (op popcntFallbackOperation) Instruction() ir.Instruction {
+	return op.Definition
+}
+
+func (op popcntFallbackOperation) Constraints() architecture.InstructionConstraints {
+	return op.InstructionConstraints
+}
+
+func (op popcntFallbackOperation) EmitTo(
+	builder *layout.SegmentBuilder,
+	selectedRegisters map[*architecture.RegisterConstraint]*architecture.Register,
+) {
+	dest := op.RegisterSources[0].RegisterConstraint
+	scratch := op.RegisterSources[1].RegisterConstraint
+
+	var mask *architecture.Register
+	if len(op.RegisterSources) == 3 {
+		mask = selectedRegisters[op.RegisterSources[2].RegisterConstraint]
+	}
+
+	popcntFallback(
+		builder,
+		ir.UnderlyingType(op.Type),
+		selectedRegisters[dest],
+		selectedRegisters[scratch],
+		mask)
+}
+
+type popcntFallbackSelector struct{}
+
+func (popcntFallbackSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	unaryOp *ir.UnaryOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	register := &architecture.RegisterConstraint{
+		Clobbered:  true,
+		AnyGeneral: true,
+		AnyFloat:   false,
+	}
+
+	sources := []architecture.RegisterMapping{
+		{
+			RegisterConstraint: register,
+			DefinitionChunk:    unaryOp.Src.Def().Chunks()[0],
+		},
+		{
+			RegisterConstraint: &architecture.RegisterConstraint{
+				Clobbered:  true,
+				AnyGeneral: true,
+				AnyFloat:   false,
+			},
+			DefinitionChunk: nil, // scratch register
+		},
+	}
+
+	if def.Size() == 8 {
+		sources = append(
+			sources,
+			architecture.RegisterMapping{
+				RegisterConstraint: &architecture.RegisterConstraint{
+					Clobbered:  true,
+					AnyGeneral: true,
+					AnyFloat:   false,
+				},
+				DefinitionChunk: nil, // mask register
+			})
+	}
+
+	return popcntFallbackOperation{
+		Definition: def,
+		InstructionConstraints: architecture.InstructionConstraints{
+			RegisterSources: sources,
+			RegisterDestinations: []architecture.RegisterMapping{
+				{
+					RegisterConstraint: register,
+					DefinitionChunk:    def.Chunks()[0],
+				},
+			},
+		},
+	}
+}
+
+// Use the supported selector when the cpu feature is available, and the
+// fallback selector otherwise.
+type featureSelector struct {
+	feature string
+
+	supported architecture.UnaryOperationSelector
+	fallback  architecture.UnaryOperationSelector
+}
+
+func (selector featureSelector) Select(
+	config architecture.Config,
+	def *ir.Definition,
+	unaryOp *ir.UnaryOperation,
+	hint architecture.SelectorHint,
+) architecture.MachineInstruction {
+	if config.Features[selector.feature] {
+		return selector.supported.Select(config, def, unaryOp, hint)
+	}
+
+	return selector.fallback.Select(config, def, unaryOp, hint)
+}
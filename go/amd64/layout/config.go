@@ -10,6 +10,10 @@ var (
 		RegisterAlignment:     16,          // SSE2 xmm registers
 		MemoryPageSize:        4096,
 		Relocator:             NewRelocator(),
+
+		// jmp *got_entry(%rip), padded with int3 to 8 bytes
+		ImportStub:                 []byte{0xff, 0x25, 0, 0, 0, 0, 0xcc, 0xcc},
+		ImportStubRelocationOffset: 2,
 	}
 
 	LinuxLayout = layout.Config{
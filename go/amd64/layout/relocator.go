@@ -8,33 +8,95 @@ import (
 	"github.com/pattyshack/chickadee/platform/layout"
 )
 
-type Rel32Relocator struct{}
+// Supported relocation forms:
+//   - pc relative: rel32 (relative to the end of the displacement)
+//   - absolute: abs64
+//   - absolute 32: abs32 (zero extended)
+//   - section offset: 32-bit offset relative to the symbol's section start
+//
+// NOTE: The relocated value includes the relocation's addend.  The snippet's
+// initial value is ignored.
+type Relocator struct{}
 
 func NewRelocator() layout.Relocator {
-	return Rel32Relocator{}
+	return Relocator{}
 }
 
-func (Rel32Relocator) Relocate(
+func (Relocator) Relocate(
+	relocation *layout.Relocation,
 	symbol *layout.Symbol,
-	startOffset int64,
 	snippet []byte,
 ) error {
+	switch relocation.Kind {
+	case layout.PCRelativeRelocation:
+		// relative to the next instruction
+		return relocateRel32(
+			symbol.Offset-(relocation.Offset+4)+relocation.Addend,
+			snippet)
+	case layout.AbsoluteRelocation:
+		return relocateAbs64(symbol.Offset+relocation.Addend, snippet)
+	case layout.Absolute32Relocation:
+		return relocateUnsigned32(
+			"abs32",
+			symbol.Offset+relocation.Addend,
+			snippet)
+	case layout.SectionOffsetRelocation:
+		return relocateUnsigned32(
+			"section offset",
+			symbol.Offset+relocation.Addend,
+			snippet)
+	default:
+		return fmt.Errorf(
+			"unsupported relocation kind (%s)",
+			relocation.Kind)
+	}
+}
+
+func relocateRel32(delta int64, snippet []byte) error {
 	if len(snippet) < 4 {
 		return fmt.Errorf("invalid rel32 relocation. not enough bytes in snippet")
 	}
 
-	displacement := int32(0)
-	n, err := binary.Decode(snippet, binary.LittleEndian, &displacement)
+	if delta < math.MinInt32 || math.MaxInt32 < delta {
+		return fmt.Errorf("invalid rel32 relocation. delta overflow (%d)", delta)
+	}
+
+	n, err := binary.Encode(snippet, binary.LittleEndian, int32(delta))
 	if err != nil || n != 4 {
 		panic("should never happen")
 	}
 
-	delta := symbol.Offset - (startOffset + 4) + int64(displacement)
-	if delta < math.MinInt32 || math.MaxInt32 < delta {
-		return fmt.Errorf("invalid rel32 relocation. delta overflow (%d)", delta)
+	return nil
+}
+
+func relocateAbs64(value int64, snippet []byte) error {
+	if len(snippet) < 8 {
+		return fmt.Errorf("invalid abs64 relocation. not enough bytes in snippet")
+	}
+
+	n, err := binary.Encode(snippet, binary.LittleEndian, value)
+	if err != nil || n != 8 {
+		panic("should never happen")
+	}
+
+	return nil
+}
+
+func relocateUnsigned32(form string, value int64, snippet []byte) error {
+	if len(snippet) < 4 {
+		return fmt.Errorf(
+			"invalid %s relocation. not enough bytes in snippet",
+			form)
+	}
+
+	if value < 0 || math.MaxUint32 < value {
+		return fmt.Errorf(
+			"invalid %s relocation. value overflow (%d)",
+			form,
+			value)
 	}
 
-	n, err = binary.Encode(snippet, binary.LittleEndian, int32(delta))
+	n, err := binary.Encode(snippet, binary.LittleEndian, uint32(value))
 	if err != nil || n != 4 {
 		panic("should never happen")
 	}
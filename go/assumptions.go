@@ -35,5 +35,5 @@
 // reporting.
 //
 // - We won't support c style union types since that interacts poorly with type
-// systems (maybe allow enum with values?)
+// systems.  Enum types with explicit values are supported.
 package chickadee
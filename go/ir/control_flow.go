@@ -15,6 +15,17 @@ const (
 	Jle = ConditionalJumpKind("Jle")
 	Jgt = ConditionalJumpKind("Jgt")
 	Jge = ConditionalJumpKind("Jge")
+
+	// Overflow checks jump to the label when (Src1 <op> Src2) overflows the
+	// int/uint sources' type (i.e., the mathematical result is not
+	// representable by the type).  Src1 and Src2 must be of the same type.
+	//
+	// NOTE: the wrapped around result is not assigned to any definition.  Use
+	// the corresponding binary operation on the non-overflow branch to compute
+	// the result.
+	JaddOverflow = ConditionalJumpKind("JaddOverflow")
+	JsubOverflow = ConditionalJumpKind("JsubOverflow")
+	JmulOverflow = ConditionalJumpKind("JmulOverflow")
 )
 
 type ConditionalJump struct {
@@ -27,16 +38,42 @@ type ConditionalJump struct {
 	Src2  Value
 }
 
+type SwitchCase struct {
+	Value Value // int/uint/enum immediate
+	Label string
+}
+
+// Multi-way branch.  Jumps to the label of the case whose value equals Value,
+// or to DefaultLabel when no case matches.  Value must be an int/uint value,
+// and the case values must be distinct immediates of the same type.
+type Switch struct {
+	controlFlowInstruction
+
+	Value        Value
+	Cases        []SwitchCase
+	DefaultLabel string
+}
+
 type TerminalKind string
 
 const (
 	Ret = TerminalKind("ret")
+
+	// Abnormally terminates the program (e.g., failed assertion or bounds
+	// violation).
+	Trap = TerminalKind("trap")
+
+	// Marks an impossible path.  Executing an unreachable terminal is undefined
+	// behavior, hence the optimizer may prune paths that lead to it.
+	Unreachable = TerminalKind("unreachable")
 )
 
+// Terminal blocks have no children, and control never falls through to the
+// next block.
 type Terminal struct {
 	controlFlowInstruction
 
 	Kind TerminalKind
 
-	ReturnValue Value // return empty struct for void
+	ReturnValue Value // return empty struct for void.  Only used by Ret.
 }
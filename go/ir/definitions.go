@@ -1,5 +1,9 @@
 package ir
 
+import (
+	"fmt"
+)
+
 const (
 	// All internal definition names are prefixed by "%"
 	PreviousFramePointer = "%previous-frame-pointer"
@@ -8,10 +12,32 @@ const (
 	ReturnValue          = "%return-value"
 )
 
+// The function / global object definition's linkage.
+type Linkage string
+
+const (
+	// The definition is visible to all compilation units.
+	GlobalLinkage = Linkage("")
+
+	// The definition is only visible within the compilation unit.  Other
+	// compilation units may define same named local definitions.
+	LocalLinkage = Linkage("local")
+
+	// Same as GlobalLinkage, except the definition may be overridden by another
+	// compilation unit's global definition.
+	WeakLinkage = Linkage("weak")
+
+	// Same as GlobalLinkage, except the definition is never exported by shared
+	// libraries (i.e., IsExported is ignored).
+	HiddenLinkage = Linkage("hidden")
+)
+
 type FunctionDefinition struct {
 	Name string
 	Type *FunctionType
 
+	Linkage
+
 	// 1-to-1 mapping between parameter types and names
 	ParameterNames []string
 
@@ -25,6 +51,11 @@ type FunctionDefinition struct {
 	// finally exit.
 	IsEntryFunction bool
 
+	// When true, the function is exported via the shared library's dynamic
+	// symbol table (when the compilation unit is linked into a shared
+	// library).  Only applicable to global and weak linkage.
+	IsExported bool
+
 	// Internal
 
 	// NOTE: The following definitions span the entire function life time.
@@ -55,13 +86,39 @@ type ObjectDefinition struct {
 	// NOTE: The declaration type cannot not be FunctionType.
 	Type Type
 
+	Linkage
+
 	// Content must either be nil or the same length as the Type's size.  If
 	// Content is nil, we'll default to all zero bytes.
 	Content []byte
+
+	// Global function / variable addresses embedded within the content (e.g.,
+	// function pointer tables).  Only supported by variable definitions.
+	EmbeddedAddresses []EmbeddedAddress
+
+	// NOTE: objects are never exported by shared libraries (only functions are,
+	// see FunctionDefinition's IsExported).  The library's own references to
+	// its objects are resolved directly rather than indirectly via the global
+	// offset table, and hence would not observe an executable's copy of an
+	// exported object.
+}
+
+// A global function / variable's address embedded within a global variable's
+// content.  The address (adjusted by Addend) is resolved at link time via an
+// absolute relocation, which overwrites the content's address-sized bytes at
+// Offset.
+type EmbeddedAddress struct {
+	Offset int
+	Name   string
+	Addend int64
 }
 
 // Logical compilation unit that forms a single object file.
 type CompilationUnit struct {
+	// Named type declarations (see DeclareStructType).  All declarations must be
+	// completed prior to compilation (see CheckTypeDeclarations).
+	TypeDeclarations []*StructType
+
 	// The function is populated into .text.  Functions can access the function
 	// using FunctionReference.
 	FunctionDefinitions []*FunctionDefinition
@@ -86,6 +143,29 @@ type CompilationUnit struct {
 	VariableDefinitions []*ObjectDefinition
 }
 
+// Checks that the compilation unit's type declarations are completed, and
+// that none of the declared struct types contains itself by value.  This must
+// be checked prior to compilation, since the types' sizes are otherwise
+// unknown.
+func (unit *CompilationUnit) CheckTypeDeclarations() error {
+	for _, declaration := range unit.TypeDeclarations {
+		if declaration.IsIncomplete() {
+			return fmt.Errorf(
+				"incomplete struct type declaration (%s)",
+				declaration.Name)
+		}
+	}
+
+	for _, declaration := range unit.TypeDeclarations {
+		err := declaration.CheckSelfContainment()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // NOTE: A Definition acts as an instruction statement, a definition for local
 // value, or a pseudo definition for non-local values.
 //
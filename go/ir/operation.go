@@ -25,6 +25,14 @@ const (
 	Neg = UnaryOperationKind("neg")
 	Not = UnaryOperationKind("not")
 
+	// Bit manipulations on int/uint.  The result type is the same as the src
+	// type.  Clz / Ctz of zero yields the src's bit width.  Bswap on 8-bit src
+	// is a no-op.
+	Popcnt = UnaryOperationKind("popcnt")
+	Clz    = UnaryOperationKind("clz")
+	Ctz    = UnaryOperationKind("ctz")
+	Bswap  = UnaryOperationKind("bswap")
+
 	ToInt8  = UnaryOperationKind("toInt8")
 	ToInt16 = UnaryOperationKind("toInt16")
 	ToInt32 = UnaryOperationKind("toInt32")
@@ -39,6 +47,10 @@ const (
 	ToFloat64 = UnaryOperationKind("toFloat64")
 )
 
+// NOTE: float to int/uint conversion truncates toward zero.  The result of
+// converting NaN or an out of range value is target specific, even when the
+// architecture config's DefinedSemantics is enabled (this matches Go's
+// semantics).
 type UnaryOperation struct {
 	operation
 
@@ -83,11 +95,27 @@ const (
 	Shl = BinaryOperationKind("shl")
 	Shr = BinaryOperationKind("shr")
 
+	Rotl = BinaryOperationKind("rotl")
+	Rotr = BinaryOperationKind("rotr")
+
 	And = BinaryOperationKind("and")
 	Or  = BinaryOperationKind("or")
 	Xor = BinaryOperationKind("xor")
 )
 
+// Shl / Shr / Rotl / Rotr's shift count (Src2) is always an uint8.  Shr is an
+// arithmetic shift for signed int and a logical shift for unsigned int.
+// Rotl / Rotr rotates by the count modulo the operand's bit width.
+//
+// The following inputs are target specific, unless the architecture config's
+// DefinedSemantics is enabled, in which case:
+//
+//   - Shl / Shr by a count >= the operand's bit width yields 0, except for
+//     signed int Shr, which yields 0 for non-negative Src1 and -1 otherwise.
+//   - int/uint Div / Rem by zero calls the architecture config's
+//     DivideByZeroHandler (which does not return).
+//   - signed int Div of the type's min value by -1 yields the min value (i.e.,
+//     the quotient wraps), and the corresponding Rem yields 0.
 type BinaryOperation struct {
 	operation
 
@@ -97,6 +125,57 @@ type BinaryOperation struct {
 	Src2 Value
 }
 
+// The memory ordering constraint of an atomic operation (see C11's
+// memory_order).
+type MemoryOrder string
+
+const (
+	Relaxed                = MemoryOrder("relaxed")
+	Acquire                = MemoryOrder("acquire")
+	Release                = MemoryOrder("release")
+	AcquireRelease         = MemoryOrder("acquireRelease")
+	SequentiallyConsistent = MemoryOrder("sequentiallyConsistent")
+)
+
+type AtomicOperationKind string
+
+const (
+	// <dest> = *Address
+	AtomicLoad = AtomicOperationKind("atomicLoad")
+
+	// *Address = Src.  The destination's type is empty struct.
+	AtomicStore = AtomicOperationKind("atomicStore")
+
+	// <dest> = *Address; *Address = Src
+	AtomicExchange = AtomicOperationKind("atomicExchange")
+
+	// <dest> = *Address; if <dest> == Expected { *Address = Src }
+	//
+	// The exchange succeeded iff the destination equals Expected.
+	AtomicCompareExchange = AtomicOperationKind("atomicCompareExchange")
+
+	// <dest> = *Address; *Address = <dest> + Src
+	AtomicFetchAdd = AtomicOperationKind("atomicFetchAdd")
+
+	// Memory fence.  Address / Src / Expected are unused, and the destination's
+	// type is empty struct.
+	Fence = AtomicOperationKind("fence")
+)
+
+// Address must be an AddressType value, whose ValueType is an int/uint type.
+// Src, Expected and the destination (unless noted otherwise) must be of the
+// same int/uint type.
+type AtomicOperation struct {
+	operation
+
+	Kind  AtomicOperationKind
+	Order MemoryOrder
+
+	Address  Value
+	Src      Value
+	Expected Value // only used by AtomicCompareExchange
+}
+
 type FunctionCallKind string
 
 const (
@@ -0,0 +1,229 @@
+package ir
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"sync"
+)
+
+// Hash-consing type interner.  Structurally equal types interned by the same
+// interner share the same canonical instance, i.e., Equals between interned
+// types reduces to pointer comparison.
+//
+// Interning a new type registers a copy of the type as the canonical
+// instance; the argument itself is never modified.  The copy's component
+// types are interned (i.e., the copy references the canonical instances).
+// The canonical instances are safe for concurrent read-only use, and must not
+// be modified.
+//
+// NOTE: incomplete struct type declarations cannot be interned.
+type TypeInterner struct {
+	mutex sync.Mutex
+
+	types map[uint64][]Type
+}
+
+func NewTypeInterner() *TypeInterner {
+	interner := &TypeInterner{
+		types: map[uint64][]Type{},
+	}
+
+	// The predefined basic types are always canonical.
+	for _, t := range []Type{
+		Uint8, Uint16, Uint32, Uint64,
+		Int8, Int16, Int32, Int64,
+		Float32, Float64,
+	} {
+		hash := TypeHash(t)
+		interner.types[hash] = append(interner.types[hash], t)
+	}
+
+	return interner
+}
+
+// Returns the type's canonical instance.  This is safe for concurrent use.
+func (interner *TypeInterner) Intern(t Type) Type {
+	interner.mutex.Lock()
+	defer interner.mutex.Unlock()
+
+	return interner.intern(t)
+}
+
+func (interner *TypeInterner) intern(t Type) Type {
+	structType, ok := t.(*StructType)
+	if ok && structType.isIncomplete {
+		panic("cannot intern incomplete struct type: " + structType.Name)
+	}
+
+	hash := TypeHash(t)
+	for _, candidate := range interner.types[hash] {
+		if candidate.Equals(t) {
+			return candidate
+		}
+	}
+
+	canonical := copyType(t)
+
+	// NOTE: the copy is registered prior to interning its component types to
+	// ensure interning terminates on recursive types (the copy is structurally
+	// equal to the original type until its components are replaced).
+	interner.types[hash] = append(interner.types[hash], canonical)
+
+	switch value := canonical.(type) {
+	case *UnsignedIntType, *SignedIntType, *FloatType:
+		// no component types
+	case *EnumType:
+		value.UnderlyingType = interner.intern(value.UnderlyingType)
+	case *AddressType:
+		value.ValueType = interner.intern(value.ValueType)
+	case *FunctionType:
+		parameterTypes := make([]Type, 0, len(value.ParameterTypes))
+		for _, parameterType := range value.ParameterTypes {
+			parameterTypes = append(parameterTypes, interner.intern(parameterType))
+		}
+		value.ParameterTypes = parameterTypes
+		value.ReturnType = interner.intern(value.ReturnType)
+	case *ArrayType:
+		value.ElementType = interner.intern(value.ElementType)
+	case *StructType:
+		fields := make([]Field, 0, len(value.Fields))
+		for _, field := range value.Fields {
+			fields = append(
+				fields,
+				Field{
+					Name: field.Name,
+					Type: interner.intern(field.Type),
+				})
+		}
+		value.Fields = fields
+	default:
+		panic(fmt.Sprintf("unsupported type: %v", t))
+	}
+
+	return canonical
+}
+
+// Returns a shallow copy of the type.  Slices are shared with the original
+// type, and must be replaced rather than modified in place.  The lazily
+// computed memory layouts are not copied since they may reference the
+// original type's (non-canonical) component types.
+func copyType(t Type) Type {
+	switch value := t.(type) {
+	case *UnsignedIntType:
+		copied := *value
+		return &copied
+	case *SignedIntType:
+		copied := *value
+		return &copied
+	case *FloatType:
+		copied := *value
+		return &copied
+	case *EnumType:
+		copied := *value
+		return &copied
+	case *AddressType:
+		copied := *value
+		return &copied
+	case *FunctionType:
+		copied := *value
+		return &copied
+	case *ArrayType:
+		return &ArrayType{
+			ElementType: value.ElementType,
+			NumElements: value.NumElements,
+			Layout:      value.Layout,
+		}
+	case *StructType:
+		return &StructType{
+			Name:   value.Name,
+			Fields: value.Fields,
+			Layout: value.Layout,
+		}
+	default:
+		panic(fmt.Sprintf("unsupported type: %v", t))
+	}
+}
+
+// Structural type hash, consistent with Equals (i.e., equal types always have
+// the same hash).
+//
+// NOTE: named struct types' field types are not included in the hash to
+// ensure hashing terminates on recursive types (recursive types must be
+// declared as named struct types).
+func TypeHash(t Type) uint64 {
+	hasher := typeHasher{fnv.New64a()}
+	hasher.writeType(t)
+	return hasher.Sum64()
+}
+
+type typeHasher struct {
+	hash.Hash64
+}
+
+func (hasher typeHasher) writeInt(value int64) {
+	var buffer [8]byte
+	binary.LittleEndian.PutUint64(buffer[:], uint64(value))
+	_, _ = hasher.Write(buffer[:])
+}
+
+func (hasher typeHasher) writeString(value string) {
+	hasher.writeInt(int64(len(value)))
+	_, _ = hasher.Write([]byte(value))
+}
+
+func (hasher typeHasher) writeType(t Type) {
+	switch value := t.(type) {
+	case *UnsignedIntType:
+		hasher.writeString("uint")
+		hasher.writeInt(int64(value.ByteSize))
+	case *SignedIntType:
+		hasher.writeString("int")
+		hasher.writeInt(int64(value.ByteSize))
+	case *FloatType:
+		hasher.writeString("float")
+		hasher.writeInt(int64(value.ByteSize))
+	case *EnumType:
+		hasher.writeString("enum")
+		hasher.writeString(value.Name)
+		hasher.writeType(value.UnderlyingType)
+		hasher.writeInt(int64(len(value.Values)))
+		for _, enumValue := range value.Values {
+			hasher.writeString(enumValue.Name)
+			hasher.writeInt(enumValue.Value)
+		}
+	case *AddressType:
+		hasher.writeString("address")
+		hasher.writeType(value.ValueType)
+	case *FunctionType:
+		hasher.writeString("function")
+		hasher.writeString(string(value.CallConventionKind))
+		hasher.writeInt(int64(len(value.ParameterTypes)))
+		for _, parameterType := range value.ParameterTypes {
+			hasher.writeType(parameterType)
+		}
+		hasher.writeType(value.ReturnType)
+	case *ArrayType:
+		hasher.writeString("array")
+		hasher.writeString(string(value.Layout))
+		hasher.writeInt(int64(value.NumElements))
+		hasher.writeType(value.ElementType)
+	case *StructType:
+		hasher.writeString("struct")
+		hasher.writeString(value.Name)
+		hasher.writeString(string(value.Layout))
+		if value.isIncomplete {
+			hasher.writeString("incomplete")
+		}
+		hasher.writeInt(int64(len(value.Fields)))
+		for _, field := range value.Fields {
+			hasher.writeString(field.Name)
+			if value.Name == "" {
+				hasher.writeType(field.Type)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unsupported type: %v", t))
+	}
+}
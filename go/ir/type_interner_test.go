@@ -0,0 +1,121 @@
+package ir
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+)
+
+func newTestInternerFunctionType() *FunctionType {
+	return NewFunctionType(
+		SysVLiteCallConvention,
+		[]Type{
+			NewAddressType(NewSignedIntType(4)),
+			NewStructType([]Field{
+				{Name: "x", Type: NewFloatType(8)},
+				{Name: "y", Type: NewArrayType(NewUnsignedIntType(1), 3)},
+			}),
+		},
+		NewStructType(nil))
+}
+
+func TestTypeHash(t *testing.T) {
+	expect.Equal(t, TypeHash(Int32), TypeHash(NewSignedIntType(4)))
+	expect.NotEqual(t, TypeHash(Int32), TypeHash(Uint32))
+	expect.NotEqual(
+		t,
+		TypeHash(NewArrayType(Int8, 4)),
+		TypeHash(NewCArrayType(Int8, 4)))
+	expect.Equal(
+		t,
+		TypeHash(newTestInternerFunctionType()),
+		TypeHash(newTestInternerFunctionType()))
+	expect.Equal(
+		t,
+		TypeHash(newTestListNode("node")),
+		TypeHash(newTestListNode("node")))
+	expect.NotEqual(
+		t,
+		TypeHash(newTestListNode("node")),
+		TypeHash(newTestListNode("other")))
+}
+
+func TestTypeInterner(t *testing.T) {
+	interner := NewTypeInterner()
+
+	expect.True(t, interner.Intern(NewSignedIntType(4)) == Int32)
+	expect.True(t, interner.Intern(NewFloatType(8)) == Float64)
+
+	function1 := newTestInternerFunctionType()
+	function2 := newTestInternerFunctionType()
+	expect.True(t, function1 != function2)
+
+	canonical := interner.Intern(function1).(*FunctionType)
+	expect.True(t, interner.Intern(function2) == canonical)
+	expect.True(t, interner.Intern(canonical) == canonical)
+
+	// The interned type is a copy; the argument is not modified.
+	expect.True(t, canonical != function1)
+	original := function1.ParameterTypes[1].(*StructType)
+	expect.True(t, original.Fields[0].Type != Float64)
+	expect.Nil(t, original.memory.computed.Load())
+
+	// Component types are canonicalized
+	parameter := canonical.ParameterTypes[1].(*StructType)
+	expect.True(t, parameter != original)
+	expect.True(t, parameter.Fields[0].Type == Float64)
+	expect.True(t, canonical.ParameterTypes[0].(*AddressType).ValueType == Int32)
+	expect.True(
+		t,
+		interner.Intern(NewArrayType(Uint8, 3)) == parameter.Fields[1].Type)
+	expect.True(t, interner.Intern(NewStructType(nil)) == canonical.ReturnType)
+
+	expect.Equal(t, 16, parameter.Size())
+	expect.Equal(t, 2, len(parameter.Chunks()))
+}
+
+func TestTypeInternerRecursiveType(t *testing.T) {
+	interner := NewTypeInterner()
+
+	node1 := newTestListNode("node")
+	node2 := newTestListNode("node")
+
+	canonical := interner.Intern(node1).(*StructType)
+	expect.True(t, canonical != node1)
+	expect.True(t, interner.Intern(node2) == canonical)
+
+	next := canonical.Fields[1].Type.(*AddressType)
+	expect.True(t, next.ValueType == canonical)
+	expect.True(t, interner.Intern(NewAddressType(node2)) == next)
+
+	// The argument still references itself.
+	expect.True(t, node1.Fields[1].Type.(*AddressType).ValueType == node1)
+
+	other := newTestListNode("other")
+	expect.True(t, interner.Intern(other) != canonical)
+}
+
+func TestTypeInternerConcurrentIntern(t *testing.T) {
+	interner := NewTypeInterner()
+
+	numWorkers := 8
+	results := make([]Type, numWorkers)
+
+	wg := sync.WaitGroup{}
+	wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func(idx int) {
+			defer wg.Done()
+
+			result := interner.Intern(newTestInternerFunctionType())
+			_ = result.(*FunctionType).ParameterTypes[1].Size()
+			results[idx] = result
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		expect.True(t, result == results[0])
+	}
+}
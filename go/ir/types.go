@@ -1,5 +1,11 @@
 package ir
 
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
 const (
 	generalRegisterSize = 8
 	addressSize         = generalRegisterSize
@@ -10,11 +16,23 @@ type Type interface {
 
 	Equals(Type) bool
 
+	// NOTE: assumed contains the (recursive) type pairs that are currently
+	// being compared.  These pairs are assumed to be equal to ensure the
+	// comparison terminates on cyclic types.
+	equals(other Type, assumed assumedEqualTypes) bool
+
 	Size() int
 
 	Chunks() []*TypeChunk
 }
 
+type typePair struct {
+	this  Type
+	other Type
+}
+
+type assumedEqualTypes map[typePair]struct{}
+
 // For internal use only.
 //
 // Each type is partition into chunks that could fit into 8-byte registers.
@@ -37,6 +55,29 @@ type TypeChunkValue struct {
 	ValueTypeChunk *TypeChunk
 }
 
+// How aggregate (struct / array) values are laid out in memory.
+type AggregateLayoutKind string
+
+const (
+	// The default layout.  Aggregate values are partitioned into 8-byte chunks
+	// such that no (non-aggregate) value spans multiple chunks, and aggregate
+	// sizes are always rounded up to a multiple of 8 bytes.  Multi-chunk values
+	// (including C layout aggregate values larger than 8 bytes) are always
+	// chunk aligned and occupy whole chunks.
+	ChunkedLayout = AggregateLayoutKind("")
+
+	// C ABI compatible layout.  Values are naturally aligned, the aggregate's
+	// alignment is the largest alignment among its values, and the aggregate's
+	// size is only rounded up to a multiple of its alignment.
+	//
+	// The value is still partitioned into eightbyte chunks for register
+	// placement / SysV eightbyte classification.  Since nested aggregate
+	// values may not be chunk aligned, the chunks are populated with the
+	// aggregate's flattened non-aggregate values (i.e., ValueTypeChunk is
+	// always nil), and the last chunk may be partially occupied.
+	CLayout = AggregateLayoutKind("C")
+)
+
 func simpleTypeChunk(t Type) []*TypeChunk {
 	return []*TypeChunk{
 		{
@@ -71,6 +112,13 @@ func NewUnsignedIntType(byteSize int) *UnsignedIntType {
 func (*UnsignedIntType) isTypeExpression() {}
 
 func (this *UnsignedIntType) Equals(other Type) bool {
+	return this.equals(other, nil)
+}
+
+func (this *UnsignedIntType) equals(
+	other Type,
+	assumed assumedEqualTypes,
+) bool {
 	if this == other {
 		return true
 	}
@@ -117,6 +165,10 @@ func NewSignedIntType(byteSize int) *SignedIntType {
 func (*SignedIntType) isTypeExpression() {}
 
 func (this *SignedIntType) Equals(other Type) bool {
+	return this.equals(other, nil)
+}
+
+func (this *SignedIntType) equals(other Type, assumed assumedEqualTypes) bool {
 	if this == other {
 		return true
 	}
@@ -163,6 +215,10 @@ func NewFloatType(byteSize int) *FloatType {
 func (*FloatType) isTypeExpression() {}
 
 func (this *FloatType) Equals(other Type) bool {
+	return this.equals(other, nil)
+}
+
+func (this *FloatType) equals(other Type, assumed assumedEqualTypes) bool {
 	if this == other {
 		return true
 	}
@@ -188,6 +244,159 @@ var (
 	Float64 = NewFloatType(8)
 )
 
+type EnumValue struct {
+	Name  string
+	Value int64 // must be representable by the enum's underlying int type
+}
+
+// Enum values are represented by their underlying int*/uint* type values.
+// Operations (including conversions and atomic operations), comparisons and
+// switches on enum values operate on the underlying values.
+//
+// The value names are only used for verification (e.g., NewEnumImmediate).
+// NOTE: debug info (DWARF) output is not supported yet, and hence the names
+// are not emitted.
+type EnumType struct {
+	Name string
+
+	// Must be an int*/uint* type
+	UnderlyingType Type
+
+	Values []EnumValue
+
+	// Internal (not part of the type signature)
+
+	chunks []*TypeChunk
+}
+
+func NewEnumType(
+	name string,
+	underlyingType Type,
+	values []EnumValue,
+) *EnumType {
+	switch underlyingType.(type) {
+	case *SignedIntType, *UnsignedIntType:
+	default:
+		panic(fmt.Sprintf("invalid enum underlying type: %v", underlyingType))
+	}
+
+	names := map[string]struct{}{}
+	for _, value := range values {
+		_, ok := names[value.Name]
+		if ok {
+			panic("duplicate enum value name: " + value.Name)
+		}
+		names[value.Name] = struct{}{}
+
+		// Ensure the value is representable by the underlying type.
+		_ = intValue(underlyingType, value.Value)
+	}
+
+	t := &EnumType{
+		Name:           name,
+		UnderlyingType: underlyingType,
+		Values:         values,
+	}
+	t.chunks = simpleTypeChunk(t)
+	return t
+}
+
+func (*EnumType) isTypeExpression() {}
+
+func (this *EnumType) Equals(other Type) bool {
+	return this.equals(other, nil)
+}
+
+func (this *EnumType) equals(other Type, assumed assumedEqualTypes) bool {
+	if this == other {
+		return true
+	}
+
+	otherEnum, ok := other.(*EnumType)
+	if !ok {
+		return false
+	}
+
+	if this.Name != otherEnum.Name ||
+		!this.UnderlyingType.Equals(otherEnum.UnderlyingType) ||
+		len(this.Values) != len(otherEnum.Values) {
+
+		return false
+	}
+
+	for idx, value := range this.Values {
+		if value != otherEnum.Values[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t *EnumType) Size() int {
+	return t.UnderlyingType.Size()
+}
+
+func (t *EnumType) Chunks() []*TypeChunk {
+	return t.chunks
+}
+
+// Returns the named value's int*/uint* representation.
+func (t *EnumType) Value(name string) interface{} {
+	for _, value := range t.Values {
+		if value.Name == name {
+			return intValue(t.UnderlyingType, value.Value)
+		}
+	}
+
+	panic(fmt.Sprintf("enum value %s not found in %s", name, t.Name))
+}
+
+// For enum types, this returns the enum's underlying int*/uint* type.
+// Otherwise, this returns the type itself.
+func UnderlyingType(t Type) Type {
+	enumType, ok := t.(*EnumType)
+	if ok {
+		return enumType.UnderlyingType
+	}
+	return t
+}
+
+// Convert the value to the int type's int*/uint* representation.
+func intValue(intType Type, value int64) interface{} {
+	var result interface{}
+	var ok bool
+	switch t := intType.(type) {
+	case *SignedIntType:
+		switch t.ByteSize {
+		case 1:
+			result, ok = int8(value), int64(int8(value)) == value
+		case 2:
+			result, ok = int16(value), int64(int16(value)) == value
+		case 4:
+			result, ok = int32(value), int64(int32(value)) == value
+		case 8:
+			result, ok = value, true
+		}
+	case *UnsignedIntType:
+		switch t.ByteSize {
+		case 1:
+			result, ok = uint8(value), int64(uint8(value)) == value
+		case 2:
+			result, ok = uint16(value), int64(uint16(value)) == value
+		case 4:
+			result, ok = uint32(value), int64(uint32(value)) == value
+		case 8:
+			result, ok = uint64(value), value >= 0
+		}
+	}
+
+	if !ok {
+		panic(fmt.Sprintf("value %d not representable by %v", value, intType))
+	}
+	return result
+}
+
 // NOTE: Unlike c pointer, int8 address type is not the same as int8 array
 // address type.  We don't support general pointer arithmetic and only
 // struct/array address type is index accessible.
@@ -219,6 +428,13 @@ func NewVariableLengthArrayAddressType(elementType Type) *AddressType {
 func (*AddressType) isTypeExpression() {}
 
 func (thisAddr *AddressType) Equals(other Type) bool {
+	return thisAddr.equals(other, assumedEqualTypes{})
+}
+
+func (thisAddr *AddressType) equals(
+	other Type,
+	assumed assumedEqualTypes,
+) bool {
 	if thisAddr == other {
 		return true
 	}
@@ -228,7 +444,7 @@ func (thisAddr *AddressType) Equals(other Type) bool {
 		return false
 	}
 
-	return thisAddr.ValueType.Equals(otherAddr.ValueType)
+	return thisAddr.ValueType.equals(otherAddr.ValueType, assumed)
 }
 
 func (*AddressType) Size() int {
@@ -283,6 +499,13 @@ func NewFunctionType(
 func (*FunctionType) isTypeExpression() {}
 
 func (thisFunction *FunctionType) Equals(other Type) bool {
+	return thisFunction.equals(other, assumedEqualTypes{})
+}
+
+func (thisFunction *FunctionType) equals(
+	other Type,
+	assumed assumedEqualTypes,
+) bool {
 	if thisFunction == other {
 		return true
 	}
@@ -300,12 +523,12 @@ func (thisFunction *FunctionType) Equals(other Type) bool {
 
 	for idx, parameterType := range thisFunction.ParameterTypes {
 		otherParameterType := otherFunction.ParameterTypes[idx]
-		if !parameterType.Equals(otherParameterType) {
+		if !parameterType.equals(otherParameterType, assumed) {
 			return false
 		}
 	}
 
-	return thisFunction.ReturnType.Equals(otherFunction.ReturnType)
+	return thisFunction.ReturnType.equals(otherFunction.ReturnType, assumed)
 }
 
 func (*FunctionType) Size() int {
@@ -321,24 +544,39 @@ type ArrayType struct {
 	// NOTE: -1 indicates variable length array (only accessible indirectly)
 	NumElements int
 
-	// NOTE: (internal use only) This is not part of the type signature.
+	Layout AggregateLayoutKind
 
-	size   int
-	chunks []*TypeChunk
+	// NOTE: (internal use only) This is not part of the type signature.
+	// size and chunks are lazily computed since the element type may reference
+	// an incomplete struct type declaration at construction time.
+	memory lazyMemoryLayout
 }
 
 func NewArrayType(elementType Type, numElements int) *ArrayType {
-	t := &ArrayType{
+	return &ArrayType{
 		ElementType: elementType,
 		NumElements: numElements,
 	}
-	t.computeChunks()
-	return t
+}
+
+func NewCArrayType(elementType Type, numElements int) *ArrayType {
+	return &ArrayType{
+		ElementType: elementType,
+		NumElements: numElements,
+		Layout:      CLayout,
+	}
 }
 
 func (*ArrayType) isTypeExpression() {}
 
 func (thisArray *ArrayType) Equals(other Type) bool {
+	return thisArray.equals(other, assumedEqualTypes{})
+}
+
+func (thisArray *ArrayType) equals(
+	other Type,
+	assumed assumedEqualTypes,
+) bool {
 	if thisArray == other {
 		return true
 	}
@@ -349,35 +587,48 @@ func (thisArray *ArrayType) Equals(other Type) bool {
 	}
 
 	return thisArray.NumElements == otherArray.NumElements &&
-		thisArray.ElementType.Equals(otherArray.ElementType)
+		thisArray.Layout == otherArray.Layout &&
+		thisArray.ElementType.equals(otherArray.ElementType, assumed)
 }
 
 func (t *ArrayType) Size() int {
-	if t.NumElements < 0 {
-		panic("unknown number of elements in array")
-	}
-	return t.size
+	return t.memoryLayout().size
 }
 
 func (t *ArrayType) Chunks() []*TypeChunk {
+	return t.memoryLayout().chunks
+}
+
+func (t *ArrayType) memoryLayout() *memoryLayout {
 	if t.NumElements < 0 {
 		panic("unknown number of elements in array")
 	}
-	return t.chunks
+	return t.memory.get(t.computeMemoryLayout)
 }
 
-func (t *ArrayType) computeChunks() {
-	elementSize := t.ElementType.Size()
-	dataSize := t.NumElements * elementSize
-	numChunks := (dataSize + generalRegisterSize - 1) / generalRegisterSize
+func (t *ArrayType) computeMemoryLayout() *memoryLayout {
+	err := checkSelfContainment(t.ElementType, nil)
+	if err != nil {
+		panic(err.Error())
+	}
 
-	t.size = numChunks * generalRegisterSize
-	if t.size == 0 {
-		t.chunks = []*TypeChunk{}
-		return
+	if t.Layout == CLayout {
+		return t.computeCLayout()
 	}
 
-	chunks := make([]*TypeChunk, 0, numChunks)
+	elementSize := t.ElementType.Size()
+	if t.NumElements == 0 || elementSize == 0 {
+		return &memoryLayout{
+			size:   0,
+			chunks: []*TypeChunk{},
+		}
+	}
+
+	// NOTE: C layout element sizes are not necessarily a multiple / divisor of
+	// the chunk size.  Elements never span chunk boundaries (multi-chunk
+	// elements are chunk aligned), and hence the number of chunks may be
+	// larger than the elements' total data size suggests.
+	chunks := []*TypeChunk{}
 	if elementSize <= generalRegisterSize {
 		currentChunk := &TypeChunk{}
 		currentSize := 0
@@ -400,7 +651,8 @@ func (t *ArrayType) computeChunks() {
 
 		chunks = append(chunks, currentChunk)
 	} else {
-		// NOTE: Aggregate type sizes are always chunk aligned
+		// NOTE: Each multi-chunk element occupies whole chunks, i.e., C layout
+		// element sizes are rounded up to a multiple of the chunk size.
 		elementChunks := t.ElementType.Chunks()
 		for i := 0; i < t.NumElements; i++ {
 			for _, elementChunk := range elementChunks {
@@ -420,10 +672,59 @@ func (t *ArrayType) computeChunks() {
 		}
 	}
 
-	if len(chunks) != numChunks {
-		panic("should never happen")
+	return &memoryLayout{
+		size:   len(chunks) * generalRegisterSize,
+		chunks: chunks,
 	}
-	t.chunks = chunks
+}
+
+func (t *ArrayType) computeCLayout() *memoryLayout {
+	elementSize := t.ElementType.Size()
+
+	size := t.NumElements * elementSize
+	chunks := newCLayoutChunks(size)
+	for i := 0; i < t.NumElements; i++ {
+		chunks.addValue(t.ElementType, i*elementSize)
+	}
+
+	return &memoryLayout{
+		size:   size,
+		chunks: chunks,
+	}
+}
+
+// An aggregate type's size, field offsets (struct types only) and chunks.
+type memoryLayout struct {
+	size         int
+	fieldOffsets []int
+	chunks       []*TypeChunk
+}
+
+// The memory layout is computed on first access, and is safe for concurrent
+// use.  The computation is retried on subsequent accesses if it panics (e.g.,
+// the aggregate references a struct type declaration that is not yet
+// completed).
+type lazyMemoryLayout struct {
+	mutex    sync.Mutex
+	computed atomic.Pointer[memoryLayout]
+}
+
+func (lazy *lazyMemoryLayout) get(compute func() *memoryLayout) *memoryLayout {
+	result := lazy.computed.Load()
+	if result != nil {
+		return result
+	}
+
+	lazy.mutex.Lock()
+	defer lazy.mutex.Unlock()
+
+	result = lazy.computed.Load()
+	if result == nil {
+		result = compute()
+		lazy.computed.Store(result)
+	}
+
+	return result
 }
 
 type Field struct {
@@ -435,15 +736,107 @@ type Field struct {
 // location book keeping / code generation (a simple value in a packed struct
 // may span multiple data chunks)
 type StructType struct {
+	// Name is empty for anonymous struct types.  Named struct types are
+	// declared via DeclareStructType.
+	Name string
+
 	Fields []Field
 
+	// NOTE: The layout must be specified prior to completing a struct type
+	// declaration.
+	Layout AggregateLayoutKind
+
 	// NOTE: (internal use only) This is not part of the type signature.
+	// size, field offsets and chunks are lazily computed since a struct type
+	// declaration could be referenced before its fields are specified.
 
-	size   int
-	chunks []*TypeChunk
+	isIncomplete bool
+
+	memory lazyMemoryLayout
 }
 
 func NewStructType(fields []Field) *StructType {
+	checkFieldNames(fields)
+	return &StructType{
+		Fields: fields,
+	}
+}
+
+func NewCStructType(fields []Field) *StructType {
+	checkFieldNames(fields)
+	return &StructType{
+		Fields: fields,
+		Layout: CLayout,
+	}
+}
+
+// Declare an incomplete named struct type.  The declared type may be
+// referenced (e.g., by an AddressType field within its own fields) before
+// the declaration is completed via SetFields.  The type's size and chunks are
+// only accessible after the declaration is completed.
+//
+// For example, a linked list node is declared as:
+//
+//	node := DeclareStructType("node")
+//	node.SetFields([]Field{
+//		{Name: "value", Type: Int64},
+//		{Name: "next", Type: NewAddressType(node)},
+//	})
+func DeclareStructType(name string) *StructType {
+	return &StructType{
+		Name:         name,
+		isIncomplete: true,
+	}
+}
+
+// Complete the struct type declaration.
+func (t *StructType) SetFields(fields []Field) {
+	if !t.isIncomplete {
+		panic("struct type already completed: " + t.Name)
+	}
+
+	checkFieldNames(fields)
+	t.Fields = fields
+	t.isIncomplete = false
+}
+
+func (t *StructType) IsIncomplete() bool {
+	return t.isIncomplete
+}
+
+// Returns an error if the struct type contains itself by value, either
+// directly or via nested struct / array fields (such a type has no finite
+// size).  Self references via address types are allowed.
+func (t *StructType) CheckSelfContainment() error {
+	return checkSelfContainment(t, nil)
+}
+
+func checkSelfContainment(t Type, containing []*StructType) error {
+	switch value := t.(type) {
+	case *ArrayType:
+		return checkSelfContainment(value.ElementType, containing)
+	case *StructType:
+		for _, outer := range containing {
+			if outer == value {
+				return fmt.Errorf(
+					"struct type (%s) contains itself by value",
+					value.Name)
+			}
+		}
+
+		containing = append(containing, value)
+		for _, field := range value.Fields {
+			err := checkSelfContainment(field.Type, containing)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkFieldNames(fields []Field) {
 	names := map[string]struct{}{}
 	for _, field := range fields {
 		_, ok := names[field.Name]
@@ -452,17 +845,18 @@ func NewStructType(fields []Field) *StructType {
 		}
 		names[field.Name] = struct{}{}
 	}
-
-	t := &StructType{
-		Fields: fields,
-	}
-	t.computeChunks()
-	return t
 }
 
 func (*StructType) isTypeExpression() {}
 
 func (thisStruct *StructType) Equals(other Type) bool {
+	return thisStruct.equals(other, assumedEqualTypes{})
+}
+
+func (thisStruct *StructType) equals(
+	other Type,
+	assumed assumedEqualTypes,
+) bool {
 	if thisStruct == other {
 		return true
 	}
@@ -472,13 +866,27 @@ func (thisStruct *StructType) Equals(other Type) bool {
 		return false
 	}
 
-	if len(thisStruct.Fields) != len(otherStruct.Fields) {
+	pair := typePair{this: thisStruct, other: otherStruct}
+	_, ok = assumed[pair]
+	if ok {
+		return true
+	}
+
+	if thisStruct.Name != otherStruct.Name ||
+		thisStruct.Layout != otherStruct.Layout ||
+		thisStruct.isIncomplete != otherStruct.isIncomplete ||
+		len(thisStruct.Fields) != len(otherStruct.Fields) {
+
 		return false
 	}
 
+	assumed[pair] = struct{}{}
+
 	for idx, field := range thisStruct.Fields {
 		otherField := otherStruct.Fields[idx]
-		if field.Name != otherField.Name || !field.Type.Equals(otherField.Type) {
+		if field.Name != otherField.Name ||
+			!field.Type.equals(otherField.Type, assumed) {
+
 			return false
 		}
 	}
@@ -487,20 +895,46 @@ func (thisStruct *StructType) Equals(other Type) bool {
 }
 
 func (t *StructType) Size() int {
-	return len(t.chunks) * generalRegisterSize
+	return t.memoryLayout().size
 }
 
 func (t *StructType) Chunks() []*TypeChunk {
-	return t.chunks
+	return t.memoryLayout().chunks
 }
 
-func (t *StructType) computeChunks() {
+// The fields' memory address offsets relative to the beginning of the struct.
+func (t *StructType) FieldOffsets() []int {
+	return t.memoryLayout().fieldOffsets
+}
+
+func (t *StructType) memoryLayout() *memoryLayout {
+	if t.isIncomplete {
+		panic("incomplete struct type: " + t.Name)
+	}
+	return t.memory.get(t.computeMemoryLayout)
+}
+
+func (t *StructType) computeMemoryLayout() *memoryLayout {
+	// NOTE: self containment must be checked prior to computing the fields'
+	// layouts, otherwise the computation would recursively wait on itself.
+	err := t.CheckSelfContainment()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if t.Layout == CLayout {
+		return t.computeCLayout()
+	}
+
 	if len(t.Fields) == 0 {
-		t.chunks = []*TypeChunk{}
-		t.size = 0
-		return
+		return &memoryLayout{
+			size:         0,
+			fieldOffsets: []int{},
+			chunks:       []*TypeChunk{},
+		}
 	}
 
+	fieldOffsets := make([]int, 0, len(t.Fields))
 	chunks := []*TypeChunk{}
 	currentChunk := &TypeChunk{}
 	currentSize := 0
@@ -517,16 +951,16 @@ func (t *StructType) computeChunks() {
 			}
 
 			// Adjust field alignment
-			fieldAlignment := Alignment(fieldSize)
-			mod := currentSize % fieldAlignment
-			if mod > 0 {
-				currentSize += fieldAlignment - mod
-			}
+			currentSize = alignOffset(currentSize, TypeAlignment(field.Type))
 
 			if currentSize+fieldSize > generalRegisterSize {
 				panic("should never happen")
 			}
 
+			fieldOffsets = append(
+				fieldOffsets,
+				len(chunks)*generalRegisterSize+currentSize)
+
 			currentChunk.Values = append(
 				currentChunk.Values,
 				TypeChunkValue{
@@ -546,6 +980,7 @@ func (t *StructType) computeChunks() {
 			currentSize = 0
 		}
 
+		fieldOffsets = append(fieldOffsets, len(chunks)*generalRegisterSize)
 		for _, fieldChunk := range field.Type.Chunks() {
 			chunks = append(
 				chunks,
@@ -566,8 +1001,139 @@ func (t *StructType) computeChunks() {
 		chunks = append(chunks, currentChunk)
 	}
 
-	t.size = len(chunks) * generalRegisterSize
-	t.chunks = chunks
+	return &memoryLayout{
+		size:         len(chunks) * generalRegisterSize,
+		fieldOffsets: fieldOffsets,
+		chunks:       chunks,
+	}
+}
+
+func (t *StructType) computeCLayout() *memoryLayout {
+	fieldOffsets := make([]int, 0, len(t.Fields))
+	size := 0
+	alignment := 1
+	for _, field := range t.Fields {
+		fieldAlignment := TypeAlignment(field.Type)
+		if fieldAlignment > alignment {
+			alignment = fieldAlignment
+		}
+
+		size = alignOffset(size, fieldAlignment)
+		fieldOffsets = append(fieldOffsets, size)
+		size += field.Type.Size()
+	}
+
+	size = alignOffset(size, alignment)
+
+	chunks := newCLayoutChunks(size)
+	for idx, field := range t.Fields {
+		chunks.addValue(field.Type, fieldOffsets[idx])
+	}
+
+	return &memoryLayout{
+		size:         size,
+		fieldOffsets: fieldOffsets,
+		chunks:       chunks,
+	}
+}
+
+// Eightbyte chunks for C layout aggregate types.
+type cLayoutChunks []*TypeChunk
+
+func newCLayoutChunks(size int) cLayoutChunks {
+	numChunks := (size + generalRegisterSize - 1) / generalRegisterSize
+	chunks := make(cLayoutChunks, 0, numChunks)
+	for len(chunks) < numChunks {
+		chunks = append(chunks, &TypeChunk{})
+	}
+	return chunks
+}
+
+// Add the value's flattened non-aggregate values to the chunks.  offset is
+// relative to the beginning of the C layout aggregate.
+func (chunks cLayoutChunks) addValue(valueType Type, offset int) {
+	switch t := valueType.(type) {
+	case *StructType:
+		fieldOffsets := t.FieldOffsets()
+		for idx, field := range t.Fields {
+			chunks.addValue(field.Type, offset+fieldOffsets[idx])
+		}
+	case *ArrayType:
+		if t.Layout == CLayout {
+			elementSize := t.ElementType.Size()
+			for i := 0; i < t.NumElements; i++ {
+				chunks.addValue(t.ElementType, offset+i*elementSize)
+			}
+			return
+		}
+
+		// Chunked layout elements are located via the array's chunks.  Each
+		// multi-chunk element is added once, on the element's first chunk.
+		for _, chunk := range t.Chunks() {
+			for _, value := range chunk.Values {
+				if value.ValueTypeChunk != nil &&
+					value.ValueTypeChunk != value.ValueType.Chunks()[0] {
+
+					continue
+				}
+
+				chunks.addValue(
+					value.ValueType,
+					offset+value.Index*generalRegisterSize+value.Offset)
+			}
+		}
+	case *UnsignedIntType, *SignedIntType, *FloatType, *EnumType,
+		*AddressType, *FunctionType:
+
+		idx := offset / generalRegisterSize
+		chunk := chunks[idx]
+		chunk.Values = append(
+			chunk.Values,
+			TypeChunkValue{
+				Index:     idx,
+				Offset:    offset % generalRegisterSize,
+				ValueType: valueType,
+			})
+	default:
+		panic(fmt.Sprintf("unsupported value type: %v", valueType))
+	}
+}
+
+func alignOffset(offset int, alignment int) int {
+	mod := offset % alignment
+	if mod > 0 {
+		offset += alignment - mod
+	}
+	return offset
+}
+
+// The type's memory address alignment.  Unlike Alignment, this supports C
+// layout aggregate types, whose sizes are not necessarily a multiple of their
+// alignments.  Zero-sized types are byte aligned.
+func TypeAlignment(t Type) int {
+	switch valueType := t.(type) {
+	case *StructType:
+		if valueType.Layout == CLayout {
+			alignment := 1
+			for _, field := range valueType.Fields {
+				fieldAlignment := TypeAlignment(field.Type)
+				if fieldAlignment > alignment {
+					alignment = fieldAlignment
+				}
+			}
+			return alignment
+		}
+	case *ArrayType:
+		if valueType.Layout == CLayout {
+			return TypeAlignment(valueType.ElementType)
+		}
+	}
+
+	size := t.Size()
+	if size == 0 {
+		return 1
+	}
+	return Alignment(size)
 }
 
 func Alignment(typeSize int) int {
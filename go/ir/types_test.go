@@ -0,0 +1,405 @@
+package ir
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+)
+
+func newTestListNode(name string) *StructType {
+	node := DeclareStructType(name)
+	node.SetFields([]Field{
+		{Name: "value", Type: Int32},
+		{Name: "next", Type: NewAddressType(node)},
+	})
+	return node
+}
+
+func TestRecursiveStructType(t *testing.T) {
+	node := newTestListNode("node")
+
+	expect.False(t, node.IsIncomplete())
+	expect.Equal(t, 16, node.Size())
+
+	chunks := node.Chunks()
+	expect.Equal(t, 2, len(chunks))
+	expect.Equal(t, 1, len(chunks[0].Values))
+	expect.Equal(t, Type(Int32), chunks[0].Values[0].ValueType)
+	expect.Equal(t, 1, len(chunks[1].Values))
+	expect.Equal(t, 1, chunks[1].Values[0].Index)
+
+	next := chunks[1].Values[0].ValueType.(*AddressType)
+	expect.True(t, next.ValueType == node)
+}
+
+func TestIncompleteStructType(t *testing.T) {
+	node := DeclareStructType("node")
+	expect.True(t, node.IsIncomplete())
+
+	// The incomplete declaration is referencable through address / array types.
+	address := NewAddressType(node)
+	expect.Equal(t, 8, address.Size())
+
+	array := NewArrayType(node, 2)
+
+	node.SetFields([]Field{{Name: "value", Type: Int64}})
+	expect.False(t, node.IsIncomplete())
+	expect.Equal(t, 16, array.Size())
+	expect.Equal(t, 2, len(array.Chunks()))
+}
+
+func TestSelfContainingStructType(t *testing.T) {
+	node := DeclareStructType("node")
+	node.SetFields([]Field{
+		{Name: "value", Type: Int64},
+		{Name: "children", Type: NewArrayType(node, 2)},
+	})
+	expect.Error(t, node.CheckSelfContainment(), "(node) contains itself")
+
+	outer := DeclareStructType("outer")
+	inner := DeclareStructType("inner")
+	inner.SetFields([]Field{{Name: "outer", Type: outer}})
+	outer.SetFields([]Field{{Name: "inner", Type: inner}})
+	expect.Error(t, outer.CheckSelfContainment(), "(outer) contains itself")
+
+	// Self references via address types are allowed.
+	expect.Nil(t, newTestListNode("list").CheckSelfContainment())
+}
+
+func TestCheckTypeDeclarations(t *testing.T) {
+	list := newTestListNode("list")
+	incomplete := DeclareStructType("incomplete")
+
+	unit := &CompilationUnit{
+		TypeDeclarations: []*StructType{list, incomplete},
+	}
+	expect.Error(
+		t,
+		unit.CheckTypeDeclarations(),
+		"incomplete struct type declaration (incomplete)")
+
+	incomplete.SetFields([]Field{{Name: "self", Type: incomplete}})
+	expect.Error(
+		t,
+		unit.CheckTypeDeclarations(),
+		"struct type (incomplete) contains itself by value")
+
+	unit.TypeDeclarations = []*StructType{list}
+	expect.Nil(t, unit.CheckTypeDeclarations())
+}
+
+func TestRecursiveStructTypeEquals(t *testing.T) {
+	node1 := newTestListNode("node")
+	node2 := newTestListNode("node")
+	expect.True(t, node1.Equals(node2))
+	expect.True(t, NewAddressType(node1).Equals(NewAddressType(node2)))
+
+	other := newTestListNode("other")
+	expect.False(t, node1.Equals(other))
+
+	// Same name, but different fields
+	different := DeclareStructType("node")
+	different.SetFields([]Field{
+		{Name: "value", Type: Int64},
+		{Name: "next", Type: NewAddressType(different)},
+	})
+	expect.False(t, node1.Equals(different))
+
+	// Anonymous struct type with the same fields is not the same type.
+	anonymous := NewStructType(node1.Fields)
+	expect.False(t, node1.Equals(anonymous))
+}
+
+func TestMutuallyRecursiveStructTypeEquals(t *testing.T) {
+	newTypes := func() (*StructType, *StructType) {
+		a := DeclareStructType("a")
+		b := DeclareStructType("b")
+		a.SetFields([]Field{{Name: "b", Type: NewAddressType(b)}})
+		b.SetFields([]Field{
+			{Name: "a", Type: NewAddressType(a)},
+			{
+				Name: "callback",
+				Type: NewFunctionType(
+					SysVLiteCallConvention,
+					[]Type{NewAddressType(a), NewAddressType(b)},
+					NewStructType(nil)),
+			},
+		})
+		return a, b
+	}
+
+	a1, b1 := newTypes()
+	a2, b2 := newTypes()
+
+	expect.True(t, a1.Equals(a2))
+	expect.True(t, b1.Equals(b2))
+	expect.False(t, a1.Equals(b2))
+	expect.Equal(t, 8, a1.Size())
+	expect.Equal(t, 16, b1.Size())
+}
+
+func TestConcurrentMemoryLayoutComputation(t *testing.T) {
+	node := newTestListNode("node")
+	arrayType := NewArrayType(node, 3)
+	structType := NewCStructType([]Field{
+		{Name: "a", Type: Int8},
+		{Name: "b", Type: arrayType},
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expect.Equal(t, 56, structType.Size())
+			expect.Equal(t, []int{0, 8}, structType.FieldOffsets())
+			expect.Equal(t, 7, len(structType.Chunks()))
+			expect.Equal(t, 48, arrayType.Size())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIncompleteMemoryLayoutComputation(t *testing.T) {
+	node := DeclareStructType("node")
+	arrayType := NewArrayType(node, 2)
+
+	func() {
+		defer func() {
+			expect.Equal(t, "incomplete struct type: node", recover())
+		}()
+		arrayType.Size()
+	}()
+
+	// The computation is retried once the declaration is completed.
+	node.SetFields([]Field{{Name: "value", Type: Int64}})
+	expect.Equal(t, 16, arrayType.Size())
+}
+
+func TestCStructLayout(t *testing.T) {
+	// struct { int8_t a; }
+	structType := NewCStructType([]Field{{Name: "a", Type: Int8}})
+	expect.Equal(t, 1, structType.Size())
+	expect.Equal(t, 1, TypeAlignment(structType))
+	expect.Equal(t, []int{0}, structType.FieldOffsets())
+	expect.Equal(t, 1, len(structType.Chunks()))
+
+	// struct { int8_t a; int32_t b; int16_t c; }
+	structType = NewCStructType([]Field{
+		{Name: "a", Type: Int8},
+		{Name: "b", Type: Int32},
+		{Name: "c", Type: Int16},
+	})
+	expect.Equal(t, 12, structType.Size())
+	expect.Equal(t, 4, TypeAlignment(structType))
+	expect.Equal(t, []int{0, 4, 8}, structType.FieldOffsets())
+
+	chunks := structType.Chunks()
+	expect.Equal(t, 2, len(chunks))
+	expect.Equal(
+		t,
+		[]TypeChunkValue{
+			{Index: 0, Offset: 0, ValueType: Int8},
+			{Index: 0, Offset: 4, ValueType: Int32},
+		},
+		chunks[0].Values)
+	expect.Equal(
+		t,
+		[]TypeChunkValue{
+			{Index: 1, Offset: 0, ValueType: Int16},
+		},
+		chunks[1].Values)
+
+	// The same fields in chunked layout
+	chunked := NewStructType(structType.Fields)
+	expect.Equal(t, 16, chunked.Size())
+	expect.Equal(t, []int{0, 4, 8}, chunked.FieldOffsets())
+	expect.False(t, chunked.Equals(structType))
+}
+
+func TestCStructNestedLayout(t *testing.T) {
+	// struct { int16_t a; struct { int8_t b; int8_t c[3]; } d; uint64_t e; }
+	inner := NewCStructType([]Field{
+		{Name: "b", Type: Int8},
+		{Name: "c", Type: NewCArrayType(Int8, 3)},
+	})
+	expect.Equal(t, 4, inner.Size())
+	expect.Equal(t, 1, TypeAlignment(inner))
+
+	outer := NewCStructType([]Field{
+		{Name: "a", Type: Int16},
+		{Name: "d", Type: inner},
+		{Name: "e", Type: Uint64},
+	})
+	expect.Equal(t, 16, outer.Size())
+	expect.Equal(t, 8, TypeAlignment(outer))
+	expect.Equal(t, []int{0, 2, 8}, outer.FieldOffsets())
+
+	chunks := outer.Chunks()
+	expect.Equal(t, 2, len(chunks))
+	expect.Equal(
+		t,
+		[]TypeChunkValue{
+			{Index: 0, Offset: 0, ValueType: Int16},
+			{Index: 0, Offset: 2, ValueType: Int8},
+			{Index: 0, Offset: 3, ValueType: Int8},
+			{Index: 0, Offset: 4, ValueType: Int8},
+			{Index: 0, Offset: 5, ValueType: Int8},
+		},
+		chunks[0].Values)
+	expect.Equal(
+		t,
+		[]TypeChunkValue{
+			{Index: 1, Offset: 0, ValueType: Uint64},
+		},
+		chunks[1].Values)
+
+	// The C layout struct is not chunk aligned within the chunked layout
+	// struct.
+	chunked := NewStructType([]Field{
+		{Name: "a", Type: Int16},
+		{Name: "d", Type: inner},
+	})
+	expect.Equal(t, 8, chunked.Size())
+	expect.Equal(t, []int{0, 2}, chunked.FieldOffsets())
+}
+
+func TestCArrayLayout(t *testing.T) {
+	arrayType := NewCArrayType(Uint8, 3)
+	expect.Equal(t, 3, arrayType.Size())
+	expect.Equal(t, 1, TypeAlignment(arrayType))
+	expect.Equal(t, 1, len(arrayType.Chunks()))
+	expect.Equal(t, 3, len(arrayType.Chunks()[0].Values))
+	expect.False(t, arrayType.Equals(NewArrayType(Uint8, 3)))
+
+	// Chunked layout elements within a C layout array.
+	element := NewArrayType(Int16, 3)
+	arrayType = NewCArrayType(element, 2)
+	expect.Equal(t, 16, arrayType.Size())
+	expect.Equal(t, 8, TypeAlignment(arrayType))
+
+	chunks := arrayType.Chunks()
+	expect.Equal(t, 2, len(chunks))
+	expect.Equal(t, 3, len(chunks[0].Values))
+	expect.Equal(t, 3, len(chunks[1].Values))
+	expect.Equal(t, 4, chunks[1].Values[2].Offset)
+
+	// C layout elements within a chunked layout array.
+	element3 := NewCStructType([]Field{
+		{Name: "a", Type: Int8},
+		{Name: "b", Type: Int16},
+	})
+	expect.Equal(t, 4, element3.Size())
+	expect.Equal(t, 2, TypeAlignment(element3))
+
+	chunkedArray := NewArrayType(element3, 3)
+	expect.Equal(t, 16, chunkedArray.Size())
+
+	// C layout struct containing a chunked layout array with chunk padding.
+	structType := NewCStructType([]Field{
+		{Name: "x", Type: Int8},
+		{Name: "y", Type: chunkedArray},
+	})
+	expect.Equal(t, 24, structType.Size())
+	expect.Equal(t, []int{0, 8}, structType.FieldOffsets())
+
+	chunks = structType.Chunks()
+	expect.Equal(t, 3, len(chunks))
+	expect.Equal(
+		t,
+		[]TypeChunkValue{
+			{Index: 1, Offset: 0, ValueType: Int8},
+			{Index: 1, Offset: 2, ValueType: Int16},
+			{Index: 1, Offset: 4, ValueType: Int8},
+			{Index: 1, Offset: 6, ValueType: Int16},
+		},
+		chunks[1].Values)
+	expect.Equal(
+		t,
+		[]TypeChunkValue{
+			{Index: 2, Offset: 0, ValueType: Int8},
+			{Index: 2, Offset: 2, ValueType: Int16},
+		},
+		chunks[2].Values)
+}
+
+func TestChunkedLayoutWithMultiChunkCLayoutValues(t *testing.T) {
+	// struct { int32_t a; int32_t b; int32_t c; }
+	element := NewCStructType([]Field{
+		{Name: "a", Type: Int32},
+		{Name: "b", Type: Int32},
+		{Name: "c", Type: Int32},
+	})
+	expect.Equal(t, 12, element.Size())
+	expect.Equal(t, 2, len(element.Chunks()))
+
+	// Each element occupies two whole chunks.
+	arrayType := NewArrayType(element, 2)
+	expect.Equal(t, 32, arrayType.Size())
+
+	chunks := arrayType.Chunks()
+	expect.Equal(t, 4, len(chunks))
+	for idx, chunk := range chunks {
+		expect.Equal(
+			t,
+			[]TypeChunkValue{
+				{
+					Index:          idx,
+					Offset:         0,
+					ValueType:      element,
+					ValueTypeChunk: element.Chunks()[idx%2],
+				},
+			},
+			chunk.Values)
+	}
+
+	structType := NewStructType([]Field{
+		{Name: "x", Type: Int8},
+		{Name: "y", Type: element},
+		{Name: "z", Type: Int32},
+	})
+	expect.Equal(t, 32, structType.Size())
+	expect.Equal(t, []int{0, 8, 24}, structType.FieldOffsets())
+	expect.Equal(t, 4, len(structType.Chunks()))
+
+	// Elements which do not evenly divide the chunk never span chunks.
+	small := NewCStructType([]Field{
+		{Name: "a", Type: Int8},
+		{Name: "b", Type: Int8},
+		{Name: "c", Type: Int8},
+	})
+	arrayType = NewArrayType(small, 5)
+	expect.Equal(t, 24, arrayType.Size())
+
+	chunks = arrayType.Chunks()
+	expect.Equal(t, 3, len(chunks))
+	expect.Equal(t, 2, len(chunks[0].Values))
+	expect.Equal(t, 2, len(chunks[1].Values))
+	expect.Equal(t, 1, len(chunks[2].Values))
+	expect.Equal(t, 3, chunks[1].Values[1].Offset)
+}
+
+func TestEnumType(t *testing.T) {
+	values := []EnumValue{
+		{Name: "a", Value: 0},
+		{Name: "b", Value: 200},
+	}
+
+	enumType := NewEnumType("e", Uint8, values)
+	expect.Equal(t, 1, enumType.Size())
+	expect.Equal(t, 1, TypeAlignment(enumType))
+	expect.Equal(t, interface{}(uint8(200)), enumType.Value("b"))
+	expect.Equal(t, Type(Uint8), UnderlyingType(enumType))
+	expect.Equal(t, Type(Int32), UnderlyingType(Int32))
+
+	immediate := NewEnumImmediate(enumType, "b").(*Immediate)
+	expect.Equal(t, interface{}(uint8(200)), immediate.Value)
+	expect.True(t, immediate.Type() == enumType)
+
+	expect.True(t, enumType.Equals(NewEnumType("e", Uint8, values)))
+	expect.False(t, enumType.Equals(NewEnumType("f", Uint8, values)))
+	expect.False(t, enumType.Equals(NewEnumType("e", Int16, values)))
+	expect.False(t, enumType.Equals(NewEnumType("e", Uint8, values[:1])))
+	expect.False(t, enumType.Equals(Uint8))
+}
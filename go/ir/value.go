@@ -17,6 +17,10 @@ type Value interface {
 }
 
 // Global (function/object/constant) definition reference
+//
+// A function reference's type is the function's FunctionType, and its value
+// is the function's address.  The value can be assigned, passed and called
+// through (via a local reference) like any other value.
 type GlobalReference struct {
 	operation
 
@@ -50,7 +54,8 @@ func (ref *GlobalReference) Def() *Definition {
 type Immediate struct {
 	operation
 
-	// int*/uint*/float* for basic types; []byte for array/struct/address types
+	// int*/uint*/float* for basic types; int*/uint* (the underlying type's
+	// representation) for enum types; []byte for array/struct/address types
 	Value interface{}
 
 	ImmediateType Type
@@ -98,6 +103,17 @@ func NewBasicImmediate(value interface{}) Value {
 	return imm
 }
 
+// Enum immediate.  The immediate's value is the named enum value's
+// underlying int*/uint* representation.
+func NewEnumImmediate(enumType *EnumType, name string) Value {
+	imm := &Immediate{
+		Value:         enumType.Value(name),
+		ImmediateType: enumType,
+	}
+
+	return imm
+}
+
 // array / struct / address immediate
 func NewComplexImmediate(immediateType Type, value []byte) Value {
 	switch immediateType.(type) {
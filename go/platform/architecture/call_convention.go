@@ -147,7 +147,7 @@ func (convention *CallConvention) AddStackEntry(t ir.Type) *StackEntry {
 func (convention *CallConvention) FinalizeCallFrameLayout() {
 	size := 0
 	for _, entry := range convention.CallFrameLayout {
-		alignment := ir.Alignment(entry.Type.Size())
+		alignment := ir.TypeAlignment(entry.Type)
 		mod := size % alignment
 		if mod > 0 {
 			size += alignment - mod
@@ -8,4 +8,31 @@ type Config struct {
 	InstructionSet
 
 	CallConventions
+
+	// Optional cpu features (instruction set extensions) available on the
+	// target machine.  The feature names are architecture specific.
+	// Instruction selectors must fall back to baseline instruction sequences
+	// when a feature is unavailable.
+	Features map[string]bool
+
+	// When false, operations inherit the target's native behavior for inputs
+	// that are not meaningful (e.g., amd64 masks shift counts and traps on
+	// integer division by zero).  When true, the instruction selectors must
+	// honor the portable (Go-like) semantics documented on ir.BinaryOperation:
+	//
+	//  - shifting by a count >= the operand's bit width yields 0 for shl and
+	//    unsigned shr, and sign fill (0 or -1) for signed shr.
+	//  - integer division / remainder by zero calls DivideByZeroHandler.
+	//  - signed MIN / -1 yields MIN, and signed MIN % -1 yields 0.
+	//
+	// NOTE: float to int/uint conversions of NaN and out of range values are
+	// not covered, and remain target specific (see ir.UnaryOperation).
+	DefinedSemantics bool
+
+	// The function symbol called on integer division / remainder by zero when
+	// DefinedSemantics is enabled.  The handler takes no arguments and must not
+	// return.  NOTE: the handler is called directly from the dividing function's
+	// body, without following any call convention.  Only the stack pointer is
+	// aligned prior to the call.
+	DivideByZeroHandler string
 }
@@ -42,6 +42,29 @@ type ConditionalJumpSelector interface {
 	Select(Config, *ir.ConditionalJump, SelectorHint) MachineInstruction
 }
 
+type SwitchSelector interface {
+	Select(Config, *ir.Switch, SelectorHint) MachineInstruction
+}
+
+type TerminalSelector interface {
+	Select(Config, *ir.Terminal, SelectorHint) MachineInstruction
+}
+
+type GlobalReferenceSelector interface {
+	Select(
+		Config,
+		*ir.Definition,
+		*ir.GlobalReference,
+		SelectorHint,
+	) MachineInstruction
+}
+
+// NOTE: call selection is governed entirely by the callee's call convention,
+// hence no hint is provided.
+type FunctionCallSelector interface {
+	Select(Config, *ir.Definition, *ir.FunctionCall) MachineInstruction
+}
+
 type UnaryOperationSelector interface {
 	Select(
 		Config,
@@ -60,6 +83,15 @@ type BinaryOperationSelector interface {
 	) MachineInstruction
 }
 
+type AtomicOperationSelector interface {
+	Select(
+		Config,
+		*ir.Definition,
+		*ir.AtomicOperation,
+		SelectorHint,
+	) MachineInstruction
+}
+
 // The set of machine instructions
 type InstructionSet struct {
 	Jump JumpSelector
@@ -88,6 +120,21 @@ type InstructionSet struct {
 	JgeInt   ConditionalJumpSelector
 	JgeFloat ConditionalJumpSelector
 
+	JaddOverflowUint ConditionalJumpSelector
+	JaddOverflowInt  ConditionalJumpSelector
+
+	JsubOverflowUint ConditionalJumpSelector
+	JsubOverflowInt  ConditionalJumpSelector
+
+	JmulOverflowUint ConditionalJumpSelector
+	JmulOverflowInt  ConditionalJumpSelector
+
+	SwitchUint SwitchSelector
+	SwitchInt  SwitchSelector
+
+	Trap        TerminalSelector
+	Unreachable TerminalSelector
+
 	// Unary operations
 
 	NotUint UnaryOperationSelector
@@ -96,6 +143,18 @@ type InstructionSet struct {
 	NegInt   UnaryOperationSelector
 	NegFloat UnaryOperationSelector
 
+	PopcntUint UnaryOperationSelector
+	PopcntInt  UnaryOperationSelector
+
+	ClzUint UnaryOperationSelector
+	ClzInt  UnaryOperationSelector
+
+	CtzUint UnaryOperationSelector
+	CtzInt  UnaryOperationSelector
+
+	BswapUint UnaryOperationSelector
+	BswapInt  UnaryOperationSelector
+
 	UintToUint  UnaryOperationSelector
 	IntToUint   UnaryOperationSelector
 	FloatToUint UnaryOperationSelector
@@ -135,6 +194,12 @@ type InstructionSet struct {
 	ShrUint BinaryOperationSelector
 	ShrInt  BinaryOperationSelector
 
+	RotlUint BinaryOperationSelector
+	RotlInt  BinaryOperationSelector
+
+	RotrUint BinaryOperationSelector
+	RotrInt  BinaryOperationSelector
+
 	AndUint BinaryOperationSelector
 	AndInt  BinaryOperationSelector
 
@@ -143,6 +208,22 @@ type InstructionSet struct {
 
 	XorUint BinaryOperationSelector
 	XorInt  BinaryOperationSelector
+
+	// Atomic operations (on int/uint values)
+
+	AtomicLoad            AtomicOperationSelector
+	AtomicStore           AtomicOperationSelector
+	AtomicExchange        AtomicOperationSelector
+	AtomicCompareExchange AtomicOperationSelector
+	AtomicFetchAdd        AtomicOperationSelector
+	Fence                 AtomicOperationSelector
+
+	// <dest> = <global function reference>
+	FunctionAddress GlobalReferenceSelector
+
+	// <dest> = <function>(<arguments>), where function is either a global
+	// function reference (direct call) or a function value (indirect call).
+	Call FunctionCallSelector
 }
 
 func SelectInstruction(
@@ -157,8 +238,10 @@ func SelectInstruction(
 		return config.Jump.Select(config, instruction, hint)
 	case *ir.ConditionalJump:
 		return selectConditionalJump(config, instruction, hint)
+	case *ir.Switch:
+		return selectSwitch(config, instruction, hint)
 	case *ir.Terminal:
-		panic("TODO")
+		return selectTerminal(config, instruction, hint)
 	default:
 		panic(fmt.Sprintf("unsupported instruction: %#v", instruction))
 	}
@@ -182,20 +265,28 @@ func selectConditionalJump(
 		return selectJgt(config, instruction, hint)
 	case ir.Jge:
 		return selectJge(config, instruction, hint)
+	case ir.JaddOverflow:
+		return selectJaddOverflow(config, instruction, hint)
+	case ir.JsubOverflow:
+		return selectJsubOverflow(config, instruction, hint)
+	case ir.JmulOverflow:
+		return selectJmulOverflow(config, instruction, hint)
 	default:
 		panic("unsupported conditional jump kind: " + instruction.Kind)
 	}
 }
 
+// NOTE: address and function values are compared as unsigned ints, and enum
+// values are compared as their underlying int types.
 func selectJeq(
 	config Config,
 	instruction *ir.ConditionalJump,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Src1.Type().(type) {
+	switch ir.UnderlyingType(instruction.Src1.Type()).(type) {
 	case *ir.SignedIntType:
 		return config.JeqInt.Select(config, instruction, hint)
-	case *ir.UnsignedIntType:
+	case *ir.UnsignedIntType, *ir.AddressType, *ir.FunctionType:
 		return config.JeqUint.Select(config, instruction, hint)
 	case *ir.FloatType:
 		return config.JeqFloat.Select(config, instruction, hint)
@@ -209,10 +300,10 @@ func selectJne(
 	instruction *ir.ConditionalJump,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Src1.Type().(type) {
+	switch ir.UnderlyingType(instruction.Src1.Type()).(type) {
 	case *ir.SignedIntType:
 		return config.JneInt.Select(config, instruction, hint)
-	case *ir.UnsignedIntType:
+	case *ir.UnsignedIntType, *ir.AddressType, *ir.FunctionType:
 		return config.JneUint.Select(config, instruction, hint)
 	case *ir.FloatType:
 		return config.JneFloat.Select(config, instruction, hint)
@@ -226,10 +317,10 @@ func selectJlt(
 	instruction *ir.ConditionalJump,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Src1.Type().(type) {
+	switch ir.UnderlyingType(instruction.Src1.Type()).(type) {
 	case *ir.SignedIntType:
 		return config.JltInt.Select(config, instruction, hint)
-	case *ir.UnsignedIntType:
+	case *ir.UnsignedIntType, *ir.AddressType, *ir.FunctionType:
 		return config.JltUint.Select(config, instruction, hint)
 	case *ir.FloatType:
 		return config.JltFloat.Select(config, instruction, hint)
@@ -243,10 +334,10 @@ func selectJle(
 	instruction *ir.ConditionalJump,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Src1.Type().(type) {
+	switch ir.UnderlyingType(instruction.Src1.Type()).(type) {
 	case *ir.SignedIntType:
 		return config.JleInt.Select(config, instruction, hint)
-	case *ir.UnsignedIntType:
+	case *ir.UnsignedIntType, *ir.AddressType, *ir.FunctionType:
 		return config.JleUint.Select(config, instruction, hint)
 	case *ir.FloatType:
 		return config.JleFloat.Select(config, instruction, hint)
@@ -260,10 +351,10 @@ func selectJgt(
 	instruction *ir.ConditionalJump,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Src1.Type().(type) {
+	switch ir.UnderlyingType(instruction.Src1.Type()).(type) {
 	case *ir.SignedIntType:
 		return config.JgtInt.Select(config, instruction, hint)
-	case *ir.UnsignedIntType:
+	case *ir.UnsignedIntType, *ir.AddressType, *ir.FunctionType:
 		return config.JgtUint.Select(config, instruction, hint)
 	case *ir.FloatType:
 		return config.JgtFloat.Select(config, instruction, hint)
@@ -277,10 +368,10 @@ func selectJge(
 	instruction *ir.ConditionalJump,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Src1.Type().(type) {
+	switch ir.UnderlyingType(instruction.Src1.Type()).(type) {
 	case *ir.SignedIntType:
 		return config.JgeInt.Select(config, instruction, hint)
-	case *ir.UnsignedIntType:
+	case *ir.UnsignedIntType, *ir.AddressType, *ir.FunctionType:
 		return config.JgeUint.Select(config, instruction, hint)
 	case *ir.FloatType:
 		return config.JgeFloat.Select(config, instruction, hint)
@@ -289,6 +380,89 @@ func selectJge(
 	}
 }
 
+func selectJaddOverflow(
+	config Config,
+	instruction *ir.ConditionalJump,
+	hint SelectorHint,
+) MachineInstruction {
+	switch instruction.Src1.Type().(type) {
+	case *ir.SignedIntType:
+		return config.JaddOverflowInt.Select(config, instruction, hint)
+	case *ir.UnsignedIntType:
+		return config.JaddOverflowUint.Select(config, instruction, hint)
+	default:
+		panic(fmt.Sprintf(
+			"supported jaddOverflow type: %v",
+			instruction.Src1.Type()))
+	}
+}
+
+func selectJsubOverflow(
+	config Config,
+	instruction *ir.ConditionalJump,
+	hint SelectorHint,
+) MachineInstruction {
+	switch instruction.Src1.Type().(type) {
+	case *ir.SignedIntType:
+		return config.JsubOverflowInt.Select(config, instruction, hint)
+	case *ir.UnsignedIntType:
+		return config.JsubOverflowUint.Select(config, instruction, hint)
+	default:
+		panic(fmt.Sprintf(
+			"supported jsubOverflow type: %v",
+			instruction.Src1.Type()))
+	}
+}
+
+func selectJmulOverflow(
+	config Config,
+	instruction *ir.ConditionalJump,
+	hint SelectorHint,
+) MachineInstruction {
+	switch instruction.Src1.Type().(type) {
+	case *ir.SignedIntType:
+		return config.JmulOverflowInt.Select(config, instruction, hint)
+	case *ir.UnsignedIntType:
+		return config.JmulOverflowUint.Select(config, instruction, hint)
+	default:
+		panic(fmt.Sprintf(
+			"supported jmulOverflow type: %v",
+			instruction.Src1.Type()))
+	}
+}
+
+func selectTerminal(
+	config Config,
+	instruction *ir.Terminal,
+	hint SelectorHint,
+) MachineInstruction {
+	switch instruction.Kind {
+	case ir.Ret:
+		panic("TODO")
+	case ir.Trap:
+		return config.Trap.Select(config, instruction, hint)
+	case ir.Unreachable:
+		return config.Unreachable.Select(config, instruction, hint)
+	default:
+		panic("unsupported terminal kind: " + instruction.Kind)
+	}
+}
+
+func selectSwitch(
+	config Config,
+	instruction *ir.Switch,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Value.Type()).(type) {
+	case *ir.SignedIntType:
+		return config.SwitchInt.Select(config, instruction, hint)
+	case *ir.UnsignedIntType:
+		return config.SwitchUint.Select(config, instruction, hint)
+	default:
+		panic(fmt.Sprintf("supported switch type: %v", instruction.Value.Type()))
+	}
+}
+
 func selectOperation(
 	config Config,
 	instruction *ir.Definition,
@@ -299,13 +473,44 @@ func selectOperation(
 		return selectUnaryOperation(config, instruction, operation, hint)
 	case *ir.BinaryOperation:
 		return selectBinaryOperation(config, instruction, operation, hint)
+	case *ir.AtomicOperation:
+		return selectAtomicOperation(config, instruction, operation, hint)
+	case *ir.GlobalReference:
+		return selectGlobalReference(config, instruction, operation, hint)
 	case *ir.FunctionCall:
-		panic("TODO")
+		return selectFunctionCall(config, instruction, operation)
 	default:
 		panic(fmt.Sprintf("unsupported operation: %#v", instruction.Operation))
 	}
 }
 
+func selectFunctionCall(
+	config Config,
+	instruction *ir.Definition,
+	call *ir.FunctionCall,
+) MachineInstruction {
+	switch call.Kind {
+	case ir.Call:
+		return config.Call.Select(config, instruction, call)
+	default:
+		panic("unsupported function call kind: " + call.Kind)
+	}
+}
+
+func selectGlobalReference(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.GlobalReference,
+	hint SelectorHint,
+) MachineInstruction {
+	switch instruction.Type.(type) {
+	case *ir.FunctionType:
+		return config.FunctionAddress.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported global reference type: %v", instruction.Type))
+	}
+}
+
 func selectUnaryOperation(
 	config Config,
 	instruction *ir.Definition,
@@ -317,6 +522,14 @@ func selectUnaryOperation(
 		return selectNeg(config, instruction, operation, hint)
 	case ir.Not:
 		return selectNot(config, instruction, operation, hint)
+	case ir.Popcnt:
+		return selectPopcnt(config, instruction, operation, hint)
+	case ir.Clz:
+		return selectClz(config, instruction, operation, hint)
+	case ir.Ctz:
+		return selectCtz(config, instruction, operation, hint)
+	case ir.Bswap:
+		return selectBswap(config, instruction, operation, hint)
 	case ir.ToInt8, ir.ToInt16, ir.ToInt32, ir.ToInt64:
 		return selectToInt(config, instruction, operation, hint)
 	case ir.ToUint8, ir.ToUint16, ir.ToUint32, ir.ToUint64:
@@ -334,7 +547,7 @@ func selectNeg(
 	operation *ir.UnaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.SignedIntType:
 		return config.NegInt.Select(config, instruction, operation, hint)
 	case *ir.FloatType:
@@ -350,7 +563,7 @@ func selectNot(
 	operation *ir.UnaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.NotUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -360,13 +573,77 @@ func selectNot(
 	}
 }
 
+func selectPopcnt(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.UnaryOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Type).(type) {
+	case *ir.UnsignedIntType:
+		return config.PopcntUint.Select(config, instruction, operation, hint)
+	case *ir.SignedIntType:
+		return config.PopcntInt.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported popcnt type: %v", instruction.Type))
+	}
+}
+
+func selectClz(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.UnaryOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Type).(type) {
+	case *ir.UnsignedIntType:
+		return config.ClzUint.Select(config, instruction, operation, hint)
+	case *ir.SignedIntType:
+		return config.ClzInt.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported clz type: %v", instruction.Type))
+	}
+}
+
+func selectCtz(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.UnaryOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Type).(type) {
+	case *ir.UnsignedIntType:
+		return config.CtzUint.Select(config, instruction, operation, hint)
+	case *ir.SignedIntType:
+		return config.CtzInt.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported ctz type: %v", instruction.Type))
+	}
+}
+
+func selectBswap(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.UnaryOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Type).(type) {
+	case *ir.UnsignedIntType:
+		return config.BswapUint.Select(config, instruction, operation, hint)
+	case *ir.SignedIntType:
+		return config.BswapInt.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported bswap type: %v", instruction.Type))
+	}
+}
+
 func selectToInt(
 	config Config,
 	instruction *ir.Definition,
 	operation *ir.UnaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch operation.Src.Def().Type.(type) {
+	switch ir.UnderlyingType(operation.Src.Def().Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.UintToInt.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -384,7 +661,7 @@ func selectToUint(
 	operation *ir.UnaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch operation.Src.Def().Type.(type) {
+	switch ir.UnderlyingType(operation.Src.Def().Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.UintToUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -402,7 +679,7 @@ func selectToFloat(
 	operation *ir.UnaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch operation.Src.Def().Type.(type) {
+	switch ir.UnderlyingType(operation.Src.Def().Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.UintToFloat.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -435,6 +712,10 @@ func selectBinaryOperation(
 		return selectShl(config, instruction, operation, hint)
 	case ir.Shr:
 		return selectShr(config, instruction, operation, hint)
+	case ir.Rotl:
+		return selectRotl(config, instruction, operation, hint)
+	case ir.Rotr:
+		return selectRotr(config, instruction, operation, hint)
 	case ir.And:
 		return selectAnd(config, instruction, operation, hint)
 	case ir.Or:
@@ -452,7 +733,7 @@ func selectAdd(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.AddUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -470,7 +751,7 @@ func selectMul(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.MulUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -488,7 +769,7 @@ func selectSub(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.SubUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -506,7 +787,7 @@ func selectDiv(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.DivUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -524,7 +805,7 @@ func selectRem(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.RemUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -540,7 +821,7 @@ func selectShl(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.ShlUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -556,7 +837,7 @@ func selectShr(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.ShrUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -566,13 +847,45 @@ func selectShr(
 	}
 }
 
+func selectRotl(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.BinaryOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Type).(type) {
+	case *ir.UnsignedIntType:
+		return config.RotlUint.Select(config, instruction, operation, hint)
+	case *ir.SignedIntType:
+		return config.RotlInt.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported rotl type: %v", instruction.Type))
+	}
+}
+
+func selectRotr(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.BinaryOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	switch ir.UnderlyingType(instruction.Type).(type) {
+	case *ir.UnsignedIntType:
+		return config.RotrUint.Select(config, instruction, operation, hint)
+	case *ir.SignedIntType:
+		return config.RotrInt.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf("supported rotr type: %v", instruction.Type))
+	}
+}
+
 func selectAnd(
 	config Config,
 	instruction *ir.Definition,
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.AndUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -588,7 +901,7 @@ func selectOr(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.OrUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -604,7 +917,7 @@ func selectXor(
 	operation *ir.BinaryOperation,
 	hint SelectorHint,
 ) MachineInstruction {
-	switch instruction.Type.(type) {
+	switch ir.UnderlyingType(instruction.Type).(type) {
 	case *ir.UnsignedIntType:
 		return config.XorUint.Select(config, instruction, operation, hint)
 	case *ir.SignedIntType:
@@ -613,3 +926,46 @@ func selectXor(
 		panic(fmt.Sprintf("supported xor type: %v", instruction.Type))
 	}
 }
+
+func selectAtomicOperation(
+	config Config,
+	instruction *ir.Definition,
+	operation *ir.AtomicOperation,
+	hint SelectorHint,
+) MachineInstruction {
+	var selector AtomicOperationSelector
+	switch operation.Kind {
+	case ir.AtomicLoad:
+		selector = config.AtomicLoad
+	case ir.AtomicStore:
+		selector = config.AtomicStore
+	case ir.AtomicExchange:
+		selector = config.AtomicExchange
+	case ir.AtomicCompareExchange:
+		selector = config.AtomicCompareExchange
+	case ir.AtomicFetchAdd:
+		selector = config.AtomicFetchAdd
+	case ir.Fence:
+		return config.Fence.Select(config, instruction, operation, hint)
+	default:
+		panic("unsupported atomic operation: " + operation.Kind)
+	}
+
+	addressType, ok := operation.Address.Type().(*ir.AddressType)
+	if !ok {
+		panic(fmt.Sprintf(
+			"supported %s address type: %v",
+			operation.Kind,
+			operation.Address.Type()))
+	}
+
+	switch ir.UnderlyingType(addressType.ValueType).(type) {
+	case *ir.UnsignedIntType, *ir.SignedIntType:
+		return selector.Select(config, instruction, operation, hint)
+	default:
+		panic(fmt.Sprintf(
+			"supported %s type: %v",
+			operation.Kind,
+			addressType.ValueType))
+	}
+}
@@ -13,6 +13,13 @@ type ArchitectureConfig struct {
 	// 4KB on most architectures
 	MemoryPageSize int64
 
+	// The indirect jump stub used for calling imported functions (e.g.,
+	// jmp *got_entry(%rip) on amd64), padded to the desired stub size.  The
+	// stub's got entry reference is pc relative, located at
+	// ImportStubRelocationOffset.
+	ImportStub                 []byte
+	ImportStubRelocationOffset int64
+
 	Relocator
 }
 
@@ -0,0 +1,407 @@
+package executable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// Based on the common (System V / GNU) ar archive format, as produced by
+// binutils' ar.
+const (
+	ArchiveMagic      = "!<arch>\n"
+	ArchiveHeaderSize = 60
+
+	archiveHeaderTerminator = "`\n"
+
+	// Member names longer than this are stored in the long name table.
+	archiveMaxShortNameLength = 15
+
+	archiveSymbolIndexName   = "/"
+	archiveSymbolIndex64Name = "/SYM64/"
+	archiveLongNameTableName = "//"
+)
+
+// Static library archive (.a) of relocatable object files.
+type Archive struct {
+	// Used for qualifying the members' names (i.e., "<name>(<member>)").
+	Name string
+
+	Members []ArchiveMember
+}
+
+type ArchiveMember struct {
+	Name string
+
+	// Global symbols defined by the member.  These are written to (and read
+	// from) the archive's symbol index.
+	Symbols []string
+
+	Content []byte
+}
+
+// Serializes the object file as a relocatable elf object archive member.
+func NewArchiveMember(
+	config Config,
+	sectionAlignment int64,
+	name string,
+	file layout.ObjectFile,
+) (
+	ArchiveMember,
+	error,
+) {
+	writer, err := NewElfRelocatableWriter(config, sectionAlignment, file)
+	if err != nil {
+		return ArchiveMember{}, err
+	}
+
+	buffer := &bytes.Buffer{}
+	_, err = writer.WriteTo(buffer)
+	if err != nil {
+		return ArchiveMember{}, err
+	}
+
+	symbols := []string{}
+	for _, defs := range []layout.Definitions{
+		file.Text.Definitions,
+		file.Init.Definitions,
+		file.ReadOnlyData.Definitions,
+		file.Data.Definitions,
+		file.BSS.Definitions,
+	} {
+		for _, symbol := range defs.Symbols {
+			// Local symbols cannot resolve other object files' references.
+			if symbol.Binding == layout.LocalBinding {
+				continue
+			}
+			symbols = append(symbols, symbol.Name)
+		}
+	}
+
+	return ArchiveMember{
+		Name:    name,
+		Symbols: symbols,
+		Content: buffer.Bytes(),
+	}, nil
+}
+
+// Pulls archive members that define currently unresolved symbols into the
+// builder until no new member can resolve any of the remaining unresolved
+// symbols (i.e., traditional static linker semantics).  Members are only
+// merged once, and members which are never referenced are not merged.
+//
+// NOTE: Like traditional static linkers, archives are processed in order.
+// Symbols referenced by objects merged after this call are not resolved
+// against this archive.
+func (archive Archive) LinkMembers(
+	builder *layout.ObjectFileBuilder,
+	reader ElfRelocatableReader,
+) error {
+	definedBy := map[string]int{}
+	for idx, member := range archive.Members {
+		for _, symbol := range member.Symbols {
+			_, ok := definedBy[symbol]
+			if !ok {
+				definedBy[symbol] = idx
+			}
+		}
+	}
+
+	merged := make([]bool, len(archive.Members))
+	for {
+		modified := false
+		for _, symbol := range builder.UnresolvedSymbols() {
+			idx, ok := definedBy[symbol]
+			if !ok || merged[idx] {
+				continue
+			}
+
+			member := archive.Members[idx]
+			file, err := reader.Read(
+				archive.Name+"("+member.Name+")",
+				member.Content)
+			if err != nil {
+				return err
+			}
+
+			builder.Merge(file)
+			merged[idx] = true
+			modified = true
+		}
+
+		if !modified {
+			return nil
+		}
+	}
+}
+
+func (archive Archive) WriteTo(writer io.Writer) (int64, error) {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(ArchiveMagic)
+
+	longNames := &bytes.Buffer{}
+	headerNames := make([]string, 0, len(archive.Members))
+	numSymbols := 0
+	symbolNamesSize := 0
+	for _, member := range archive.Members {
+		if strings.ContainsAny(member.Name, "/\n") {
+			return 0, fmt.Errorf("invalid archive member name (%s)", member.Name)
+		}
+
+		if len(member.Name) > archiveMaxShortNameLength {
+			headerNames = append(
+				headerNames,
+				"/"+strconv.Itoa(longNames.Len()))
+			longNames.WriteString(member.Name + "/\n")
+		} else {
+			headerNames = append(headerNames, member.Name+"/")
+		}
+
+		numSymbols += len(member.Symbols)
+		for _, symbol := range member.Symbols {
+			symbolNamesSize += len(symbol) + 1
+		}
+	}
+
+	// The symbol index is the first member, followed by the long name table.
+	// The index's symbol offsets refer to the members' header offsets, which
+	// are computed prior to writing the index.
+	offset := len(ArchiveMagic)
+	if numSymbols > 0 {
+		offset += archiveMemberSize(4 + 4*numSymbols + symbolNamesSize)
+	}
+	if longNames.Len() > 0 {
+		offset += archiveMemberSize(longNames.Len())
+	}
+
+	if numSymbols > 0 {
+		index := binary.BigEndian.AppendUint32(nil, uint32(numSymbols))
+		names := make([]byte, 0, symbolNamesSize)
+		for _, member := range archive.Members {
+			for _, symbol := range member.Symbols {
+				index = binary.BigEndian.AppendUint32(index, uint32(offset))
+				names = append(names, symbol...)
+				names = append(names, 0)
+			}
+			offset += archiveMemberSize(len(member.Content))
+		}
+
+		writeArchiveMember(
+			buffer,
+			archiveSymbolIndexName,
+			append(index, names...))
+	}
+
+	if longNames.Len() > 0 {
+		writeArchiveMember(buffer, archiveLongNameTableName, longNames.Bytes())
+	}
+
+	for idx, member := range archive.Members {
+		writeArchiveMember(buffer, headerNames[idx], member.Content)
+	}
+
+	return buffer.WriteTo(writer)
+}
+
+func archiveMemberSize(contentSize int) int {
+	return ArchiveHeaderSize + contentSize + contentSize%2
+}
+
+// NOTE: timestamp, owner and group are zeroed for deterministic output.
+func writeArchiveMember(buffer *bytes.Buffer, name string, content []byte) {
+	fmt.Fprintf(
+		buffer,
+		"%-16s%-12d%-6d%-6d%-8o%-10d%s",
+		name,
+		0,     // timestamp
+		0,     // owner id
+		0,     // group id
+		0o644, // file mode
+		len(content),
+		archiveHeaderTerminator)
+
+	buffer.Write(content)
+	if len(content)%2 == 1 {
+		buffer.WriteByte('\n')
+	}
+}
+
+func ReadArchive(name string, content []byte) (Archive, error) {
+	archive, err := readArchive(name, content)
+	if err != nil {
+		return Archive{}, fmt.Errorf(
+			"failed to read archive (%s): %w",
+			name,
+			err)
+	}
+	return archive, nil
+}
+
+func readArchive(name string, content []byte) (Archive, error) {
+	if !bytes.HasPrefix(content, []byte(ArchiveMagic)) {
+		return Archive{}, fmt.Errorf("invalid archive. bad magic")
+	}
+
+	archive := Archive{
+		Name: name,
+	}
+
+	var symbolIndex []byte
+	is64BitIndex := false
+	var longNames []byte
+
+	// member header offset -> member index
+	memberIndices := map[int]int{}
+
+	offset := len(ArchiveMagic)
+	for offset < len(content) {
+		if len(content)-offset < ArchiveHeaderSize {
+			return Archive{}, fmt.Errorf(
+				"invalid archive member header at offset %d. not enough bytes",
+				offset)
+		}
+
+		header := content[offset : offset+ArchiveHeaderSize]
+		if string(header[58:60]) != archiveHeaderTerminator {
+			return Archive{}, fmt.Errorf(
+				"invalid archive member header at offset %d. bad terminator",
+				offset)
+		}
+
+		sizeString := strings.TrimRight(string(header[48:58]), " ")
+		size, err := strconv.Atoi(sizeString)
+		if err != nil || size < 0 ||
+			size > len(content)-offset-ArchiveHeaderSize {
+
+			return Archive{}, fmt.Errorf(
+				"invalid archive member header at offset %d. bad size (%s)",
+				offset,
+				sizeString)
+		}
+
+		start := offset + ArchiveHeaderSize
+		memberContent := content[start : start+size]
+
+		headerName := strings.TrimRight(string(header[:16]), " ")
+		switch {
+		case headerName == archiveSymbolIndexName:
+			symbolIndex = memberContent
+		case headerName == archiveSymbolIndex64Name:
+			symbolIndex = memberContent
+			is64BitIndex = true
+		case headerName == archiveLongNameTableName:
+			longNames = memberContent
+		case strings.HasPrefix(headerName, "/"):
+			nameOffset, err := strconv.Atoi(headerName[1:])
+			if err != nil || nameOffset < 0 || nameOffset >= len(longNames) {
+				return Archive{}, fmt.Errorf(
+					"invalid archive member name (%s) at offset %d",
+					headerName,
+					offset)
+			}
+
+			end := bytes.Index(longNames[nameOffset:], []byte("/\n"))
+			if end < 0 {
+				return Archive{}, fmt.Errorf(
+					"invalid archive member name (%s) at offset %d",
+					headerName,
+					offset)
+			}
+
+			memberIndices[offset] = len(archive.Members)
+			archive.Members = append(
+				archive.Members,
+				ArchiveMember{
+					Name:    string(longNames[nameOffset : nameOffset+end]),
+					Content: memberContent,
+				})
+		default:
+			memberIndices[offset] = len(archive.Members)
+			archive.Members = append(
+				archive.Members,
+				ArchiveMember{
+					Name:    strings.TrimSuffix(headerName, "/"),
+					Content: memberContent,
+				})
+		}
+
+		offset = start + size + size%2
+	}
+
+	if symbolIndex == nil {
+		return archive, nil
+	}
+
+	err := archive.readSymbolIndex(symbolIndex, is64BitIndex, memberIndices)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	return archive, nil
+}
+
+func (archive *Archive) readSymbolIndex(
+	index []byte,
+	is64BitIndex bool,
+	memberIndices map[int]int,
+) error {
+	entrySize := 4
+	if is64BitIndex {
+		entrySize = 8
+	}
+
+	readEntry := func() (uint64, bool) {
+		if len(index) < entrySize {
+			return 0, false
+		}
+
+		var value uint64
+		if is64BitIndex {
+			value = binary.BigEndian.Uint64(index)
+		} else {
+			value = uint64(binary.BigEndian.Uint32(index))
+		}
+		index = index[entrySize:]
+		return value, true
+	}
+
+	numSymbols, ok := readEntry()
+	if !ok || numSymbols > uint64(len(index)/entrySize) {
+		return fmt.Errorf("invalid archive symbol index. bad symbol count")
+	}
+
+	offsets := make([]uint64, 0, numSymbols)
+	for i := uint64(0); i < numSymbols; i++ {
+		offset, _ := readEntry()
+		offsets = append(offsets, offset)
+	}
+
+	for _, offset := range offsets {
+		end := bytes.IndexByte(index, 0)
+		if end < 0 {
+			return fmt.Errorf("invalid archive symbol index. bad symbol name")
+		}
+
+		symbol := string(index[:end])
+		index = index[end+1:]
+
+		idx, ok := memberIndices[int(offset)]
+		if !ok {
+			return fmt.Errorf(
+				"invalid archive symbol index. symbol (%s) member offset (%d) "+
+					"not found",
+				symbol,
+				offset)
+		}
+
+		member := &archive.Members[idx]
+		member.Symbols = append(member.Symbols, symbol)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package executable_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+	"github.com/pattyshack/gt/testing/suite"
+
+	"github.com/pattyshack/chickadee/amd64"
+	"github.com/pattyshack/chickadee/platform/layout"
+	. "github.com/pattyshack/chickadee/platform/layout/executable"
+)
+
+type ArchiveSuite struct{}
+
+func TestArchive(t *testing.T) {
+	suite.RunTests(t, &ArchiveSuite{})
+}
+
+func (ArchiveSuite) TestWriteAndRead(t *testing.T) {
+	archive := Archive{
+		Name: "libtest.a",
+		Members: []ArchiveMember{
+			{
+				Name:    "short.o",
+				Symbols: []string{"foo", "bar"},
+				Content: []byte("odd"),
+			},
+			{
+				Name:    "a_very_long_member_name.o",
+				Symbols: []string{"baz"},
+				Content: []byte("even"),
+			},
+			{
+				Name:    "no_symbols.o",
+				Content: []byte("content"),
+			},
+		},
+	}
+
+	buffer := &bytes.Buffer{}
+	n, err := archive.WriteTo(buffer)
+	expect.Nil(t, err)
+
+	content := buffer.Bytes()
+	expect.Equal(t, int64(len(content)), n)
+	expect.Equal(t, ArchiveMagic, string(content[:8]))
+	expect.Equal(t, "/               ", string(content[8:24]))
+
+	// symbol index content: count + 3 offsets + "foo\0bar\0baz\0"
+	expect.Equal(t, "28        `\n", string(content[56:68]))
+	expect.Equal(t, []byte{0, 0, 0, 3}, content[68:72])
+
+	read, err := ReadArchive("libtest.a", content)
+	expect.Nil(t, err)
+	expect.Equal(t, archive, read)
+}
+
+func (ArchiveSuite) TestReadInvalidArchive(t *testing.T) {
+	_, err := ReadArchive("libtest.a", []byte("!<arch>\nfoo"))
+	expect.Error(t, err, "not enough bytes")
+
+	_, err = ReadArchive("libtest.a", []byte("not an archive"))
+	expect.Error(t, err, "bad magic")
+}
+
+func newArchiveMember(
+	t *testing.T,
+	name string,
+	symbol string,
+	reference string,
+) ArchiveMember {
+	builder := layout.NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte{0xe9, 0, 0, 0, 0}, // jmp <reference>
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    symbol,
+					Size:    5,
+				},
+			},
+		},
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   reference,
+					Offset: 1,
+				},
+			},
+		})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	member, err := NewArchiveMember(
+		amd64.Linux.ExecutableFormat,
+		amd64.Linux.Layout.Architecture.RegisterAlignment,
+		name,
+		file)
+	expect.Nil(t, err)
+	expect.Equal(t, []string{symbol}, member.Symbols)
+
+	return member
+}
+
+func (ArchiveSuite) TestLinkMembers(t *testing.T) {
+	archive := Archive{
+		Name: "libtest.a",
+		Members: []ArchiveMember{
+			newArchiveMember(t, "unused.o", "unused", "missing"),
+			newArchiveMember(t, "helper.o", "helper", "external"),
+			newArchiveMember(t, "a_very_long_member_name.o", "used", "helper"),
+		},
+	}
+
+	buffer := &bytes.Buffer{}
+	_, err := archive.WriteTo(buffer)
+	expect.Nil(t, err)
+
+	archive, err = ReadArchive("libtest.a", buffer.Bytes())
+	expect.Nil(t, err)
+
+	builder := layout.NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte{0xe8, 0, 0, 0, 0}, // call used
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "main",
+					Size:    5,
+				},
+			},
+		},
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "used",
+					Offset: 1,
+				},
+			},
+		})
+	expect.Equal(t, []string{"used"}, builder.UnresolvedSymbols())
+
+	err = archive.LinkMembers(
+		&builder,
+		NewElfRelocatableReader(
+			amd64.Linux.ExecutableFormat,
+			amd64.Linux.Layout))
+	expect.Nil(t, err)
+	expect.Equal(t, []string{"external"}, builder.UnresolvedSymbols())
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	names := []string{}
+	for _, symbol := range file.Text.Definitions.Symbols {
+		names = append(names, symbol.Name)
+	}
+	expect.Equal(t, []string{"main", "used", "helper"}, names)
+
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{
+				Name:   "external",
+				Offset: 5 + 16 + 1, // main + used (padded) + jmp opcode
+			},
+		},
+		file.Text.Relocations.Symbols)
+}
+
+func (ArchiveSuite) TestArchiveMemberSkipsLocalSymbols(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte{
+			0xc3, // ret
+			0xc3, // ret
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "helper",
+					Offset:  0,
+					Size:    1,
+					Binding: layout.LocalBinding,
+				},
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "used",
+					Offset:  1,
+					Size:    1,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	member, err := NewArchiveMember(
+		amd64.Linux.ExecutableFormat,
+		amd64.Linux.Layout.Architecture.RegisterAlignment,
+		"local.o",
+		file)
+	expect.Nil(t, err)
+
+	// The local helper cannot satisfy other object files' references.
+	expect.Equal(t, []string{"used"}, member.Symbols)
+}
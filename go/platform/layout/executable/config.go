@@ -6,4 +6,15 @@ type Config struct {
 
 	// Elf's EM_* machine architecture constant (defined in elf_header.go)
 	ElfMachineArchitecture uint16
+
+	// When true, the executable is written as a position independent
+	// executable (ET_DYN) with zero-based addresses (VirtualAddressStart is
+	// ignored), which is loaded at an arbitrary address by DynamicLinker.
+	PositionIndependent bool
+
+	// The dynamic linker / program interpreter's path (e.g.,
+	// /lib64/ld-linux-x86-64.so.2 on amd64 linux).  Only used by position
+	// independent executables and dynamically linked executables (i.e.,
+	// executables which import symbols from shared libraries).
+	DynamicLinker string
 }
@@ -0,0 +1,283 @@
+package executable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The dynamic linking metadata for position independent executables,
+// dynamically linked executables, and shared libraries.  The metadata is
+// loaded as a separate read-only segment, placed after the image's read-write
+// segment.
+//
+// The segment's section order:
+//
+//	.interp (executables only)
+//	.dynstr
+//	.hash
+//	.dynsym
+//	.rela.dyn (optional)
+//	.dynamic
+type ElfDynamicSegment struct {
+	// The segment's file offset and address.
+	Start   int64
+	Address uint64
+
+	// Empty for shared libraries.
+	Interpreter string
+
+	// The shared library's own name (DT_SONAME).  Only used by shared
+	// libraries.
+	SharedObjectName string
+
+	// The shared libraries' names (DT_NEEDED), in load order.
+	NeededLibraries []string
+
+	// NOTE: The first entry is the null symbol.
+	Symbols     []Elf64SymbolEntry
+	SymbolNames []string
+	StringTable ElfStringTable
+
+	Relocations []Elf64RelocationEntry
+
+	// Additional .dynamic entries (the needed / soname / table / size entries
+	// and the terminating DT_NULL entry are generated by finalize).
+	ExtraEntries []Elf64DynamicEntry
+	Entries      []Elf64DynamicEntry
+
+	// SysV symbol hash table (DT_HASH) used by the dynamic linker for looking
+	// up the executable's symbols (e.g., copy relocated objects).
+	HashTable []uint32
+
+	// Segment relative section offsets.
+	InterpreterOffset int64
+	StringTableOffset int64
+	SymbolTableOffset int64
+	RelocationsOffset int64
+	DynamicOffset     int64
+	HashTableOffset   int64
+
+	Size int64
+}
+
+func NewElfDynamicSegment(
+	interpreter string,
+	sharedObjectName string,
+) ElfDynamicSegment {
+	segment := ElfDynamicSegment{
+		Interpreter:      interpreter,
+		SharedObjectName: sharedObjectName,
+		Symbols:          []Elf64SymbolEntry{{}},
+		SymbolNames:      []string{""},
+		StringTable:      NewElfStringTable(),
+	}
+
+	if sharedObjectName != "" {
+		segment.StringTable.MaybeInsert(sharedObjectName)
+	}
+
+	return segment
+}
+
+func (segment ElfDynamicSegment) InterpreterSize() int64 {
+	if segment.Interpreter == "" {
+		return 0
+	}
+	return int64(len(segment.Interpreter) + 1)
+}
+
+func (segment *ElfDynamicSegment) AddNeededLibrary(library string) {
+	for _, needed := range segment.NeededLibraries {
+		if needed == library {
+			return
+		}
+	}
+
+	segment.StringTable.MaybeInsert(library)
+	segment.NeededLibraries = append(segment.NeededLibraries, library)
+}
+
+// Returns the symbol's .dynsym index.
+func (segment *ElfDynamicSegment) AddSymbol(
+	name string,
+	entry Elf64SymbolEntry,
+) uint32 {
+	entry.NameIndex, _ = segment.StringTable.MaybeInsert(name)
+
+	idx := uint32(len(segment.Symbols))
+	segment.Symbols = append(segment.Symbols, entry)
+	segment.SymbolNames = append(segment.SymbolNames, name)
+	return idx
+}
+
+// Reference: System V ABI, Chapter 5, Hash Table
+func elfHash(name string) uint32 {
+	hash := uint32(0)
+	for i := 0; i < len(name); i++ {
+		hash = (hash << 4) + uint32(name[i])
+		high := hash & 0xf0000000
+		if high != 0 {
+			hash ^= high >> 24
+		}
+		hash &^= high
+	}
+	return hash
+}
+
+// The hash table's layout: nbucket, nchain, bucket[nbucket], chain[nchain]
+func (segment *ElfDynamicSegment) computeHashTable() {
+	numBuckets := uint32(len(segment.Symbols))
+	numChains := uint32(len(segment.Symbols))
+
+	table := make([]uint32, 2+numBuckets+numChains)
+	table[0] = numBuckets
+	table[1] = numChains
+
+	buckets := table[2 : 2+numBuckets]
+	chains := table[2+numBuckets:]
+	for idx := uint32(1); idx < numChains; idx++ {
+		bucket := elfHash(segment.SymbolNames[idx]) % numBuckets
+		chains[idx] = buckets[bucket]
+		buckets[bucket] = idx
+	}
+
+	segment.HashTable = table
+}
+
+// Assign section offsets and generate the hash table and .dynamic entries.
+func (segment *ElfDynamicSegment) finalize(start int64, address uint64) {
+	segment.Start = start
+	segment.Address = address
+
+	segment.computeHashTable()
+
+	offset := int64(0)
+	segment.InterpreterOffset = offset
+	offset += segment.InterpreterSize()
+
+	segment.StringTableOffset = offset
+	offset += int64(segment.StringTable.Size)
+
+	// NOTE: the hash table's size is a multiple of 8.
+	offset = ((offset + 7) / 8) * 8
+	segment.HashTableOffset = offset
+	offset += int64(len(segment.HashTable)) * 4
+
+	segment.SymbolTableOffset = offset
+	offset += int64(len(segment.Symbols)) * Elf64SymbolEntrySize
+
+	segment.RelocationsOffset = offset
+	relocationsSize := int64(
+		len(segment.Relocations)) * Elf64RelocationEntrySize
+	offset += relocationsSize
+
+	entries := []Elf64DynamicEntry{}
+	for _, library := range segment.NeededLibraries {
+		entries = append(
+			entries,
+			Elf64DynamicEntry{
+				Tag:   DT_NEEDED,
+				Value: uint64(segment.StringTable.Indices[library]),
+			})
+	}
+
+	if segment.SharedObjectName != "" {
+		nameIdx := segment.StringTable.Indices[segment.SharedObjectName]
+		entries = append(
+			entries,
+			Elf64DynamicEntry{Tag: DT_SONAME, Value: uint64(nameIdx)})
+	}
+
+	entries = append(
+		entries,
+		Elf64DynamicEntry{
+			Tag:   DT_HASH,
+			Value: address + uint64(segment.HashTableOffset),
+		},
+		Elf64DynamicEntry{
+			Tag:   DT_STRTAB,
+			Value: address + uint64(segment.StringTableOffset),
+		},
+		Elf64DynamicEntry{Tag: DT_STRSZ, Value: uint64(segment.StringTable.Size)},
+		Elf64DynamicEntry{
+			Tag:   DT_SYMTAB,
+			Value: address + uint64(segment.SymbolTableOffset),
+		},
+		Elf64DynamicEntry{Tag: DT_SYMENT, Value: Elf64SymbolEntrySize})
+
+	if relocationsSize > 0 {
+		entries = append(
+			entries,
+			Elf64DynamicEntry{
+				Tag:   DT_RELA,
+				Value: address + uint64(segment.RelocationsOffset),
+			},
+			Elf64DynamicEntry{Tag: DT_RELASZ, Value: uint64(relocationsSize)},
+			Elf64DynamicEntry{Tag: DT_RELAENT, Value: Elf64RelocationEntrySize})
+	}
+
+	entries = append(entries, segment.ExtraEntries...)
+	entries = append(entries, Elf64DynamicEntry{Tag: DT_NULL})
+	segment.Entries = entries
+
+	segment.DynamicOffset = offset
+	offset += int64(len(entries)) * Elf64DynamicEntrySize
+
+	segment.Size = offset
+}
+
+func (segment ElfDynamicSegment) WriteTo(writer io.Writer) (int64, error) {
+	buffer := &bytes.Buffer{}
+	buffer.Grow(int(segment.Size))
+
+	if segment.Interpreter != "" {
+		buffer.WriteString(segment.Interpreter)
+		buffer.WriteByte(0)
+	}
+
+	_, err := segment.StringTable.WriteTo(buffer)
+	if err != nil {
+		panic("should never happen")
+	}
+
+	buffer.Write(make([]byte, segment.HashTableOffset-int64(buffer.Len())))
+
+	err = binary.Write(buffer, binary.LittleEndian, segment.HashTable)
+	if err != nil {
+		panic("should never happen")
+	}
+
+	for _, symbol := range segment.Symbols {
+		err = binary.Write(buffer, binary.LittleEndian, symbol)
+		if err != nil {
+			panic("should never happen")
+		}
+	}
+
+	for _, relocation := range segment.Relocations {
+		err = binary.Write(buffer, binary.LittleEndian, relocation)
+		if err != nil {
+			panic("should never happen")
+		}
+	}
+
+	for _, entry := range segment.Entries {
+		err = binary.Write(buffer, binary.LittleEndian, entry)
+		if err != nil {
+			panic("should never happen")
+		}
+	}
+
+	if int64(buffer.Len()) != segment.Size {
+		panic("should never happen")
+	}
+
+	n, err := buffer.WriteTo(writer)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write dynamic segment: %w", err)
+	}
+
+	return n, nil
+}
@@ -7,36 +7,96 @@ const (
 	Elf64SectionHeaderEntrySize = 64
 	Elf64ProgramHeaderEntrySize = 56
 	Elf64SymbolEntrySize        = 24
+	Elf64RelocationEntrySize    = 24
+	Elf64DynamicEntrySize       = 16
 
+	ELFCLASS64  = 2
 	ELFDATA2LSB = 1 // aka little endian
 
 	EM_X86_64 = 62 // aka amd64
 
+	ET_REL  = 1 // relocatable object file
 	ET_EXEC = 2
 	ET_DYN  = 3 // shared library or position independent executable
 
 	PT_LOAD      = 1
+	PT_DYNAMIC   = 2
+	PT_INTERP    = 3
 	PT_PHDR      = 6
 	PT_GNU_STACK = 0x6474e551
+	PT_GNU_RELRO = 0x6474e552
 
 	SHT_PROGBITS = 1
 	SHT_SYMTAB   = 2
 	SHT_STRTAB   = 3
+	SHT_RELA     = 4
+	SHT_HASH     = 5
+	SHT_DYNAMIC  = 6
+	SHT_NOTE     = 7
 	SHT_NOBITS   = 8
+	SHT_REL      = 9
+	SHT_DYNSYM   = 11
+
+	SHT_X86_64_UNWIND = 0x70000001 // .eh_frame on amd64
 
 	SHF_WRITE     = 0x1
 	SHF_ALLOC     = 0x2
 	SHF_EXECINSTR = 0x4
+	SHF_INFO_LINK = 0x40
 
-	STB_GLOBAL = byte(1)
-
-	STT_OBJECT = byte(1)
-	STT_FUNC   = byte(2)
+	SHN_UNDEF  = uint16(0)
+	SHN_ABS    = uint16(0xfff1)
+	SHN_COMMON = uint16(0xfff2)
+	SHN_XINDEX = uint16(0xffff)
 
-	functionSymbolInfo = (STB_GLOBAL << 4) | STT_FUNC
-	objectSymbolInfo   = (STB_GLOBAL << 4) | STT_OBJECT
-
-	STV_DEFAULT = byte(0)
+	STB_LOCAL  = byte(0)
+	STB_GLOBAL = byte(1)
+	STB_WEAK   = byte(2)
+
+	STT_NOTYPE  = byte(0)
+	STT_OBJECT  = byte(1)
+	STT_FUNC    = byte(2)
+	STT_SECTION = byte(3)
+	STT_FILE    = byte(4)
+
+	functionSymbolInfo  = (STB_GLOBAL << 4) | STT_FUNC
+	objectSymbolInfo    = (STB_GLOBAL << 4) | STT_OBJECT
+	undefinedSymbolInfo = (STB_GLOBAL << 4) | STT_NOTYPE
+
+	weakUndefinedSymbolInfo = (STB_WEAK << 4) | STT_NOTYPE
+
+	STV_DEFAULT   = byte(0)
+	STV_INTERNAL  = byte(1)
+	STV_HIDDEN    = byte(2)
+	STV_PROTECTED = byte(3)
+
+	// x86-64 psABI relocation types
+	R_X86_64_NONE     = uint32(0)
+	R_X86_64_64       = uint32(1)
+	R_X86_64_PC32     = uint32(2)
+	R_X86_64_PLT32    = uint32(4)
+	R_X86_64_COPY     = uint32(5)
+	R_X86_64_GLOB_DAT = uint32(6)
+	R_X86_64_RELATIVE = uint32(8)
+	R_X86_64_32       = uint32(10)
+
+	// .dynamic entry tags
+	DT_NULL         = int64(0)
+	DT_NEEDED       = int64(1)
+	DT_HASH         = int64(4)
+	DT_STRTAB       = int64(5)
+	DT_SYMTAB       = int64(6)
+	DT_RELA         = int64(7)
+	DT_RELASZ       = int64(8)
+	DT_RELAENT      = int64(9)
+	DT_STRSZ        = int64(10)
+	DT_SYMENT       = int64(11)
+	DT_SONAME       = int64(14)
+	DT_INIT_ARRAY   = int64(25)
+	DT_INIT_ARRAYSZ = int64(27)
+	DT_FLAGS_1      = int64(0x6ffffffb)
+
+	DF_1_PIE = uint64(0x08000000)
 )
 
 // Header structs matching c's elf64 header definitions.  These are only used
@@ -106,3 +166,16 @@ type Elf64SymbolEntry struct {
 	Value        uint64 // st_value
 	Size         uint64 // st_size
 }
+
+// Elf64_Rela
+type Elf64RelocationEntry struct {
+	Offset uint64 // r_offset
+	Info   uint64 // r_info (32 bits symbol index, 32 bits relocation type)
+	Addend int64  // r_addend
+}
+
+// Elf64_Dyn
+type Elf64DynamicEntry struct {
+	Tag   int64  // d_tag
+	Value uint64 // d_un (d_val or d_ptr)
+}
@@ -0,0 +1,734 @@
+package executable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// Relocatable object file (ET_REL) reader.  This is the reverse of
+// ElfRelocatableWriter: the object file's .text / .init / .rodata / .data /
+// .bss sections, symbol table and .rela.* sections are loaded into a
+// layout.ObjectFile, which can be merged into a layout.ObjectFileBuilder.
+//
+// Sections with the same prefix (e.g., .text and .text.startup) are
+// concatenated into the same segment.  Note sections and unwind tables
+// (.eh_frame) are dropped, as are non-allocated sections (e.g., debug info).
+//
+// Local symbols (including section symbols) are qualified by the object
+// file's name (i.e., "<name>:<symbol>") to avoid collisions with other object
+// files' local symbols.
+//
+// NOTE: ObjectFileBuilder.Merge does not realign merged segments.  The read
+// segments' sizes are padded to the layout's register alignment, and sections
+// with larger alignment requirements are rejected.
+type ElfRelocatableReader struct {
+	Config
+
+	Layout layout.Config
+}
+
+func NewElfRelocatableReader(
+	config Config,
+	layoutConfig layout.Config,
+) ElfRelocatableReader {
+	return ElfRelocatableReader{
+		Config: config,
+		Layout: layoutConfig,
+	}
+}
+
+type elfReadSection struct {
+	name   string
+	header Elf64SectionHeaderEntry
+
+	layout.Section
+
+	// Only set for loaded sections.  content is a copy of the file's content
+	// (the copy is relocated in place by the linker).
+	content []byte
+	defs    layout.Definitions
+	relocs  layout.Relocations
+}
+
+type elfReadSymbol struct {
+	name  string
+	entry Elf64SymbolEntry
+
+	// Only set for symbols defined in loaded sections.
+	*elfReadSection
+	definition *layout.Symbol
+
+	// Section symbols are only defined when referenced by relocations.
+	isReferenced bool
+}
+
+type elfObjectFileParser struct {
+	ElfRelocatableReader
+
+	fileName string
+	content  []byte
+
+	header   Elf64Header
+	sections []*elfReadSection
+	symbols  []*elfReadSymbol
+}
+
+func (reader ElfRelocatableReader) Read(
+	name string,
+	content []byte,
+) (
+	layout.ObjectFile,
+	error,
+) {
+	parser := &elfObjectFileParser{
+		ElfRelocatableReader: reader,
+		fileName:             name,
+		content:              content,
+	}
+
+	file, err := parser.parse()
+	if err != nil {
+		return layout.ObjectFile{}, fmt.Errorf(
+			"failed to read elf object file (%s): %w",
+			name,
+			err)
+	}
+
+	return file, nil
+}
+
+func (parser *elfObjectFileParser) parse() (layout.ObjectFile, error) {
+	err := parser.parseHeader()
+	if err != nil {
+		return layout.ObjectFile{}, err
+	}
+
+	err = parser.parseSectionHeader()
+	if err != nil {
+		return layout.ObjectFile{}, err
+	}
+
+	err = parser.parseSymbols()
+	if err != nil {
+		return layout.ObjectFile{}, err
+	}
+
+	err = parser.parseRelocations()
+	if err != nil {
+		return layout.ObjectFile{}, err
+	}
+
+	for _, symbol := range parser.symbols {
+		if symbol.definition != nil &&
+			(symbol.entry.Info&0xf != STT_SECTION || symbol.isReferenced) {
+
+			symbol.defs.Symbols = append(symbol.defs.Symbols, symbol.definition)
+		}
+	}
+
+	return parser.build()
+}
+
+func (parser *elfObjectFileParser) parseHeader() error {
+	if len(parser.content) < Elf64HeaderSize {
+		return fmt.Errorf("invalid elf header. not enough bytes")
+	}
+
+	n, err := binary.Decode(
+		parser.content[:Elf64HeaderSize],
+		binary.LittleEndian,
+		&parser.header)
+	if err != nil || n != Elf64HeaderSize {
+		panic("should never happen")
+	}
+
+	header := parser.header
+	if header.Magic != [4]byte{0x7f, 'E', 'L', 'F'} {
+		return fmt.Errorf("invalid elf header. bad magic")
+	}
+
+	if header.Class != ELFCLASS64 {
+		return fmt.Errorf("unsupported elf class (%d)", header.Class)
+	}
+
+	if header.DataEncoding != ELFDATA2LSB {
+		return fmt.Errorf(
+			"unsupported elf data encoding (%d)",
+			header.DataEncoding)
+	}
+
+	if header.FileType != ET_REL {
+		return fmt.Errorf("unsupported elf file type (%d)", header.FileType)
+	}
+
+	if header.MachineArchitecture != parser.ElfMachineArchitecture {
+		return fmt.Errorf(
+			"unsupported elf machine architecture (%d)",
+			header.MachineArchitecture)
+	}
+
+	if header.SectionHeaderEntrySize != Elf64SectionHeaderEntrySize {
+		return fmt.Errorf(
+			"invalid elf header. unexpected section header entry size (%d)",
+			header.SectionHeaderEntrySize)
+	}
+
+	// NOTE: extended section numbering stores the number of section header
+	// entries in the first section header entry.
+	if header.NumSectionHeaderEntries == 0 && header.SectionHeaderOffset != 0 {
+		return fmt.Errorf("unsupported extended section numbering")
+	}
+
+	return nil
+}
+
+func (parser *elfObjectFileParser) parseSectionHeader() error {
+	header := parser.header
+
+	start := header.SectionHeaderOffset
+	size := uint64(header.NumSectionHeaderEntries) * Elf64SectionHeaderEntrySize
+	if start > uint64(len(parser.content)) ||
+		size > uint64(len(parser.content))-start {
+
+		return fmt.Errorf("invalid section header. out of range")
+	}
+
+	entries := make([]Elf64SectionHeaderEntry, header.NumSectionHeaderEntries)
+	n, err := binary.Decode(
+		parser.content[start:start+size],
+		binary.LittleEndian,
+		entries)
+	if err != nil || uint64(n) != size {
+		panic("should never happen")
+	}
+
+	parser.sections = make([]*elfReadSection, 0, len(entries))
+	for _, entry := range entries {
+		parser.sections = append(
+			parser.sections,
+			&elfReadSection{
+				header: entry,
+			})
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	names, err := parser.sectionContent(header.SectionStringTableIndex)
+	if err != nil {
+		return err
+	}
+
+	for idx, section := range parser.sections {
+		if idx == 0 {
+			continue
+		}
+
+		section.name, err = cString(names, section.header.NameIndex)
+		if err != nil {
+			return fmt.Errorf("invalid section (%d) name: %w", idx, err)
+		}
+
+		err = parser.classifySection(section)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (parser *elfObjectFileParser) classifySection(
+	section *elfReadSection,
+) error {
+	header := section.header
+	if header.Flags&SHF_ALLOC == 0 ||
+		header.Type == SHT_NOTE ||
+		header.Type == SHT_X86_64_UNWIND ||
+		section.name == ".eh_frame" {
+
+		return nil
+	}
+
+	for _, candidate := range []layout.Section{
+		layout.TextSection,
+		layout.InitSection,
+		layout.ReadOnlyDataSection,
+		layout.ReadWriteDataSection,
+		layout.BSSSection,
+	} {
+		prefix := string(candidate)
+		if section.name == prefix || strings.HasPrefix(section.name, prefix+".") {
+			section.Section = candidate
+			break
+		}
+	}
+
+	expectedType := uint32(SHT_PROGBITS)
+	if section.Section == layout.BSSSection {
+		expectedType = SHT_NOBITS
+	}
+
+	if section.Section == layout.UnknownSection ||
+		header.Type != expectedType {
+
+		return fmt.Errorf(
+			"unsupported section (%s). type: %d flags: %#x",
+			section.name,
+			header.Type,
+			header.Flags)
+	}
+
+	maxAlignment := parser.Layout.Architecture.RegisterAlignment
+	if header.AddressAlignment > uint64(maxAlignment) {
+		return fmt.Errorf(
+			"unsupported section (%s). alignment (%d) larger than "+
+				"register alignment (%d)",
+			section.name,
+			header.AddressAlignment,
+			maxAlignment)
+	}
+
+	if header.Type == SHT_NOBITS {
+		return nil
+	}
+
+	content, err := parser.sectionBytes(section)
+	if err != nil {
+		return err
+	}
+
+	section.content = bytes.Clone(content)
+	return nil
+}
+
+func (parser *elfObjectFileParser) sectionContent(idx uint16) ([]byte, error) {
+	if int(idx) >= len(parser.sections) {
+		return nil, fmt.Errorf("invalid section index (%d)", idx)
+	}
+
+	return parser.sectionBytes(parser.sections[idx])
+}
+
+func (parser *elfObjectFileParser) sectionBytes(
+	section *elfReadSection,
+) (
+	[]byte,
+	error,
+) {
+	header := section.header
+	if header.Type == SHT_NOBITS {
+		return nil, nil
+	}
+
+	if header.Offset > uint64(len(parser.content)) ||
+		header.Size > uint64(len(parser.content))-header.Offset {
+
+		return nil, fmt.Errorf(
+			"invalid section (%s). content out of range",
+			section.name)
+	}
+
+	return parser.content[header.Offset : header.Offset+header.Size], nil
+}
+
+func cString(table []byte, idx uint32) (string, error) {
+	if uint64(idx) >= uint64(len(table)) {
+		return "", fmt.Errorf("string table index (%d) out of range", idx)
+	}
+
+	end := bytes.IndexByte(table[idx:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at index (%d)", idx)
+	}
+
+	return string(table[idx : int(idx)+end]), nil
+}
+
+func (parser *elfObjectFileParser) parseSymbols() error {
+	var symbolTable *elfReadSection
+	for _, section := range parser.sections {
+		if section.header.Type != SHT_SYMTAB {
+			continue
+		}
+
+		if symbolTable != nil {
+			return fmt.Errorf("unexpected multiple symbol tables")
+		}
+		symbolTable = section
+	}
+
+	if symbolTable == nil {
+		return nil
+	}
+
+	content, err := parser.sectionBytes(symbolTable)
+	if err != nil {
+		return err
+	}
+
+	if symbolTable.header.EntrySize != Elf64SymbolEntrySize ||
+		len(content)%Elf64SymbolEntrySize != 0 {
+
+		return fmt.Errorf("invalid symbol table (%s)", symbolTable.name)
+	}
+
+	names, err := parser.sectionContent(uint16(symbolTable.header.Link))
+	if err != nil {
+		return err
+	}
+
+	entries := make([]Elf64SymbolEntry, len(content)/Elf64SymbolEntrySize)
+	n, err := binary.Decode(content, binary.LittleEndian, entries)
+	if err != nil || n != len(content) {
+		panic("should never happen")
+	}
+
+	parser.symbols = make([]*elfReadSymbol, 0, len(entries))
+	for idx, entry := range entries {
+		symbol := &elfReadSymbol{
+			entry: entry,
+		}
+		parser.symbols = append(parser.symbols, symbol)
+
+		if idx == 0 {
+			continue
+		}
+
+		symbol.name, err = cString(names, entry.NameIndex)
+		if err != nil {
+			return fmt.Errorf("invalid symbol (%d) name: %w", idx, err)
+		}
+
+		err = parser.defineSymbol(symbol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (parser *elfObjectFileParser) defineSymbol(symbol *elfReadSymbol) error {
+	binding := symbol.entry.Info >> 4
+	symbolType := symbol.entry.Info & 0xf
+
+	switch binding {
+	case STB_LOCAL:
+	case STB_GLOBAL, STB_WEAK:
+		if symbolType == STT_SECTION {
+			return fmt.Errorf("invalid non-local section symbol (%s)", symbol.name)
+		}
+	default:
+		return fmt.Errorf(
+			"unsupported symbol (%s) binding (%d)",
+			symbol.name,
+			binding)
+	}
+
+	switch symbolType {
+	case STT_NOTYPE, STT_OBJECT, STT_FUNC, STT_SECTION:
+	case STT_FILE:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unsupported symbol (%s) type (%d)",
+			symbol.name,
+			symbolType)
+	}
+
+	switch symbol.entry.SectionIndex {
+	case SHN_UNDEF:
+		if binding == STB_LOCAL {
+			return fmt.Errorf("invalid undefined local symbol (%s)", symbol.name)
+		}
+		return nil
+	case SHN_COMMON:
+		return fmt.Errorf("unsupported common symbol (%s)", symbol.name)
+	case SHN_ABS, SHN_XINDEX:
+		// NOTE: relocations referencing these symbols are rejected since the
+		// symbols are not defined.
+		return nil
+	}
+
+	if int(symbol.entry.SectionIndex) >= len(parser.sections) {
+		return fmt.Errorf(
+			"invalid symbol (%s) section index (%d)",
+			symbol.name,
+			symbol.entry.SectionIndex)
+	}
+
+	section := parser.sections[symbol.entry.SectionIndex]
+	if section.Section == layout.UnknownSection {
+		// The symbol is defined in a dropped section.
+		return nil
+	}
+
+	if symbolType == STT_SECTION {
+		symbol.name = section.name
+	}
+
+	if binding == STB_LOCAL {
+		symbol.name = parser.fileName + ":" + symbol.name
+	}
+
+	kind := layout.ObjectKind
+	switch symbolType {
+	case STT_FUNC:
+		kind = layout.FunctionKind
+	case STT_NOTYPE, STT_SECTION:
+		if section.Section == layout.TextSection ||
+			section.Section == layout.InitSection {
+
+			kind = layout.FunctionKind
+		}
+	}
+
+	size := symbol.entry.Size
+	if symbolType == STT_SECTION {
+		size = section.header.Size
+	}
+
+	if symbol.entry.Value > section.header.Size ||
+		size > section.header.Size-symbol.entry.Value {
+
+		return fmt.Errorf(
+			"invalid symbol (%s). value out of section (%s) range",
+			symbol.name,
+			section.name)
+	}
+
+	symbolBinding := layout.GlobalBinding
+	switch binding {
+	case STB_LOCAL:
+		symbolBinding = layout.LocalBinding
+	case STB_WEAK:
+		symbolBinding = layout.WeakBinding
+	}
+
+	// NOTE: protected symbols are treated as default visibility symbols since
+	// references within the image are always resolved at link time.
+	visibility := layout.DefaultVisibility
+	switch symbol.entry.Visibility & 0x3 {
+	case STV_INTERNAL, STV_HIDDEN:
+		visibility = layout.HiddenVisibility
+	}
+
+	symbol.elfReadSection = section
+	symbol.definition = &layout.Symbol{
+		Kind:       kind,
+		Section:    section.Section,
+		Name:       symbol.name,
+		Offset:     int64(symbol.entry.Value),
+		Size:       int64(size),
+		Binding:    symbolBinding,
+		Visibility: visibility,
+	}
+
+	return nil
+}
+
+func (parser *elfObjectFileParser) parseRelocations() error {
+	for _, section := range parser.sections {
+		if section.header.Type != SHT_RELA && section.header.Type != SHT_REL {
+			continue
+		}
+
+		idx := section.header.Info
+		if int(idx) >= len(parser.sections) {
+			return fmt.Errorf(
+				"invalid relocation section (%s). target section index (%d) "+
+					"out of range",
+				section.name,
+				idx)
+		}
+
+		target := parser.sections[idx]
+		if target.Section == layout.UnknownSection {
+			// Relocations for dropped sections.
+			continue
+		}
+
+		if section.header.Type == SHT_REL {
+			return fmt.Errorf(
+				"unsupported relocation section (%s). implicit addends",
+				section.name)
+		}
+
+		content, err := parser.sectionBytes(section)
+		if err != nil {
+			return err
+		}
+
+		if section.header.EntrySize != Elf64RelocationEntrySize ||
+			len(content)%Elf64RelocationEntrySize != 0 {
+
+			return fmt.Errorf("invalid relocation section (%s)", section.name)
+		}
+
+		entries := make(
+			[]Elf64RelocationEntry,
+			len(content)/Elf64RelocationEntrySize)
+		n, err := binary.Decode(content, binary.LittleEndian, entries)
+		if err != nil || n != len(content) {
+			panic("should never happen")
+		}
+
+		for _, entry := range entries {
+			err := parser.convertRelocation(target, entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (parser *elfObjectFileParser) convertRelocation(
+	section *elfReadSection,
+	entry Elf64RelocationEntry,
+) error {
+	relocationType := uint32(entry.Info)
+	if relocationType == R_X86_64_NONE {
+		return nil
+	}
+
+	symbolIdx := entry.Info >> 32
+	if symbolIdx == 0 || symbolIdx >= uint64(len(parser.symbols)) {
+		return fmt.Errorf(
+			"invalid relocation in %s at offset %#x. bad symbol index (%d)",
+			section.name,
+			entry.Offset,
+			symbolIdx)
+	}
+
+	symbol := parser.symbols[symbolIdx]
+	if symbol.definition == nil &&
+		(symbol.entry.SectionIndex != SHN_UNDEF ||
+			symbol.entry.Info>>4 == STB_LOCAL) {
+
+		return fmt.Errorf(
+			"unsupported relocation in %s at offset %#x. symbol (%s) not "+
+				"defined in a supported section",
+			section.name,
+			entry.Offset,
+			symbol.name)
+	}
+	symbol.isReferenced = true
+
+	relocation := &layout.Relocation{
+		Name:   symbol.name,
+		Offset: int64(entry.Offset),
+		Addend: entry.Addend,
+		IsWeak: symbol.definition == nil && symbol.entry.Info>>4 == STB_WEAK,
+	}
+
+	var size uint64
+	switch {
+	case parser.ElfMachineArchitecture == EM_X86_64 &&
+		(relocationType == R_X86_64_PC32 || relocationType == R_X86_64_PLT32):
+
+		// NOTE: rel32 displacement is relative to the next instruction, i.e.,
+		// the end of the displacement, whereas elf's addend is relative to the
+		// start of the displacement.
+		relocation.Addend += 4
+		size = 4
+	case parser.ElfMachineArchitecture == EM_X86_64 &&
+		relocationType == R_X86_64_64:
+
+		relocation.Kind = layout.AbsoluteRelocation
+		size = 8
+	case parser.ElfMachineArchitecture == EM_X86_64 &&
+		relocationType == R_X86_64_32:
+
+		relocation.Kind = layout.Absolute32Relocation
+		size = 4
+	default:
+		return fmt.Errorf(
+			"unsupported relocation type (%d) in %s at offset %#x",
+			relocationType,
+			section.name,
+			entry.Offset)
+	}
+
+	if entry.Offset > uint64(len(section.content)) ||
+		size > uint64(len(section.content))-entry.Offset {
+
+		return fmt.Errorf(
+			"invalid relocation in %s at offset %#x. out of section range",
+			section.name,
+			entry.Offset)
+	}
+
+	section.relocs.Symbols = append(section.relocs.Symbols, relocation)
+	return nil
+}
+
+func (parser *elfObjectFileParser) build() (layout.ObjectFile, error) {
+	builder := layout.NewObjectFileBuilder()
+
+	alignment := parser.Layout.Architecture.RegisterAlignment
+	for _, section := range parser.sections {
+		var segment *layout.SegmentBuilder
+		padding := parser.Layout.DataPadding
+		switch section.Section {
+		case layout.UnknownSection:
+			continue
+		case layout.TextSection:
+			segment = &builder.Text
+			padding = parser.Layout.InstructionPadding
+		case layout.InitSection:
+			segment = &builder.Init
+			padding = parser.Layout.InstructionPadding
+		case layout.ReadOnlyDataSection:
+			segment = &builder.ReadOnlyData
+		case layout.ReadWriteDataSection:
+			segment = &builder.Data
+		case layout.BSSSection:
+			if section.header.AddressAlignment > 1 {
+				builder.BSS.Pad(int64(section.header.AddressAlignment))
+			}
+			builder.BSS.Append(
+				layout.BSSSegment{
+					Size:        int64(section.header.Size),
+					Definitions: section.defs,
+				})
+			continue
+		default:
+			panic("should never happen")
+		}
+
+		if section.header.AddressAlignment > 1 {
+			err := segment.Pad(int64(section.header.AddressAlignment), padding)
+			if err != nil {
+				return layout.ObjectFile{}, err
+			}
+		}
+
+		segment.AppendData(section.content, section.defs, section.relocs)
+	}
+
+	for _, entry := range []struct {
+		*layout.SegmentBuilder
+		padding []byte
+	}{
+		{&builder.Text, parser.Layout.InstructionPadding},
+		{&builder.Init, parser.Layout.InstructionPadding},
+		{&builder.ReadOnlyData, parser.Layout.DataPadding},
+		{&builder.Data, parser.Layout.DataPadding},
+	} {
+		err := entry.Pad(alignment, entry.padding)
+		if err != nil {
+			return layout.ObjectFile{}, err
+		}
+	}
+	builder.BSS.Pad(alignment)
+
+	return builder.Finalize(parser.Layout)
+}
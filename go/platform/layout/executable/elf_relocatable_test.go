@@ -0,0 +1,714 @@
+package executable_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	"github.com/pattyshack/chickadee/amd64"
+	"github.com/pattyshack/chickadee/platform/layout"
+	. "github.com/pattyshack/chickadee/platform/layout/executable"
+)
+
+// This is approximately:
+//
+// .section .text
+//
+// add:
+//
+//	movq rovalue(%rip), %rax
+//	addq value(%rip), %rax
+//	addq %rdi, %rax
+//	ret
+//
+// tail_call:
+//
+//	addq $100, counter(%rip)
+//	jmp external
+//
+// .section .rodata
+//
+// rovalue: .quad 31
+//
+// .section .data
+//
+// table: .quad add, tail_call
+//
+// .section .bss
+//
+// counter: .quad 0
+func (ElfSuite) newRelocatableWriter(t *testing.T) ElfRelocatableWriter {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{
+			0x48, 0x8b, 0x05, 0, 0, 0, 0, // movq rovalue(%rip), %rax - (relocation)
+			0x48, 0x03, 0x05, 0, 0, 0, 0, // addq value(%rip), %rax - (relocation)
+			0x48, 0x01, 0xf8, // addq %rdi, %rax
+			0xc3, // ret
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "add",
+					Offset:  0,
+					Size:    18,
+				},
+			},
+		},
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "rovalue",
+					Offset: 3,
+				},
+				{
+					Name:   "value",
+					Offset: 10,
+				},
+			},
+		})
+
+	builder.Text.AppendData(
+		[]byte{
+			// addq $100, counter(%rip) - (relocation, adjusted by the imm32)
+			0x48, 0x81, 0x05, 0, 0, 0, 0, 0x64, 0, 0, 0,
+			0xe9, 0, 0, 0, 0, // jmp external - (relocation)
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "tail_call",
+					Offset:  0,
+					Size:    16,
+				},
+			},
+		},
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "counter",
+					Offset: 3,
+					Addend: -4,
+				},
+				{
+					Name:   "external",
+					Offset: 12,
+				},
+			},
+		})
+
+	builder.ReadOnlyData.AppendObject(
+		layout.ReadOnlyDataSection,
+		"rovalue",
+		[]byte{31, 0, 0, 0, 0, 0, 0, 0},
+		nil)
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"value",
+		[]byte{11, 0, 0, 0, 0, 0, 0, 0},
+		nil)
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"table",
+		[]byte{
+			0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "add",
+				Offset: 0,
+			},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "tail_call",
+				Offset: 8,
+				Addend: 1,
+			},
+		})
+
+	builder.BSS.AppendObject("counter", 8)
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	writer, err := NewElfRelocatableWriter(
+		amd64.Linux.ExecutableFormat,
+		amd64.Linux.Layout.Architecture.RegisterAlignment,
+		file)
+	expect.Nil(t, err)
+
+	return writer
+}
+
+func (s ElfSuite) TestRelocatableWriterInitialization(t *testing.T) {
+	writer := s.newRelocatableWriter(t)
+
+	expect.Equal(t, ET_REL, writer.Header.FileType)
+	expect.Equal(t, 0, writer.Header.NumProgramHeaderEntries)
+
+	expect.Equal(t, 4, writer.TextIndex)
+	expect.Equal(t, 0, writer.InitIndex)
+	expect.Equal(t, 5, writer.ReadOnlyDataIndex)
+	expect.Equal(t, 6, writer.DataIndex)
+	expect.Equal(t, 7, writer.BSSIndex)
+
+	expect.Equal(
+		t,
+		[]string{
+			".text",
+			".rodata",
+			".data",
+			".bss",
+			".note.GNU-stack",
+			".rela.text",
+			".rela.data",
+			".symtab",
+			".strtab",
+			".shstrtab",
+		},
+		writer.SectionStringTable.Entries)
+
+	expect.Equal(
+		t,
+		[]string{
+			"add",
+			"tail_call",
+			"rovalue",
+			"value",
+			"table",
+			"counter",
+			"external",
+		},
+		writer.StringTable.Entries)
+
+	expect.Equal(t, 11, writer.Header.NumSectionHeaderEntries)
+	expect.Equal(t, 11, len(writer.SectionHeader))
+
+	prevEnd := uint64(Elf64HeaderSize)
+	for _, entry := range writer.SectionHeader[1:] {
+		expect.True(t, entry.Offset >= prevEnd)
+		expect.Equal(t, 0, entry.Address)
+		expect.Equal(t, 0, entry.Offset%entry.AddressAlignment)
+
+		if entry.Type != SHT_NOBITS {
+			prevEnd = entry.Offset + entry.Size
+		}
+	}
+	expect.True(t, uint64(writer.SectionHeaderStart) >= prevEnd)
+}
+
+func (s ElfSuite) TestRelocatableWrite(t *testing.T) {
+	writer := s.newRelocatableWriter(t)
+
+	buffer := &bytes.Buffer{}
+	numWritten, err := writer.WriteTo(buffer)
+	expect.Nil(t, err)
+
+	content := buffer.Bytes()
+	expect.Equal(t, int64(len(content)), numWritten)
+	expect.Equal(
+		t,
+		writer.SectionHeaderStart+11*Elf64SectionHeaderEntrySize,
+		numWritten)
+
+	file, err := elf.NewFile(bytes.NewReader(content))
+	expect.Nil(t, err)
+
+	expect.Equal(t, elf.ET_REL, file.Type)
+	expect.Equal(t, elf.EM_X86_64, file.Machine)
+
+	text := file.Section(".text")
+	expect.NotNil(t, text)
+	textContent, err := text.Data()
+	expect.Nil(t, err)
+	expect.Equal(t, 34, len(textContent))
+	expect.Equal(t, byte(0xe9), textContent[29])
+
+	bss := file.Section(".bss")
+	expect.NotNil(t, bss)
+	expect.Equal(t, elf.SHT_NOBITS, bss.Type)
+	expect.Equal(t, 8, bss.Size)
+
+	symbols, err := file.Symbols()
+	expect.Nil(t, err)
+
+	type symbol struct {
+		name    string
+		section string
+		value   uint64
+		size    uint64
+		kind    elf.SymType
+	}
+
+	actual := []symbol{}
+	for _, sym := range symbols {
+		sectionName := ""
+		if sym.Section != elf.SHN_UNDEF {
+			sectionName = file.Sections[sym.Section].Name
+		}
+		actual = append(
+			actual,
+			symbol{
+				name:    sym.Name,
+				section: sectionName,
+				value:   sym.Value,
+				size:    sym.Size,
+				kind:    elf.ST_TYPE(sym.Info),
+			})
+		expect.Equal(t, elf.STB_GLOBAL, elf.ST_BIND(sym.Info))
+	}
+
+	expect.Equal(
+		t,
+		[]symbol{
+			{"add", ".text", 0, 18, elf.STT_FUNC},
+			{"tail_call", ".text", 18, 16, elf.STT_FUNC},
+			{"rovalue", ".rodata", 0, 8, elf.STT_OBJECT},
+			{"value", ".data", 0, 8, elf.STT_OBJECT},
+			{"table", ".data", 8, 16, elf.STT_OBJECT},
+			{"counter", ".bss", 0, 8, elf.STT_OBJECT},
+			{"external", "", 0, 0, elf.STT_NOTYPE},
+		},
+		actual)
+
+	type relocation struct {
+		offset uint64
+		symbol string
+		kind   elf.R_X86_64
+		addend int64
+	}
+
+	readRelocations := func(name string, target *elf.Section) []relocation {
+		section := file.Section(name)
+		expect.NotNil(t, section)
+		expect.Equal(t, elf.SHT_RELA, section.Type)
+		expect.Equal(t, target, file.Sections[section.Info])
+
+		data, err := section.Data()
+		expect.Nil(t, err)
+
+		entries := make([]Elf64RelocationEntry, len(data)/24)
+		_, err = binary.Decode(data, binary.LittleEndian, entries)
+		expect.Nil(t, err)
+
+		result := []relocation{}
+		for _, entry := range entries {
+			result = append(
+				result,
+				relocation{
+					offset: entry.Offset,
+					symbol: symbols[elf.R_SYM64(entry.Info)-1].Name,
+					kind:   elf.R_X86_64(elf.R_TYPE64(entry.Info)),
+					addend: entry.Addend,
+				})
+		}
+		return result
+	}
+
+	expect.Equal(
+		t,
+		[]relocation{
+			{3, "rovalue", elf.R_X86_64_PC32, -4},
+			{10, "value", elf.R_X86_64_PC32, -4},
+			{21, "counter", elf.R_X86_64_PC32, -8},
+			{30, "external", elf.R_X86_64_PLT32, -4},
+		},
+		readRelocations(".rela.text", text))
+
+	expect.Equal(
+		t,
+		[]relocation{
+			{8, "add", elf.R_X86_64_64, 0},
+			{16, "tail_call", elf.R_X86_64_64, 1},
+		},
+		readRelocations(".rela.data", file.Section(".data")))
+}
+
+func (ElfSuite) TestRelocatableWriteLocalSymbols(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{
+			0xc3, // ret
+			0xc3, // ret
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:       layout.FunctionKind,
+					Section:    layout.TextSection,
+					Name:       "entry",
+					Offset:     0,
+					Size:       1,
+					Visibility: layout.HiddenVisibility,
+				},
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "helper",
+					Offset:  1,
+					Size:    1,
+					Binding: layout.LocalBinding,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"helperAddress",
+		[]byte{0, 0, 0, 0, 0, 0, 0, 0},
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "helper",
+				Offset: 0,
+			},
+		})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	writer, err := NewElfRelocatableWriter(
+		amd64.Linux.ExecutableFormat,
+		amd64.Linux.Layout.Architecture.RegisterAlignment,
+		file)
+	expect.Nil(t, err)
+
+	expect.Equal(t, 1, writer.NumLocalSymbols)
+
+	buffer := &bytes.Buffer{}
+	_, err = writer.WriteTo(buffer)
+	expect.Nil(t, err)
+
+	elfFile, err := elf.NewFile(bytes.NewReader(buffer.Bytes()))
+	expect.Nil(t, err)
+
+	// The null symbol and helper are local symbols.
+	expect.Equal(t, 2, elfFile.Section(".symtab").Info)
+
+	symbols, err := elfFile.Symbols()
+	expect.Nil(t, err)
+	expect.Equal(t, 3, len(symbols))
+
+	expect.Equal(t, "helper", symbols[0].Name)
+	expect.Equal(t, elf.STB_LOCAL, elf.ST_BIND(symbols[0].Info))
+	expect.Equal(t, elf.STV_DEFAULT, elf.ST_VISIBILITY(symbols[0].Other))
+
+	expect.Equal(t, "entry", symbols[1].Name)
+	expect.Equal(t, elf.STB_GLOBAL, elf.ST_BIND(symbols[1].Info))
+	expect.Equal(t, elf.STV_HIDDEN, elf.ST_VISIBILITY(symbols[1].Other))
+
+	expect.Equal(t, "helperAddress", symbols[2].Name)
+	expect.Equal(t, elf.STB_GLOBAL, elf.ST_BIND(symbols[2].Info))
+
+	rela := elfFile.Section(".rela.data")
+	expect.NotNil(t, rela)
+	data, err := rela.Data()
+	expect.Nil(t, err)
+
+	entries := make([]Elf64RelocationEntry, 1)
+	_, err = binary.Decode(data, binary.LittleEndian, entries)
+	expect.Nil(t, err)
+	expect.Equal(t, 1, elf.R_SYM64(entries[0].Info)) // helper
+}
+
+func (s ElfSuite) TestRelocatableWeakSymbols(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{0xc3}, // ret
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "handler",
+					Offset:  0,
+					Size:    1,
+					Binding: layout.WeakBinding,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"hooks",
+		make([]byte, 32),
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "handler",
+				Offset: 0,
+			},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "optional",
+				Offset: 8,
+				IsWeak: true,
+			},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "required",
+				Offset: 16,
+				IsWeak: true,
+			},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "required",
+				Offset: 24,
+			},
+		})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	writer, err := NewElfRelocatableWriter(
+		amd64.Linux.ExecutableFormat,
+		amd64.Linux.Layout.Architecture.RegisterAlignment,
+		file)
+	expect.Nil(t, err)
+
+	buffer := &bytes.Buffer{}
+	_, err = writer.WriteTo(buffer)
+	expect.Nil(t, err)
+
+	elfFile, err := elf.NewFile(bytes.NewReader(buffer.Bytes()))
+	expect.Nil(t, err)
+
+	symbols, err := elfFile.Symbols()
+	expect.Nil(t, err)
+
+	bindings := map[string]elf.SymBind{}
+	for _, symbol := range symbols {
+		bindings[symbol.Name] = elf.ST_BIND(symbol.Info)
+	}
+
+	expect.Equal(
+		t,
+		map[string]elf.SymBind{
+			"handler":  elf.STB_WEAK,
+			"hooks":    elf.STB_GLOBAL,
+			"optional": elf.STB_WEAK,
+			// required is weak only if all references are weak.
+			"required": elf.STB_GLOBAL,
+		},
+		bindings)
+
+	read, err := s.newRelocatableReader().Read("test.o", buffer.Bytes())
+	expect.Nil(t, err)
+
+	expect.Equal(t, 1, len(read.Text.Definitions.Symbols))
+	expect.Equal(
+		t,
+		layout.WeakBinding,
+		read.Text.Definitions.Symbols[0].Binding)
+
+	weak := map[string]bool{}
+	for _, relocation := range read.Data.Relocations.Symbols {
+		weak[relocation.Name] = relocation.IsWeak
+	}
+
+	expect.Equal(
+		t,
+		map[string]bool{
+			"handler":  false,
+			"optional": true,
+			"required": false,
+		},
+		weak)
+}
+
+func (s ElfSuite) writeRelocatable(t *testing.T) (ElfRelocatableWriter, []byte) {
+	writer := s.newRelocatableWriter(t)
+
+	buffer := &bytes.Buffer{}
+	_, err := writer.WriteTo(buffer)
+	expect.Nil(t, err)
+
+	return writer, buffer.Bytes()
+}
+
+func (ElfSuite) newRelocatableReader() ElfRelocatableReader {
+	return NewElfRelocatableReader(
+		amd64.Linux.ExecutableFormat,
+		amd64.Linux.Layout)
+}
+
+func (s ElfSuite) TestRelocatableRead(t *testing.T) {
+	_, content := s.writeRelocatable(t)
+
+	file, err := s.newRelocatableReader().Read("test.o", content)
+	expect.Nil(t, err)
+
+	expect.Equal(t, 48, file.Text.Size)
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:    layout.FunctionKind,
+				Section: layout.TextSection,
+				Name:    "add",
+				Offset:  0,
+				Size:    18,
+			},
+			{
+				Kind:    layout.FunctionKind,
+				Section: layout.TextSection,
+				Name:    "tail_call",
+				Offset:  18,
+				Size:    16,
+			},
+		},
+		file.Text.Definitions.Symbols)
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{Name: "rovalue", Offset: 3},
+			{Name: "value", Offset: 10},
+			{Name: "counter", Offset: 21, Addend: -4},
+			{Name: "external", Offset: 30},
+		},
+		file.Text.Relocations.Symbols)
+
+	text := file.Text.Flatten()
+	expect.Equal(t, []byte{0, 0, 0, 0}, text[3:7])
+	expect.Equal(t, []byte{0, 0, 0, 0, 0x64}, text[21:26])
+	expect.Equal(t, []byte{0xe9, 0, 0, 0, 0}, text[29:34])
+	expect.Equal(t, byte(0xcc), text[34])
+
+	expect.Equal(t, 0, file.Init.Size)
+
+	expect.Equal(t, 16, file.ReadOnlyData.Size)
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.ReadOnlyDataSection,
+				Name:    "rovalue",
+				Offset:  0,
+				Size:    8,
+			},
+		},
+		file.ReadOnlyData.Definitions.Symbols)
+	expect.Equal(t, byte(31), file.ReadOnlyData.Flatten()[0])
+
+	expect.Equal(t, 32, file.Data.Size)
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.ReadWriteDataSection,
+				Name:    "value",
+				Offset:  0,
+				Size:    8,
+			},
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.ReadWriteDataSection,
+				Name:    "table",
+				Offset:  8,
+				Size:    16,
+			},
+		},
+		file.Data.Definitions.Symbols)
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{Kind: layout.AbsoluteRelocation, Name: "add", Offset: 8},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "tail_call",
+				Offset: 16,
+				Addend: 1,
+			},
+		},
+		file.Data.Relocations.Symbols)
+	expect.Equal(
+		t,
+		[]byte{
+			11, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		file.Data.Flatten()[:24])
+
+	expect.Equal(t, 16, file.BSS.Size)
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.BSSSection,
+				Name:    "counter",
+				Offset:  0,
+				Size:    8,
+			},
+		},
+		file.BSS.Definitions.Symbols)
+}
+
+func (s ElfSuite) TestRelocatableReadLocalSymbol(t *testing.T) {
+	writer, content := s.writeRelocatable(t)
+
+	// Change rovalue's binding to STB_LOCAL
+	idx := writer.SymbolIndices["rovalue"]
+	offset := writer.SectionHeader[3].Offset + uint64(idx)*Elf64SymbolEntrySize
+	content[offset+4] = STT_OBJECT
+
+	file, err := s.newRelocatableReader().Read("test.o", content)
+	expect.Nil(t, err)
+
+	expect.Equal(
+		t,
+		"test.o:rovalue",
+		file.ReadOnlyData.Definitions.Symbols[0].Name)
+	expect.Equal(
+		t,
+		layout.LocalBinding,
+		file.ReadOnlyData.Definitions.Symbols[0].Binding)
+	expect.Equal(
+		t,
+		&layout.Relocation{Name: "test.o:rovalue", Offset: 3},
+		file.Text.Relocations.Symbols[0])
+}
+
+func (s ElfSuite) TestRelocatableReadUnsupportedRelocation(t *testing.T) {
+	writer, content := s.writeRelocatable(t)
+
+	for _, entry := range writer.SectionHeader {
+		if entry.Type == SHT_RELA && entry.Info == uint32(writer.TextIndex) {
+			// Change the first .rela.text entry's type to R_X86_64_GOTPCREL
+			content[entry.Offset+8] = 9
+		}
+	}
+
+	_, err := s.newRelocatableReader().Read("test.o", content)
+	expect.Error(
+		t,
+		err,
+		"unsupported relocation type (9) in .text at offset 0x3")
+}
+
+func (s ElfSuite) TestRelocatableReadWrongFileType(t *testing.T) {
+	_, content := s.writeRelocatable(t)
+	content[16] = ET_EXEC
+
+	_, err := s.newRelocatableReader().Read("test.o", content)
+	expect.Error(t, err, "unsupported elf file type (2)")
+}
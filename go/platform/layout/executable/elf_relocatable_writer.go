@@ -0,0 +1,615 @@
+package executable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// Relocatable object file (ET_REL) writer.  Unlike ElfWriter, this serializes
+// a (not yet linked) layout.ObjectFile, which can be linked by the system
+// linker, possibly with object files compiled from other languages (e.g., c).
+//
+// The object file's unresolved symbol relocations are written as .rela.*
+// entries, symbol values are relative to the start of their sections, and
+// symbols referenced but not defined by the object file are written as
+// undefined symbols.
+//
+// NOTE: the .init section's content (a sequence of calls to modules' init
+// functions) is compatible with the system's crti.o / crtn.o .init prologue
+// and epilogue; the _init symbol is not defined by the object file.
+type ElfRelocatableWriter struct {
+	Config
+
+	Header Elf64Header
+
+	// Content sections' address alignment.
+	SectionAlignment int64
+
+	TextIndex         uint16
+	InitIndex         uint16
+	ReadOnlyDataIndex uint16
+	DataIndex         uint16
+	BSSIndex          uint16
+
+	// NOTE: The first entry is the null symbol.  Defined local symbols are
+	// listed prior to defined global symbols, which are listed prior to
+	// undefined symbols.
+	Symbols         []Elf64SymbolEntry
+	SymbolIndices   map[string]uint32
+	NumLocalSymbols int
+
+	StringTable ElfStringTable
+
+	SectionStringTable ElfStringTable
+
+	SectionHeaderStart int64
+	SectionHeader      []Elf64SectionHeaderEntry
+
+	// The section contents, indexed by section header index.  The string
+	// tables' and the symbol table's contents are generated during writing.
+	SectionContents []layout.Content
+}
+
+type elfRelocatableSegment struct {
+	layout.Section
+	name string
+	*layout.Segment
+}
+
+func NewElfRelocatableWriter(
+	config Config,
+	sectionAlignment int64,
+	file layout.ObjectFile,
+) (
+	ElfRelocatableWriter,
+	error,
+) {
+	sectionStringTableIndex := uint16(1)
+
+	// NOTE: We'll assign string tables and symbol table to fixed section header
+	// locations at the front of the list to simplify file generation.
+	//
+	// The section header order:
+	//   (NULL) - required by elf specification
+	//   .shstrtab
+	//   .strtab
+	//   .symtab
+	//   .text
+	//   .init (optional)
+	//   .rodata (optional)
+	//   .data (optional)
+	//   .bss (optional)
+	//   .note.GNU-stack
+	//   .rela.<section> (optional, one per section with relocations)
+	//
+	// NOTE: the empty .note.GNU-stack section tells the linker that the object
+	// file does not require an executable stack.
+	writer := ElfRelocatableWriter{
+		Config: config,
+
+		// NOTE: SectionHeaderOffset and NumSectionHeaderEntries are defer
+		// populated.
+		Header: Elf64Header{
+			ElfIdentifier: ElfIdentifier{
+				Magic:              [4]byte{0x7f, 'E', 'L', 'F'},
+				Class:              2, // ELFCLASS64 (we don't support elf32 format)
+				DataEncoding:       ELFDATA2LSB,
+				IdentifierVersion:  1, // EI_CURRENT (only valid value)
+				OperatingSystemABI: 0, // ELFOSABI_NONE (aka System V ABI)
+				ABIVersion:         0, // (only valid value)
+			},
+			FileType:                ET_REL,
+			MachineArchitecture:     config.ElfMachineArchitecture,
+			FormatVersion:           1, // EV_CURRENT (only valid value)
+			ArchitectureFlags:       0, // (only valid value)
+			ElfHeaderSize:           Elf64HeaderSize,
+			SectionHeaderEntrySize:  Elf64SectionHeaderEntrySize,
+			SectionStringTableIndex: sectionStringTableIndex,
+		},
+
+		SectionAlignment: sectionAlignment,
+
+		Symbols:            []Elf64SymbolEntry{{}},
+		SymbolIndices:      map[string]uint32{},
+		StringTable:        NewElfStringTable(),
+		SectionStringTable: NewElfStringTable(),
+		SectionHeader:      make([]Elf64SectionHeaderEntry, 4, 15),
+		SectionContents:    make([]layout.Content, 4, 15),
+	}
+
+	segments := []elfRelocatableSegment{
+		{layout.TextSection, ".text", &file.Text},
+		{layout.InitSection, ".init", &file.Init},
+		{layout.ReadOnlyDataSection, ".rodata", &file.ReadOnlyData},
+		{layout.ReadWriteDataSection, ".data", &file.Data},
+	}
+
+	writer.addContentSectionHeaderEntries(segments, file.BSS)
+
+	err := writer.addDefinedSymbols(segments, file.BSS)
+	if err != nil {
+		return ElfRelocatableWriter{}, err
+	}
+
+	err = writer.addRelocationSectionHeaderEntries(segments)
+	if err != nil {
+		return ElfRelocatableWriter{}, err
+	}
+
+	writer.addSymbolTableHeaderEntry()
+	writer.addStringTableHeaderEntry()
+	writer.addSectionStringTableHeaderEntry()
+
+	writer.assignSectionOffsets()
+
+	return writer, nil
+}
+
+func (elf *ElfRelocatableWriter) sectionIndex(
+	section layout.Section,
+) (
+	uint16,
+	error,
+) {
+	idx := uint16(0)
+	switch section {
+	case layout.TextSection:
+		idx = elf.TextIndex
+	case layout.InitSection:
+		idx = elf.InitIndex
+	case layout.ReadOnlyDataSection:
+		idx = elf.ReadOnlyDataIndex
+	case layout.ReadWriteDataSection:
+		idx = elf.DataIndex
+	case layout.BSSSection:
+		idx = elf.BSSIndex
+	}
+
+	if idx == 0 {
+		return 0, fmt.Errorf("unsupported symbol section (%s)", section)
+	}
+	return idx, nil
+}
+
+// .text .init .rodata .data .bss
+func (elf *ElfRelocatableWriter) addContentSectionHeaderEntries(
+	segments []elfRelocatableSegment,
+	bss layout.BSSSegment,
+) {
+	for _, segment := range segments {
+		if segment.Section != layout.TextSection && segment.Size == 0 {
+			continue
+		}
+
+		flags := uint64(SHF_ALLOC)
+		switch segment.Section {
+		case layout.TextSection, layout.InitSection:
+			flags |= SHF_EXECINSTR
+		case layout.ReadWriteDataSection:
+			flags |= SHF_WRITE
+		}
+
+		idx := uint16(len(elf.SectionHeader))
+		switch segment.Section {
+		case layout.TextSection:
+			elf.TextIndex = idx
+		case layout.InitSection:
+			elf.InitIndex = idx
+		case layout.ReadOnlyDataSection:
+			elf.ReadOnlyDataIndex = idx
+		case layout.ReadWriteDataSection:
+			elf.DataIndex = idx
+		default:
+			panic("should never happen")
+		}
+
+		nameIdx, _ := elf.SectionStringTable.MaybeInsert(segment.name)
+		elf.SectionHeader = append(
+			elf.SectionHeader,
+			Elf64SectionHeaderEntry{
+				NameIndex:        nameIdx,
+				Type:             SHT_PROGBITS,
+				Flags:            flags,
+				Size:             uint64(segment.Size),
+				AddressAlignment: uint64(elf.SectionAlignment),
+			})
+		elf.SectionContents = append(elf.SectionContents, segment.Content)
+	}
+
+	if bss.Size > 0 {
+		nameIdx, _ := elf.SectionStringTable.MaybeInsert(".bss")
+		elf.BSSIndex = uint16(len(elf.SectionHeader))
+		elf.SectionHeader = append(
+			elf.SectionHeader,
+			Elf64SectionHeaderEntry{
+				NameIndex:        nameIdx,
+				Type:             SHT_NOBITS,
+				Flags:            SHF_ALLOC | SHF_WRITE,
+				Size:             uint64(bss.Size),
+				AddressAlignment: uint64(elf.SectionAlignment),
+			})
+		elf.SectionContents = append(elf.SectionContents, layout.Content{})
+	}
+
+	nameIdx, _ := elf.SectionStringTable.MaybeInsert(".note.GNU-stack")
+	elf.SectionHeader = append(
+		elf.SectionHeader,
+		Elf64SectionHeaderEntry{
+			NameIndex:        nameIdx,
+			Type:             SHT_PROGBITS,
+			AddressAlignment: 1,
+		})
+	elf.SectionContents = append(elf.SectionContents, layout.Content{})
+}
+
+func (elf *ElfRelocatableWriter) addDefinedSymbols(
+	segments []elfRelocatableSegment,
+	bss layout.BSSSegment,
+) error {
+	symbols := []*layout.Symbol{}
+	for _, segment := range segments {
+		symbols = append(symbols, segment.Definitions.Symbols...)
+	}
+	symbols = append(symbols, bss.Definitions.Symbols...)
+
+	symbols, elf.NumLocalSymbols = sortLocalSymbolsFirst(symbols)
+
+	for _, symbol := range symbols {
+		symbolInfo, visibility, err := convertSymbolInfo(symbol)
+		if err != nil {
+			return err
+		}
+
+		sectionIdx, err := elf.sectionIndex(symbol.Section)
+		if err != nil {
+			return err
+		}
+
+		elf.addSymbol(
+			symbol.Name,
+			Elf64SymbolEntry{
+				Info:         symbolInfo,
+				Visibility:   visibility,
+				SectionIndex: sectionIdx,
+				Value:        uint64(symbol.Offset),
+				Size:         uint64(symbol.Size),
+			})
+	}
+
+	return nil
+}
+
+func (elf *ElfRelocatableWriter) addSymbol(
+	name string,
+	entry Elf64SymbolEntry,
+) uint32 {
+	idx, ok := elf.SymbolIndices[name]
+	if ok {
+		return idx
+	}
+
+	entry.NameIndex, _ = elf.StringTable.MaybeInsert(name)
+
+	idx = uint32(len(elf.Symbols))
+	elf.SymbolIndices[name] = idx
+	elf.Symbols = append(elf.Symbols, entry)
+	return idx
+}
+
+// .rela.text .rela.init .rela.rodata .rela.data
+func (elf *ElfRelocatableWriter) addRelocationSectionHeaderEntries(
+	segments []elfRelocatableSegment,
+) error {
+	for _, segment := range segments {
+		if len(segment.Relocations.Labels) > 0 {
+			return fmt.Errorf(
+				"unexpected label relocations in %s",
+				segment.Section)
+		}
+
+		if len(segment.Relocations.Symbols) == 0 {
+			continue
+		}
+
+		targetIdx, err := elf.sectionIndex(segment.Section)
+		if err != nil {
+			return err
+		}
+
+		content := make([]byte, 0, len(segment.Relocations.Symbols)*Elf64RelocationEntrySize)
+		for _, relocation := range segment.Relocations.Symbols {
+			info := undefinedSymbolInfo
+			if relocation.IsWeak {
+				info = weakUndefinedSymbolInfo
+			}
+
+			symbolIdx := elf.addSymbol(
+				relocation.Name,
+				Elf64SymbolEntry{
+					Info:         info,
+					Visibility:   STV_DEFAULT,
+					SectionIndex: SHN_UNDEF,
+				})
+
+			// The undefined symbol is weak only if all references are weak.
+			symbol := &elf.Symbols[symbolIdx]
+			if !relocation.IsWeak && symbol.Info == weakUndefinedSymbolInfo {
+				symbol.Info = undefinedSymbolInfo
+			}
+
+			entry, err := elf.convertRelocation(
+				segment.Segment,
+				relocation,
+				symbolIdx)
+			if err != nil {
+				return err
+			}
+
+			content, err = binary.Append(content, binary.LittleEndian, entry)
+			if err != nil {
+				panic("should never happen")
+			}
+		}
+
+		nameIdx, _ := elf.SectionStringTable.MaybeInsert(".rela" + segment.name)
+		elf.SectionHeader = append(
+			elf.SectionHeader,
+			Elf64SectionHeaderEntry{
+				NameIndex:        nameIdx,
+				Type:             SHT_RELA,
+				Flags:            SHF_INFO_LINK,
+				Size:             uint64(len(content)),
+				Link:             3, // .symtab
+				Info:             uint32(targetIdx),
+				AddressAlignment: 8,
+				EntrySize:        Elf64RelocationEntrySize,
+			})
+		elf.SectionContents = append(
+			elf.SectionContents,
+			layout.Content{
+				Size:       int64(len(content)),
+				DataChunks: [][]byte{content},
+			})
+	}
+
+	return nil
+}
+
+// NOTE: Section offset relocations are not supported since elf has no
+// equivalent relocation type for allocated sections.
+func (elf *ElfRelocatableWriter) convertRelocation(
+	segment *layout.Segment,
+	relocation *layout.Relocation,
+	symbolIdx uint32,
+) (
+	Elf64RelocationEntry,
+	error,
+) {
+	if elf.ElfMachineArchitecture != EM_X86_64 {
+		return Elf64RelocationEntry{}, fmt.Errorf(
+			"unsupported elf machine architecture (%d)",
+			elf.ElfMachineArchitecture)
+	}
+
+	snippet, err := segment.Peek(relocation.Offset)
+	if err != nil {
+		return Elf64RelocationEntry{}, err
+	}
+
+	var relocationType uint32
+	addend := relocation.Addend
+	switch relocation.Kind {
+	case layout.PCRelativeRelocation:
+		if len(snippet) < 4 {
+			return Elf64RelocationEntry{}, fmt.Errorf(
+				"invalid rel32 relocation. not enough bytes in snippet")
+		}
+
+		// The rel32 displacement is relative to the next instruction, i.e.,
+		// the end of the displacement, whereas elf's addend is relative to the
+		// start of the displacement.
+		relocationType = R_X86_64_PC32
+		addend -= 4
+
+		// call / jmp rel32
+		if relocation.Offset > 0 {
+			opcode, err := segment.Peek(relocation.Offset - 1)
+			if err != nil {
+				return Elf64RelocationEntry{}, err
+			}
+
+			if opcode[0] == 0xe8 || opcode[0] == 0xe9 {
+				relocationType = R_X86_64_PLT32
+			}
+		}
+	case layout.AbsoluteRelocation:
+		if len(snippet) < 8 {
+			return Elf64RelocationEntry{}, fmt.Errorf(
+				"invalid abs64 relocation. not enough bytes in snippet")
+		}
+
+		relocationType = R_X86_64_64
+	case layout.Absolute32Relocation:
+		if len(snippet) < 4 {
+			return Elf64RelocationEntry{}, fmt.Errorf(
+				"invalid abs32 relocation. not enough bytes in snippet")
+		}
+
+		relocationType = R_X86_64_32
+	default:
+		return Elf64RelocationEntry{}, fmt.Errorf(
+			"unsupported relocation kind (%s)",
+			relocation.Kind)
+	}
+
+	return Elf64RelocationEntry{
+		Offset: uint64(relocation.Offset),
+		Info:   uint64(symbolIdx)<<32 | uint64(relocationType),
+		Addend: addend,
+	}, nil
+}
+
+func (elf *ElfRelocatableWriter) addSymbolTableHeaderEntry() {
+	nameIndex, _ := elf.SectionStringTable.MaybeInsert(".symtab")
+	size := int64(len(elf.Symbols)) * Elf64SymbolEntrySize
+	elf.SectionHeader[3] = Elf64SectionHeaderEntry{
+		NameIndex: nameIndex,
+		Type:      SHT_SYMTAB,
+		Size:      uint64(size),
+		Link:      2, // .strtab
+		// Reference: Elf Book III Figure 1-1. sh_link and sh_info Interpretation
+		//
+		// One greater than the symbol table index of the last local symbol
+		// (binding STB_LOCAL).  The null symbol is a local symbol.
+		Info:             uint32(1 + elf.NumLocalSymbols),
+		AddressAlignment: 8,
+		EntrySize:        Elf64SymbolEntrySize,
+	}
+}
+
+func (elf *ElfRelocatableWriter) addStringTableHeaderEntry() {
+	nameIndex, _ := elf.SectionStringTable.MaybeInsert(".strtab")
+	elf.SectionHeader[2] = Elf64SectionHeaderEntry{
+		NameIndex:        nameIndex,
+		Type:             SHT_STRTAB,
+		Size:             uint64(elf.StringTable.Size),
+		AddressAlignment: 1,
+	}
+}
+
+func (elf *ElfRelocatableWriter) addSectionStringTableHeaderEntry() {
+	nameIndex, _ := elf.SectionStringTable.MaybeInsert(".shstrtab")
+	elf.SectionHeader[1] = Elf64SectionHeaderEntry{
+		NameIndex:        nameIndex,
+		Type:             SHT_STRTAB,
+		Size:             uint64(elf.SectionStringTable.Size),
+		AddressAlignment: 1,
+	}
+}
+
+// Sections are written in section header order, immediately after the elf
+// header.
+func (elf *ElfRelocatableWriter) assignSectionOffsets() {
+	offset := uint64(Elf64HeaderSize)
+	for idx := 1; idx < len(elf.SectionHeader); idx++ {
+		entry := &elf.SectionHeader[idx]
+
+		alignment := entry.AddressAlignment
+		offset = ((offset + alignment - 1) / alignment) * alignment
+		entry.Offset = offset
+
+		if entry.Type != SHT_NOBITS {
+			offset += entry.Size
+		}
+	}
+
+	elf.SectionHeaderStart = int64(((offset + 7) / 8) * 8)
+	elf.Header.SectionHeaderOffset = uint64(elf.SectionHeaderStart)
+	elf.Header.NumSectionHeaderEntries = uint16(len(elf.SectionHeader))
+}
+
+func (elf ElfRelocatableWriter) WriteTo(writer io.Writer) (int64, error) {
+	header := make([]byte, Elf64HeaderSize)
+	n, err := binary.Encode(header, binary.LittleEndian, elf.Header)
+	if err != nil || n != Elf64HeaderSize {
+		panic("should never happen")
+	}
+
+	n, err = writer.Write(header)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write elf header: %w", err)
+	}
+	numWritten := int64(n)
+
+	for idx := 1; idx < len(elf.SectionHeader); idx++ {
+		entry := elf.SectionHeader[idx]
+		if entry.Type == SHT_NOBITS {
+			continue
+		}
+
+		padding := int64(entry.Offset) - numWritten
+		if padding > 0 {
+			n, err := writer.Write(make([]byte, padding))
+			if err != nil {
+				return 0, fmt.Errorf("failed to write section: %w", err)
+			}
+			numWritten += int64(n)
+		}
+
+		var written int64
+		switch idx {
+		case 1:
+			written, err = elf.SectionStringTable.WriteTo(writer)
+		case 2:
+			written, err = elf.StringTable.WriteTo(writer)
+		case 3:
+			written, err = elf.writeSymbolTable(writer)
+		default:
+			for _, chunk := range elf.SectionContents[idx].DataChunks {
+				n, err = writer.Write(chunk)
+				written += int64(n)
+				if err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to write section: %w", err)
+		}
+		numWritten += written
+	}
+
+	// NOTE: By convention, elf section header are at the end of the file.
+	padding := elf.SectionHeaderStart - numWritten
+	if padding > 0 {
+		n, err := writer.Write(make([]byte, padding))
+		if err != nil {
+			return 0, fmt.Errorf("failed to write section header: %w", err)
+		}
+		numWritten += int64(n)
+	}
+
+	buffer := make([]byte, Elf64SectionHeaderEntrySize)
+	for _, entry := range elf.SectionHeader {
+		n, err := binary.Encode(buffer, binary.LittleEndian, entry)
+		if err != nil || n != Elf64SectionHeaderEntrySize {
+			panic("should never happen")
+		}
+
+		n, err = writer.Write(buffer)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write section header: %w", err)
+		}
+		numWritten += int64(n)
+	}
+
+	return numWritten, nil
+}
+
+func (elf ElfRelocatableWriter) writeSymbolTable(
+	writer io.Writer,
+) (
+	int64,
+	error,
+) {
+	numWritten := int64(0)
+	buffer := make([]byte, Elf64SymbolEntrySize)
+	for _, symbol := range elf.Symbols {
+		n, err := binary.Encode(buffer, binary.LittleEndian, symbol)
+		if err != nil || n != Elf64SymbolEntrySize {
+			panic("should never happen")
+		}
+
+		n, err = writer.Write(buffer)
+		numWritten += int64(n)
+		if err != nil {
+			return numWritten, err
+		}
+	}
+
+	return numWritten, nil
+}
@@ -2,6 +2,7 @@ package executable_test
 
 import (
 	"bytes"
+	"debug/elf"
 	"encoding/binary"
 	"testing"
 
@@ -600,3 +601,652 @@ func (s ElfSuite) TestWrite(t *testing.T) {
 	expect.Equal(t, SHT_NOBITS, entry.Type)
 	expect.Equal(t, uint64(writer.BSSSize), entry.Size)
 }
+
+func (ElfSuite) TestRebaseAbsoluteAddresses(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{0xc3}, // ret
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "start",
+					Offset:  0,
+					Size:    1,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"functionTable",
+		[]byte{
+			0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "start",
+				Offset: 0,
+			},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "start",
+				Offset: 8,
+				Addend: 1,
+			},
+		})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(amd64.Linux.Layout, "start")
+	expect.Nil(t, err)
+
+	start := uint64(image.ExecutableSegmentStart)
+	imageData := image.Data.Flatten()
+	expect.Equal(t, start, binary.LittleEndian.Uint64(imageData))
+	expect.Equal(t, start+1, binary.LittleEndian.Uint64(imageData[8:]))
+
+	writer, err := NewElfWriter(amd64.Linux.ExecutableFormat, image)
+	expect.Nil(t, err)
+
+	address := writer.VirtualAddressStart + start
+	data := writer.Data.Flatten()
+	expect.Equal(t, address, binary.LittleEndian.Uint64(data))
+	expect.Equal(t, address+1, binary.LittleEndian.Uint64(data[8:]))
+
+	// The image's content is not modified.
+	expect.Equal(t, start, binary.LittleEndian.Uint64(imageData))
+}
+
+func (ElfSuite) TestRebaseAbsolute32Addresses(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{0xc3}, // ret
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "start",
+					Offset:  0,
+					Size:    1,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"offsets",
+		[]byte{
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+		},
+		[]*layout.Relocation{
+			{
+				Kind:   layout.Absolute32Relocation,
+				Name:   "start",
+				Offset: 0,
+				Addend: 1,
+			},
+			{
+				Kind:   layout.SectionOffsetRelocation,
+				Name:   "offsets",
+				Offset: 4,
+				Addend: 4,
+			},
+		})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(amd64.Linux.Layout, "start")
+	expect.Nil(t, err)
+
+	start := uint32(image.ExecutableSegmentStart)
+
+	writer, err := NewElfWriter(amd64.Linux.ExecutableFormat, image)
+	expect.Nil(t, err)
+
+	// Section offsets are not rebased.
+	address := uint32(writer.VirtualAddressStart) + start
+	data := writer.Data.Flatten()
+	expect.Equal(t, address+1, binary.LittleEndian.Uint32(data))
+	expect.Equal(t, uint32(4), binary.LittleEndian.Uint32(data[4:]))
+
+	config := amd64.Linux.ExecutableFormat
+	config.PositionIndependent = true
+
+	_, err = NewElfWriter(config, image)
+	expect.Error(
+		t,
+		err,
+		"absolute 32-bit address (8192) not supported by position independent")
+}
+
+func (ElfSuite) TestPositionIndependentExecutable(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{0xc3}, // ret
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "start",
+					Offset:  0,
+					Size:    1,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.ReadOnlyData.AppendObject(
+		layout.ReadOnlyDataSection,
+		"valueAddress",
+		[]byte{0, 0, 0, 0, 0, 0, 0, 0},
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "value",
+				Offset: 0,
+			},
+		})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"functionTable",
+		[]byte{
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "start",
+				Offset: 0,
+				Addend: 1,
+			},
+		})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"value",
+		[]byte{42, 0, 0, 0, 0, 0, 0, 0},
+		nil)
+
+	builder.BSS.AppendObject("scratch", 8)
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(amd64.Linux.Layout, "start")
+	expect.Nil(t, err)
+
+	config := amd64.Linux.ExecutableFormat
+	config.PositionIndependent = true
+
+	writer, err := NewElfWriter(config, image)
+	expect.Nil(t, err)
+
+	expect.Equal(t, ET_DYN, writer.Header.FileType)
+	expect.Equal(t, 0, writer.BaseAddress)
+	expect.Equal(t, image.EntryPoint, int64(writer.Header.EntryPointAddress))
+	expect.True(t, writer.HasReadOnlyRelocations)
+
+	start := uint64(image.ExecutableSegmentStart)
+	valueAddress := uint64(image.ReadWriteSegmentStart + 8)
+	expect.Equal(
+		t,
+		[]Elf64RelocationEntry{
+			{
+				Offset: uint64(image.ReadOnlySegmentStart),
+				Info:   uint64(R_X86_64_RELATIVE),
+				Addend: int64(valueAddress),
+			},
+			{
+				Offset: uint64(image.ReadWriteSegmentStart),
+				Info:   uint64(R_X86_64_RELATIVE),
+				Addend: int64(start + 1),
+			},
+		},
+		writer.DynamicSegment.Relocations)
+
+	// The content is relocated by the dynamic linker.
+	expect.Equal(t, image.Data.Flatten(), writer.Data.Flatten())
+
+	types := []uint32{}
+	for _, entry := range writer.ProgramHeader {
+		types = append(types, entry.Type)
+	}
+	expect.Equal(
+		t,
+		[]uint32{
+			PT_PHDR,
+			PT_INTERP,
+			PT_LOAD, // header
+			PT_LOAD, // .text .init
+			PT_LOAD, // .rodata
+			PT_LOAD, // .data .bss
+			PT_LOAD, // dynamic segment
+			PT_DYNAMIC,
+			PT_GNU_RELRO,
+			PT_GNU_STACK,
+		},
+		types)
+
+	expect.Equal(t, 0b110, writer.ProgramHeader[4].Flags) // rw- until relocated
+
+	dynamicSegment := writer.ProgramHeader[6]
+	expect.Equal(t, 0, dynamicSegment.VirtualAddress%uint64(image.MemoryPageSize))
+	expect.True(
+		t,
+		dynamicSegment.VirtualAddress >= valueAddress+8+uint64(image.BSSSize))
+
+	buffer := &bytes.Buffer{}
+	numWritten, err := writer.WriteTo(buffer)
+	expect.Nil(t, err)
+	expect.Equal(t, int64(buffer.Len()), numWritten)
+
+	elfFile, err := elf.NewFile(bytes.NewReader(buffer.Bytes()))
+	expect.Nil(t, err)
+	expect.Equal(t, elf.ET_DYN, elfFile.Type)
+
+	for _, prog := range elfFile.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+
+		interpreter := make([]byte, prog.Filesz)
+		_, err := prog.ReadAt(interpreter, 0)
+		expect.Nil(t, err)
+		expect.Equal(t, config.DynamicLinker+"\x00", string(interpreter))
+	}
+
+	flags, err := elfFile.DynValue(elf.DT_FLAGS_1)
+	expect.Nil(t, err)
+	expect.Equal(t, []uint64{DF_1_PIE}, flags)
+
+	relocations, err := elfFile.DynValue(elf.DT_RELASZ)
+	expect.Nil(t, err)
+	expect.Equal(t, []uint64{2 * Elf64RelocationEntrySize}, relocations)
+
+	rela := elfFile.Section(".rela.dyn")
+	expect.NotNil(t, rela)
+	content, err := rela.Data()
+	expect.Nil(t, err)
+
+	entries := make([]Elf64RelocationEntry, 2)
+	_, err = binary.Decode(content, binary.LittleEndian, entries)
+	expect.Nil(t, err)
+	expect.Equal(t, writer.DynamicSegment.Relocations, entries)
+}
+
+func (ElfSuite) TestDynamicImports(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{
+			0x48, 0x8b, 0x35, 0, 0, 0, 0, // mov rsi, [rip + stdout]
+			0xe8, 0, 0, 0, 0, // call fputs
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "start",
+					Offset:  0,
+					Size:    12,
+				},
+			},
+		},
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "stdout",
+					Offset: 3,
+				},
+				{
+					Name:   "fputs",
+					Offset: 8,
+				},
+			},
+		})
+
+	builder.Imports = []*layout.Import{
+		{
+			Kind:    layout.FunctionKind,
+			Name:    "fputs",
+			Library: "libc.so.6",
+		},
+		{
+			Kind:    layout.ObjectKind,
+			Name:    "stdout",
+			Library: "libc.so.6",
+			Size:    8,
+		},
+	}
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(amd64.Linux.Layout, "start")
+	expect.Nil(t, err)
+
+	config := amd64.Linux.ExecutableFormat
+	config.DynamicLinker = ""
+
+	_, err = NewElfWriter(config, image)
+	expect.Error(t, err, "requires a dynamic linker")
+
+	config = amd64.Linux.ExecutableFormat
+
+	writer, err := NewElfWriter(config, image)
+	expect.Nil(t, err)
+
+	expect.Equal(t, ET_EXEC, writer.Header.FileType)
+	expect.True(t, writer.HasDynamicSegment)
+
+	fputsEntry := writer.BaseAddress + uint64(image.Imports[0].Offset)
+	stdoutCopy := writer.BaseAddress + uint64(image.Imports[1].Offset)
+	expect.Equal(
+		t,
+		[]Elf64RelocationEntry{
+			{
+				Offset: fputsEntry,
+				Info:   1<<32 | uint64(R_X86_64_GLOB_DAT),
+			},
+			{
+				Offset: stdoutCopy,
+				Info:   2<<32 | uint64(R_X86_64_COPY),
+			},
+		},
+		writer.DynamicSegment.Relocations)
+
+	types := []uint32{}
+	for _, entry := range writer.ProgramHeader {
+		types = append(types, entry.Type)
+	}
+	expect.Equal(
+		t,
+		[]uint32{
+			PT_PHDR,
+			PT_INTERP,
+			PT_LOAD, // header
+			PT_LOAD, // .text .init
+			PT_LOAD, // .data .bss
+			PT_LOAD, // dynamic segment
+			PT_DYNAMIC,
+			PT_GNU_STACK,
+		},
+		types)
+
+	buffer := &bytes.Buffer{}
+	numWritten, err := writer.WriteTo(buffer)
+	expect.Nil(t, err)
+	expect.Equal(t, int64(buffer.Len()), numWritten)
+
+	elfFile, err := elf.NewFile(bytes.NewReader(buffer.Bytes()))
+	expect.Nil(t, err)
+	expect.Equal(t, elf.ET_EXEC, elfFile.Type)
+
+	libraries, err := elfFile.ImportedLibraries()
+	expect.Nil(t, err)
+	expect.Equal(t, []string{"libc.so.6"}, libraries)
+
+	symbols, err := elfFile.DynamicSymbols()
+	expect.Nil(t, err)
+	expect.Equal(t, 2, len(symbols))
+
+	expect.Equal(t, "fputs", symbols[0].Name)
+	expect.Equal(t, elf.STT_FUNC, elf.ST_TYPE(symbols[0].Info))
+	expect.Equal(t, elf.SHN_UNDEF, symbols[0].Section)
+
+	expect.Equal(t, "stdout", symbols[1].Name)
+	expect.Equal(t, elf.STT_OBJECT, elf.ST_TYPE(symbols[1].Info))
+	expect.Equal(t, elf.SectionIndex(writer.BSSIndex), symbols[1].Section)
+	expect.Equal(t, stdoutCopy, symbols[1].Value)
+	expect.Equal(t, 8, symbols[1].Size)
+
+	hash := elfFile.Section(".hash")
+	expect.NotNil(t, hash)
+	content, err := hash.Data()
+	expect.Nil(t, err)
+
+	table := make([]uint32, len(writer.DynamicSegment.HashTable))
+	_, err = binary.Decode(content, binary.LittleEndian, table)
+	expect.Nil(t, err)
+	expect.Equal(t, writer.DynamicSegment.HashTable, table)
+	expect.Equal(t, []uint32{3, 3}, table[:2]) // nbucket, nchain
+}
+
+func (ElfSuite) TestSharedLibrary(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{
+			0xc3, // ret
+			0xc3, // ret
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:       layout.FunctionKind,
+					Section:    layout.TextSection,
+					Name:       "initFunc",
+					Offset:     0,
+					Size:       1,
+					Visibility: layout.HiddenVisibility,
+					IsExported: true, // hidden symbols are never exported
+				},
+				{
+					Kind:       layout.FunctionKind,
+					Section:    layout.TextSection,
+					Name:       "libFunc",
+					Offset:     1,
+					Size:       1,
+					IsExported: true,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.Init.AppendData(
+		[]byte{0xe8, 0, 0, 0, 0}, // call initFunc
+		layout.Definitions{},
+		layout.Relocations{
+			Symbols: []*layout.Relocation{
+				{
+					Name:   "initFunc",
+					Offset: 1,
+				},
+			},
+		})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"counter",
+		[]byte{0, 0, 0, 0},
+		nil)
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	image, err := file.ToSharedLibraryImage(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	config := amd64.Linux.ExecutableFormat
+	writer, err := NewElfSharedLibraryWriter(config, "libtest.so", image)
+	expect.Nil(t, err)
+
+	expect.Equal(t, ET_DYN, writer.Header.FileType)
+	expect.Equal(t, 0, writer.Header.EntryPointAddress)
+	expect.Equal(t, 0, writer.BaseAddress)
+	expect.True(t, writer.IsSharedLibrary)
+	expect.True(t, writer.PositionIndependent)
+
+	// The init array's .init address is relocated by the dynamic linker.
+	expect.Equal(
+		t,
+		[]Elf64RelocationEntry{
+			{
+				Offset: uint64(image.InitArrayStart),
+				Info:   uint64(R_X86_64_RELATIVE),
+				Addend: int64(image.ExecutableSegmentStart + image.Text.Size),
+			},
+		},
+		writer.DynamicSegment.Relocations)
+
+	types := []uint32{}
+	for _, entry := range writer.ProgramHeader {
+		types = append(types, entry.Type)
+	}
+	expect.Equal(
+		t,
+		[]uint32{
+			PT_PHDR,
+			PT_LOAD, // header
+			PT_LOAD, // .text .init
+			PT_LOAD, // .data .bss
+			PT_LOAD, // dynamic segment
+			PT_DYNAMIC,
+			PT_GNU_STACK,
+		},
+		types)
+
+	buffer := &bytes.Buffer{}
+	numWritten, err := writer.WriteTo(buffer)
+	expect.Nil(t, err)
+	expect.Equal(t, int64(buffer.Len()), numWritten)
+
+	elfFile, err := elf.NewFile(bytes.NewReader(buffer.Bytes()))
+	expect.Nil(t, err)
+	expect.Equal(t, elf.ET_DYN, elfFile.Type)
+	expect.Nil(t, elfFile.Section(".interp"))
+
+	soname, err := elfFile.DynString(elf.DT_SONAME)
+	expect.Nil(t, err)
+	expect.Equal(t, []string{"libtest.so"}, soname)
+
+	initArray, err := elfFile.DynValue(elf.DT_INIT_ARRAY)
+	expect.Nil(t, err)
+	expect.Equal(t, []uint64{uint64(image.InitArrayStart)}, initArray)
+
+	initArraySize, err := elfFile.DynValue(elf.DT_INIT_ARRAYSZ)
+	expect.Nil(t, err)
+	expect.Equal(t, []uint64{8}, initArraySize)
+
+	flags, err := elfFile.DynValue(elf.DT_FLAGS_1)
+	expect.Nil(t, err)
+	expect.Equal(t, 0, len(flags))
+
+	symbols, err := elfFile.DynamicSymbols()
+	expect.Nil(t, err)
+	expect.Equal(t, 1, len(symbols))
+
+	expect.Equal(t, "libFunc", symbols[0].Name)
+	expect.Equal(t, elf.STT_FUNC, elf.ST_TYPE(symbols[0].Info))
+	expect.Equal(t, elf.SectionIndex(writer.TextIndex), symbols[0].Section)
+	expect.Equal(t, uint64(image.ExecutableSegmentStart+1), symbols[0].Value)
+}
+
+func (ElfSuite) TestSharedLibraryExportedObject(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{0xc3}, // ret
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:       layout.FunctionKind,
+					Section:    layout.TextSection,
+					Name:       "libFunc",
+					Size:       1,
+					IsExported: true,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	builder.Data.AppendObject(
+		layout.ReadWriteDataSection,
+		"counter",
+		[]byte{0, 0, 0, 0},
+		nil)
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	file.Data.Definitions.Symbols[0].IsExported = true
+
+	image, err := file.ToSharedLibraryImage(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	_, err = NewElfSharedLibraryWriter(
+		amd64.Linux.ExecutableFormat,
+		"libtest.so",
+		image)
+	expect.Error(t, err, "cannot export object symbol (counter)")
+}
+
+func (ElfSuite) TestLocalSymbolsFirst(t *testing.T) {
+	builder := layout.NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte{
+			0xc3, // ret
+			0xc3, // ret
+		},
+		layout.Definitions{
+			Symbols: []*layout.Symbol{
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "start",
+					Offset:  0,
+					Size:    1,
+				},
+				{
+					Kind:    layout.FunctionKind,
+					Section: layout.TextSection,
+					Name:    "helper",
+					Offset:  1,
+					Size:    1,
+					Binding: layout.LocalBinding,
+				},
+			},
+		},
+		layout.Relocations{})
+
+	file, err := builder.Finalize(amd64.Linux.Layout)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(amd64.Linux.Layout, "start")
+	expect.Nil(t, err)
+
+	writer, err := NewElfWriter(amd64.Linux.ExecutableFormat, image)
+	expect.Nil(t, err)
+
+	names := []string{}
+	for _, symbol := range writer.Definitions.Symbols {
+		names = append(names, symbol.Name)
+	}
+	expect.Equal(t, []string{"helper", "start", "_init"}, names)
+	expect.Equal(t, 1, writer.NumLocalSymbols)
+	expect.Equal(t, 1, writer.SectionHeader[writer.SymbolTableIndex].Info)
+
+	// The image's symbols are not reordered.
+	expect.Equal(t, "start", image.Definitions.Symbols[0].Name)
+
+	numWritten, err := writer.WriteTo(&bytes.Buffer{})
+	expect.Nil(t, err)
+	expect.True(t, numWritten > 0)
+}
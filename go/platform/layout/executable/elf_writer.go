@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/pattyshack/chickadee/platform/layout"
 )
@@ -13,10 +14,30 @@ type ElfWriter struct {
 
 	Header Elf64Header
 
+	// The image's load address.  This is zero for position independent
+	// executables (the dynamic linker chooses the load address).
+	BaseAddress uint64
+
 	ProgramHeader []Elf64ProgramHeaderEntry
 
 	layout.ExecutableImage
 
+	// True if the output is a shared library (ET_DYN without entry point and
+	// program interpreter).  Shared libraries are always position independent.
+	IsSharedLibrary bool
+
+	// True for shared libraries, position independent executables, and
+	// executables which import symbols from shared libraries.
+	HasDynamicSegment bool
+
+	// Only used when HasDynamicSegment is true.
+	DynamicSegment ElfDynamicSegment
+
+	// True if the read-only segment contains embedded absolute addresses,
+	// which must be relocated by the dynamic linker (the segment is writable
+	// until relocated, and is covered by the PT_GNU_RELRO entry).
+	HasReadOnlyRelocations bool
+
 	TextIndex         uint16
 	InitIndex         uint16
 	ReadOnlyDataIndex uint16
@@ -24,9 +45,11 @@ type ElfWriter struct {
 	BSSIndex          uint16
 
 	// NOTE: The symbol table is implicitly generated from []*layout.Symbol
-	// during writing.
+	// during writing.  The image's local symbols are reordered prior to its
+	// global symbols.
 	SymbolTableStart int64
 	SymbolTableIndex uint16
+	NumLocalSymbols  int
 
 	StringTableStart int64
 	StringTableIndex uint16
@@ -46,9 +69,65 @@ func NewElfWriter(
 ) (
 	ElfWriter,
 	error,
+) {
+	return newElfWriter(config, image, false, "")
+}
+
+// The image must be generated by layout.ObjectFile's ToSharedLibraryImage.
+// Only symbols marked as exported are included in the shared library's
+// dynamic symbol table.  Exported object symbols are rejected (see
+// layout.Symbol's IsExported).  The dynamic section only references the init
+// array; DT_FINI_ARRAY is never emitted since the image has no fini array
+// (see layout.ObjectFile's ToSharedLibraryImage).
+//
+// NOTE: references within the shared library are resolved at link time, and
+// cannot be interposed by other libraries' definitions (similar to
+// -Bsymbolic).
+func NewElfSharedLibraryWriter(
+	config Config,
+	sharedObjectName string,
+	image layout.ExecutableImage,
+) (
+	ElfWriter,
+	error,
+) {
+	config.PositionIndependent = true
+	config.DynamicLinker = ""
+	return newElfWriter(config, image, true, sharedObjectName)
+}
+
+func newElfWriter(
+	config Config,
+	image layout.ExecutableImage,
+	isSharedLibrary bool,
+	sharedObjectName string,
+) (
+	ElfWriter,
+	error,
 ) {
 	sectionStringTableIndex := uint16(1)
-	entryPoint := config.VirtualAddressStart + uint64(image.EntryPoint)
+
+	fileType := uint16(ET_EXEC)
+	baseAddress := config.VirtualAddressStart
+	if config.PositionIndependent {
+		fileType = ET_DYN
+		baseAddress = 0
+	}
+
+	hasDynamicSegment := config.PositionIndependent || len(image.Imports) > 0
+
+	numMetadataEntries := 3 // PHDR, LOAD (header), LOAD .text .init
+	if hasDynamicSegment && !isSharedLibrary {
+		if config.DynamicLinker == "" {
+			return ElfWriter{}, fmt.Errorf(
+				"position independent or dynamically linked executable requires " +
+					"a dynamic linker")
+		}
+
+		numMetadataEntries = 4 // + INTERP
+	}
+
+	entryPoint := baseAddress + uint64(image.EntryPoint)
 
 	// NOTE: We'll assign string tables and symbol table to fixed section header
 	// locations at the front of the list to simplify file generation.
@@ -63,18 +142,28 @@ func NewElfWriter(
 	//   .rodata
 	//   .data
 	//   .bss
+	//   .interp .dynstr .hash .dynsym .rela.dyn .dynamic (dynamic segment only)
 	//
 	// The program header order:
 	//   PHDR             (r-- memory page aligned)
+	//   INTERP           (r-- dynamic segment only, excluding shared libraries)
 	//   LOAD (header)    (r-- memory page aligned)
 	//   LOAD .text .init (r-e memory page aligned)
 	//   LOAD .rodata     (r-- memory page aligned)
 	//   LOAD .data .bss  (rw- memory page aligned)
+	//   LOAD .interp ... (r-- memory page aligned, dynamic segment only)
+	//   DYNAMIC          (r-- dynamic segment only)
+	//   GNU_RELRO        (r-- position independent with .rodata relocations)
 	//   GNU_STACK        (rw- 8-byte aligned)
 	//
 	// NOTE: the GNU_STACK entry tells linux that the stack is not executable
 	//
-	// TODO INTERP for dynamic linking / PIC executable
+	// NOTE: position independent executables' embedded absolute addresses are
+	// relocated by the dynamic linker at load time (R_X86_64_RELATIVE on amd64).
+	//
+	// NOTE: imported functions' global offset table entries are populated by
+	// the dynamic linker at load time (R_X86_64_GLOB_DAT on amd64), and
+	// imported objects are copied into .bss (R_X86_64_COPY on amd64).
 	writer := ElfWriter{
 		Config: config,
 
@@ -89,8 +178,7 @@ func NewElfWriter(
 				OperatingSystemABI: 0, // ELFOSABI_NONE (aka System V ABI)
 				ABIVersion:         0, // (only valid value)
 			},
-			// TODO switch to position independent executable (ET_DYN)
-			FileType:                ET_EXEC,
+			FileType:                fileType,
 			MachineArchitecture:     config.ElfMachineArchitecture,
 			FormatVersion:           1, // EV_CURRENT (only valid value)
 			EntryPointAddress:       entryPoint,
@@ -102,7 +190,14 @@ func NewElfWriter(
 			SectionStringTableIndex: sectionStringTableIndex,
 		},
 
-		ProgramHeader:    make([]Elf64ProgramHeaderEntry, 3, 6),
+		BaseAddress:       baseAddress,
+		IsSharedLibrary:   isSharedLibrary,
+		HasDynamicSegment: hasDynamicSegment,
+
+		ProgramHeader: make(
+			[]Elf64ProgramHeaderEntry,
+			numMetadataEntries,
+			numMetadataEntries+6),
 		ExecutableImage:  image,
 		SymbolTableIndex: 3,
 		StringTableIndex: 2,
@@ -110,12 +205,32 @@ func NewElfWriter(
 			image.Definitions.Symbols),
 		SectionStringTableIndex: sectionStringTableIndex,
 		SectionStringTable:      NewElfStringTable(),
-		SectionHeader:           make([]Elf64SectionHeaderEntry, 4, 10),
+		SectionHeader:           make([]Elf64SectionHeaderEntry, 4, 15),
+	}
+
+	writer.Definitions.Symbols, writer.NumLocalSymbols = sortLocalSymbolsFirst(
+		image.Definitions.Symbols)
+
+	if hasDynamicSegment {
+		writer.DynamicSegment = NewElfDynamicSegment(
+			config.DynamicLinker,
+			sharedObjectName)
+	}
+
+	err := writer.rebaseAbsoluteAddresses()
+	if err != nil {
+		return ElfWriter{}, err
 	}
 
 	writer.addExecutableSegmentHeaderEntries()
 	writer.maybeAddReadOnlySegmentHeaderEntries()
 	writer.maybeAddReadWriteSegmentHeaderEntries()
+
+	err = writer.maybeAddDynamicSegmentHeaderEntries()
+	if err != nil {
+		return ElfWriter{}, err
+	}
+
 	writer.updateMetadataHeaderEntries()
 
 	writer.addSymbolTableHeaderEntry()
@@ -125,17 +240,174 @@ func NewElfWriter(
 	return writer, nil
 }
 
+// The image's absolute addresses are relative to the start of the image.
+// Rebase these addresses by VirtualAddressStart.  For position independent
+// executables, the addresses are instead rebased by the dynamic linker at load
+// time via relative relocations (32-bit absolute addresses are not supported
+// by position independent executables).
+//
+// NOTE: the affected content is copied since the image's content may be
+// shared.
+func (elf *ElfWriter) rebaseAbsoluteAddresses() error {
+	if len(elf.AbsoluteAddresses) == 0 && len(elf.Absolute32Addresses) == 0 {
+		return nil
+	}
+
+	if elf.PositionIndependent {
+		if len(elf.Absolute32Addresses) > 0 {
+			return fmt.Errorf(
+				"absolute 32-bit address (%d) not supported by position "+
+					"independent image",
+				elf.Absolute32Addresses[0])
+		}
+
+		if elf.ElfMachineArchitecture != EM_X86_64 {
+			return fmt.Errorf(
+				"unsupported elf machine architecture (%d)",
+				elf.ElfMachineArchitecture)
+		}
+	}
+
+	type segment struct {
+		start     int64
+		content   *layout.Content
+		copied    bool
+		flattened []byte
+	}
+
+	segments := []*segment{
+		{
+			start:   elf.ReadOnlySegmentStart,
+			content: &elf.ReadOnlyData,
+		},
+		{
+			start:   elf.ReadWriteSegmentStart,
+			content: &elf.Data,
+		},
+	}
+
+	// Returns the address' segment, and the address' bytes.  The content is
+	// copied only if the address is rebased in place.
+	lookup := func(
+		offset int64,
+		size int,
+		inPlace bool,
+	) (
+		*segment,
+		[]byte,
+		error,
+	) {
+		var found *segment
+		for _, seg := range segments {
+			if seg.start <= offset && offset < seg.start+seg.content.Size {
+				found = seg
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, nil, fmt.Errorf(
+				"absolute address (%d) not in data segments",
+				offset)
+		}
+
+		var address []byte
+		if inPlace {
+			if !found.copied {
+				content := make([]byte, found.content.Size)
+				copy(content, found.content.Flatten())
+				found.content.DataChunks = [][]byte{content}
+				found.copied = true
+			}
+
+			address = found.content.DataChunks[0][offset-found.start:]
+		} else {
+			if found.flattened == nil {
+				found.flattened = found.content.Flatten()
+			}
+
+			address = found.flattened[offset-found.start:]
+		}
+
+		if len(address) < size {
+			return nil, nil, fmt.Errorf(
+				"absolute address (%d) out of bound",
+				offset)
+		}
+
+		return found, address, nil
+	}
+
+	for _, offset := range elf.AbsoluteAddresses {
+		found, address, err := lookup(offset, 8, !elf.PositionIndependent)
+		if err != nil {
+			return err
+		}
+
+		if elf.PositionIndependent {
+			if found.content == &elf.ReadOnlyData {
+				elf.HasReadOnlyRelocations = true
+			}
+
+			elf.DynamicSegment.Relocations = append(
+				elf.DynamicSegment.Relocations,
+				Elf64RelocationEntry{
+					Offset: uint64(offset),
+					Info:   uint64(R_X86_64_RELATIVE),
+					Addend: int64(binary.LittleEndian.Uint64(address)),
+				})
+			continue
+		}
+
+		binary.LittleEndian.PutUint64(
+			address,
+			binary.LittleEndian.Uint64(address)+elf.BaseAddress)
+	}
+
+	for _, offset := range elf.Absolute32Addresses {
+		_, address, err := lookup(offset, 4, true)
+		if err != nil {
+			return err
+		}
+
+		rebased := uint64(binary.LittleEndian.Uint32(address)) + elf.BaseAddress
+		if rebased > math.MaxUint32 {
+			return fmt.Errorf(
+				"absolute 32-bit address (%d) overflow (%#x)",
+				offset,
+				rebased)
+		}
+
+		binary.LittleEndian.PutUint32(address, uint32(rebased))
+	}
+
+	return nil
+}
+
+// Index of the LOAD (elf header page) program header entry.  The LOAD .text
+// .init entry immediately follows.
+func (elf *ElfWriter) headerLoadIndex() int {
+	if elf.hasInterpreter() {
+		return 2 // after INTERP
+	}
+	return 1
+}
+
+func (elf *ElfWriter) hasInterpreter() bool {
+	return elf.HasDynamicSegment && !elf.IsSharedLibrary
+}
+
 // LOAD .text .init
 func (elf *ElfWriter) addExecutableSegmentHeaderEntries() {
 	start := uint64(elf.ExecutableSegmentStart)
-	startAddress := elf.VirtualAddressStart + start
+	startAddress := elf.BaseAddress + start
 	fileSize := uint64(elf.Text.Size + elf.Init.Size)
 
 	// NOTE: This may not be the final start location if there are additional
 	// executable image file segments.
 	elf.SymbolTableStart = int64(start + fileSize)
 
-	elf.ProgramHeader[2] = Elf64ProgramHeaderEntry{
+	elf.ProgramHeader[elf.headerLoadIndex()+1] = Elf64ProgramHeaderEntry{
 		Type:            PT_LOAD,
 		Flags:           0b101, // r-e
 		ContentOffset:   start,
@@ -192,9 +464,16 @@ func (elf *ElfWriter) maybeAddReadOnlySegmentHeaderEntries() {
 	}
 
 	start := uint64(elf.ReadOnlySegmentStart)
-	startAddress := elf.VirtualAddressStart + start
+	startAddress := elf.BaseAddress + start
 	fileSize := uint64(elf.ReadOnlyData.Size)
 
+	// NOTE: the segment is write protected by the dynamic linker after
+	// relocation (see GNU_RELRO).
+	flags := uint32(0b100) // r--
+	if elf.HasReadOnlyRelocations {
+		flags = 0b110 // rw-
+	}
+
 	// NOTE: This may not be the final start location if there are additional
 	// executable image file segments.
 	elf.SymbolTableStart = int64(start + fileSize)
@@ -203,7 +482,7 @@ func (elf *ElfWriter) maybeAddReadOnlySegmentHeaderEntries() {
 		elf.ProgramHeader,
 		Elf64ProgramHeaderEntry{
 			Type:            PT_LOAD,
-			Flags:           0b100, // r--
+			Flags:           flags,
 			ContentOffset:   start,
 			VirtualAddress:  startAddress,
 			PhysicalAddress: startAddress,
@@ -237,11 +516,11 @@ func (elf *ElfWriter) maybeAddReadWriteSegmentHeaderEntries() {
 	}
 
 	start := uint64(elf.ReadWriteSegmentStart)
-	startAddress := elf.VirtualAddressStart + start
+	startAddress := elf.BaseAddress + start
 	fileSize := uint64(elf.Data.Size)
 
-	// NOTE: This is the symbol table's final start location since there are no
-	// more executable image file segments.
+	// NOTE: This may not be the final start location if there is a dynamic
+	// segment.
 	elf.SymbolTableStart = int64(start + fileSize)
 
 	elf.ProgramHeader = append(
@@ -298,11 +577,313 @@ func (elf *ElfWriter) maybeAddReadWriteSegmentHeaderEntries() {
 	}
 }
 
+// LOAD .interp .dynstr .hash .dynsym .rela.dyn .dynamic
+// DYNAMIC
+func (elf *ElfWriter) maybeAddDynamicSegmentHeaderEntries() error {
+	if !elf.HasDynamicSegment {
+		return nil
+	}
+
+	segment := &elf.DynamicSegment
+
+	err := elf.addImports()
+	if err != nil {
+		return err
+	}
+
+	if elf.IsSharedLibrary {
+		err := elf.addExports()
+		if err != nil {
+			return err
+		}
+
+		if elf.InitArraySize > 0 {
+			segment.ExtraEntries = append(
+				segment.ExtraEntries,
+				Elf64DynamicEntry{
+					Tag:   DT_INIT_ARRAY,
+					Value: elf.BaseAddress + uint64(elf.InitArrayStart),
+				},
+				Elf64DynamicEntry{
+					Tag:   DT_INIT_ARRAYSZ,
+					Value: uint64(elf.InitArraySize),
+				})
+		}
+	} else if elf.PositionIndependent {
+		segment.ExtraEntries = append(
+			segment.ExtraEntries,
+			Elf64DynamicEntry{Tag: DT_FLAGS_1, Value: DF_1_PIE})
+	}
+
+	pageSize := elf.MemoryPageSize
+
+	imageEnd := elf.ExecutableSegmentStart + elf.Text.Size + elf.Init.Size
+	if elf.ReadOnlyData.Size > 0 {
+		imageEnd = elf.ReadOnlySegmentStart + elf.ReadOnlyData.Size
+	}
+	if elf.Data.Size > 0 || elf.BSSSize > 0 {
+		imageEnd = elf.ReadWriteSegmentStart + elf.Data.Size + elf.BSSSize
+	}
+
+	// NOTE: the segment's file offset differs from its address when the image
+	// has .bss.  Both are memory page aligned.
+	start := ((elf.SymbolTableStart + pageSize - 1) / pageSize) * pageSize
+	address := elf.BaseAddress +
+		uint64(((imageEnd+pageSize-1)/pageSize)*pageSize)
+
+	segment.finalize(start, address)
+
+	// NOTE: This is the symbol table's final start location since there are no
+	// more executable image file segments.
+	elf.SymbolTableStart = start + segment.Size
+
+	elf.ProgramHeader = append(
+		elf.ProgramHeader,
+		Elf64ProgramHeaderEntry{
+			Type:            PT_LOAD,
+			Flags:           0b100, // r--
+			ContentOffset:   uint64(start),
+			VirtualAddress:  address,
+			PhysicalAddress: address,
+			FileImageSize:   uint64(segment.Size),
+			MemoryImageSize: uint64(segment.Size),
+			Alignment:       uint64(pageSize),
+		})
+
+	dynamicSize := uint64(len(segment.Entries)) * Elf64DynamicEntrySize
+	elf.ProgramHeader = append(
+		elf.ProgramHeader,
+		Elf64ProgramHeaderEntry{
+			Type:            PT_DYNAMIC,
+			Flags:           0b100, // r--
+			ContentOffset:   uint64(start + segment.DynamicOffset),
+			VirtualAddress:  address + uint64(segment.DynamicOffset),
+			PhysicalAddress: address + uint64(segment.DynamicOffset),
+			FileImageSize:   dynamicSize,
+			MemoryImageSize: dynamicSize,
+			Alignment:       8,
+		})
+
+	addSection := func(
+		name string,
+		sectionType uint32,
+		offset int64,
+		size uint64,
+		link uint16,
+		info uint32,
+		alignment uint64,
+		entrySize uint64,
+	) uint16 {
+		nameIdx, _ := elf.SectionStringTable.MaybeInsert(name)
+		idx := uint16(len(elf.SectionHeader))
+		elf.SectionHeader = append(
+			elf.SectionHeader,
+			Elf64SectionHeaderEntry{
+				NameIndex:        nameIdx,
+				Type:             sectionType,
+				Flags:            SHF_ALLOC,
+				Address:          address + uint64(offset),
+				Offset:           uint64(start + offset),
+				Size:             size,
+				Link:             uint32(link),
+				Info:             info,
+				AddressAlignment: alignment,
+				EntrySize:        entrySize,
+			})
+		return idx
+	}
+
+	if segment.InterpreterSize() > 0 {
+		addSection(
+			".interp",
+			SHT_PROGBITS,
+			segment.InterpreterOffset,
+			uint64(segment.InterpreterSize()),
+			0,
+			0,
+			1,
+			0)
+	}
+
+	stringTableIdx := addSection(
+		".dynstr",
+		SHT_STRTAB,
+		segment.StringTableOffset,
+		uint64(segment.StringTable.Size),
+		0,
+		0,
+		1,
+		0)
+
+	symbolTableIdx := uint16(len(elf.SectionHeader) + 1) // .dynsym follows .hash
+	addSection(
+		".hash",
+		SHT_HASH,
+		segment.HashTableOffset,
+		uint64(len(segment.HashTable))*4,
+		symbolTableIdx,
+		0,
+		8,
+		4)
+
+	addSection(
+		".dynsym",
+		SHT_DYNSYM,
+		segment.SymbolTableOffset,
+		uint64(len(segment.Symbols))*Elf64SymbolEntrySize,
+		stringTableIdx,
+		1, // The null symbol is the only local symbol.
+		8,
+		Elf64SymbolEntrySize)
+
+	if len(segment.Relocations) > 0 {
+		addSection(
+			".rela.dyn",
+			SHT_RELA,
+			segment.RelocationsOffset,
+			uint64(len(segment.Relocations))*Elf64RelocationEntrySize,
+			symbolTableIdx,
+			0,
+			8,
+			Elf64RelocationEntrySize)
+	}
+
+	addSection(
+		".dynamic",
+		SHT_DYNAMIC,
+		segment.DynamicOffset,
+		dynamicSize,
+		stringTableIdx,
+		0,
+		8,
+		Elf64DynamicEntrySize)
+
+	return nil
+}
+
+// Add the imported symbols to the dynamic symbol table, and add the imports'
+// dynamic relocations.
+func (elf *ElfWriter) addImports() error {
+	if len(elf.Imports) == 0 {
+		return nil
+	}
+
+	if elf.ElfMachineArchitecture != EM_X86_64 {
+		return fmt.Errorf(
+			"unsupported elf machine architecture (%d)",
+			elf.ElfMachineArchitecture)
+	}
+
+	segment := &elf.DynamicSegment
+	for _, entry := range elf.Imports {
+		segment.AddNeededLibrary(entry.Library)
+
+		var symbol Elf64SymbolEntry
+		var relocationType uint32
+		switch entry.Kind {
+		case layout.FunctionKind:
+			symbol = Elf64SymbolEntry{
+				Info:         functionSymbolInfo,
+				Visibility:   STV_DEFAULT,
+				SectionIndex: SHN_UNDEF,
+			}
+			relocationType = R_X86_64_GLOB_DAT
+		case layout.ObjectKind:
+			if elf.IsSharedLibrary {
+				return fmt.Errorf(
+					"cannot import object (%s) into shared library",
+					entry.Name)
+			}
+
+			// NOTE: the copy relocated object is defined by the executable.
+			symbol = Elf64SymbolEntry{
+				Info:         objectSymbolInfo,
+				Visibility:   STV_DEFAULT,
+				SectionIndex: elf.BSSIndex,
+				Value:        elf.BaseAddress + uint64(entry.Offset),
+				Size:         uint64(entry.Size),
+			}
+			relocationType = R_X86_64_COPY
+		default:
+			return fmt.Errorf(
+				"unsupported import (%s) kind (%s)",
+				entry.Name,
+				entry.Kind)
+		}
+
+		symbolIdx := segment.AddSymbol(entry.Name, symbol)
+		segment.Relocations = append(
+			segment.Relocations,
+			Elf64RelocationEntry{
+				Offset: elf.BaseAddress + uint64(entry.Offset),
+				Info:   uint64(symbolIdx)<<32 | uint64(relocationType),
+			})
+	}
+
+	return nil
+}
+
+// Add the exported symbols to the dynamic symbol table.
+func (elf *ElfWriter) addExports() error {
+	segment := &elf.DynamicSegment
+	for _, symbol := range elf.Definitions.Symbols {
+		if !symbol.IsExported ||
+			symbol.Binding == layout.LocalBinding ||
+			symbol.Visibility == layout.HiddenVisibility {
+
+			continue
+		}
+
+		// NOTE: executables copy relocate exported objects, which requires the
+		// library's own references to the objects to go through the GOT.
+		if symbol.Kind == layout.ObjectKind {
+			return fmt.Errorf(
+				"cannot export object symbol (%s) from shared library. "+
+					"exporting objects is not supported",
+				symbol.Name)
+		}
+
+		entry, err := elf.convertSymbol(symbol)
+		if err != nil {
+			return err
+		}
+
+		segment.AddSymbol(symbol.Name, entry)
+	}
+
+	return nil
+}
+
 // PHDR
+// INTERP
 // LOAD (elf header page)
 // ...
+// GNU_RELRO
 // GNU_STACK
 func (elf *ElfWriter) updateMetadataHeaderEntries() {
+	memoryPageSize := uint64(elf.MemoryPageSize)
+
+	// GNU_RELRO
+	//
+	// NOTE: the dynamic linker only write protects whole memory pages.
+	if elf.HasReadOnlyRelocations {
+		start := uint64(elf.ReadOnlySegmentStart)
+		size := uint64(elf.ReadOnlyData.Size)
+		size = ((size + memoryPageSize - 1) / memoryPageSize) * memoryPageSize
+		elf.ProgramHeader = append(
+			elf.ProgramHeader,
+			Elf64ProgramHeaderEntry{
+				Type:            PT_GNU_RELRO,
+				Flags:           0b100, // r--
+				ContentOffset:   start,
+				VirtualAddress:  elf.BaseAddress + start,
+				PhysicalAddress: elf.BaseAddress + start,
+				FileImageSize:   size,
+				MemoryImageSize: size,
+				Alignment:       1,
+			})
+	}
+
 	// GNU_STACK
 	elf.ProgramHeader = append(
 		elf.ProgramHeader,
@@ -317,14 +898,31 @@ func (elf *ElfWriter) updateMetadataHeaderEntries() {
 			Alignment:       8,
 		})
 
+	// INTERP
+	if elf.hasInterpreter() {
+		segment := elf.DynamicSegment
+		start := uint64(segment.Start + segment.InterpreterOffset)
+		address := segment.Address + uint64(segment.InterpreterOffset)
+		size := uint64(segment.InterpreterSize())
+		elf.ProgramHeader[1] = Elf64ProgramHeaderEntry{
+			Type:            PT_INTERP,
+			Flags:           0b100, // r--
+			ContentOffset:   start,
+			VirtualAddress:  address,
+			PhysicalAddress: address,
+			FileImageSize:   size,
+			MemoryImageSize: size,
+			Alignment:       1,
+		}
+	}
+
 	// LOAD (elf header page)
-	memoryPageSize := uint64(elf.MemoryPageSize)
-	elf.ProgramHeader[1] = Elf64ProgramHeaderEntry{
+	elf.ProgramHeader[elf.headerLoadIndex()] = Elf64ProgramHeaderEntry{
 		Type:            PT_LOAD,
 		Flags:           0b100, // r--
 		ContentOffset:   0,
-		VirtualAddress:  elf.VirtualAddressStart,
-		PhysicalAddress: elf.VirtualAddressStart,
+		VirtualAddress:  elf.BaseAddress,
+		PhysicalAddress: elf.BaseAddress,
 		FileImageSize:   memoryPageSize,
 		MemoryImageSize: memoryPageSize,
 		Alignment:       memoryPageSize,
@@ -336,8 +934,8 @@ func (elf *ElfWriter) updateMetadataHeaderEntries() {
 		Type:            PT_PHDR,
 		Flags:           0b100, // r--
 		ContentOffset:   Elf64HeaderSize,
-		VirtualAddress:  elf.VirtualAddressStart + Elf64HeaderSize,
-		PhysicalAddress: elf.VirtualAddressStart + Elf64HeaderSize,
+		VirtualAddress:  elf.BaseAddress + Elf64HeaderSize,
+		PhysicalAddress: elf.BaseAddress + Elf64HeaderSize,
 		FileImageSize:   size,
 		MemoryImageSize: size,
 		Alignment:       memoryPageSize,
@@ -362,9 +960,7 @@ func (elf *ElfWriter) addSymbolTableHeaderEntry() {
 		//
 		// One greater than the symbol table index of the last local symbol
 		// (binding STB_LOCAL).
-		//
-		// This is always zero since we only generate global symbols.
-		Info:             0,
+		Info:             uint32(elf.NumLocalSymbols),
 		AddressAlignment: 8,
 		EntrySize:        Elf64SymbolEntrySize,
 	}
@@ -423,6 +1019,11 @@ func (elf ElfWriter) WriteTo(writer io.Writer) (int64, error) {
 		return 0, err
 	}
 
+	numWritten, err = elf.maybeWriteDynamicSegment(writer, numWritten)
+	if err != nil {
+		return 0, err
+	}
+
 	numWritten, err = elf.writeSymbolTable(writer, numWritten)
 	if err != nil {
 		return 0, err
@@ -546,6 +1147,34 @@ func (elf ElfWriter) writeExecutableImage(
 	return numWritten, nil
 }
 
+func (elf ElfWriter) maybeWriteDynamicSegment(
+	writer io.Writer,
+	numWritten int64,
+) (
+	int64,
+	error,
+) {
+	if !elf.HasDynamicSegment {
+		return numWritten, nil
+	}
+
+	padding := elf.DynamicSegment.Start - numWritten
+	if padding > 0 {
+		n, err := writer.Write(make([]byte, padding))
+		if err != nil {
+			return 0, fmt.Errorf("failed to write dynamic segment: %w", err)
+		}
+		numWritten += int64(n)
+	}
+
+	n, err := elf.DynamicSegment.WriteTo(writer)
+	if err != nil {
+		return 0, err
+	}
+
+	return numWritten + n, nil
+}
+
 func (elf ElfWriter) convertSymbol(
 	symbol *layout.Symbol,
 ) (
@@ -557,16 +1186,9 @@ func (elf ElfWriter) convertSymbol(
 		panic("should never happen")
 	}
 
-	var symbolInfo byte
-	switch symbol.Kind {
-	case layout.FunctionKind:
-		symbolInfo = functionSymbolInfo
-	case layout.ObjectKind:
-		symbolInfo = objectSymbolInfo
-	default:
-		return Elf64SymbolEntry{}, fmt.Errorf(
-			"unsupported symbol kind (%s)",
-			symbol.Kind)
+	symbolInfo, visibility, err := convertSymbolInfo(symbol)
+	if err != nil {
+		return Elf64SymbolEntry{}, err
 	}
 
 	sectionIdx := uint16(0)
@@ -592,13 +1214,74 @@ func (elf ElfWriter) convertSymbol(
 	return Elf64SymbolEntry{
 		NameIndex:    nameIdx,
 		Info:         symbolInfo,
-		Visibility:   STV_DEFAULT,
+		Visibility:   visibility,
 		SectionIndex: sectionIdx,
-		Value:        elf.VirtualAddressStart + uint64(symbol.Offset),
+		Value:        elf.BaseAddress + uint64(symbol.Offset),
 		Size:         uint64(symbol.Size),
 	}, nil
 }
 
+// Returns the symbol's st_info (binding and type) and st_other (visibility).
+func convertSymbolInfo(symbol *layout.Symbol) (byte, byte, error) {
+	var symbolType byte
+	switch symbol.Kind {
+	case layout.FunctionKind:
+		symbolType = STT_FUNC
+	case layout.ObjectKind:
+		symbolType = STT_OBJECT
+	default:
+		return 0, 0, fmt.Errorf("unsupported symbol kind (%s)", symbol.Kind)
+	}
+
+	var binding byte
+	switch symbol.Binding {
+	case layout.GlobalBinding:
+		binding = STB_GLOBAL
+	case layout.LocalBinding:
+		binding = STB_LOCAL
+	case layout.WeakBinding:
+		binding = STB_WEAK
+	default:
+		return 0, 0, fmt.Errorf(
+			"unsupported symbol binding (%s)",
+			symbol.Binding)
+	}
+
+	var visibility byte
+	switch symbol.Visibility {
+	case layout.DefaultVisibility:
+		visibility = STV_DEFAULT
+	case layout.HiddenVisibility:
+		visibility = STV_HIDDEN
+	default:
+		return 0, 0, fmt.Errorf(
+			"unsupported symbol visibility (%s)",
+			symbol.Visibility)
+	}
+
+	return (binding << 4) | symbolType, visibility, nil
+}
+
+// Returns the symbols with local symbols listed prior to global symbols (as
+// required by the elf specification), and the number of local symbols.
+func sortLocalSymbolsFirst(symbols []*layout.Symbol) ([]*layout.Symbol, int) {
+	sorted := make([]*layout.Symbol, 0, len(symbols))
+	for _, symbol := range symbols {
+		if symbol.Binding == layout.LocalBinding {
+			sorted = append(sorted, symbol)
+		}
+	}
+
+	numLocals := len(sorted)
+	for _, symbol := range symbols {
+		if symbol.Binding != layout.LocalBinding {
+			sorted = append(sorted, symbol)
+		}
+	}
+
+	return sorted, numLocals
+}
+
 func (elf ElfWriter) writeSymbolTable(
 	writer io.Writer,
 	numWritten int64,
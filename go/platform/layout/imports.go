@@ -0,0 +1,176 @@
+package layout
+
+import (
+	"fmt"
+)
+
+// A symbol imported from a shared library, which is resolved by the dynamic
+// linker at load time.
+//
+// References to an imported function are resolved to an indirect jump stub
+// (see ArchitectureConfig.ImportStub) in .text, which jumps to the address
+// stored in the function's global offset table entry in .data.
+//
+// References to an imported object are resolved to the object's copy in
+// .bss.  The dynamic linker copies the object's initial value into the image
+// at load time (i.e., copy relocation).
+type Import struct {
+	Kind SymbolKind // FunctionKind or ObjectKind
+
+	Name string
+
+	// The shared library which defines the symbol (e.g., "libc.so.6").
+	Library string
+
+	// Only used by ObjectKind.
+	Size int64
+
+	// The image offset of the function's global offset table entry, or the
+	// object's .bss copy.  Populated by ToExecutableImage.
+	Offset int64
+}
+
+// The suffix of the function's global offset table entry symbol name.
+const GlobalOffsetTableEntrySuffix = "@GOT"
+
+// Deduplicate the imports.  Duplicate imports must be identical.
+func mergeImports(list []*Import) ([]*Import, error) {
+	result := []*Import{}
+	imports := map[string]*Import{}
+	for _, entry := range list {
+		if entry.Kind != FunctionKind && entry.Kind != ObjectKind {
+			return nil, fmt.Errorf(
+				"invalid import %s. unsupported kind (%s)",
+				entry.Name,
+				entry.Kind)
+		}
+
+		existing, ok := imports[entry.Name]
+		if !ok {
+			imports[entry.Name] = entry
+			result = append(result, entry)
+			continue
+		}
+
+		if existing.Kind != entry.Kind ||
+			existing.Library != entry.Library ||
+			existing.Size != entry.Size {
+
+			return nil, fmt.Errorf("found conflicting imports (%s)", entry.Name)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Add imported functions' stubs to .text, the functions' global offset table
+// entries to .data, and imported objects' copies to .bss.  The returned
+// symbols' offsets are the imports' offsets once the segments are shifted.
+//
+// NOTE: .text, .data and .bss must not be padded / shifted prior to this
+// call.
+func (file *ObjectFile) addImports(
+	config Config,
+	imports []*Import,
+) (
+	[]*Symbol,
+	error,
+) {
+	defined := map[string]struct{}{}
+	for _, defs := range []Definitions{
+		file.Text.Definitions,
+		file.Init.Definitions,
+		file.ReadOnlyData.Definitions,
+		file.Data.Definitions,
+		file.BSS.Definitions,
+	} {
+		for _, symbol := range defs.Symbols {
+			defined[symbol.Name] = struct{}{}
+		}
+	}
+
+	stub := config.Architecture.ImportStub
+	alignment := config.Architecture.RegisterAlignment
+
+	symbols := make([]*Symbol, 0, len(imports))
+	for _, entry := range imports {
+		_, ok := defined[entry.Name]
+		if ok {
+			return nil, fmt.Errorf(
+				"imported symbol (%s) is also defined",
+				entry.Name)
+		}
+
+		if entry.Kind == ObjectKind {
+			file.BSS.Pad(alignment)
+
+			symbol := &Symbol{
+				Kind:    ObjectKind,
+				Section: BSSSection,
+				Name:    entry.Name,
+				Offset:  file.BSS.Size,
+				Size:    entry.Size,
+			}
+			file.BSS.Definitions.Symbols = append(
+				file.BSS.Definitions.Symbols,
+				symbol)
+			file.BSS.Size += entry.Size
+
+			symbols = append(symbols, symbol)
+			continue
+		}
+
+		if len(stub) == 0 {
+			return nil, fmt.Errorf(
+				"cannot import function (%s). import stub not supported",
+				entry.Name)
+		}
+
+		gotEntryName := entry.Name + GlobalOffsetTableEntrySuffix
+
+		file.Text.Definitions.Symbols = append(
+			file.Text.Definitions.Symbols,
+			&Symbol{
+				Kind:    FunctionKind,
+				Section: TextSection,
+				Name:    entry.Name,
+				Offset:  file.Text.Size,
+				Size:    int64(len(stub)),
+			})
+		file.Text.Relocations.Symbols = append(
+			file.Text.Relocations.Symbols,
+			&Relocation{
+				Name: gotEntryName,
+				Offset: file.Text.Size +
+					config.Architecture.ImportStubRelocationOffset,
+			})
+
+		content := make([]byte, len(stub))
+		copy(content, stub)
+		file.Text.Append(content)
+
+		err := file.Data.MaybePad(8, config.DataPadding)
+		if err != nil {
+			return nil, err
+		}
+
+		symbol := &Symbol{
+			Kind:    ObjectKind,
+			Section: ReadWriteDataSection,
+			Name:    gotEntryName,
+			Offset:  file.Data.Size,
+			Size:    8,
+		}
+		file.Data.Definitions.Symbols = append(
+			file.Data.Definitions.Symbols,
+			symbol)
+		file.Data.Append(make([]byte, 8)) // 64-bit address
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols, nil
+}
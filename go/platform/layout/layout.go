@@ -1,6 +1,9 @@
 // Assumptions:
-// - all relocations are position independent (i.e., use pc relative offset)
-//   and can be incrementally linked
+// - all code relocations are position independent (i.e., use pc relative
+//   offset) and can be incrementally linked
+// - position dependent relocations (absolute addresses and section offsets,
+//   only used by global data) are resolved when the executable image is
+//   created
 
 package layout
 
@@ -104,6 +107,10 @@ func (segment *Segment) SetRelocations(unresolved Relocations) {
 type SegmentBuilder struct {
 	Size     int64
 	Segments []Segment
+
+	// Read-only data (e.g., jump tables) emitted alongside a function's code.
+	// See AppendReadOnlyData.
+	ReadOnlyData *SegmentBuilder
 }
 
 func NewSegmentBuilder() *SegmentBuilder {
@@ -137,6 +144,67 @@ func (builder *SegmentBuilder) AppendBasicData(data []byte) {
 	builder.AppendData(data, Definitions{}, Relocations{})
 }
 
+// Append read-only data (e.g., jump tables) associated with the function's
+// code.  The data is placed in .rodata by ObjectFileBuilder.AppendFunction.
+//
+// The code and the data may reference each other's labels via pc relative
+// label relocations.  Since the sections are laid out independently, these
+// relocations are rewritten into symbol relocations by AppendFunction.
+func (builder *SegmentBuilder) AppendReadOnlyData(
+	data []byte,
+	defs Definitions,
+	relocs Relocations,
+) {
+	if builder.ReadOnlyData == nil {
+		builder.ReadOnlyData = NewSegmentBuilder()
+	}
+
+	builder.ReadOnlyData.AppendData(data, defs, relocs)
+}
+
+// Append a global object definition (for .rodata / .data).  The object's
+// embedded addresses are specified as absolute symbol relocations (relative
+// to the start of the object's content).  Returns the object's symbol (e.g.,
+// for setting the symbol's binding).
+func (builder *SegmentBuilder) AppendObject(
+	section Section,
+	name string,
+	content []byte,
+	embeddedAddresses []*Relocation,
+) *Symbol {
+	symbol := &Symbol{
+		Kind:    ObjectKind,
+		Section: section,
+		Name:    name,
+		Size:    int64(len(content)),
+	}
+
+	builder.AppendData(
+		content,
+		Definitions{
+			Symbols: []*Symbol{symbol},
+		},
+		Relocations{
+			Symbols: embeddedAddresses,
+		})
+
+	return symbol
+}
+
+// Pad the accumulated content to the alignment.
+func (builder *SegmentBuilder) Pad(alignment int64, padding []byte) error {
+	content := Content{Size: builder.Size}
+	err := content.MaybePad(alignment, padding)
+	if err != nil {
+		return err
+	}
+
+	if content.Size > builder.Size {
+		builder.AppendBasicData(content.DataChunks[0])
+	}
+	return nil
+}
+
 func (builder *SegmentBuilder) Finalize(
 	config ArchitectureConfig,
 ) (
@@ -147,9 +215,44 @@ func (builder *SegmentBuilder) Finalize(
 	if err != nil {
 		return Segment{}, err
 	}
+	relocations := MergeRelocations(builder.Segments...)
+
+	// Segment relative offsets are neither absolute addresses nor section
+	// offsets.  Hold back position dependent relocations until the executable
+	// image is created.
+	var positionDependent []*Relocation
+	if len(relocations.Symbols) > 0 {
+		relative := make([]*Relocation, 0, len(relocations.Symbols))
+		for _, relocation := range relocations.Symbols {
+			if relocation.Kind == PCRelativeRelocation {
+				relative = append(relative, relocation)
+			} else {
+				positionDependent = append(positionDependent, relocation)
+			}
+		}
+		relocations.Symbols = relative
+	}
+
+	// Weak definitions may be overridden by other segments' definitions.  Hold
+	// back references to weak symbols until the executable image is created.
+	for _, symbol := range defs.Symbols {
+		if symbol.Binding != WeakBinding {
+			continue
+		}
+
+		strong := make(map[string]*Symbol, len(symbols))
+		for name, symbol := range symbols {
+			if symbol.Binding != WeakBinding {
+				strong[name] = symbol
+			}
+		}
+		symbols = strong
+		break
+	}
+
 	merged := Segment{
 		Definitions: defs,
-		Relocations: MergeRelocations(builder.Segments...),
+		Relocations: relocations,
 	}
 
 	buffered := Content{}
@@ -165,11 +268,15 @@ func (builder *SegmentBuilder) Finalize(
 		merged.Content.Append(buffered.Flatten())
 	}
 
-	err = Link(&merged, labels, symbols, config.Relocator)
+	err = Link(&merged, labels, symbols, nil, config.Relocator)
 	if err != nil {
 		return Segment{}, err
 	}
 
+	merged.Relocations.Symbols = append(
+		merged.Relocations.Symbols,
+		positionDependent...)
+
 	return merged, nil
 }
 
@@ -209,21 +316,33 @@ func (builder *BSSSegmentBuilder) Append(segment BSSSegment) {
 	builder.Segments = append(builder.Segments, segment)
 }
 
-func (builder *BSSSegmentBuilder) AppendObject(name string, size int64) {
+func (builder *BSSSegmentBuilder) AppendObject(
+	name string,
+	size int64,
+) *Symbol {
+	symbol := &Symbol{
+		Kind:    ObjectKind,
+		Section: BSSSection,
+		Name:    name,
+		Size:    size,
+	}
+
 	builder.Append(
 		BSSSegment{
 			Size: size,
 			Definitions: Definitions{
-				Symbols: []*Symbol{
-					{
-						Kind:    ObjectKind,
-						Section: BSSSection,
-						Name:    name,
-						Size:    size,
-					},
-				},
+				Symbols: []*Symbol{symbol},
 			},
 		})
+
+	return symbol
+}
+
+func (builder *BSSSegmentBuilder) Pad(alignment int64) {
+	mod := builder.Size % alignment
+	if mod > 0 {
+		builder.Append(BSSSegment{Size: alignment - mod})
+	}
 }
 
 func (builder *BSSSegmentBuilder) Finalize() (BSSSegment, error) {
@@ -244,18 +363,389 @@ type ObjectFileBuilder struct {
 	ReadOnlyData SegmentBuilder
 	Data         SegmentBuilder
 	BSS          BSSSegmentBuilder
+
+	Imports []*Import
+
+	// Symbol names defined or referenced by the merged object files.
+	names map[string]struct{}
+
+	// The merged object files' local symbols (and their object files'
+	// references to the symbols), keyed by symbol name.
+	locals map[string]*mergedLocalSymbol
+
+	numRenamed int
+}
+
+type mergedLocalSymbol struct {
+	*Symbol
+	references []*Relocation
 }
 
 func NewObjectFileBuilder() ObjectFileBuilder {
 	return ObjectFileBuilder{}
 }
 
+// NOTE: The merged object file's local symbols (and the object file's
+// references to these symbols) are renamed whenever their names conflict with
+// other merged object files' symbols, or with the symbols defined / referenced
+// directly by the builder's segment builders (e.g., "helper" is renamed to
+// "helper.1").  Local symbols defined directly by the builder's segment
+// builders are never renamed.
 func (builder *ObjectFileBuilder) Merge(file ObjectFile) {
+	builder.renameLocalSymbols(file)
+
 	builder.Text.Append(file.Text)
 	builder.Init.Append(file.Init)
 	builder.ReadOnlyData.Append(file.ReadOnlyData)
 	builder.Data.Append(file.Data)
 	builder.BSS.Append(file.BSS)
+	builder.Imports = append(builder.Imports, file.Imports...)
+}
+
+func (builder *ObjectFileBuilder) renameLocalSymbols(file ObjectFile) {
+	if builder.names == nil {
+		builder.names = map[string]struct{}{}
+		builder.locals = map[string]*mergedLocalSymbol{}
+	}
+
+	symbols := []*Symbol{}
+	relocations := []*Relocation{}
+	for _, segment := range []Segment{
+		file.Text,
+		file.Init,
+		file.ReadOnlyData,
+		file.Data,
+	} {
+		symbols = append(symbols, segment.Definitions.Symbols...)
+		relocations = append(relocations, segment.Relocations.Symbols...)
+	}
+	symbols = append(symbols, file.BSS.Definitions.Symbols...)
+
+	locals := []*mergedLocalSymbol{}
+	fileLocals := map[string]*mergedLocalSymbol{}
+	names := []string{}
+	for _, symbol := range symbols {
+		if symbol.Binding == LocalBinding {
+			local := &mergedLocalSymbol{Symbol: symbol}
+			locals = append(locals, local)
+			fileLocals[symbol.Name] = local
+		} else {
+			names = append(names, symbol.Name)
+		}
+	}
+
+	for _, relocation := range relocations {
+		local, ok := fileLocals[relocation.Name]
+		if ok {
+			local.references = append(local.references, relocation)
+		} else {
+			names = append(names, relocation.Name)
+		}
+	}
+
+	for _, entry := range file.Imports {
+		names = append(names, entry.Name)
+	}
+
+	// Previously merged local symbols which conflict with the object file's
+	// global symbols / references are renamed.
+	for _, name := range names {
+		local, ok := builder.locals[name]
+		if ok {
+			delete(builder.locals, name)
+			builder.renameLocalSymbol(local)
+		}
+		builder.names[name] = struct{}{}
+	}
+
+	for _, local := range locals {
+		_, ok := builder.names[local.Name]
+		if ok {
+			builder.renameLocalSymbol(local)
+		} else {
+			builder.names[local.Name] = struct{}{}
+			builder.locals[local.Name] = local
+		}
+	}
+}
+
+// Renames the merged local symbols which conflict with the symbols defined /
+// referenced directly by the builder's segment builders.  Since data could be
+// appended to the segment builders at any time, this must be called prior to
+// resolving symbols.
+func (builder *ObjectFileBuilder) renameConflictingLocalSymbols() {
+	if len(builder.locals) == 0 {
+		return
+	}
+
+	merged := map[*Symbol]struct{}{}
+	references := map[*Relocation]struct{}{}
+	for _, local := range builder.locals {
+		merged[local.Symbol] = struct{}{}
+		for _, relocation := range local.references {
+			references[relocation] = struct{}{}
+		}
+	}
+
+	names := []string{}
+	for _, segments := range [][]Segment{
+		builder.Text.Segments,
+		builder.Init.Segments,
+		builder.ReadOnlyData.Segments,
+		builder.Data.Segments,
+	} {
+		for _, segment := range segments {
+			for _, symbol := range segment.Definitions.Symbols {
+				_, ok := merged[symbol]
+				if !ok {
+					names = append(names, symbol.Name)
+				}
+			}
+
+			for _, relocation := range segment.Relocations.Symbols {
+				_, ok := references[relocation]
+				if !ok {
+					names = append(names, relocation.Name)
+				}
+			}
+		}
+	}
+
+	for _, segment := range builder.BSS.Segments {
+		for _, symbol := range segment.Definitions.Symbols {
+			_, ok := merged[symbol]
+			if !ok {
+				names = append(names, symbol.Name)
+			}
+		}
+	}
+
+	for _, entry := range builder.Imports {
+		names = append(names, entry.Name)
+	}
+
+	conflicts := []*mergedLocalSymbol{}
+	for _, name := range names {
+		local, ok := builder.locals[name]
+		if ok {
+			delete(builder.locals, name)
+			conflicts = append(conflicts, local)
+		}
+		builder.names[name] = struct{}{}
+	}
+
+	for _, local := range conflicts {
+		builder.renameLocalSymbol(local)
+	}
+}
+
+func (builder *ObjectFileBuilder) renameLocalSymbol(local *mergedLocalSymbol) {
+	name := ""
+	for {
+		builder.numRenamed++
+		name = fmt.Sprintf("%s.%d", local.Name, builder.numRenamed)
+
+		_, ok := builder.names[name]
+		if !ok {
+			break
+		}
+	}
+
+	local.Name = name
+	for _, relocation := range local.references {
+		relocation.Name = name
+	}
+
+	builder.names[name] = struct{}{}
+	builder.locals[name] = local
+}
+
+// Finalizes the function's code segment builder, and appends the code to
+// .text, and the code's read-only data (if any) to .rodata.  The function's
+// labels are local to the function, and are not visible to other segments.
+//
+// Label references between the code and its read-only data are rewritten
+// into pc relative symbol relocations, against the code's function symbol and
+// against a local "<function>.rodata" symbol (defined at the start of the
+// read-only data) respectively.  These references require the code to define
+// exactly one non-weak function symbol.
+func (builder *ObjectFileBuilder) AppendFunction(
+	config Config,
+	function *SegmentBuilder,
+) error {
+	code, err := function.Finalize(config.Architecture)
+	if err != nil {
+		return err
+	}
+
+	readOnlyData := Segment{}
+	if function.ReadOnlyData != nil {
+		readOnlyData, err = function.ReadOnlyData.Finalize(config.Architecture)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(code.Relocations.Labels) > 0 ||
+		len(readOnlyData.Relocations.Labels) > 0 {
+
+		err := bindFunctionLabels(&code, &readOnlyData)
+		if err != nil {
+			return err
+		}
+	}
+
+	code.Definitions.Labels = nil
+	builder.Text.Append(code)
+
+	if readOnlyData.Size == 0 && len(readOnlyData.Definitions.Symbols) == 0 {
+		return nil
+	}
+
+	err = builder.ReadOnlyData.Pad(
+		config.Architecture.RegisterAlignment,
+		config.DataPadding)
+	if err != nil {
+		return err
+	}
+
+	readOnlyData.Definitions.Labels = nil
+	builder.ReadOnlyData.Append(readOnlyData)
+	return nil
+}
+
+// Rewrites the unresolved label relocations between the function's code and
+// its read-only data into symbol relocations.
+//
+// NOTE: pc relative relocations are linear in the symbol's address, hence
+// shifting the symbol by the label's offset is compensated by the addend.
+func bindFunctionLabels(code *Segment, readOnlyData *Segment) error {
+	var function *Symbol
+	for _, symbol := range code.Definitions.Symbols {
+		if symbol.Kind != FunctionKind {
+			continue
+		}
+
+		if function != nil {
+			return fmt.Errorf(
+				"cannot bind function labels. found multiple function symbols "+
+					"(%s, %s)",
+				function.Name,
+				symbol.Name)
+		}
+		function = symbol
+	}
+
+	if function == nil {
+		return fmt.Errorf("cannot bind function labels. function symbol not found")
+	}
+
+	if function.Binding == WeakBinding {
+		return fmt.Errorf(
+			"cannot bind function labels. function symbol (%s) is weak",
+			function.Name)
+	}
+
+	var readOnlyDataSymbol *Symbol
+	if len(code.Relocations.Labels) > 0 {
+		readOnlyDataSymbol = &Symbol{
+			Kind:    ObjectKind,
+			Section: ReadOnlyDataSection,
+			Name:    function.Name + ".rodata",
+			Size:    readOnlyData.Size,
+			Binding: LocalBinding,
+		}
+		readOnlyData.Definitions.Symbols = append(
+			readOnlyData.Definitions.Symbols,
+			readOnlyDataSymbol)
+	}
+
+	bind := func(
+		segment *Segment,
+		labels []*Symbol,
+		symbol *Symbol,
+	) error {
+		offsets := make(map[string]int64, len(labels))
+		for _, label := range labels {
+			offsets[label.Name] = label.Offset
+		}
+
+		for _, relocation := range segment.Relocations.Labels {
+			offset, ok := offsets[relocation.Name]
+			if !ok {
+				return fmt.Errorf("unresolved label (%s)", relocation.Name)
+			}
+
+			if relocation.Kind != PCRelativeRelocation {
+				return fmt.Errorf(
+					"cannot bind label (%s). unsupported relocation kind (%s)",
+					relocation.Name,
+					relocation.Kind)
+			}
+
+			relocation.Name = symbol.Name
+			relocation.Addend += offset - symbol.Offset
+		}
+
+		segment.Relocations.Symbols = append(
+			segment.Relocations.Symbols,
+			segment.Relocations.Labels...)
+		segment.Relocations.Labels = nil
+		return nil
+	}
+
+	err := bind(code, readOnlyData.Definitions.Labels, readOnlyDataSymbol)
+	if err != nil {
+		return err
+	}
+
+	return bind(readOnlyData, code.Definitions.Labels, function)
+}
+
+// Returns the names of symbols referenced, but not defined, by the merged
+// segments, in first reference order.  Symbols which are only weakly
+// referenced are excluded.
+func (builder *ObjectFileBuilder) UnresolvedSymbols() []string {
+	builder.renameConflictingLocalSymbols()
+
+	defined := map[string]struct{}{}
+	relocations := []*Relocation{}
+	for _, segments := range [][]Segment{
+		builder.Text.Segments,
+		builder.Init.Segments,
+		builder.ReadOnlyData.Segments,
+		builder.Data.Segments,
+	} {
+		for _, segment := range segments {
+			for _, symbol := range segment.Definitions.Symbols {
+				defined[symbol.Name] = struct{}{}
+			}
+			relocations = append(relocations, segment.Relocations.Symbols...)
+		}
+	}
+
+	for _, segment := range builder.BSS.Segments {
+		for _, symbol := range segment.Definitions.Symbols {
+			defined[symbol.Name] = struct{}{}
+		}
+	}
+
+	unresolved := []string{}
+	for _, relocation := range relocations {
+		if relocation.IsWeak {
+			continue
+		}
+
+		_, ok := defined[relocation.Name]
+		if ok {
+			continue
+		}
+
+		defined[relocation.Name] = struct{}{} // dedup
+		unresolved = append(unresolved, relocation.Name)
+	}
+
+	return unresolved
 }
 
 func (builder *ObjectFileBuilder) Finalize(
@@ -264,6 +754,8 @@ func (builder *ObjectFileBuilder) Finalize(
 	ObjectFile,
 	error,
 ) {
+	builder.renameConflictingLocalSymbols()
+
 	file := ObjectFile{}
 
 	text, err := builder.Text.Finalize(config.Architecture)
@@ -296,6 +788,12 @@ func (builder *ObjectFileBuilder) Finalize(
 	}
 	file.BSS = bss
 
+	imports, err := mergeImports(builder.Imports)
+	if err != nil {
+		return ObjectFile{}, err
+	}
+	file.Imports = imports
+
 	return file, nil
 }
 
@@ -305,6 +803,10 @@ type ObjectFile struct {
 	ReadOnlyData Segment
 	Data         Segment
 	BSS          BSSSegment
+
+	// Symbols imported from shared libraries.  Imported symbols' references
+	// are resolved when the executable image is created.
+	Imports []*Import
 }
 
 // NOTE: start symbol is not part of Config since each module may have its
@@ -315,23 +817,112 @@ func (file ObjectFile) ToExecutableImage(
 ) (
 	ExecutableImage,
 	error,
+) {
+	image, symbols, err := file.toImage(config, false)
+	if err != nil {
+		return ExecutableImage{}, err
+	}
+
+	start, ok := symbols[startSymbol]
+	if !ok || start.Kind != FunctionKind {
+		return ExecutableImage{}, fmt.Errorf(
+			"start function symbol (%s) not found",
+			startSymbol)
+	}
+	image.EntryPoint = start.Offset
+
+	return image, nil
+}
+
+// The shared library image has no entry point.  Instead, the .init function
+// is registered in the image's init array (see InitArrayStart), which is run
+// by the dynamic linker when the library is loaded.
+//
+// NOTE: the image has no fini array since compilation units have no
+// finalization function counterpart to InitFunction (i.e., there is nothing
+// to run when the library is unloaded).
+//
+// NOTE: shared libraries cannot import objects since copy relocations are
+// only supported by executables.
+func (file ObjectFile) ToSharedLibraryImage(
+	config Config,
+) (
+	ExecutableImage,
+	error,
+) {
+	image, _, err := file.toImage(config, true)
+	if err != nil {
+		return ExecutableImage{}, err
+	}
+
+	return image, nil
+}
+
+func (file ObjectFile) toImage(
+	config Config,
+	isSharedLibrary bool,
+) (
+	ExecutableImage,
+	map[string]*Symbol,
+	error,
 ) {
 	pageSize := config.Architecture.MemoryPageSize
 	alignment := config.Architecture.RegisterAlignment
 	if pageSize%alignment != 0 {
-		return ExecutableImage{}, fmt.Errorf(
+		return ExecutableImage{}, nil, fmt.Errorf(
 			"memory page size (%d) not multiples of section alignment (%d)",
 			pageSize,
 			alignment)
 	}
 
 	if file.Text.Size == 0 {
-		return ExecutableImage{}, fmt.Errorf("empty .text segment")
+		return ExecutableImage{}, nil, fmt.Errorf("empty .text segment")
+	}
+
+	// NOTE: the imports are copied since their offsets are populated below.
+	imports := make([]*Import, 0, len(file.Imports))
+	for _, entry := range file.Imports {
+		copied := *entry
+		imports = append(imports, &copied)
+	}
+
+	if isSharedLibrary {
+		for _, entry := range imports {
+			if entry.Kind == ObjectKind {
+				return ExecutableImage{}, nil, fmt.Errorf(
+					"cannot import object (%s) into shared library",
+					entry.Name)
+			}
+		}
 	}
 
-	err := file.Text.MaybePad(alignment, config.InstructionPadding)
+	importSymbols, err := file.addImports(config, imports)
 	if err != nil {
-		return ExecutableImage{}, err
+		return ExecutableImage{}, nil, err
+	}
+
+	err = file.Text.MaybePad(alignment, config.InstructionPadding)
+	if err != nil {
+		return ExecutableImage{}, nil, err
+	}
+
+	// The init array's only entry is the .init function's address.
+	initArrayOffset := int64(-1)
+	if isSharedLibrary && file.Init.Size > 0 {
+		err = file.Data.MaybePad(8, config.DataPadding)
+		if err != nil {
+			return ExecutableImage{}, nil, err
+		}
+
+		initArrayOffset = file.Data.Size
+		file.Data.Relocations.Symbols = append(
+			file.Data.Relocations.Symbols,
+			&Relocation{
+				Kind:   AbsoluteRelocation,
+				Name:   config.InitSymbol,
+				Offset: initArrayOffset,
+			})
+		file.Data.Append(make([]byte, 8)) // 64-bit address
 	}
 
 	file.Init.Append(config.InitEpilogue)
@@ -347,17 +938,17 @@ func (file ObjectFile) ToExecutableImage(
 
 	err = file.Init.MaybePad(alignment, config.InstructionPadding)
 	if err != nil {
-		return ExecutableImage{}, err
+		return ExecutableImage{}, nil, err
 	}
 
 	err = file.ReadOnlyData.MaybePad(alignment, config.DataPadding)
 	if err != nil {
-		return ExecutableImage{}, err
+		return ExecutableImage{}, nil, err
 	}
 
 	err = file.Data.MaybePad(alignment, config.DataPadding)
 	if err != nil {
-		return ExecutableImage{}, err
+		return ExecutableImage{}, nil, err
 	}
 
 	file.BSS.Pad(config.Architecture.RegisterAlignment)
@@ -373,24 +964,35 @@ func (file ObjectFile) ToExecutableImage(
 	}
 
 	offset := config.ExecutableImageStartPage * pageSize
+	sectionStarts := make(map[Section]int64, 5)
 
 	image.ExecutableSegmentStart = offset
+	sectionStarts[TextSection] = offset
 	file.Text.ShiftAll(offset)
 	offset += image.Text.Size
 
+	sectionStarts[InitSection] = offset
 	file.Init.ShiftAll(offset)
 	offset += image.Init.Size
 
 	offset = ((offset + (pageSize - 1)) / pageSize) * pageSize
 	image.ReadOnlySegmentStart = offset
+	sectionStarts[ReadOnlyDataSection] = offset
 	file.ReadOnlyData.ShiftAll(offset)
 	offset += image.ReadOnlyData.Size
 
 	offset = ((offset + (pageSize - 1)) / pageSize) * pageSize
 	image.ReadWriteSegmentStart = offset
+	sectionStarts[ReadWriteDataSection] = offset
 	file.Data.ShiftAll(offset)
 	offset += image.Data.Size
 
+	if initArrayOffset >= 0 {
+		image.InitArrayStart = image.ReadWriteSegmentStart + initArrayOffset
+		image.InitArraySize = 8
+	}
+
+	sectionStarts[BSSSection] = offset
 	file.BSS.ShiftAll(offset)
 
 	segments := []RelocatableInfo{
@@ -403,40 +1005,108 @@ func (file ObjectFile) ToExecutableImage(
 
 	defs, labels, symbols, err := MergeDefinitions(segments...)
 	if err != nil {
-		return ExecutableImage{}, err
+		return ExecutableImage{}, nil, err
 	}
 
 	if len(labels) > 0 {
-		return ExecutableImage{}, fmt.Errorf("unexpected labels in object file")
+		return ExecutableImage{}, nil, fmt.Errorf(
+			"unexpected labels in object file")
 	}
 
 	image.Definitions = defs
 	image.Relocations = MergeRelocations(segments...)
 
 	if len(image.Relocations.Labels) > 0 {
-		return ExecutableImage{}, fmt.Errorf(
+		return ExecutableImage{}, nil, fmt.Errorf(
 			"unexpected label relocations in object file")
 	}
 
-	start, ok := symbols[startSymbol]
-	if !ok || start.Kind != FunctionKind {
-		return ExecutableImage{}, fmt.Errorf(
-			"start function symbol (%s) not found",
-			startSymbol)
+	// Unresolved weak references are resolved to null addresses (i.e., the
+	// relocated value is the addend).  Null addresses are never rebased.
+	//
+	// NOTE: the weak references are relocated directly rather than through the
+	// symbol table since strong references to the same undefined symbol must
+	// remain unresolved.
+	var failures []*RelocationError
+	remaining := make([]*Relocation, 0, len(image.Relocations.Symbols))
+	for _, relocation := range image.Relocations.Symbols {
+		_, ok := symbols[relocation.Name]
+		if ok || !relocation.IsWeak {
+			remaining = append(remaining, relocation)
+			continue
+		}
+
+		switch relocation.Kind {
+		case AbsoluteRelocation, Absolute32Relocation:
+		case PCRelativeRelocation:
+			return ExecutableImage{}, nil, fmt.Errorf(
+				"cannot resolve undefined weak symbol (%s) pc relative reference",
+				relocation.Name)
+		default:
+			return ExecutableImage{}, nil, fmt.Errorf(
+				"cannot resolve undefined weak symbol (%s) %s reference",
+				relocation.Name,
+				relocation.Kind)
+		}
+
+		null := &Symbol{
+			Kind:   ObjectKind,
+			Name:   relocation.Name,
+			Offset: 0,
+		}
+
+		err := relocate(&image, relocation, null, config.Architecture.Relocator)
+		if err != nil {
+			failures = append(failures, newRelocationError(image, relocation, err))
+		}
 	}
-	image.EntryPoint = start.Offset
 
-	err = Link(&image, labels, symbols, config.Architecture.Relocator)
-	if err != nil {
-		return ExecutableImage{}, err
+	if len(remaining) == 0 {
+		remaining = nil
+	}
+	image.Relocations.Symbols = remaining
+
+	// NOTE: absolute relocations are resolved relative to the start of the
+	// image.  The executable writer must rebase these addresses by the image's
+	// load address.
+	for _, relocation := range image.Relocations.Symbols {
+		switch relocation.Kind {
+		case AbsoluteRelocation:
+			image.AbsoluteAddresses = append(
+				image.AbsoluteAddresses,
+				relocation.Offset)
+		case Absolute32Relocation:
+			image.Absolute32Addresses = append(
+				image.Absolute32Addresses,
+				relocation.Offset)
+		}
 	}
 
-	if len(image.Relocations.Symbols) > 0 {
-		return ExecutableImage{}, fmt.Errorf(
-			"unresolved symbol relocations in object file")
+	if len(imports) > 0 {
+		for idx, entry := range imports {
+			entry.Offset = importSymbols[idx].Offset
+		}
+		image.Imports = imports
 	}
 
-	return image, nil
+	failures = append(
+		failures,
+		linkAll(
+			&image,
+			labels,
+			symbols,
+			sectionStarts,
+			config.Architecture.Relocator)...)
+
+	for _, relocation := range image.Relocations.Symbols {
+		failures = append(failures, newRelocationError(image, relocation, nil))
+	}
+
+	if len(failures) > 0 {
+		return ExecutableImage{}, nil, &LinkError{Errors: failures}
+	}
+
+	return image, symbols, nil
 }
 
 type ExecutableImage struct {
@@ -458,6 +1128,23 @@ type ExecutableImage struct {
 
 	Definitions
 	Relocations
+
+	// Image offsets of embedded absolute addresses (from absolute relocations).
+	// The addresses are relative to the start of the image.
+	AbsoluteAddresses []int64
+
+	// Same as AbsoluteAddresses, except the embedded addresses are 32-bit
+	// (from absolute 32 relocations).
+	Absolute32Addresses []int64
+
+	// Symbols imported from shared libraries, with image relative offsets.
+	Imports []*Import
+
+	// The image offset and size of the init array, which holds the .init
+	// function's address.  Only populated for shared library images with
+	// non-empty .init.
+	InitArrayStart int64
+	InitArraySize  int64
 }
 
 func (image ExecutableImage) ShiftAll(offset int64) {
@@ -2,6 +2,7 @@ package layout
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/pattyshack/gt/testing/expect"
@@ -27,10 +28,23 @@ var (
 type testRelocator struct{}
 
 func (r testRelocator) Relocate(
+	relocation *Relocation,
 	symbol *Symbol,
-	startOffset int64,
 	snippet []byte,
 ) error {
+	startOffset := relocation.Offset
+	switch relocation.Kind {
+	case PCRelativeRelocation:
+	case AbsoluteRelocation:
+		return r.relocateAbsolute(relocation, symbol, snippet)
+	case Absolute32Relocation:
+		return r.relocateValue(symbol.Offset+relocation.Addend, 4, snippet)
+	case SectionOffsetRelocation:
+		return r.relocateValue(symbol.Offset+relocation.Addend, 4, snippet)
+	default:
+		return fmt.Errorf("unsupported relocation kind (%s)", relocation.Kind)
+	}
+
 	switch symbol.Kind {
 	case BasicBlockKind:
 		return r.relocateBasicBlock(symbol, startOffset, snippet)
@@ -100,6 +114,32 @@ func (testRelocator) relocateObject(
 	return nil
 }
 
+func (r testRelocator) relocateAbsolute(
+	relocation *Relocation,
+	symbol *Symbol,
+	snippet []byte,
+) error {
+	return r.relocateValue(symbol.Offset+relocation.Addend, 8, snippet)
+}
+
+func (testRelocator) relocateValue(
+	value int64,
+	size int,
+	snippet []byte,
+) error {
+	result := fmt.Sprintf("%d", value)
+	for len(result) < size {
+		result += "_"
+	}
+
+	if len(result) != size {
+		panic("should never happen in test")
+	}
+
+	copy(snippet, []byte(result))
+	return nil
+}
+
 func newTestRelocator() Relocator {
 	return testRelocator{}
 }
@@ -796,3 +836,1181 @@ func (LayoutSuite) TestObjectFileAndExecutableImage(t *testing.T) {
 
 	expect.Equal(t, Relocations{}, image.Relocations)
 }
+
+func (LayoutSuite) TestAbsoluteRelocation(t *testing.T) {
+	builder := NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte("text!"), // start = 10
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "textFunc",
+					Offset:  0,
+					Size:    5,
+				},
+			},
+		},
+		Relocations{})
+
+	builder.Data.AppendObject(
+		ReadWriteDataSection,
+		"table", // start = 30
+		[]byte("tbl ________ ________;"),
+		[]*Relocation{
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "textFunc",
+				Offset: 4,
+			},
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "table",
+				Offset: 13,
+			},
+		})
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	// Absolute relocations are not resolved by segment finalization, even when
+	// the symbol is defined within the segment.
+	expect.Equal(
+		t,
+		"tbl ________ ________;",
+		string(file.Data.Content.Flatten()))
+	expect.Equal(
+		t,
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    ObjectKind,
+					Section: ReadWriteDataSection,
+					Name:    "table",
+					Offset:  0,
+					Size:    22,
+				},
+			},
+		},
+		file.Data.Definitions)
+	expect.Equal(
+		t,
+		Relocations{
+			Symbols: []*Relocation{
+				{
+					Kind:   AbsoluteRelocation,
+					Name:   "textFunc",
+					Offset: 4,
+				},
+				{
+					Kind:   AbsoluteRelocation,
+					Name:   "table",
+					Offset: 13,
+				},
+			},
+		},
+		file.Data.Relocations)
+
+	image, err := file.ToExecutableImage(testConfig, "textFunc")
+	expect.Nil(t, err)
+
+	expect.Equal(t, 10, image.ExecutableSegmentStart)
+	expect.Equal(t, 30, image.ReadWriteSegmentStart)
+	expect.Equal(
+		t,
+		"tbl 10______ 30______;###",
+		string(image.Data.Flatten()))
+	expect.Equal(t, []int64{30 + 4, 30 + 13}, image.AbsoluteAddresses)
+	expect.Equal(t, Relocations{}, image.Relocations)
+}
+
+func (LayoutSuite) TestAbsolute32AndSectionOffsetRelocations(t *testing.T) {
+	builder := NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte("text!"), // start = 10
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "textFunc",
+					Offset:  0,
+					Size:    5,
+				},
+			},
+		},
+		Relocations{})
+
+	builder.Data.AppendObject(ReadWriteDataSection, "prefix", []byte("pp"), nil)
+	builder.Data.AppendObject(
+		ReadWriteDataSection,
+		"table", // start = 32
+		[]byte("tbl ____ ____ ________;"),
+		[]*Relocation{
+			{
+				Kind:   Absolute32Relocation,
+				Name:   "textFunc",
+				Offset: 4,
+				Addend: 1,
+			},
+			{
+				Kind:   SectionOffsetRelocation,
+				Name:   "table",
+				Offset: 9,
+			},
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "textFunc",
+				Offset: 14,
+				Addend: 2,
+			},
+		})
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	// Position dependent relocations are not resolved by segment finalization,
+	// even when the symbol is defined within the segment.
+	expect.Equal(
+		t,
+		"pptbl ____ ____ ________;",
+		string(file.Data.Content.Flatten()))
+	expect.Equal(t, 3, len(file.Data.Relocations.Symbols))
+
+	image, err := file.ToExecutableImage(testConfig, "textFunc")
+	expect.Nil(t, err)
+
+	expect.Equal(t, 30, image.ReadWriteSegmentStart)
+
+	// textFunc + 1 (abs32), table's .data offset (section offset), and
+	// textFunc + 2 (abs64).
+	expect.Equal(
+		t,
+		"pptbl 11__ 2___ 12______;",
+		string(image.Data.Flatten()))
+	expect.Equal(t, []int64{30 + 16}, image.AbsoluteAddresses)
+	expect.Equal(t, []int64{30 + 6}, image.Absolute32Addresses)
+	expect.Equal(t, Relocations{}, image.Relocations)
+}
+
+func (LayoutSuite) TestLinkStraddlingRelocation(t *testing.T) {
+	segment := Segment{
+		Content: Content{
+			Size: 11,
+			DataChunks: [][]byte{
+				[]byte("ab XXX"),
+				[]byte("XXXF\n"),
+			},
+		},
+		Relocations: Relocations{
+			Symbols: []*Relocation{
+				{
+					Name:   "target",
+					Offset: 3,
+				},
+			},
+		},
+	}
+
+	symbols := map[string]*Symbol{
+		"target": {
+			Kind:    FunctionKind,
+			Section: TextSection,
+			Name:    "target",
+			Offset:  20,
+			Size:    1,
+		},
+	}
+
+	err := Link(&segment, nil, symbols, nil, newTestRelocator())
+	expect.Nil(t, err)
+
+	expect.Equal(t, Relocations{}, segment.Relocations)
+	expect.Equal(t, "ab 9__", string(segment.Content.DataChunks[0]))
+	expect.Equal(t, "___F\n", string(segment.Content.DataChunks[1]))
+}
+
+func (LayoutSuite) TestLinkErrors(t *testing.T) {
+	segment := Segment{
+		Content: Content{
+			Size: 10,
+			DataChunks: [][]byte{
+				[]byte("XXXXXXF\n!!"),
+			},
+		},
+		Definitions: Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "main",
+					Offset:  0,
+					Size:    8,
+				},
+			},
+		},
+		Relocations: Relocations{
+			Symbols: []*Relocation{
+				{
+					Kind:   RelocationKind("bogus"),
+					Name:   "target",
+					Offset: 0,
+				},
+				{
+					Name:   "unresolved",
+					Offset: 0,
+				},
+				{
+					Name:   "target",
+					Offset: 100,
+				},
+			},
+		},
+	}
+
+	symbols := map[string]*Symbol{
+		"target": {
+			Kind:    FunctionKind,
+			Section: TextSection,
+			Name:    "target",
+			Offset:  20,
+			Size:    1,
+		},
+	}
+
+	err := Link(&segment, nil, symbols, nil, newTestRelocator())
+	expect.Error(
+		t,
+		err,
+		"found 2 link errors:\n"+
+			"  failed to relocate symbol (target) referenced by main+0x0 (.text): "+
+			"unsupported relocation kind (bogus)\n"+
+			"  failed to relocate symbol (target) referenced at offset 0x64: "+
+			"snippet out of range")
+
+	linkErr, ok := err.(*LinkError)
+	expect.True(t, ok)
+	expect.Equal(t, 2, len(linkErr.Errors))
+	expect.Equal(t, "main", linkErr.Errors[0].Referrer.Name)
+	expect.Nil(t, linkErr.Errors[1].Referrer)
+
+	// Unresolved relocations are kept for incremental linking.
+	expect.Equal(
+		t,
+		Relocations{
+			Symbols: []*Relocation{
+				{
+					Name:   "unresolved",
+					Offset: 0,
+				},
+			},
+		},
+		segment.Relocations)
+}
+
+func (LayoutSuite) TestUnresolvedSymbolErrors(t *testing.T) {
+	builder := NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("XXXXXXF\nXXXXXXF\n"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "main",
+					Offset:  0,
+					Size:    16,
+				},
+			},
+		},
+		Relocations{
+			Symbols: []*Relocation{
+				{
+					Name:   "foo",
+					Offset: 0,
+				},
+				{
+					Name:   "bar",
+					Offset: 8,
+				},
+			},
+		})
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	_, err = file.ToExecutableImage(testConfig, "main")
+	expect.Error(
+		t,
+		err,
+		"found 2 link errors:\n"+
+			"  unresolved symbol (foo) referenced by main+0x0 (.text)\n"+
+			"  unresolved symbol (bar) referenced by main+0x8 (.text)")
+}
+
+func (LayoutSuite) TestSegmentBuilderPad(t *testing.T) {
+	builder := NewSegmentBuilder()
+
+	err := builder.Pad(4, []byte("!"))
+	expect.Nil(t, err)
+	expect.Equal(t, 0, builder.Size)
+
+	builder.AppendBasicData([]byte("abcde"))
+
+	err = builder.Pad(4, []byte("!"))
+	expect.Nil(t, err)
+	expect.Equal(t, 8, builder.Size)
+
+	err = builder.Pad(4, []byte("!"))
+	expect.Nil(t, err)
+	expect.Equal(t, 8, builder.Size)
+
+	builder.AppendBasicData([]byte("f"))
+
+	err = builder.Pad(4, []byte("#$"))
+	expect.Error(t, err, "cannot pad content")
+
+	segment, err := builder.Finalize(testConfig.Architecture)
+	expect.Nil(t, err)
+	expect.Equal(t, "abcde!!!f", string(segment.Flatten()))
+
+	bss := &BSSSegmentBuilder{}
+	bss.AppendObject("foo", 3)
+	bss.Pad(4)
+	bss.AppendObject("bar", 4)
+	bss.Pad(4)
+	expect.Equal(t, 8, bss.Size)
+}
+
+func (LayoutSuite) TestAppendFunction(t *testing.T) {
+	newFunction := func(name string) *SegmentBuilder {
+		function := NewSegmentBuilder()
+		function.AppendData(
+			[]byte("lea"),
+			Definitions{
+				Symbols: []*Symbol{
+					{
+						Kind:    FunctionKind,
+						Section: TextSection,
+						Name:    name,
+						Size:    5,
+					},
+				},
+			},
+			Relocations{
+				Labels: []*Relocation{
+					{
+						Name:   "table",
+						Offset: 1,
+					},
+				},
+			})
+		function.AppendData(
+			[]byte("t!"),
+			Definitions{
+				Labels: []*Symbol{
+					{
+						Kind: BasicBlockKind,
+						Name: "target",
+					},
+				},
+			},
+			Relocations{})
+
+		function.AppendReadOnlyData([]byte("abc"), Definitions{}, Relocations{})
+		function.AppendReadOnlyData(
+			[]byte("1234"),
+			Definitions{
+				Labels: []*Symbol{
+					{
+						Kind: BasicBlockKind,
+						Name: "table",
+					},
+				},
+			},
+			Relocations{
+				Labels: []*Relocation{
+					{
+						Name:   "target",
+						Addend: 4,
+					},
+				},
+			})
+
+		return function
+	}
+
+	builder := NewObjectFileBuilder()
+
+	// The functions' labels are local to each function.
+	err := builder.AppendFunction(testConfig, newFunction("foo"))
+	expect.Nil(t, err)
+
+	err = builder.AppendFunction(testConfig, newFunction("bar"))
+	expect.Nil(t, err)
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	expect.Equal(t, "leat!leat!", string(file.Text.Flatten()))
+	expect.Equal(t, 0, len(file.Text.Definitions.Labels))
+	expect.Equal(t, 0, len(file.Text.Relocations.Labels))
+	expect.Equal(
+		t,
+		[]*Relocation{
+			{
+				Name:   "foo.rodata",
+				Offset: 1,
+				Addend: 3,
+			},
+			{
+				Name:   "bar.rodata",
+				Offset: 6,
+				Addend: 3,
+			},
+		},
+		file.Text.Relocations.Symbols)
+
+	// The read-only data are aligned to the register alignment.
+	expect.Equal(t, "abc1234###abc1234", string(file.ReadOnlyData.Flatten()))
+	expect.Equal(t, 0, len(file.ReadOnlyData.Definitions.Labels))
+	expect.Equal(
+		t,
+		[]*Symbol{
+			{
+				Kind:    ObjectKind,
+				Section: ReadOnlyDataSection,
+				Name:    "foo.rodata",
+				Size:    7,
+				Binding: LocalBinding,
+			},
+			{
+				Kind:    ObjectKind,
+				Section: ReadOnlyDataSection,
+				Name:    "bar.rodata",
+				Offset:  10,
+				Size:    7,
+				Binding: LocalBinding,
+			},
+		},
+		file.ReadOnlyData.Definitions.Symbols)
+	expect.Equal(
+		t,
+		[]*Relocation{
+			{
+				Name:   "foo",
+				Offset: 3,
+				Addend: 7,
+			},
+			{
+				Name:   "bar",
+				Offset: 13,
+				Addend: 7,
+			},
+		},
+		file.ReadOnlyData.Relocations.Symbols)
+}
+
+func (LayoutSuite) TestAppendFunctionErrors(t *testing.T) {
+	builder := NewObjectFileBuilder()
+
+	function := NewSegmentBuilder()
+	function.AppendData(
+		[]byte("lea"),
+		Definitions{},
+		Relocations{
+			Labels: []*Relocation{
+				{
+					Name:   "table",
+					Offset: 1,
+				},
+			},
+		})
+
+	err := builder.AppendFunction(testConfig, function)
+	expect.Error(t, err, "function symbol not found")
+
+	function.AppendData(
+		nil,
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "foo",
+					Size:    3,
+				},
+			},
+		},
+		Relocations{})
+
+	err = builder.AppendFunction(testConfig, function)
+	expect.Error(t, err, "unresolved label (table)")
+}
+
+func (LayoutSuite) TestImports(t *testing.T) {
+	config := testConfig
+	config.Architecture.ImportStub = []byte("jmp ________O\n")
+	config.Architecture.ImportStubRelocationOffset = 4
+
+	builder := NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte("call ______F\n"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "start",
+					Offset:  0,
+					Size:    13,
+				},
+			},
+		},
+		Relocations{
+			Symbols: []*Relocation{
+				{
+					Name:   "write",
+					Offset: 5,
+				},
+			},
+		})
+
+	builder.Data.AppendObject(
+		ReadWriteDataSection,
+		"errnoPtr",
+		[]byte("ptr ________O\n"),
+		[]*Relocation{
+			{
+				Name:   "errno",
+				Offset: 4,
+			},
+		})
+
+	builder.Imports = []*Import{
+		{
+			Kind:    FunctionKind,
+			Name:    "write",
+			Library: "libc.so.6",
+		},
+		{
+			Kind:    ObjectKind,
+			Name:    "errno",
+			Library: "libc.so.6",
+			Size:    4,
+		},
+		{
+			Kind:    FunctionKind,
+			Name:    "write",
+			Library: "libc.so.6",
+		},
+	}
+
+	file, err := builder.Finalize(config)
+	expect.Nil(t, err)
+	expect.Equal(t, 2, len(file.Imports))
+
+	image, err := file.ToExecutableImage(config, "start")
+	expect.Nil(t, err)
+
+	// The call is resolved to the write's stub, and the stub's jump is resolved
+	// to write's global offset table entry.
+	expect.Equal(t, 10, image.ExecutableSegmentStart)
+	expect.Equal(
+		t,
+		"call 0_____F\njmp 29______O\n!!!",
+		string(image.Text.Flatten()))
+
+	expect.Equal(t, 50, image.ReadWriteSegmentStart)
+	expect.Equal(
+		t,
+		"ptr 11______O\n##\x00\x00\x00\x00\x00\x00\x00\x00#",
+		string(image.Data.Flatten()))
+	expect.Equal(t, 5, image.BSSSize)
+
+	expect.Equal(
+		t,
+		[]*Import{
+			{
+				Kind:    FunctionKind,
+				Name:    "write",
+				Library: "libc.so.6",
+				Offset:  66,
+			},
+			{
+				Kind:    ObjectKind,
+				Name:    "errno",
+				Library: "libc.so.6",
+				Size:    4,
+				Offset:  75,
+			},
+		},
+		image.Imports)
+
+	// The object file's imports are not modified.
+	expect.Equal(t, 0, file.Imports[0].Offset)
+
+	builder.Imports = append(
+		builder.Imports,
+		&Import{
+			Kind:    ObjectKind,
+			Name:    "write",
+			Library: "libc.so.6",
+			Size:    8,
+		})
+
+	_, err = builder.Finalize(config)
+	expect.Error(t, err, "found conflicting imports (write)")
+
+	builder.Imports = []*Import{
+		{
+			Kind:    FunctionKind,
+			Name:    "start",
+			Library: "libc.so.6",
+		},
+	}
+
+	file, err = builder.Finalize(config)
+	expect.Nil(t, err)
+
+	_, err = file.ToExecutableImage(config, "start")
+	expect.Error(t, err, "imported symbol (start) is also defined")
+}
+
+func (LayoutSuite) TestSharedLibraryImage(t *testing.T) {
+	builder := NewObjectFileBuilder()
+
+	builder.Text.AppendData(
+		[]byte("func"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:       FunctionKind,
+					Section:    TextSection,
+					Name:       "libFunc",
+					Offset:     0,
+					Size:       4,
+					IsExported: true,
+				},
+			},
+		},
+		Relocations{})
+
+	builder.Init.AppendData(
+		[]byte("init"),
+		Definitions{},
+		Relocations{})
+
+	builder.Data.AppendBasicData([]byte("abc"))
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	image, err := file.ToSharedLibraryImage(testConfig)
+	expect.Nil(t, err)
+
+	expect.Equal(t, 0, image.EntryPoint)
+	expect.Equal(t, 10, image.ExecutableSegmentStart)
+	expect.Equal(t, 30, image.ReadWriteSegmentStart)
+
+	// The init array entry is aligned to 8 bytes, and holds .init's address.
+	expect.Equal(t, 30+8, image.InitArrayStart)
+	expect.Equal(t, 8, image.InitArraySize)
+	expect.Equal(t, "abc#####15______####", string(image.Data.Flatten()))
+	expect.Equal(t, []int64{30 + 8}, image.AbsoluteAddresses)
+
+	expect.Equal(
+		t,
+		&Symbol{
+			Kind:       FunctionKind,
+			Section:    TextSection,
+			Name:       "libFunc",
+			Offset:     10,
+			Size:       4,
+			IsExported: true,
+		},
+		image.Definitions.Symbols[0])
+
+	// Shared libraries without .init content do not have init arrays.
+	builder = NewObjectFileBuilder()
+	builder.Text.AppendBasicData([]byte("func"))
+
+	file, err = builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	image, err = file.ToSharedLibraryImage(testConfig)
+	expect.Nil(t, err)
+	expect.Equal(t, 0, image.InitArraySize)
+	expect.Equal(t, []int64(nil), image.AbsoluteAddresses)
+
+	// Shared libraries cannot import objects.
+	builder.Imports = []*Import{
+		{
+			Kind:    ObjectKind,
+			Name:    "errno",
+			Library: "libc.so.6",
+			Size:    4,
+		},
+	}
+
+	file, err = builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	_, err = file.ToSharedLibraryImage(testConfig)
+	expect.Error(t, err, "cannot import object (errno) into shared library")
+}
+
+func (LayoutSuite) TestMergeLocalSymbols(t *testing.T) {
+	newFile := func(
+		text string,
+		binding SymbolBinding,
+		pointer string,
+	) ObjectFile {
+		builder := NewObjectFileBuilder()
+		builder.Text.AppendData(
+			[]byte(text),
+			Definitions{
+				Symbols: []*Symbol{
+					{
+						Kind:    FunctionKind,
+						Section: TextSection,
+						Name:    "helper",
+						Offset:  0,
+						Size:    int64(len(text)),
+						Binding: binding,
+					},
+				},
+			},
+			Relocations{})
+
+		builder.Data.AppendObject(
+			ReadWriteDataSection,
+			pointer,
+			[]byte("________"),
+			[]*Relocation{
+				{
+					Kind:   AbsoluteRelocation,
+					Name:   "helper",
+					Offset: 0,
+				},
+			})
+
+		file, err := builder.Finalize(testConfig)
+		expect.Nil(t, err)
+		return file
+	}
+
+	file1 := newFile("aaaa", LocalBinding, "ptr1")
+	file2 := newFile("bbbb", LocalBinding, "ptr2")
+	file3 := newFile("cccc", GlobalBinding, "ptr3")
+
+	builder := NewObjectFileBuilder()
+
+	// file1's helper does not conflict with other symbols.
+	builder.Merge(file1)
+	expect.Equal(t, "helper", file1.Text.Definitions.Symbols[0].Name)
+
+	// file2's helper conflicts with file1's helper.
+	builder.Merge(file2)
+	expect.Equal(t, "helper", file1.Text.Definitions.Symbols[0].Name)
+	expect.Equal(t, "helper.1", file2.Text.Definitions.Symbols[0].Name)
+	expect.Equal(t, "helper.1", file2.Data.Relocations.Symbols[0].Name)
+
+	// file1's helper conflicts with file3's global helper.
+	builder.Merge(file3)
+	expect.Equal(t, "helper.2", file1.Text.Definitions.Symbols[0].Name)
+	expect.Equal(t, "helper.2", file1.Data.Relocations.Symbols[0].Name)
+	expect.Equal(t, "helper.1", file2.Text.Definitions.Symbols[0].Name)
+	expect.Equal(t, "helper", file3.Text.Definitions.Symbols[0].Name)
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(testConfig, "helper")
+	expect.Nil(t, err)
+
+	expect.Equal(t, 10+8, image.EntryPoint)
+	expect.Equal(
+		t,
+		"10______14______18______#",
+		string(image.Data.Flatten()))
+}
+
+func (LayoutSuite) TestMergeLocalSymbolsWithBuilderSymbols(t *testing.T) {
+	newLocalFile := func() ObjectFile {
+		builder := NewObjectFileBuilder()
+		builder.Text.AppendData(
+			[]byte("aaaa"),
+			Definitions{
+				Symbols: []*Symbol{
+					{
+						Kind:    FunctionKind,
+						Section: TextSection,
+						Name:    "helper",
+						Offset:  0,
+						Size:    4,
+						Binding: LocalBinding,
+					},
+				},
+			},
+			Relocations{})
+
+		builder.Data.AppendObject(
+			ReadWriteDataSection,
+			"ptr",
+			[]byte("________"),
+			[]*Relocation{
+				{
+					Kind:   AbsoluteRelocation,
+					Name:   "helper",
+					Offset: 0,
+				},
+			})
+
+		file, err := builder.Finalize(testConfig)
+		expect.Nil(t, err)
+		return file
+	}
+
+	appendGlobalHelper := func(builder *ObjectFileBuilder) {
+		builder.Text.AppendData(
+			[]byte("bbbb"),
+			Definitions{
+				Symbols: []*Symbol{
+					{
+						Kind:    FunctionKind,
+						Section: TextSection,
+						Name:    "helper",
+						Offset:  0,
+						Size:    4,
+					},
+				},
+			},
+			Relocations{})
+	}
+
+	check := func(builder *ObjectFileBuilder) {
+		file, err := builder.Finalize(testConfig)
+		expect.Nil(t, err)
+
+		names := map[string]int64{}
+		for _, symbol := range file.Text.Definitions.Symbols {
+			names[symbol.Name] = symbol.Offset
+		}
+		expect.Equal(t, 2, len(names))
+
+		image, err := file.ToExecutableImage(testConfig, "helper")
+		expect.Nil(t, err)
+
+		// The merged file's ptr still references the merged file's helper.
+		expect.Equal(t, 10+names["helper"], image.EntryPoint)
+		expect.Equal(
+			t,
+			fmt.Sprintf("%d", 10+names["helper.1"]),
+			strings.TrimRight(string(image.Data.Flatten()), "_#"))
+	}
+
+	// The builder's global helper is appended after the merge.
+	builder := NewObjectFileBuilder()
+	builder.Merge(newLocalFile())
+	appendGlobalHelper(&builder)
+	check(&builder)
+
+	// The builder's global helper is appended before the merge.
+	builder = NewObjectFileBuilder()
+	appendGlobalHelper(&builder)
+	builder.Merge(newLocalFile())
+	check(&builder)
+}
+
+func (LayoutSuite) TestWeakSymbols(t *testing.T) {
+	// file1 provides weak default definitions, and weakly references hook.
+	builder := NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("aaaa"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "handler",
+					Offset:  0,
+					Size:    4,
+					Binding: WeakBinding,
+				},
+			},
+		},
+		Relocations{})
+	builder.Text.AppendData(
+		[]byte("XXXXXXF\n"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "main",
+					Offset:  0,
+					Size:    8,
+				},
+			},
+		},
+		Relocations{
+			Symbols: []*Relocation{
+				{
+					Name:   "handler",
+					Offset: 0,
+				},
+			},
+		})
+	builder.Data.AppendData(
+		[]byte("wwwwwwww"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    ObjectKind,
+					Section: ReadWriteDataSection,
+					Name:    "config",
+					Offset:  0,
+					Size:    8,
+					Binding: WeakBinding,
+				},
+			},
+		},
+		Relocations{})
+	builder.Data.AppendObject(
+		ReadWriteDataSection,
+		"pointers",
+		[]byte("________________"),
+		[]*Relocation{
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "config",
+				Offset: 0,
+			},
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "hook",
+				Offset: 8,
+				Addend: 3,
+				IsWeak: true,
+			},
+		})
+
+	file1, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	// The reference to the weak handler is held back since the handler could
+	// be overridden by another object file.
+	expect.Equal(t, 1, len(file1.Text.Relocations.Symbols))
+	expect.Equal(t, "XXXXXXF\n", string(file1.Text.Content.Flatten()[4:]))
+
+	// file2 overrides the weak default definitions.
+	builder = NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("bbbb"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "handler",
+					Offset:  0,
+					Size:    4,
+				},
+			},
+		},
+		Relocations{})
+	builder.BSS.AppendObject("config", 8)
+
+	file2, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	builder = NewObjectFileBuilder()
+	builder.Merge(file1)
+	builder.Merge(file2)
+
+	// hook is only weakly referenced.
+	expect.Equal(t, []string{}, builder.UnresolvedSymbols())
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	image, err := file.ToExecutableImage(testConfig, "main")
+	expect.Nil(t, err)
+
+	expect.Equal(t, 14, image.EntryPoint)
+
+	// main (at 14) calls file2's handler (at 22).
+	expect.Equal(
+		t,
+		"aaaa0_____F\nbbbb!!!!",
+		string(image.Text.Flatten()))
+
+	// config resolves to file2's .bss object (at 65), and the unresolved weak
+	// hook reference resolves to null (plus the addend).
+	expect.Equal(
+		t,
+		"wwwwwwww65______3_______#",
+		string(image.Data.Flatten()))
+	expect.Equal(t, []int64{48}, image.AbsoluteAddresses)
+
+	numHandlers := 0
+	for _, symbol := range image.Definitions.Symbols {
+		switch symbol.Name {
+		case "handler":
+			numHandlers++
+			expect.Equal(t, GlobalBinding, symbol.Binding)
+			expect.Equal(t, 22, symbol.Offset)
+		case "config":
+			expect.Equal(t, BSSSection, symbol.Section)
+		}
+	}
+	expect.Equal(t, 1, numHandlers)
+}
+
+func (LayoutSuite) TestWeakAndStrongUndefinedReferences(t *testing.T) {
+	builder := NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("main"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "main",
+					Offset:  0,
+					Size:    4,
+				},
+			},
+		},
+		Relocations{})
+	builder.Data.AppendObject(
+		ReadWriteDataSection,
+		"pointers",
+		[]byte("________________"),
+		[]*Relocation{
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "missing",
+				Offset: 0,
+				IsWeak: true,
+			},
+			{
+				Kind:   AbsoluteRelocation,
+				Name:   "missing",
+				Offset: 8,
+			},
+		})
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	// Only the weak reference resolves to null.
+	_, err = file.ToExecutableImage(testConfig, "main")
+	expect.Error(
+		t,
+		err,
+		"unresolved symbol (missing) referenced by pointers+0x8 (.data)")
+}
+
+func (LayoutSuite) TestWeakSymbolResolutionErrors(t *testing.T) {
+	newWeakHandler := func() *Symbol {
+		return &Symbol{
+			Kind:    FunctionKind,
+			Section: TextSection,
+			Name:    "handler",
+			Offset:  0,
+			Size:    4,
+			Binding: WeakBinding,
+		}
+	}
+
+	// The overriding strong definition is validated.
+	builder := NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("aaaa"),
+		Definitions{Symbols: []*Symbol{newWeakHandler()}},
+		Relocations{})
+	builder.Text.AppendData(
+		[]byte("bbbb"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:       FunctionKind,
+					Section:    TextSection,
+					Name:       "handler",
+					Offset:     0,
+					Size:       4,
+					Visibility: "bogus",
+				},
+			},
+		},
+		Relocations{})
+
+	_, err := builder.Finalize(testConfig)
+	expect.Error(t, err, "invalid symbol handler. unsupported visibility")
+
+	// Local definitions never override weak definitions.
+	builder = NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("aaaa"),
+		Definitions{Symbols: []*Symbol{newWeakHandler()}},
+		Relocations{})
+	builder.Text.AppendData(
+		[]byte("bbbb"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "handler",
+					Offset:  0,
+					Size:    4,
+					Binding: LocalBinding,
+				},
+			},
+		},
+		Relocations{})
+
+	_, err = builder.Finalize(testConfig)
+	expect.Error(t, err, "found duplicate symbol (handler)")
+}
+
+func (LayoutSuite) TestWeakPCRelativeReference(t *testing.T) {
+	builder := NewObjectFileBuilder()
+	builder.Text.AppendData(
+		[]byte("XXXXXXF\n"),
+		Definitions{
+			Symbols: []*Symbol{
+				{
+					Kind:    FunctionKind,
+					Section: TextSection,
+					Name:    "main",
+					Offset:  0,
+					Size:    8,
+				},
+			},
+		},
+		Relocations{
+			Symbols: []*Relocation{
+				{
+					Name:   "hook",
+					Offset: 0,
+					IsWeak: true,
+				},
+			},
+		})
+
+	file, err := builder.Finalize(testConfig)
+	expect.Nil(t, err)
+
+	_, err = file.ToExecutableImage(testConfig, "main")
+	expect.Error(
+		t,
+		err,
+		"cannot resolve undefined weak symbol (hook) pc relative reference")
+}
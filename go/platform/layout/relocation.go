@@ -2,6 +2,7 @@ package layout
 
 import (
 	"fmt"
+	"strings"
 )
 
 type RelocatableInfo interface {
@@ -23,8 +24,14 @@ type Relocatable interface {
 }
 
 type Relocator interface {
-	// Modify the snippet with the relocated address.
-	Relocate(symbol *Symbol, startOffset int64, snippet []byte) error
+	// Modify the snippet with the relocated value, as specified by the
+	// relocation's kind and addend.  The snippet starts at relocation's offset.
+	// For SectionOffsetRelocation, the symbol's offset is relative to the start
+	// of the symbol's section.
+	//
+	// The relocator must return an error if the relocation kind is not
+	// supported by the architecture.
+	Relocate(relocation *Relocation, symbol *Symbol, snippet []byte) error
 }
 
 type Section string
@@ -46,13 +53,42 @@ const (
 	ObjectKind     = SymbolKind("object")
 )
 
+type SymbolBinding string
+
+const (
+	// Global symbols are visible to all object files.
+	GlobalBinding = SymbolBinding("")
+
+	// Local symbols are only visible within the defining object file.  Same
+	// named local symbols may be defined by different object files (see
+	// ObjectFileBuilder.Merge).
+	LocalBinding = SymbolBinding("local")
+
+	// Weak symbols are global symbols which may be overridden by a same named
+	// global symbol (i.e., strong beats weak).  When there are multiple weak
+	// definitions (and no global definition), the first definition is used.
+	WeakBinding = SymbolBinding("weak")
+)
+
+type SymbolVisibility string
+
+const (
+	DefaultVisibility = SymbolVisibility("")
+
+	// Hidden symbols are visible to other object files at link time, but are
+	// never exported by shared libraries.
+	HiddenVisibility = SymbolVisibility("hidden")
+)
+
 type Symbol struct {
 	Kind SymbolKind
 
 	Section // Not set for labels.
 
 	// NOTE: For compatibility with standards such as elf, symbol name must be
-	// unique regardless of symbol kind.
+	// unique regardless of symbol kind.  Local symbols' names are only unique
+	// within the object file, and weak symbols' names may be shared with other
+	// global / weak symbols.
 	Name string
 
 	// Relative to the start of the content segment.
@@ -60,6 +96,19 @@ type Symbol struct {
 
 	// Symbol's content value range [Offset, Offset + Size).  Not set for label.
 	Size int64
+
+	// Not set for labels.
+	Binding    SymbolBinding
+	Visibility SymbolVisibility
+
+	// When true, the symbol is exported via the shared library's dynamic symbol
+	// table.  Only used by shared libraries.  Local and hidden symbols are
+	// never exported.
+	//
+	// NOTE: exporting objects is not supported.  Executables copy relocate
+	// exported objects, but the library's own references are resolved
+	// PC-relatively to the library's copy rather than indirectly via the GOT.
+	IsExported bool
 }
 
 type Definitions struct {
@@ -106,6 +155,9 @@ func MergeDefinitions[T RelocatableInfo](
 	labels := make(map[string]*Symbol, numLabels)
 	symbols := make(map[string]*Symbol, numSymbols)
 
+	// symbol name -> merged.Symbols index
+	indices := make(map[string]int, numSymbols)
+
 	for _, relocatable := range relocatables {
 		defs := relocatable.Defs()
 
@@ -129,13 +181,6 @@ func MergeDefinitions[T RelocatableInfo](
 		}
 
 		for _, symbol := range defs.Symbols {
-			_, ok := symbols[symbol.Name]
-			if ok {
-				return Definitions{}, nil, nil, fmt.Errorf(
-					"found duplicate symbol (%s)",
-					symbol.Name)
-			}
-
 			if symbol.Kind != FunctionKind && symbol.Kind != ObjectKind {
 				return Definitions{}, nil, nil, fmt.Errorf(
 					"invalid symbol %s. unsupported kind (%s)",
@@ -143,7 +188,48 @@ func MergeDefinitions[T RelocatableInfo](
 					symbol.Kind)
 			}
 
+			if symbol.Binding != GlobalBinding &&
+				symbol.Binding != LocalBinding &&
+				symbol.Binding != WeakBinding {
+
+				return Definitions{}, nil, nil, fmt.Errorf(
+					"invalid symbol %s. unsupported binding (%s)",
+					symbol.Name,
+					symbol.Binding)
+			}
+
+			if symbol.Visibility != DefaultVisibility &&
+				symbol.Visibility != HiddenVisibility {
+
+				return Definitions{}, nil, nil, fmt.Errorf(
+					"invalid symbol %s. unsupported visibility (%s)",
+					symbol.Name,
+					symbol.Visibility)
+			}
+
+			existing, ok := symbols[symbol.Name]
+			if ok {
+				switch {
+				case symbol.Binding == LocalBinding ||
+					existing.Binding == LocalBinding:
+					// Local symbols never participate in weak resolution.
+				case symbol.Binding == WeakBinding:
+					// The existing definition takes precedence.
+					continue
+				case existing.Binding == WeakBinding:
+					// Strong beats weak.
+					symbols[symbol.Name] = symbol
+					merged.Symbols[indices[symbol.Name]] = symbol
+					continue
+				}
+
+				return Definitions{}, nil, nil, fmt.Errorf(
+					"found duplicate symbol (%s)",
+					symbol.Name)
+			}
+
 			symbols[symbol.Name] = symbol
+			indices[symbol.Name] = len(merged.Symbols)
 			merged.Symbols = append(merged.Symbols, symbol)
 		}
 	}
@@ -151,9 +237,47 @@ func MergeDefinitions[T RelocatableInfo](
 	return merged, labels, symbols, nil
 }
 
+type RelocationKind string
+
+const (
+	// The relocated value is the symbol's address relative to the instruction
+	// pointer (e.g., rel32 on amd64).
+	PCRelativeRelocation = RelocationKind("")
+
+	// The relocated value is the symbol's absolute address (e.g., abs64 on
+	// amd64).  This is used by global data which embeds addresses.
+	AbsoluteRelocation = RelocationKind("absolute")
+
+	// Same as AbsoluteRelocation, except the relocated value is 32-bit (e.g.,
+	// abs32 on amd64).  Not supported by position independent images.
+	Absolute32Relocation = RelocationKind("absolute 32")
+
+	// The relocated value is the symbol's offset relative to the start of the
+	// symbol's section (e.g., 32-bit offsets in debug info).
+	SectionOffsetRelocation = RelocationKind("section offset")
+)
+
+// NOTE: Only pc relative relocations are position independent.  The other
+// relocations are only resolved once the executable image's layout is final
+// (i.e., they are never resolved by segment finalization).
 type Relocation struct {
+	Kind RelocationKind
+
 	Name   string
 	Offset int64 // Offset relative to the start of the content segment.
+
+	// The relocated value is adjusted by the addend.  For pc relative
+	// relocations, the addend is relative to the end of the relocated value
+	// (i.e., the next instruction for rel32).  The content's original bytes at
+	// Offset are ignored.
+	Addend int64
+
+	// When true, the referenced symbol need not be defined.  Unresolved weak
+	// absolute references are resolved to null addresses (i.e., the relocated
+	// value is the addend) when the executable image is created; other
+	// unresolved weak references are not supported.  Weak references never
+	// pull in archive members (see ObjectFileBuilder.UnresolvedSymbols).
+	IsWeak bool
 }
 
 type Relocations struct {
@@ -198,14 +322,157 @@ func MergeRelocations[T RelocatableInfo](relocatables ...T) Relocations {
 	return merged
 }
 
+// The maximum relocated value size (in bytes) supported by the linker (e.g.,
+// abs64).  Relocated values may straddle the content's data chunk boundaries.
+const MaxRelocationSize = 8
+
+// A relocation which could not be linked.
+type RelocationError struct {
+	*Relocation
+
+	// The function / object definition which contains the relocation.  nil if
+	// the relocation is not within any definition.
+	Referrer *Symbol
+
+	// nil if the relocation's symbol is unresolved.
+	Err error
+}
+
+func newRelocationError(
+	content RelocatableInfo,
+	relocation *Relocation,
+	err error,
+) *RelocationError {
+	var referrer *Symbol
+	for _, symbol := range content.Defs().Symbols {
+		if symbol.Offset <= relocation.Offset &&
+			relocation.Offset < symbol.Offset+symbol.Size {
+
+			referrer = symbol
+			break
+		}
+	}
+
+	return &RelocationError{
+		Relocation: relocation,
+		Referrer:   referrer,
+		Err:        err,
+	}
+}
+
+func (err *RelocationError) Error() string {
+	origin := fmt.Sprintf("at offset %#x", err.Offset)
+	if err.Referrer != nil {
+		origin = fmt.Sprintf(
+			"by %s+%#x (%s)",
+			err.Referrer.Name,
+			err.Offset-err.Referrer.Offset,
+			err.Referrer.Section)
+	}
+
+	if err.Err == nil {
+		return fmt.Sprintf("unresolved symbol (%s) referenced %s", err.Name, origin)
+	}
+
+	return fmt.Sprintf(
+		"failed to relocate symbol (%s) referenced %s: %s",
+		err.Name,
+		origin,
+		err.Err)
+}
+
+func (err *RelocationError) Unwrap() error {
+	return err.Err
+}
+
+// The aggregated link failures, in relocation order.
+type LinkError struct {
+	Errors []*RelocationError
+}
+
+func (err *LinkError) Error() string {
+	if len(err.Errors) == 1 {
+		return err.Errors[0].Error()
+	}
+
+	lines := make([]string, 0, len(err.Errors)+1)
+	lines = append(lines, fmt.Sprintf("found %d link errors:", len(err.Errors)))
+	for _, failure := range err.Errors {
+		lines = append(lines, "  "+failure.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (err *LinkError) Unwrap() []error {
+	errs := make([]error, 0, len(err.Errors))
+	for _, failure := range err.Errors {
+		errs = append(errs, failure)
+	}
+	return errs
+}
+
+func relocate(
+	content Relocatable,
+	relocation *Relocation,
+	symbol *Symbol,
+	relocator Relocator,
+) error {
+	snippet, err := content.Peek(relocation.Offset)
+	if err != nil {
+		return err
+	}
+
+	if len(snippet) >= MaxRelocationSize {
+		return relocator.Relocate(relocation, symbol, snippet)
+	}
+
+	// The relocated value may straddle data chunk boundaries.  Relocate a
+	// contiguous copy, then write the copy back to the chunks.
+	buffer := make([]byte, 0, MaxRelocationSize)
+	for len(snippet) > 0 {
+		numNeeded := MaxRelocationSize - len(buffer)
+		if len(snippet) > numNeeded {
+			snippet = snippet[:numNeeded]
+		}
+
+		buffer = append(buffer, snippet...)
+		if len(buffer) == MaxRelocationSize {
+			break
+		}
+
+		snippet, err = content.Peek(relocation.Offset + int64(len(buffer)))
+		if err != nil { // end of content
+			break
+		}
+	}
+
+	err = relocator.Relocate(relocation, symbol, buffer)
+	if err != nil {
+		return err
+	}
+
+	written := 0
+	for written < len(buffer) {
+		snippet, err := content.Peek(relocation.Offset + int64(written))
+		if err != nil {
+			panic("should never happen")
+		}
+		written += copy(snippet, buffer[written:])
+	}
+
+	return nil
+}
+
 func link(
 	content Relocatable,
 	symbols map[string]*Symbol,
+	sectionStarts map[Section]int64,
 	relocations []*Relocation,
 	relocator Relocator,
 ) (
 	[]*Relocation,
-	error,
+	[]*RelocationError,
 ) {
 	if len(relocations) == 0 {
 		return nil, nil
@@ -216,47 +483,82 @@ func link(
 	}
 
 	unresolved := make([]*Relocation, 0, len(relocations))
+	var failures []*RelocationError
 	for _, reloc := range relocations {
 		symbol, ok := symbols[reloc.Name]
-		if ok {
-			snippet, err := content.Peek(reloc.Offset)
-			if err != nil {
-				return nil, err
-			}
-			relocator.Relocate(symbol, reloc.Offset, snippet)
-		} else {
+		if ok && reloc.Kind == SectionOffsetRelocation {
+			var start int64
+			start, ok = sectionStarts[symbol.Section]
+
+			sectionRelative := *symbol
+			sectionRelative.Offset -= start
+			symbol = &sectionRelative
+		}
+
+		if !ok {
 			unresolved = append(unresolved, reloc)
+			continue
+		}
+
+		err := relocate(content, reloc, symbol, relocator)
+		if err != nil {
+			failures = append(failures, newRelocationError(content, reloc, err))
 		}
 	}
 
 	if len(unresolved) == 0 {
-		return nil, nil
+		return nil, failures
 	}
-	return unresolved, nil
+	return unresolved, failures
 }
 
+// Resolve the content's relocations.  Unresolved relocations are kept in the
+// content.  The returned error (if any) is a *LinkError, which lists all
+// failed relocations.
+//
+// sectionStarts is only used by SectionOffsetRelocation, and may be nil when
+// the sections' layout is not final.
 func Link(
 	content Relocatable,
 	labels map[string]*Symbol,
 	symbols map[string]*Symbol,
+	sectionStarts map[Section]int64,
 	relocator Relocator,
 ) error {
+	failures := linkAll(content, labels, symbols, sectionStarts, relocator)
+	if len(failures) > 0 {
+		return &LinkError{Errors: failures}
+	}
+	return nil
+}
+
+func linkAll(
+	content Relocatable,
+	labels map[string]*Symbol,
+	symbols map[string]*Symbol,
+	sectionStarts map[Section]int64,
+	relocator Relocator,
+) []*RelocationError {
 	relocations := content.Relocs()
 
 	unbinded := Relocations{}
 
-	unresolved, err := link(content, labels, relocations.Labels, relocator)
-	if err != nil {
-		return err
-	}
+	unresolved, failures := link(
+		content,
+		labels,
+		nil,
+		relocations.Labels,
+		relocator)
 	unbinded.Labels = unresolved
 
-	unresolved, err = link(content, symbols, relocations.Symbols, relocator)
-	if err != nil {
-		return err
-	}
+	unresolved, symbolFailures := link(
+		content,
+		symbols,
+		sectionStarts,
+		relocations.Symbols,
+		relocator)
 	unbinded.Symbols = unresolved
 
 	content.SetRelocations(unbinded)
-	return nil
+	return append(failures, symbolFailures...)
 }
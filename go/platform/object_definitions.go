@@ -0,0 +1,154 @@
+package platform
+
+import (
+	"fmt"
+
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+// Appends the compilation unit's global constants to .rodata, and global
+// variables to .data (or to .bss when the variable has neither content nor
+// embedded addresses).  Each object is aligned to its type's alignment.
+//
+// The variables' embedded addresses are emitted as absolute relocations,
+// which are resolved (or rebased) when the executable image is created.
+func AppendObjectDefinitions(
+	config layout.Config,
+	builder *layout.ObjectFileBuilder,
+	unit *ir.CompilationUnit,
+) error {
+	for _, def := range unit.ConstantDefinitions {
+		if len(def.EmbeddedAddresses) > 0 {
+			return fmt.Errorf(
+				"invalid constant definition (%s). embedded addresses are only "+
+					"supported by variable definitions",
+				def.Name)
+		}
+
+		content, err := objectContent(def)
+		if err != nil {
+			return err
+		}
+
+		err = builder.ReadOnlyData.Pad(
+			int64(ir.TypeAlignment(def.Type)),
+			config.DataPadding)
+		if err != nil {
+			return err
+		}
+
+		symbol := builder.ReadOnlyData.AppendObject(
+			layout.ReadOnlyDataSection,
+			def.Name,
+			content,
+			nil)
+		setObjectLinkage(symbol, def)
+	}
+
+	for _, def := range unit.VariableDefinitions {
+		content, err := objectContent(def)
+		if err != nil {
+			return err
+		}
+
+		alignment := int64(ir.TypeAlignment(def.Type))
+
+		if def.Content == nil && len(def.EmbeddedAddresses) == 0 {
+			builder.BSS.Pad(alignment)
+			symbol := builder.BSS.AppendObject(def.Name, int64(len(content)))
+			setObjectLinkage(symbol, def)
+			continue
+		}
+
+		embeddedAddresses, err := embeddedAddressRelocations(def, len(content))
+		if err != nil {
+			return err
+		}
+
+		err = builder.Data.Pad(alignment, config.DataPadding)
+		if err != nil {
+			return err
+		}
+
+		symbol := builder.Data.AppendObject(
+			layout.ReadWriteDataSection,
+			def.Name,
+			content,
+			embeddedAddresses)
+		setObjectLinkage(symbol, def)
+	}
+
+	return nil
+}
+
+func objectContent(def *ir.ObjectDefinition) ([]byte, error) {
+	if _, ok := def.Type.(*ir.FunctionType); ok {
+		return nil, fmt.Errorf(
+			"invalid object definition (%s). function type is not allowed",
+			def.Name)
+	}
+
+	size := def.Type.Size()
+	if def.Content == nil {
+		return make([]byte, size), nil
+	}
+
+	if len(def.Content) != size {
+		return nil, fmt.Errorf(
+			"invalid object definition (%s). content size (%d) does not match "+
+				"type size (%d)",
+			def.Name,
+			len(def.Content),
+			size)
+	}
+
+	return def.Content, nil
+}
+
+func embeddedAddressRelocations(
+	def *ir.ObjectDefinition,
+	size int,
+) (
+	[]*layout.Relocation,
+	error,
+) {
+	addressSize := ir.NewAddressType(ir.Uint8).Size()
+
+	relocations := make([]*layout.Relocation, 0, len(def.EmbeddedAddresses))
+	for _, address := range def.EmbeddedAddresses {
+		if address.Offset < 0 || address.Offset+addressSize > size {
+			return nil, fmt.Errorf(
+				"invalid object definition (%s). embedded address (%s) at "+
+					"offset %d out of bound",
+				def.Name,
+				address.Name,
+				address.Offset)
+		}
+
+		relocations = append(
+			relocations,
+			&layout.Relocation{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   address.Name,
+				Offset: int64(address.Offset),
+				Addend: address.Addend,
+			})
+	}
+
+	return relocations, nil
+}
+
+func setObjectLinkage(symbol *layout.Symbol, def *ir.ObjectDefinition) {
+	switch def.Linkage {
+	case ir.GlobalLinkage:
+	case ir.LocalLinkage:
+		symbol.Binding = layout.LocalBinding
+	case ir.WeakLinkage:
+		symbol.Binding = layout.WeakBinding
+	case ir.HiddenLinkage:
+		symbol.Visibility = layout.HiddenVisibility
+	default:
+		panic("unsupported linkage: " + def.Linkage)
+	}
+}
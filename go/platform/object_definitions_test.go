@@ -0,0 +1,167 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/pattyshack/gt/testing/expect"
+
+	amd64 "github.com/pattyshack/chickadee/amd64/layout"
+	"github.com/pattyshack/chickadee/ir"
+	"github.com/pattyshack/chickadee/platform/layout"
+)
+
+func TestAppendObjectDefinitions(t *testing.T) {
+	unit := &ir.CompilationUnit{
+		ConstantDefinitions: []*ir.ObjectDefinition{
+			{
+				Name:    "flag",
+				Type:    ir.Uint8,
+				Content: []byte{1},
+			},
+			{
+				Name:    "answer",
+				Type:    ir.Int32,
+				Linkage: ir.LocalLinkage,
+				Content: []byte{42, 0, 0, 0},
+			},
+		},
+		VariableDefinitions: []*ir.ObjectDefinition{
+			{
+				Name:    "counter",
+				Type:    ir.Int64,
+				Linkage: ir.WeakLinkage,
+			},
+			{
+				Name:    "callbacks",
+				Type:    ir.NewArrayType(ir.NewAddressType(ir.Int8), 2),
+				Linkage: ir.HiddenLinkage,
+				EmbeddedAddresses: []ir.EmbeddedAddress{
+					{Offset: 8, Name: "callback"},
+					{Offset: 0, Name: "answer", Addend: 2},
+				},
+			},
+		},
+	}
+
+	builder := layout.NewObjectFileBuilder()
+	err := AppendObjectDefinitions(amd64.LinuxLayout, &builder, unit)
+	expect.Nil(t, err)
+
+	file, err := builder.Finalize(amd64.LinuxLayout)
+	expect.Nil(t, err)
+
+	expect.Equal(
+		t,
+		[]byte{1, 0, 0, 0, 42, 0, 0, 0},
+		file.ReadOnlyData.Flatten())
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.ReadOnlyDataSection,
+				Name:    "flag",
+				Size:    1,
+			},
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.ReadOnlyDataSection,
+				Name:    "answer",
+				Offset:  4,
+				Size:    4,
+				Binding: layout.LocalBinding,
+			},
+		},
+		file.ReadOnlyData.Definitions.Symbols)
+
+	// Variables with embedded addresses are placed in .data.
+	expect.Equal(t, make([]byte, 16), file.Data.Flatten())
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:       layout.ObjectKind,
+				Section:    layout.ReadWriteDataSection,
+				Name:       "callbacks",
+				Size:       16,
+				Visibility: layout.HiddenVisibility,
+			},
+		},
+		file.Data.Definitions.Symbols)
+	expect.Equal(
+		t,
+		[]*layout.Relocation{
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "callback",
+				Offset: 8,
+			},
+			{
+				Kind:   layout.AbsoluteRelocation,
+				Name:   "answer",
+				Addend: 2,
+			},
+		},
+		file.Data.Relocations.Symbols)
+
+	expect.Equal(t, 8, file.BSS.Size)
+	expect.Equal(
+		t,
+		[]*layout.Symbol{
+			{
+				Kind:    layout.ObjectKind,
+				Section: layout.BSSSection,
+				Name:    "counter",
+				Size:    8,
+				Binding: layout.WeakBinding,
+			},
+		},
+		file.BSS.Definitions.Symbols)
+}
+
+func TestAppendInvalidObjectDefinitions(t *testing.T) {
+	appendObjects := func(unit *ir.CompilationUnit) error {
+		builder := layout.NewObjectFileBuilder()
+		return AppendObjectDefinitions(amd64.LinuxLayout, &builder, unit)
+	}
+
+	err := appendObjects(
+		&ir.CompilationUnit{
+			ConstantDefinitions: []*ir.ObjectDefinition{
+				{
+					Name:    "answer",
+					Type:    ir.Int32,
+					Content: []byte{42},
+				},
+			},
+		})
+	expect.Error(t, err, "content size (1) does not match type size (4)")
+
+	err = appendObjects(
+		&ir.CompilationUnit{
+			ConstantDefinitions: []*ir.ObjectDefinition{
+				{
+					Name: "callback",
+					Type: ir.NewAddressType(ir.Int8),
+					EmbeddedAddresses: []ir.EmbeddedAddress{
+						{Name: "foo"},
+					},
+				},
+			},
+		})
+	expect.Error(t, err, "only supported by variable definitions")
+
+	err = appendObjects(
+		&ir.CompilationUnit{
+			VariableDefinitions: []*ir.ObjectDefinition{
+				{
+					Name: "callbacks",
+					Type: ir.NewArrayType(ir.Int32, 2),
+					EmbeddedAddresses: []ir.EmbeddedAddress{
+						{Offset: 8, Name: "foo"},
+					},
+				},
+			},
+		})
+	expect.Error(t, err, "embedded address (foo) at offset 8 out of bound")
+}